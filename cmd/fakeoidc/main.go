@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"me-ai/pkg/fakeoidc"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":9999", "адрес для прослушивания")
+	issuer := flag.String("issuer", "", "issuer; по умолчанию http://<host из запроса>")
+	clientID := flag.String("client-id", "me-ai", "client_id приложения")
+	secret := flag.String("client-secret", "", "client_secret; пустой — публичный клиент")
+	subject := flag.String("sub", "fake-user", "sub пользователя, под которым проходит вход")
+	email := flag.String("email", "user@example.com", "email пользователя")
+	verified := flag.Bool("email-verified", true, "подтверждён ли email у провайдера")
+	name := flag.String("name", "Fake User", "имя пользователя")
+	flag.Parse()
+
+	server := fakeoidc.New(*clientID, *secret)
+	server.Issuer = *issuer
+	server.SetUser(fakeoidc.User{
+		Subject:       *subject,
+		Email:         *email,
+		EmailVerified: *verified,
+		Name:          *name,
+	})
+
+	fmt.Printf("Fake OIDC provider is listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"me-ai/pkg/fakeollama"
+	"net/http"
+	"os"
+	"strings"
+)
+
+func main() {
+	addr := flag.String("addr", ":11434", "адрес для прослушивания")
+	script := flag.String("script", "", "JSONL-файл с заскриптованными ответами (fakeollama.Reply на строку)")
+	latency := flag.Duration("latency", 0, "задержка перед каждым ответом")
+	models := flag.String("models", fakeollama.DefaultModel, "список моделей для /api/tags через запятую")
+	dim := flag.Int("dim", fakeollama.DefaultEmbeddingDim, "размерность эмбеддингов")
+	flag.Parse()
+
+	server := fakeollama.New()
+	server.Latency = *latency
+	server.EmbeddingDim = *dim
+	server.Models = strings.Split(*models, ",")
+
+	if *script != "" {
+		replies, err := loadScript(*script)
+		if err != nil {
+			log.Fatalf("Cannot load script %s: %v", *script, err)
+		}
+		server.Enqueue(replies...)
+		log.Printf("Loaded %d scripted replies", len(replies))
+	}
+
+	fmt.Printf("Fake Ollama is listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
+
+func loadScript(path string) ([]fakeollama.Reply, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var replies []fakeollama.Reply
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var reply fakeollama.Reply
+		if err := json.Unmarshal([]byte(text), &reply); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		replies = append(replies, reply)
+	}
+	return replies, scanner.Err()
+}
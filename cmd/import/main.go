@@ -0,0 +1,73 @@
+// Команда import загружает выгрузку чатов в аккаунт пользователя напрямую в базу:
+//
+//	go run ./cmd/import -user alice@example.com conversations.json
+//
+// Повторный запуск с тем же файлом пропускает уже импортированные беседы.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"me-ai/configs"
+	"me-ai/internal/importer"
+	"me-ai/internal/models"
+	"me-ai/pkg/db"
+	"os"
+	"strings"
+)
+
+func main() {
+	email := flag.String("user", "", "email пользователя, которому добавить беседы")
+	format := flag.String("format", "", "формат файла: "+strings.Join(importer.Formats, ", ")+" (по умолчанию — определить)")
+	asJSON := flag.Bool("json", false, "печатать отчёт в JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -user EMAIL [-format FORMAT] FILE...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *email == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	configs.LoadConfig()
+	if err := db.Init(); err != nil {
+		log.Fatalf("DB init error: %v", err)
+	}
+	user, err := (&models.UserRepository{}).FindByEmail(*email)
+	if err != nil {
+		log.Fatalf("User %s not found: %v", *email, err)
+	}
+	service := importer.NewService(&models.ConversationRepository{})
+
+	failed := false
+	for _, path := range flag.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Cannot read %s: %v", path, err)
+		}
+		report, err := service.Import(user.ID, *format, data)
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		if report.Failed > 0 {
+			failed = true
+		}
+		if *asJSON {
+			json.NewEncoder(os.Stdout).Encode(report)
+			continue
+		}
+		fmt.Printf("%s (%s): imported %d, duplicates %d, failed %d\n",
+			path, report.Format, report.Imported, report.Duplicates, report.Failed)
+		for _, c := range report.Conversations {
+			if c.Status == importer.StatusFailed {
+				fmt.Printf("  #%d %q: %s\n", c.Index, c.Title, c.Error)
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -3,10 +3,10 @@ package main
 import (
 	"fmt"
 	"me-ai/configs"
-	"me-ai/internal/llm"
-
 	"me-ai/internal/auth"
-	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/internal/server"
+
 	"me-ai/pkg/db"
 	"net/http"
 )
@@ -17,33 +17,43 @@ func main() {
 		panic(err)
 	}
 
-	llmService := llm.NewLLMService(cfg.LLM.URL, cfg.LLM.ApiKey)
-	chatHandler := llm.NewChatHandler(llmService)
-	wsHandler := llm.NewWebSocketHandler(llmService)
-
-	router := http.NewServeMux()
-
-	auth.NewAuthHandler(router, auth.AuthHandlerDeps{Config: cfg})
-
-	jwtMw := middleware.JWTAuth(cfg.Auth.Secret)
-	corsMw := middleware.CORS
-
-	protected := http.NewServeMux()
-	protected.HandleFunc("/api/chat", chatHandler.HandleChat)
-	protected.HandleFunc("/api/ws", wsHandler.HandleWebSocket)
-	protected.HandleFunc("/api/conversations", chatHandler.ListConversations)         // GET
-	protected.HandleFunc("/api/conversations/create", chatHandler.CreateConversation) // POST
-	protected.HandleFunc("/api/conversations/delete", chatHandler.DeleteConversation) // POST
-	protected.HandleFunc("/api/conversations/rename", chatHandler.RenameConversation) // POST
-	protected.HandleFunc("/api/messages", chatHandler.ListMessages)                   // GET
-	protected.HandleFunc("/api/messages/delete", chatHandler.DeleteMessage)           // POST
+	tokens, err := auth.NewJWT(cfg.Auth)
+	if err != nil {
+		panic(err)
+	}
 
-	router.Handle("/api/", corsMw(jwtMw(protected)))
+	mail, err := auth.NewMailer(cfg.Mail)
+	if err != nil {
+		panic(err)
+	}
 
-	server := &http.Server{
+	router := server.NewRouter(server.Deps{
+		Config:                 cfg,
+		JWT:                    tokens,
+		UserRepository:         &models.UserRepository{},
+		ConversationRepository: &models.ConversationRepository{},
+		MessageRepository:      &models.MessageRepository{},
+		RefreshTokenRepository: &models.RefreshTokenRepository{},
+		APIKeyRepository:       &models.APIKeyRepository{},
+		UsageRepository:        &models.UsageRepository{},
+		AuditLogRepository:     &models.AuditLogRepository{},
+		UserTokenRepository:    &models.UserTokenRepository{},
+		LoginLockoutRepository: &models.LoginLockoutRepository{},
+		RecoveryCodeRepository: &models.RecoveryCodeRepository{},
+		IdentityRepository:     &models.IdentityRepository{},
+		SearchRepository:       &models.SearchRepository{},
+		ExportJobRepository:    &models.ExportJobRepository{},
+		EmbeddingRepository:    &models.EmbeddingRepository{},
+		FolderRepository:       &models.FolderRepository{},
+		TagRepository:          &models.TagRepository{},
+		ShareRepository:        &models.ShareRepository{},
+		Mailer:                 mail,
+	})
+
+	srv := &http.Server{
 		Addr:    ":8081",
 		Handler: router,
 	}
 	fmt.Println("Server is listening on port 8081")
-	server.ListenAndServe()
+	srv.ListenAndServe()
 }
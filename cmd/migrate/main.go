@@ -0,0 +1,106 @@
+// Команда migrate управляет схемой базы из DATABASE_URL (PostgreSQL или SQLite):
+//
+//	go run ./cmd/migrate status    # какие миграции применены, какие ждут, какие изменены
+//	go run ./cmd/migrate up [N]    # применить N новых миграций, без N — все
+//	go run ./cmd/migrate down [N]  # откатить N последних, без N — одну
+//	go run ./cmd/migrate redo      # откатить и заново применить последнюю
+//
+// SQL встроен в бинарник, запускать можно из любого каталога.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"me-ai/configs"
+	"me-ai/migrations"
+	"me-ai/pkg/db"
+	"me-ai/pkg/migrate"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s status | up [N] | down [N] | redo\n", os.Args[0])
+	}
+	flag.Parse()
+	cmd, n := flag.Arg(0), 0
+	switch {
+	case (cmd == "status" || cmd == "redo") && flag.NArg() == 1:
+	case (cmd == "up" || cmd == "down") && flag.NArg() <= 2:
+		if flag.NArg() == 2 {
+			var err error
+			if n, err = strconv.Atoi(flag.Arg(1)); err != nil || n < 1 {
+				flag.Usage()
+				os.Exit(2)
+			}
+		} else if cmd == "down" {
+			n = 1
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	configs.LoadConfig()
+	if err := db.Init(); err != nil {
+		log.Fatalf("DB init error: %v", err)
+	}
+	fsys, err := migrations.For(db.Dialect())
+	if err != nil {
+		log.Fatal(err)
+	}
+	runner, err := migrate.New(db.DB, fsys)
+	if err != nil {
+		log.Fatalf("Invalid migrations: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var done []migrate.Migration
+	switch cmd {
+	case "status":
+		err = printStatus(ctx, runner)
+	case "up":
+		done, err = runner.Up(ctx, n)
+	case "down":
+		done, err = runner.Down(ctx, n)
+	case "redo":
+		done, err = runner.Redo(ctx)
+	}
+	for _, m := range done {
+		fmt.Printf("%s: %s\n", cmd, m)
+	}
+	if errors.Is(err, migrate.ErrModified) {
+		log.Fatalf("%v (run status to see all changed migrations)", err)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+	if cmd != "status" && len(done) == 0 {
+		fmt.Println("Nothing to do")
+	}
+}
+
+func printStatus(ctx context.Context, runner *migrate.Runner) error {
+	statuses, err := runner.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range statuses {
+		applied := "-"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, applied)
+	}
+	return w.Flush()
+}
@@ -3,14 +3,26 @@ package configs
 import (
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Db   DbConfig
-	LLM  LLMConfig
-	Auth AuthConfig
+	Db     DbConfig
+	LLM    LLMConfig
+	Auth   AuthConfig
+	Mail   MailConfig
+	OIDC   OIDCConfig
+	Export ExportConfig
+	Import ImportConfig
+	// Embeddings — семантический поиск по истории.
+	Embeddings EmbeddingsConfig
+	Trash      TrashConfig
+	Share      ShareConfig
 }
 
 type DbConfig struct {
@@ -23,7 +35,103 @@ type LLMConfig struct {
 }
 
 type AuthConfig struct {
-	Secret string
+	Secret     string
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// Algorithm — алгоритм подписи: HS256 (по умолчанию, секрет TOKEN), RS256 или EdDSA.
+	Algorithm      string
+	KeyID          string
+	PrivateKeyFile string
+	// VerifyKeyFiles — дополнительные публичные ключи kid -> путь для ротации.
+	VerifyKeyFiles map[string]string
+	// Algorithms — явный allowlist алгоритмов; пустой — алгоритмы загруженных ключей.
+	Algorithms []string
+
+	// EmailVerificationRequired — без подтверждённого email вход запрещён.
+	EmailVerificationRequired bool
+	EmailVerificationTTL      time.Duration
+	PasswordResetTTL          time.Duration
+
+	// Защита от перебора паролей; 0 в порогах отключает соответствующую блокировку.
+	LoginMaxAttempts   int
+	LoginMaxIPAttempts int
+	LoginBaseDelay     time.Duration
+	LoginLockout       time.Duration
+	// TrustProxyHeaders — брать IP клиента из X-Forwarded-For (только за своим прокси).
+	TrustProxyHeaders bool
+}
+
+type MailConfig struct {
+	// Driver — smtp, file или log (по умолчанию: письма печатаются в stdout).
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	File         string
+	// AppURL — адрес фронтенда, на который ведут ссылки из писем.
+	AppURL string
+}
+
+type OIDCConfig struct {
+	// RedirectURL — адрес колбэка бэкенда, зарегистрированный у всех провайдеров.
+	RedirectURL string
+	Providers   []OIDCProviderConfig
+}
+
+type ExportConfig struct {
+	// Dir — каталог для готовых архивов фоновой выгрузки.
+	Dir string
+	// TTL — сколько живёт ссылка на скачивание и сам архив.
+	TTL time.Duration
+	// SyncMaxMessages — до стольких сообщений архив отдаётся сразу в ответе,
+	// больше — собирается в фоне.
+	SyncMaxMessages int
+	// TemplatesDir — каталог с conversation.md.tmpl и conversation.html.tmpl,
+	// заменяющими встроенные шаблоны выгрузки беседы; пустой — только встроенные.
+	TemplatesDir string
+}
+
+type EmbeddingsConfig struct {
+	// Model — модель эмбеддингов у LLM-провайдера; пустая отключает семантический поиск.
+	Model string
+	// BatchSize — сколько сообщений отправляется за один запрос к провайдеру.
+	BatchSize int
+	// Interval — как часто фоновый индексатор ищет новые сообщения; 0 — только по запросу backfill.
+	Interval time.Duration
+}
+
+type TrashConfig struct {
+	// Retention — сколько удалённые беседы и сообщения хранятся в корзине.
+	Retention time.Duration
+	// PurgeInterval — как часто фоновая очистка удаляет просроченное; 0 отключает её.
+	PurgeInterval time.Duration
+}
+
+// ShareConfig — защита паролей публичных ссылок от перебора; 0 в порогах
+// отключает соответствующую блокировку.
+type ShareConfig struct {
+	// PasswordMaxAttempts — неверных паролей к ссылке с одного IP до блокировки.
+	PasswordMaxAttempts int
+	// PasswordMaxLinkAttempts — неверных паролей к ссылке со всех IP до её блокировки.
+	PasswordMaxLinkAttempts int
+	// PasswordLockout — длительность блокировки и окно подсчёта неудач.
+	PasswordLockout time.Duration
+}
+
+type ImportConfig struct {
+	// MaxBytes — предельный размер загружаемого файла выгрузки.
+	MaxBytes int
+}
+
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
 }
 
 func LoadConfig() *Config {
@@ -42,8 +150,163 @@ func LoadConfig() *Config {
 		},
 
 		Auth: AuthConfig{
-			Secret: os.Getenv("TOKEN"),
+			Secret:     os.Getenv("TOKEN"),
+			Issuer:     getEnv("JWT_ISSUER", "me-ai"),
+			AccessTTL:  getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTTL: getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+			Algorithm:      getEnv("JWT_ALG", "HS256"),
+			KeyID:          getEnv("JWT_KEY_ID", "default"),
+			PrivateKeyFile: os.Getenv("JWT_PRIVATE_KEY_FILE"),
+			VerifyKeyFiles: getMap("JWT_VERIFY_KEYS"),
+			Algorithms:     getList("JWT_ALGORITHMS"),
+
+			EmailVerificationRequired: getBool("EMAIL_VERIFICATION_REQUIRED", true),
+			EmailVerificationTTL:      getDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			PasswordResetTTL:          getDuration("PASSWORD_RESET_TTL", time.Hour),
+
+			LoginMaxAttempts:   getInt("LOGIN_MAX_ATTEMPTS", 10),
+			LoginMaxIPAttempts: getInt("LOGIN_MAX_IP_ATTEMPTS", 100),
+			LoginBaseDelay:     getDuration("LOGIN_BASE_DELAY", time.Second),
+			LoginLockout:       getDuration("LOGIN_LOCKOUT", 15*time.Minute),
+			TrustProxyHeaders:  getBool("TRUST_PROXY_HEADERS", false),
+		},
+
+		Mail: MailConfig{
+			Driver:       getEnv("MAILER", "log"),
+			From:         getEnv("MAIL_FROM", "me-ai <no-reply@localhost>"),
+			SMTPHost:     os.Getenv("SMTP_HOST"),
+			SMTPPort:     getInt("SMTP_PORT", 587),
+			SMTPUsername: os.Getenv("SMTP_USERNAME"),
+			SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+			File:         getEnv("MAIL_FILE", "mail.log"),
+			AppURL:       strings.TrimRight(getEnv("APP_URL", "http://localhost:5173"), "/"),
+		},
+
+		OIDC: OIDCConfig{
+			RedirectURL: getEnv("OIDC_REDIRECT_URL", "http://localhost:8081/api/auth/oidc/callback"),
+			Providers:   getOIDCProviders(),
+		},
+
+		Export: ExportConfig{
+			Dir:             getEnv("EXPORT_DIR", filepath.Join(os.TempDir(), "me-ai-exports")),
+			TTL:             getDuration("EXPORT_TTL", 24*time.Hour),
+			SyncMaxMessages: getInt("EXPORT_SYNC_MAX_MESSAGES", 5000),
+			TemplatesDir:    os.Getenv("EXPORT_TEMPLATES_DIR"),
+		},
+
+		Import: ImportConfig{
+			MaxBytes: getInt("IMPORT_MAX_BYTES", 100<<20),
+		},
+
+		Embeddings: EmbeddingsConfig{
+			Model:     getEnv("EMBEDDING_MODEL", "nomic-embed-text"),
+			BatchSize: getInt("EMBEDDING_BATCH_SIZE", 32),
+			Interval:  getDuration("EMBEDDING_INTERVAL", 10*time.Second),
 		},
+
+		Trash: TrashConfig{
+			Retention:     getDuration("TRASH_RETENTION", 30*24*time.Hour),
+			PurgeInterval: getDuration("TRASH_PURGE_INTERVAL", time.Hour),
+		},
+
+		Share: ShareConfig{
+			PasswordMaxAttempts:     getInt("SHARE_PASSWORD_MAX_ATTEMPTS", 10),
+			PasswordMaxLinkAttempts: getInt("SHARE_PASSWORD_MAX_LINK_ATTEMPTS", 50),
+			PasswordLockout:         getDuration("SHARE_PASSWORD_LOCKOUT", 15*time.Minute),
+		},
+	}
+
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %s", key, v, fallback)
+		return fallback
+	}
+	return d
+}
 
+func getBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %t", key, v, fallback)
+		return fallback
+	}
+	return b
+}
+
+func getInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %d", key, v, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getOIDCProviders читает провайдеров из OIDC_PROVIDERS=corp,google и переменных
+// OIDC_<ИМЯ>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _SCOPES.
+func getOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range getList("OIDC_PROVIDERS") {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		p := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       getList(prefix + "SCOPES"),
+		}
+		if p.Issuer == "" || p.ClientID == "" {
+			log.Printf("OIDC provider %q skipped: %sISSUER and %sCLIENT_ID are required", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+// getMap разбирает значения вида "a=1,b=2".
+func getMap(key string) map[string]string {
+	m := map[string]string{}
+	for _, item := range getList(key) {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok {
+			log.Printf("Invalid %s entry %q, expected key=value", key, item)
+			continue
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m
 }
@@ -5,6 +5,15 @@ import LoginPage from './pages/LoginPage.tsx';
 import RegisterPage from './pages/RegisterPage.tsx';
 import ChatListPage from './pages/ChatListPage.tsx';
 import ChatPage from './pages/ChatPage.tsx';
+import VerifyEmailPage from './pages/VerifyEmailPage.tsx';
+import ForgotPasswordPage from './pages/ForgotPasswordPage.tsx';
+import ResetPasswordPage from './pages/ResetPasswordPage.tsx';
+import OIDCCallbackPage from './pages/OIDCCallbackPage.tsx';
+import ConfirmEmailPage from './pages/ConfirmEmailPage.tsx';
+import AccountPage from './pages/AccountPage.tsx';
+import SearchPage from './pages/SearchPage.tsx';
+import TrashPage from './pages/TrashPage.tsx';
+import SharedPage from './pages/SharedPage.tsx';
 import TopBar from './components/TopBar';
 import ChatLayout from './components/ChatLayout';
 import { useAuth } from './context/AuthContext';
@@ -24,6 +33,15 @@ function App() {
           <Routes>
             <Route path="/login" element={<LoginPage />} />
             <Route path="/register" element={<RegisterPage />} />
+            <Route path="/verify-email" element={<VerifyEmailPage />} />
+            <Route path="/forgot-password" element={<ForgotPasswordPage />} />
+            <Route path="/reset-password" element={<ResetPasswordPage />} />
+            <Route path="/oidc/callback" element={<OIDCCallbackPage />} />
+            <Route path="/confirm-email" element={<ConfirmEmailPage />} />
+            <Route path="/shared" element={<SharedPage />} />
+            <Route path="/account" element={<PrivateRoute><TopBar /><AccountPage /></PrivateRoute>} />
+            <Route path="/search" element={<PrivateRoute><TopBar /><SearchPage /></PrivateRoute>} />
+            <Route path="/trash" element={<PrivateRoute><TopBar /><TrashPage /></PrivateRoute>} />
             <Route path="/chats" element={<PrivateRoute><TopBar /><ChatLayout><ChatListPage /></ChatLayout></PrivateRoute>} />
             <Route path="/chat/:id" element={<PrivateRoute><TopBar /><ChatLayout><ChatPage /></ChatLayout></PrivateRoute>} />
             <Route path="*" element={<Navigate to="/login" replace />} />
@@ -0,0 +1,69 @@
+import axios from 'axios';
+
+const API_URL = '/api';
+
+export interface Profile {
+  id: number;
+  email: string;
+  name: string;
+  role: string;
+  email_verified: boolean;
+  pending_email?: string;
+  two_factor_enabled: boolean;
+  created_at: string;
+}
+
+export interface Session {
+  id: string;
+  user_agent: string;
+  ip: string;
+  created_at: string;
+  last_used_at: string;
+  current: boolean;
+}
+
+export async function getProfile() {
+  const res = await axios.get(`${API_URL}/account`);
+  return res.data as Profile;
+}
+
+// updateProfile меняет имя сразу, а email — после перехода по ссылке из письма на новый адрес.
+export async function updateProfile(changes: { name?: string; email?: string }) {
+  const res = await axios.post(`${API_URL}/account/update`, changes);
+  return res.data as Profile;
+}
+
+export async function confirmEmailChange(token: string) {
+  await axios.post(`${API_URL}/auth/email/confirm`, { token });
+}
+
+export async function changePassword(currentPassword: string, newPassword: string) {
+  await axios.post(`${API_URL}/account/password`, { current_password: currentPassword, new_password: newPassword });
+}
+
+export async function getSessions() {
+  const res = await axios.get(`${API_URL}/account/sessions`);
+  return res.data as Session[];
+}
+
+export async function revokeSession(id: string) {
+  await axios.post(`${API_URL}/account/sessions/revoke`, { id });
+}
+
+export async function revokeOtherSessions() {
+  await axios.post(`${API_URL}/account/sessions/revoke-others`);
+}
+
+export async function downloadExport() {
+  const res = await axios.get(`${API_URL}/account/export`, { responseType: 'blob' });
+  const url = URL.createObjectURL(res.data);
+  const link = document.createElement('a');
+  link.href = url;
+  link.download = 'me-ai-export.json';
+  link.click();
+  URL.revokeObjectURL(url);
+}
+
+export async function deleteAccount(password: string, code?: string) {
+  await axios.post(`${API_URL}/account/delete`, { password, code });
+}
@@ -1,13 +1,91 @@
 import axios from 'axios';
 
 const API_URL = '/api';
+const REFRESH_TOKEN_KEY = 'refresh_token';
 
-export async function login(email: string, password: string) {
+interface TokenResponse {
+  token: string;
+  refresh_token: string;
+}
+
+function storeSession(data: TokenResponse) {
+  localStorage.setItem(REFRESH_TOKEN_KEY, data.refresh_token);
+  return data.token;
+}
+
+export type LoginResult = { token: string } | { challenge: string };
+
+// login возвращает challenge, если у пользователя включена 2FA: сессию выдаст verifyTwoFactor.
+export async function login(email: string, password: string): Promise<LoginResult> {
   const res = await axios.post(`${API_URL}/auth/login`, { email, password });
-  return res.data.token as string;
+  if (res.data.two_factor_required) return { challenge: res.data.challenge_token };
+  return { token: storeSession(res.data) };
+}
+
+export async function verifyTwoFactor(challenge: string, code: string) {
+  const res = await axios.post(`${API_URL}/auth/2fa/verify`, { challenge_token: challenge, code });
+  return storeSession(res.data);
 }
 
-export async function register(email: string, name: string, password: string) {
+// register возвращает null, если перед входом нужно подтвердить email.
+export async function register(email: string, name: string, password: string): Promise<string | null> {
   const res = await axios.post(`${API_URL}/auth/register`, { email, name, password });
-  return res.data.token as string;
-} 
\ No newline at end of file
+  if (res.data.verification_required) return null;
+  return storeSession(res.data);
+}
+
+export async function verifyEmail(token: string) {
+  await axios.post(`${API_URL}/auth/verify`, { token });
+}
+
+export async function resendVerification(email: string) {
+  await axios.post(`${API_URL}/auth/verify/resend`, { email });
+}
+
+export async function forgotPassword(email: string) {
+  await axios.post(`${API_URL}/auth/password/forgot`, { email });
+}
+
+export async function resetPassword(token: string, password: string) {
+  await axios.post(`${API_URL}/auth/password/reset`, { token, password });
+}
+
+export async function getOIDCProviders(): Promise<string[]> {
+  const res = await axios.get(`${API_URL}/auth/oidc/providers`);
+  return res.data.providers || [];
+}
+
+export function oidcLoginURL(provider: string) {
+  return `${API_URL}/auth/oidc/login?provider=${encodeURIComponent(provider)}`;
+}
+
+// completeOIDCLogin разбирает фрагмент адреса, с которым бэкенд вернул браузер после SSO.
+export function completeOIDCLogin(hash: string): LoginResult | { error: string } {
+  const params = new URLSearchParams(hash.replace(/^#/, ''));
+  const challenge = params.get('challenge_token');
+  if (challenge) return { challenge };
+  const token = params.get('token');
+  const refreshToken = params.get('refresh_token');
+  if (token && refreshToken) return { token: storeSession({ token, refresh_token: refreshToken }) };
+  return { error: params.get('error') || 'unknown' };
+}
+
+export async function refresh(): Promise<string | null> {
+  const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+  if (!refreshToken) return null;
+  try {
+    const res = await axios.post(`${API_URL}/auth/refresh`, { refresh_token: refreshToken });
+    return storeSession(res.data);
+  } catch {
+    localStorage.removeItem(REFRESH_TOKEN_KEY);
+    return null;
+  }
+}
+
+export async function logout() {
+  const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+  localStorage.removeItem(REFRESH_TOKEN_KEY);
+  if (refreshToken) {
+    await axios.post(`${API_URL}/auth/logout`, { refresh_token: refreshToken }).catch(() => {});
+  }
+}
@@ -2,9 +2,45 @@ import axios from 'axios';
 
 const API_URL = '/api';
 
-export async function getChats() {
-  const res = await axios.get(`${API_URL}/conversations`);
-  return res.data as { id: number; title: string }[];
+// Page — keyset-страница: before_cursor ведёт к более старым записям, after_cursor — к более новым.
+export interface Page {
+  before_cursor?: string;
+  after_cursor?: string;
+  has_before: boolean;
+  has_after: boolean;
+  total?: number;
+}
+
+export interface Chat {
+  id: number;
+  title: string;
+  pinned_at?: string;
+  archived_at?: string;
+  folder_id?: number;
+  tags?: string[];
+}
+
+export interface ChatFilters {
+  archived?: 'true' | 'false' | 'all';
+  pinned?: boolean;
+  folder_id?: number | 'none';
+  tag?: string[];
+}
+
+export async function getChats(before?: string, filters: ChatFilters = {}) {
+  const res = await axios.get(`${API_URL}/conversations`, {
+    params: { before, ...filters },
+    paramsSerializer: { indexes: null },
+  });
+  return res.data as Page & { conversations: Chat[] };
+}
+
+export type BulkAction = 'move' | 'tag' | 'untag' | 'pin' | 'unpin' | 'archive' | 'unarchive' | 'delete';
+
+// bulkChats применяет действие ко всем чатам сразу: при ошибке не меняется ни один.
+export async function bulkChats(action: BulkAction, ids: number[], extra: { folder_id?: number | null; tags?: string[] } = {}) {
+  const res = await axios.post(`${API_URL}/conversations/bulk`, { action, ids, ...extra });
+  return res.data as { action: BulkAction; updated: number };
 }
 
 export async function createChat(title: string) {
@@ -12,14 +48,54 @@ export async function createChat(title: string) {
   return res.data as { id: number; title: string };
 }
 
-export async function getMessages(conversationId: number) {
-  const res = await axios.get(`${API_URL}/messages?conversation_id=${conversationId}`);
-  return res.data as { id: number; content: string; role: string }[];
+export interface Message {
+  id: number;
+  content: string;
+  role: string;
+  edited_at?: string;
+  status?: 'pending' | 'streaming' | 'complete' | 'failed';
+}
+
+// ChatResponse приходит и при ошибке модели (HTTP 500): тогда status — failed, а reply_id годится для retryReply.
+export interface ChatResponse {
+  message: string;
+  timestamp: string;
+  user_message_id?: string;
+  reply_id: string;
+  status: 'complete' | 'failed';
+  error?: string;
 }
 
+// getMessages без before возвращает самые новые сообщения; внутри страницы они идут по времени.
+export async function getMessages(conversationId: number, before?: string) {
+  const res = await axios.get(`${API_URL}/messages`, { params: { conversation_id: conversationId, before } });
+  return res.data as Page & { messages: Message[] };
+}
+
+// editMessage с regenerate убирает в корзину сообщения после правленого и возвращает новый ответ модели в reply.
+export async function editMessage(id: number, content: string, regenerate = false) {
+  const res = await axios.post(`${API_URL}/messages/edit`, { id, content, regenerate });
+  return res.data as { message: Message; reply?: Message };
+}
+
+export async function getRevisions(id: number) {
+  const res = await axios.get(`${API_URL}/messages/revisions`, { params: { id } });
+  return res.data as { id: number; message_id: number; content: string; created_at: string }[];
+}
+
+// sendMessage и retryReply не бросают исключение, если реплика сохранена, а модель не ответила: это видно по status.
 export async function sendMessage(conversationId: number, message: string) {
-  const res = await axios.post(`${API_URL}/chat`, { conversation_id: conversationId, message });
-  return res.data as { message: string; timestamp: string };
+  const res = await axios.post(`${API_URL}/chat`, { conversation_id: conversationId, message }, { validateStatus: chatStatus });
+  return res.data as ChatResponse;
+}
+
+export async function retryReply(replyId: number) {
+  const res = await axios.post(`${API_URL}/chat/retry`, { reply_id: replyId }, { validateStatus: chatStatus });
+  return res.data as ChatResponse;
+}
+
+function chatStatus(status: number) {
+  return status === 200 || status === 500;
 }
 
 export async function deleteChat(id: number) {
@@ -0,0 +1,69 @@
+import axios from 'axios';
+
+const API_URL = '/api';
+
+export interface ExportJob {
+  id: number;
+  status: 'pending' | 'running' | 'done' | 'failed' | 'expired';
+  size: number;
+  error?: string;
+  created_at: string;
+  completed_at?: string;
+  expires_at?: string;
+  download_url?: string;
+}
+
+function saveBlob(data: Blob, filename: string) {
+  const url = URL.createObjectURL(data);
+  const link = document.createElement('a');
+  link.href = url;
+  link.download = filename;
+  link.click();
+  URL.revokeObjectURL(url);
+}
+
+// requestArchive скачивает ZIP сразу или, для большого аккаунта, возвращает фоновое задание.
+export async function requestArchive(): Promise<ExportJob | null> {
+  const res = await axios.get(`${API_URL}/export`, { responseType: 'blob' });
+  if (res.status === 202) {
+    return JSON.parse(await (res.data as Blob).text()) as ExportJob;
+  }
+  const match = /filename="([^"]+)"/.exec(res.headers['content-disposition'] || '');
+  saveBlob(res.data, match ? match[1] : 'me-ai-export.zip');
+  return null;
+}
+
+export type ConversationFormat = 'md' | 'html' | 'json';
+
+// filenameFrom читает имя из Content-Disposition; кириллица приходит в filename* (RFC 2231).
+function filenameFrom(header: string | undefined, fallback: string) {
+  const encoded = /filename\*=utf-8''([^;]+)/i.exec(header || '');
+  if (encoded) return decodeURIComponent(encoded[1]);
+  const plain = /filename="?([^";]+)"?/.exec(header || '');
+  return plain ? plain[1] : fallback;
+}
+
+// downloadConversation скачивает один чат; include — 'reasoning' и/или 'tools'.
+export async function downloadConversation(id: number, format: ConversationFormat, include: string[] = []) {
+  const res = await axios.get(`${API_URL}/conversations/export`, {
+    params: { id, format, include: include.join(',') || undefined },
+    responseType: 'blob',
+  });
+  saveBlob(res.data, filenameFrom(res.headers['content-disposition'], `${id}.${format}`));
+}
+
+export async function getExportJob(id: number) {
+  const res = await axios.get(`${API_URL}/export/jobs`, { params: { id } });
+  return res.data as ExportJob;
+}
+
+// waitExportJob опрашивает задание, пока архив собирается.
+export async function waitExportJob(id: number, intervalMs = 2000): Promise<ExportJob> {
+  for (;;) {
+    const job = await getExportJob(id);
+    if (job.status !== 'pending' && job.status !== 'running') {
+      return job;
+    }
+    await new Promise(resolve => setTimeout(resolve, intervalMs));
+  }
+}
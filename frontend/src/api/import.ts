@@ -0,0 +1,29 @@
+import axios from 'axios';
+
+const API_URL = '/api';
+
+export interface ImportResult {
+  index: number;
+  title: string;
+  source_id?: string;
+  status: 'imported' | 'duplicate' | 'failed';
+  conversation_id?: number;
+  messages: number;
+  error?: string;
+}
+
+export interface ImportReport {
+  format: string;
+  imported: number;
+  duplicates: number;
+  failed: number;
+  conversations: ImportResult[];
+}
+
+// importConversations загружает conversations.json или ZIP ChatGPT, выгрузку me-ai или JSONL.
+export async function importConversations(file: File, format = '') {
+  const form = new FormData();
+  form.append('file', file);
+  const res = await axios.post(`${API_URL}/import`, form, { params: format ? { format } : {} });
+  return res.data as ImportReport;
+}
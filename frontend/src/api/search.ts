@@ -0,0 +1,47 @@
+import axios from 'axios';
+
+const API_URL = '/api';
+
+export interface SearchHit {
+  kind: 'message' | 'conversation';
+  id: number;
+  conversation_id: number;
+  conversation_title: string;
+  role?: string;
+  // snippet — готовый HTML: текст экранирован сервером, совпадения в <mark>.
+  snippet: string;
+  rank: number;
+  created_at: string;
+}
+
+export interface SearchFilters {
+  conversation_id?: number;
+  role?: string;
+  from?: string;
+  to?: string;
+}
+
+export async function search(q: string, filters: SearchFilters = {}, cursor?: string) {
+  const res = await axios.get(`${API_URL}/search`, { params: { q, ...filters, cursor } });
+  return res.data as { results: SearchHit[]; next_cursor?: string };
+}
+
+export interface SemanticMessage {
+  id: string;
+  role: string;
+  content: string;
+  created_at: string;
+}
+
+export interface SemanticResult {
+  score: number;
+  conversation: { id: number; title: string };
+  message: SemanticMessage;
+  before: SemanticMessage[];
+  after: SemanticMessage[];
+}
+
+export async function semanticSearch(q: string, conversation_id?: number) {
+  const res = await axios.get(`${API_URL}/search/semantic`, { params: { q, conversation_id } });
+  return res.data as { model: string; results: SemanticResult[] };
+}
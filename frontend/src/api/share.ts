@@ -0,0 +1,48 @@
+import axios from 'axios';
+
+const API_URL = '/api';
+
+export interface Share {
+  id: number;
+  conversation_id: number;
+  title: string;
+  message_count: number;
+  has_password: boolean;
+  expires_at?: string;
+  revoked_at?: string;
+  views: number;
+  last_viewed_at?: string;
+  created_at: string;
+  // token и url приходят только при создании ссылки.
+  token?: string;
+  url?: string;
+}
+
+export interface SharedConversation {
+  title: string;
+  shared_at: string;
+  expires_at?: string;
+  messages: { role: string; content: string; created_at: string }[];
+}
+
+export async function createShare(conversationId: number, options: { expires_at?: string; password?: string } = {}) {
+  const res = await axios.post(`${API_URL}/shares/create`, { conversation_id: conversationId, ...options });
+  return res.data as Share;
+}
+
+export async function getShares(conversationId?: number) {
+  const res = await axios.get(`${API_URL}/shares`, { params: { conversation_id: conversationId } });
+  return res.data as Share[];
+}
+
+export async function revokeShare(id: number) {
+  await axios.post(`${API_URL}/shares/revoke`, { id });
+}
+
+// getShared открывает ссылку без авторизации; пароль передаётся только в теле POST.
+export async function getShared(token: string, password?: string) {
+  const res = password
+    ? await axios.post(`${API_URL}/shared`, { token, password })
+    : await axios.get(`${API_URL}/shared`, { params: { token } });
+  return res.data as SharedConversation;
+}
@@ -0,0 +1,41 @@
+import axios from 'axios';
+
+const API_URL = '/api';
+
+export type TrashType = 'conversation' | 'message';
+
+export interface TrashedChat {
+  id: number;
+  title: string;
+  deleted_at: string;
+  purge_at: string;
+}
+
+export interface TrashedMessage {
+  id: string;
+  conversation_id: number;
+  conversation_title: string;
+  role: string;
+  content: string;
+  deleted_at: string;
+  purge_at: string;
+}
+
+export async function getTrash() {
+  const res = await axios.get(`${API_URL}/trash`);
+  return res.data as { conversations: TrashedChat[]; messages: TrashedMessage[] };
+}
+
+export async function restoreFromTrash(type: TrashType, id: number) {
+  await axios.post(`${API_URL}/trash/restore`, { type, id });
+}
+
+// deleteFromTrash удаляет элемент окончательно, без возможности восстановления.
+export async function deleteFromTrash(type: TrashType, id: number) {
+  await axios.post(`${API_URL}/trash/delete`, { type, id });
+}
+
+export async function emptyTrash() {
+  const res = await axios.post(`${API_URL}/trash/empty`);
+  return res.data as { conversations: number; messages: number };
+}
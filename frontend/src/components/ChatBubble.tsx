@@ -1,12 +1,18 @@
 import React from 'react';
-import { Box, Typography, Avatar, useTheme } from '@mui/material';
+import { Box, Typography, Avatar, Button, IconButton, useTheme } from '@mui/material';
+import EditIcon from '@mui/icons-material/Edit';
 
 interface ChatBubbleProps {
   content: string;
   role: 'user' | 'assistant';
+  edited?: boolean;
+  // failed — ответ модели не получен; onRetry показывает кнопку повтора.
+  failed?: boolean;
+  onEdit?: () => void;
+  onRetry?: () => void;
 }
 
-const ChatBubble: React.FC<ChatBubbleProps> = ({ content, role }) => {
+const ChatBubble: React.FC<ChatBubbleProps> = ({ content, role, edited, failed, onEdit, onRetry }) => {
   const theme = useTheme();
   const isUser = role === 'user';
   return (
@@ -44,7 +50,19 @@ const ChatBubble: React.FC<ChatBubbleProps> = ({ content, role }) => {
         }}
       >
         <Typography variant="body1" sx={{ whiteSpace: 'pre-line' }}>{content}</Typography>
+        {edited && <Typography variant="caption" sx={{ opacity: 0.7 }}>изменено</Typography>}
+        {failed && (
+          <Box sx={{ display: 'flex', alignItems: 'center', gap: 1 }}>
+            <Typography variant="caption" color="error">Ответ не получен</Typography>
+            {onRetry && <Button size="small" onClick={onRetry}>Повторить</Button>}
+          </Box>
+        )}
       </Box>
+      {onEdit && (
+        <IconButton size="small" onClick={onEdit} aria-label="Редактировать" sx={{ mx: 0.5 }}>
+          <EditIcon fontSize="small" />
+        </IconButton>
+      )}
     </Box>
   );
 };
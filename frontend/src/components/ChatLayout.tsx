@@ -1,7 +1,7 @@
 import React, { useEffect, useState } from 'react';
 import { Box, CircularProgress } from '@mui/material';
 import ChatSidebar from './ChatSidebar';
-import { getChats, createChat, deleteChat } from '../api/chat';
+import { getChats, createChat, deleteChat, bulkChats, Chat } from '../api/chat';
 import { useNavigate, useLocation, useParams } from 'react-router-dom';
 
 interface ChatLayoutProps {
@@ -12,18 +12,16 @@ const ChatLayout: React.FC<ChatLayoutProps> = ({ children }) => {
   const navigate = useNavigate();
   const location = useLocation();
   const params = useParams();
-  const [chats, setChats] = useState<{ id: number; title: string }[]>([]);
+  const [chats, setChats] = useState<Chat[]>([]);
   const [loading, setLoading] = useState(true);
   const [creating, setCreating] = useState(false);
   const [error, setError] = useState('');
   const [selectedId, setSelectedId] = useState<number | null>(null);
+  // olderCursor — курсор следующей страницы более старых чатов.
+  const [olderCursor, setOlderCursor] = useState<string | undefined>();
 
   useEffect(() => {
-    setLoading(true);
-    getChats()
-      .then(data => setChats(Array.isArray(data) ? data : []))
-      .catch(() => setChats([]))
-      .finally(() => setLoading(false));
+    refreshChats();
     // eslint-disable-next-line
   }, []);
 
@@ -69,19 +67,52 @@ const ChatLayout: React.FC<ChatLayoutProps> = ({ children }) => {
     }
   };
 
+  const handlePin = async (chat: Chat) => {
+    try {
+      await bulkChats(chat.pinned_at ? 'unpin' : 'pin', [chat.id]);
+      refreshChats();
+    } catch {
+      setError('Ошибка закрепления чата');
+    }
+  };
+
+  const handleArchive = async (id: number) => {
+    try {
+      await bulkChats('archive', [id]);
+      setChats(prev => prev.filter(chat => chat.id !== id));
+    } catch {
+      setError('Ошибка архивации чата');
+    }
+  };
+
   const handleSelect = (id: number) => {
     setSelectedId(id);
     navigate(`/chat/${id}`);
   };
 
+  // Закреплённые чаты загружаются отдельно и всегда идут первыми, остальные листаются.
   const refreshChats = () => {
     setLoading(true);
-    getChats()
-      .then(data => setChats(Array.isArray(data) ? data : []))
+    Promise.all([getChats(undefined, { pinned: true }), getChats(undefined, { pinned: false })])
+      .then(([pinned, page]) => {
+        setChats([...pinned.conversations, ...page.conversations]);
+        setOlderCursor(page.has_before ? page.before_cursor : undefined);
+      })
       .catch(() => setChats([]))
       .finally(() => setLoading(false));
   };
 
+  const loadMoreChats = async () => {
+    if (!olderCursor) return;
+    try {
+      const page = await getChats(olderCursor, { pinned: false });
+      setChats(prev => [...prev, ...page.conversations]);
+      setOlderCursor(page.has_before ? page.before_cursor : undefined);
+    } catch {
+      setError('Ошибка загрузки чатов');
+    }
+  };
+
   return (
     <Box sx={{ display: 'flex', flexDirection: { xs: 'column', sm: 'row' }, gap: 3, minHeight: 500 }}>
       <ChatSidebar
@@ -92,6 +123,9 @@ const ChatLayout: React.FC<ChatLayoutProps> = ({ children }) => {
         onNewChat={handleNewChat}
         onDelete={handleDelete}
         onRefresh={refreshChats}
+        onPin={handlePin}
+        onArchive={handleArchive}
+        onLoadMore={olderCursor ? loadMoreChats : undefined}
       />
       <Box sx={{ flex: 1, minWidth: 0 }}>
         {children}
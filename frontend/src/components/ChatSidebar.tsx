@@ -2,19 +2,26 @@ import React, { useState } from 'react';
 import { Box, List, ListItem, ListItemButton, ListItemAvatar, Avatar, ListItemText, Typography, Button, Divider, CircularProgress, IconButton, TextField } from '@mui/material';
 import AddIcon from '@mui/icons-material/Add';
 import EditIcon from '@mui/icons-material/Edit';
-import { renameChat } from '../api/chat';
+import PushPinIcon from '@mui/icons-material/PushPin';
+import PushPinOutlinedIcon from '@mui/icons-material/PushPinOutlined';
+import ArchiveIcon from '@mui/icons-material/Archive';
+import { renameChat, Chat } from '../api/chat';
 
 interface ChatSidebarProps {
-  chats: { id: number; title: string }[];
+  chats: Chat[];
   loading: boolean;
   selectedId?: number;
   onSelect: (id: number) => void;
   onNewChat: () => void;
   onDelete: (id: number) => void;
   onRefresh?: () => void;
+  onPin?: (chat: Chat) => void;
+  onArchive?: (id: number) => void;
+  // onLoadMore задан, пока на сервере есть более старые чаты.
+  onLoadMore?: () => void;
 }
 
-const ChatSidebar: React.FC<ChatSidebarProps> = ({ chats, loading, selectedId, onSelect, onNewChat, onDelete, onRefresh }) => {
+const ChatSidebar: React.FC<ChatSidebarProps> = ({ chats, loading, selectedId, onSelect, onNewChat, onDelete, onRefresh, onPin, onArchive, onLoadMore }) => {
   const safeChats = Array.isArray(chats) ? chats : [];
   const [editingId, setEditingId] = useState<number | null>(null);
   const [editValue, setEditValue] = useState('');
@@ -83,6 +90,16 @@ const ChatSidebar: React.FC<ChatSidebarProps> = ({ chats, loading, selectedId, o
                 ) : (
                   <ListItemText primary={chat.title} />
                 )}
+                {onPin && (
+                  <IconButton size="small" title={chat.pinned_at ? 'Открепить' : 'Закрепить'} onClick={e => { e.stopPropagation(); onPin(chat); }}>
+                    {chat.pinned_at ? <PushPinIcon fontSize="small" /> : <PushPinOutlinedIcon fontSize="small" />}
+                  </IconButton>
+                )}
+                {onArchive && (
+                  <IconButton size="small" title="В архив" onClick={e => { e.stopPropagation(); onArchive(chat.id); }}>
+                    <ArchiveIcon fontSize="small" />
+                  </IconButton>
+                )}
                 <IconButton size="small" onClick={e => { e.stopPropagation(); handleEdit(chat); }} sx={{ ml: 1 }}>
                   <EditIcon fontSize="small" />
                 </IconButton>
@@ -92,6 +109,7 @@ const ChatSidebar: React.FC<ChatSidebarProps> = ({ chats, loading, selectedId, o
               </ListItemButton>
             </ListItem>
           ))}
+          {onLoadMore && <Button onClick={onLoadMore} fullWidth size="small">Показать ещё</Button>}
         </List>
       )}
     </Box>
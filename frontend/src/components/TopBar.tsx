@@ -29,6 +29,15 @@ const TopBar: React.FC = () => {
             {dark ? <Brightness7Icon /> : <Brightness4Icon />}
           </IconButton>
         </Tooltip>
+        <Button color="inherit" onClick={() => navigate('/search')} sx={{ fontWeight: 600 }}>
+          Поиск
+        </Button>
+        <Button color="inherit" onClick={() => navigate('/trash')} sx={{ fontWeight: 600 }}>
+          Корзина
+        </Button>
+        <Button color="inherit" onClick={() => navigate('/account')} sx={{ fontWeight: 600 }}>
+          Аккаунт
+        </Button>
         <Button color="inherit" onClick={handleLogout} sx={{ fontWeight: 600 }}>
           Выйти
         </Button>
@@ -1,5 +1,6 @@
 import React, { createContext, useContext, useState, useEffect } from 'react';
-import axios from 'axios';
+import axios, { type InternalAxiosRequestConfig } from 'axios';
+import { refresh, logout as logoutSession } from '../api/auth';
 
 interface AuthContextType {
   token: string | null;
@@ -28,12 +29,38 @@ export const AuthProvider: React.FC<{ children: React.ReactNode }> = ({ children
     }
   }, [token]);
 
+  // Access token живёт недолго: на 401 один раз обновляем его по refresh token и повторяем запрос.
+  useEffect(() => {
+    let refreshing: Promise<string | null> | null = null;
+    const interceptor = axios.interceptors.response.use(undefined, async (error) => {
+      const original = error.config as (InternalAxiosRequestConfig & { _retry?: boolean }) | undefined;
+      if (error.response?.status !== 401 || !original || original._retry || original.url?.includes('/auth/')) {
+        return Promise.reject(error);
+      }
+      original._retry = true;
+      refreshing = refreshing ?? refresh().finally(() => { refreshing = null; });
+      const fresh = await refreshing;
+      if (!fresh) {
+        setTokenState(null);
+        return Promise.reject(error);
+      }
+      axios.defaults.headers.common['Authorization'] = `Bearer ${fresh}`;
+      original.headers['Authorization'] = `Bearer ${fresh}`;
+      setTokenState(fresh);
+      return axios(original);
+    });
+    return () => axios.interceptors.response.eject(interceptor);
+  }, []);
+
   const setToken = (t: string | null) => setTokenState(t);
-  const logout = () => setTokenState(null);
+  const logout = () => {
+    logoutSession();
+    setTokenState(null);
+  };
 
   return (
     <AuthContext.Provider value={{ token, setToken, logout }}>
       {children}
     </AuthContext.Provider>
   );
-}; 
\ No newline at end of file
+};
@@ -0,0 +1,201 @@
+import React, { useEffect, useState } from 'react';
+import { Box, Button, Divider, List, ListItem, ListItemText, Paper, TextField, Typography } from '@mui/material';
+import { useNavigate } from 'react-router-dom';
+import {
+  changePassword,
+  deleteAccount,
+  downloadExport,
+  getProfile,
+  getSessions,
+  Profile,
+  revokeOtherSessions,
+  revokeSession,
+  Session,
+  updateProfile,
+} from '../api/account';
+import { requestArchive, waitExportJob } from '../api/export';
+import { importConversations, ImportReport } from '../api/import';
+import { useAuth } from '../context/AuthContext';
+
+const AccountPage: React.FC = () => {
+  const [profile, setProfile] = useState<Profile | null>(null);
+  const [sessions, setSessions] = useState<Session[]>([]);
+  const [name, setName] = useState('');
+  const [email, setEmail] = useState('');
+  const [currentPassword, setCurrentPassword] = useState('');
+  const [newPassword, setNewPassword] = useState('');
+  const [deletePassword, setDeletePassword] = useState('');
+  const [deleteCode, setDeleteCode] = useState('');
+  const [message, setMessage] = useState('');
+  const [error, setError] = useState('');
+  const [importReport, setImportReport] = useState<ImportReport | null>(null);
+  const navigate = useNavigate();
+  const { logout } = useAuth();
+
+  const load = async () => {
+    const p = await getProfile();
+    setProfile(p);
+    setName(p.name);
+    setEmail(p.email);
+    setSessions(await getSessions());
+  };
+
+  useEffect(() => {
+    load().catch(() => setError('Не удалось загрузить профиль'));
+  }, []);
+
+  const run = async (action: () => Promise<void>, done: string) => {
+    setError('');
+    setMessage('');
+    try {
+      await action();
+      setMessage(done);
+    } catch (err: any) {
+      setError(err?.response?.data || 'Ошибка');
+    }
+  };
+
+  // Большой архив собирается в фоне: ждём задание и переходим по ссылке на скачивание.
+  const downloadArchive = async () => {
+    const pending = await requestArchive();
+    if (!pending) return;
+    setMessage('Архив собирается, это может занять несколько минут…');
+    const job = await waitExportJob(pending.id);
+    if (job.status !== 'done' || !job.download_url) {
+      throw { response: { data: job.error || 'Не удалось собрать архив' } };
+    }
+    window.location.href = job.download_url;
+  };
+
+  const handleImport = (e: React.ChangeEvent<HTMLInputElement>) => {
+    const file = e.target.files?.[0];
+    e.target.value = '';
+    if (!file) return;
+    run(async () => {
+      const report = await importConversations(file);
+      setImportReport(report);
+    }, 'Импорт завершён');
+  };
+
+  const handleProfile = (e: React.FormEvent) => {
+    e.preventDefault();
+    run(async () => {
+      const changes: { name?: string; email?: string } = {};
+      if (name !== profile?.name) changes.name = name;
+      if (email !== profile?.email) changes.email = email;
+      setProfile(await updateProfile(changes));
+    }, email !== profile?.email ? 'Мы отправили ссылку для подтверждения на новый адрес' : 'Профиль сохранён');
+  };
+
+  const handlePassword = (e: React.FormEvent) => {
+    e.preventDefault();
+    run(async () => {
+      await changePassword(currentPassword, newPassword);
+      setCurrentPassword('');
+      setNewPassword('');
+      setSessions(await getSessions());
+    }, 'Пароль изменён, остальные сессии завершены');
+  };
+
+  const handleDelete = (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!window.confirm('Удалить аккаунт вместе со всеми чатами? Это необратимо.')) return;
+    run(async () => {
+      await deleteAccount(deletePassword, deleteCode || undefined);
+      logout();
+      navigate('/login');
+    }, 'Аккаунт удалён');
+  };
+
+  if (!profile) {
+    return <Typography color={error ? 'error' : undefined}>{error || 'Загрузка...'}</Typography>;
+  }
+
+  return (
+    <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 600, mx: 'auto' }}>
+      <Typography variant="h5" mb={2}>Аккаунт</Typography>
+      {message && <Typography color="primary" mb={1}>{message}</Typography>}
+      {error && <Typography color="error" mb={1}>{error}</Typography>}
+
+      <form onSubmit={handleProfile}>
+        <TextField label="Имя" value={name} onChange={e => setName(e.target.value)} fullWidth margin="normal" required />
+        <TextField label="Email" type="email" value={email} onChange={e => setEmail(e.target.value)} fullWidth margin="normal" required />
+        {profile.pending_email && (
+          <Typography variant="body2" color="text.secondary">
+            Ожидает подтверждения: {profile.pending_email}
+          </Typography>
+        )}
+        <Button type="submit" variant="contained" sx={{ mt: 1 }}>Сохранить</Button>
+      </form>
+
+      <Divider sx={{ my: 3 }} />
+      <Typography variant="h6">Пароль</Typography>
+      <form onSubmit={handlePassword}>
+        <TextField label="Текущий пароль" type="password" value={currentPassword} onChange={e => setCurrentPassword(e.target.value)} fullWidth margin="normal" required />
+        <TextField label="Новый пароль" type="password" value={newPassword} onChange={e => setNewPassword(e.target.value)} fullWidth margin="normal" required />
+        <Button type="submit" variant="contained" sx={{ mt: 1 }}>Сменить пароль</Button>
+      </form>
+
+      <Divider sx={{ my: 3 }} />
+      <Typography variant="h6">Сессии</Typography>
+      <List dense>
+        {sessions.map(s => (
+          <ListItem
+            key={s.id}
+            secondaryAction={!s.current && (
+              <Button size="small" onClick={() => run(async () => {
+                await revokeSession(s.id);
+                setSessions(await getSessions());
+              }, 'Сессия завершена')}>
+                Завершить
+              </Button>
+            )}
+          >
+            <ListItemText
+              primary={`${s.user_agent || 'Неизвестное устройство'}${s.current ? ' (эта сессия)' : ''}`}
+              secondary={`${s.ip}, активна ${new Date(s.last_used_at).toLocaleString()}`}
+            />
+          </ListItem>
+        ))}
+      </List>
+      <Button onClick={() => run(async () => {
+        await revokeOtherSessions();
+        setSessions(await getSessions());
+      }, 'Остальные сессии завершены')}>
+        Завершить все, кроме этой
+      </Button>
+
+      <Divider sx={{ my: 3 }} />
+      <Typography variant="h6">Данные и удаление</Typography>
+      <Button onClick={() => run(downloadExport, 'Экспорт скачан')} sx={{ mt: 1 }}>Скачать мои данные</Button>
+      <Button onClick={() => run(downloadArchive, 'Архив скачан')} sx={{ mt: 1, ml: 1 }}>Архив ZIP с транскриптами</Button>
+      <Button component="label" sx={{ mt: 1, ml: 1 }}>
+        Импортировать беседы
+        <input type="file" hidden accept=".json,.jsonl,.zip" onChange={handleImport} />
+      </Button>
+      {importReport && (
+        <Box sx={{ mt: 1 }}>
+          <Typography variant="body2">
+            Импортировано: {importReport.imported}, уже были: {importReport.duplicates}, с ошибками: {importReport.failed}
+          </Typography>
+          <List dense>
+            {importReport.conversations.filter(c => c.status === 'failed').map(c => (
+              <ListItem key={c.index}>
+                <ListItemText primary={c.title || `Беседа №${c.index + 1}`} secondary={c.error} />
+              </ListItem>
+            ))}
+          </List>
+        </Box>
+      )}
+      <form onSubmit={handleDelete}>
+        <TextField label="Пароль" type="password" value={deletePassword} onChange={e => setDeletePassword(e.target.value)} fullWidth margin="normal" required />
+        {profile.two_factor_enabled && (
+          <TextField label="Код 2FA" value={deleteCode} onChange={e => setDeleteCode(e.target.value)} fullWidth margin="normal" required />
+        )}
+        <Button type="submit" color="error" variant="outlined" sx={{ mt: 1 }}>Удалить аккаунт</Button>
+      </form>
+    </Box>
+  );
+};
+
+export default AccountPage;
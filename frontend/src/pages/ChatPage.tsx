@@ -1,44 +1,93 @@
 import React, { useEffect, useState, useRef } from 'react';
-import { Box, Button, TextField, Typography, Paper, List, CircularProgress, InputAdornment, IconButton } from '@mui/material';
+import { Box, Button, TextField, Typography, Paper, List, CircularProgress, InputAdornment, IconButton, Menu, MenuItem } from '@mui/material';
 import { useParams } from 'react-router-dom';
-import { getMessages, sendMessage } from '../api/chat';
+import { getMessages, sendMessage, retryReply, editMessage, Message, ChatResponse } from '../api/chat';
+import { createShare } from '../api/share';
+import { downloadConversation, ConversationFormat } from '../api/export';
 import ChatBubble from '../components/ChatBubble';
 import SendIcon from '@mui/icons-material/Send';
 
+function replyFrom(res: ChatResponse): Message {
+  return { id: Number(res.reply_id), content: res.message, role: 'assistant', status: res.status };
+}
+
 const ChatPage: React.FC = () => {
   const { id } = useParams();
   const conversationId = Number(id);
   const [message, setMessage] = useState('');
-  const [messages, setMessages] = useState<{ id: number; content: string; role: string }[]>([]);
+  // local — сообщения, добавленные до ответа сервера: их id ещё неизвестны, править их нельзя.
+  const [messages, setMessages] = useState<(Message & { local?: boolean })[]>([]);
   const [loading, setLoading] = useState(true);
   const [sending, setSending] = useState(false);
   const [error, setError] = useState('');
   const listRef = useRef<HTMLUListElement>(null);
+  // olderCursor — курсор более старых сообщений; подгружаются при прокрутке вверх.
+  const [olderCursor, setOlderCursor] = useState<string | undefined>();
+  const [loadingOlder, setLoadingOlder] = useState(false);
+  // keepOffset — расстояние от низа списка, которое нужно сохранить после подгрузки.
+  const keepOffset = useRef<number | null>(null);
+  const [shareUrl, setShareUrl] = useState('');
+  const [exportAnchor, setExportAnchor] = useState<HTMLElement | null>(null);
+  const [editing, setEditing] = useState<Message | null>(null);
+  const [editText, setEditText] = useState('');
 
   useEffect(() => {
     if (!conversationId) return;
     setLoading(true);
+    setShareUrl('');
+    setEditing(null);
     getMessages(conversationId)
-      .then(data => setMessages(Array.isArray(data) ? data : []))
+      .then(page => {
+        setMessages(page.messages);
+        setOlderCursor(page.has_before ? page.before_cursor : undefined);
+      })
       .catch(() => setError('Ошибка загрузки сообщений'))
       .finally(() => setLoading(false));
   }, [conversationId]);
 
   useEffect(() => {
-    if (listRef.current) {
-      listRef.current.scrollTop = listRef.current.scrollHeight;
+    const list = listRef.current;
+    if (!list) return;
+    if (keepOffset.current !== null) {
+      list.scrollTop = list.scrollHeight - keepOffset.current;
+      keepOffset.current = null;
+    } else {
+      list.scrollTop = list.scrollHeight;
     }
   }, [messages]);
 
+  const handleScroll = async (e: React.UIEvent<HTMLUListElement>) => {
+    const list = e.currentTarget;
+    if (list.scrollTop > 50 || !olderCursor || loadingOlder) return;
+    setLoadingOlder(true);
+    try {
+      const page = await getMessages(conversationId, olderCursor);
+      keepOffset.current = list.scrollHeight - list.scrollTop;
+      setMessages(prev => [...page.messages, ...prev]);
+      setOlderCursor(page.has_before ? page.before_cursor : undefined);
+    } catch {
+      setError('Ошибка загрузки сообщений');
+    } finally {
+      setLoadingOlder(false);
+    }
+  };
+
   const handleSend = async () => {
     if (!message.trim() || !conversationId) return;
     setSending(true);
     setError('');
+    const localId = Date.now();
     try {
-      setMessages(prev => [...prev, { id: Date.now(), content: message, role: 'user' }]);
+      setMessages(prev => [...prev, { id: localId, content: message, role: 'user', local: true }]);
       setMessage('');
       const res = await sendMessage(conversationId, message);
-      setMessages(prev => [...prev, { id: Date.now() + 1, content: res.message, role: 'assistant' }]);
+      if (!res.reply_id) throw new Error(res.error);
+      // Сервер сохранил реплику: временный id меняется на настоящий, ответ добавляется даже неудавшимся.
+      setMessages(prev => [
+        ...prev.map(m => (m.id === localId ? { ...m, id: Number(res.user_message_id), local: false } : m)),
+        replyFrom(res),
+      ]);
+      if (res.status === 'failed') setError('Модель не ответила — можно повторить');
     } catch {
       setError('Ошибка отправки сообщения');
     } finally {
@@ -46,20 +95,115 @@ const ChatPage: React.FC = () => {
     }
   };
 
+  const handleRetry = async (replyId: number) => {
+    setSending(true);
+    setError('');
+    try {
+      const res = await retryReply(replyId);
+      setMessages(prev => prev.map(m => (m.id === replyId ? replyFrom(res) : m)));
+      if (res.status === 'failed') setError('Модель снова не ответила');
+    } catch {
+      setError('Не удалось повторить ответ');
+    } finally {
+      setSending(false);
+    }
+  };
+
+  // handleShare создаёт ссылку на текущее состояние чата: новые сообщения в неё не попадут.
+  const handleShare = async () => {
+    try {
+      const share = await createShare(conversationId);
+      setShareUrl(share.url || '');
+      await navigator.clipboard?.writeText(share.url || '');
+    } catch {
+      setError('Не удалось создать ссылку');
+    }
+  };
+
+  const startEdit = (msg: Message) => {
+    setEditing(msg);
+    setEditText(msg.content);
+  };
+
+  // handleEdit сохраняет правку; regenerate заменяет всё после сообщения новым ответом модели.
+  const handleEdit = async (regenerate: boolean) => {
+    if (!editing || !editText.trim()) return;
+    setSending(true);
+    setError('');
+    try {
+      const res = await editMessage(editing.id, editText, regenerate);
+      setMessages(prev => {
+        const idx = prev.findIndex(m => m.id === editing.id);
+        const kept = regenerate ? prev.slice(0, idx) : prev.slice();
+        if (regenerate) {
+          return [...kept, res.message, ...(res.reply ? [res.reply] : [])];
+        }
+        kept[idx] = res.message;
+        return kept;
+      });
+      setEditing(null);
+    } catch {
+      setError('Не удалось изменить сообщение');
+    } finally {
+      setSending(false);
+    }
+  };
+
+  const handleExport = async (format: ConversationFormat) => {
+    setExportAnchor(null);
+    try {
+      await downloadConversation(conversationId, format);
+    } catch {
+      setError('Не удалось скачать чат');
+    }
+  };
+
   return (
     <Box component={Paper} elevation={3} sx={{ p: { xs: 1, sm: 3 }, maxWidth: 700, mx: 'auto', minHeight: 400, display: 'flex', flexDirection: 'column', borderRadius: 4 }}>
       <Typography variant="h6" mb={2} align="center">Чат #{id}</Typography>
+      <Box sx={{ display: 'flex', justifyContent: 'flex-end', alignItems: 'center', gap: 1, mb: 1 }}>
+        {shareUrl && <TextField value={shareUrl} size="small" fullWidth InputProps={{ readOnly: true }} helperText="Ссылка скопирована" />}
+        <Button size="small" onClick={e => setExportAnchor(e.currentTarget)}>Скачать</Button>
+        <Menu anchorEl={exportAnchor} open={!!exportAnchor} onClose={() => setExportAnchor(null)}>
+          <MenuItem onClick={() => handleExport('md')}>Markdown</MenuItem>
+          <MenuItem onClick={() => handleExport('html')}>HTML</MenuItem>
+          <MenuItem onClick={() => handleExport('json')}>JSON</MenuItem>
+        </Menu>
+        <Button size="small" onClick={handleShare}>Поделиться</Button>
+      </Box>
       {loading ? <CircularProgress sx={{ display: 'block', mx: 'auto', my: 4 }} /> : (
-        <List ref={listRef} sx={{ flex: 1, overflowY: 'auto', mb: 2, maxHeight: { xs: 300, sm: 400 }, px: 0 }}>
+        <List ref={listRef} onScroll={handleScroll} sx={{ flex: 1, overflowY: 'auto', mb: 2, maxHeight: { xs: 300, sm: 400 }, px: 0 }}>
+          {loadingOlder && <CircularProgress size={20} sx={{ display: 'block', mx: 'auto', my: 1 }} />}
           {messages.length === 0 && <Typography align="center" color="text.secondary" mt={4}>Нет сообщений</Typography>}
           {messages.map((msg, idx) => (
             <li key={msg.id + '-' + idx} style={{ listStyle: 'none' }}>
-              <ChatBubble content={msg.content} role={msg.role as 'user' | 'assistant'} />
+              <ChatBubble
+                content={msg.content}
+                role={msg.role as 'user' | 'assistant'}
+                edited={!!msg.edited_at}
+                failed={msg.status === 'failed'}
+                onEdit={msg.local || sending || (msg.status && msg.status !== 'complete') ? undefined : () => startEdit(msg)}
+                onRetry={msg.status === 'failed' && !sending && idx === messages.length - 1 ? () => handleRetry(msg.id) : undefined}
+              />
             </li>
           ))}
         </List>
       )}
       {error && <Typography color="error" align="center">{error}</Typography>}
+      {editing && (
+        <Box sx={{ display: 'flex', flexDirection: 'column', gap: 1, mt: 1 }}>
+          <TextField value={editText} onChange={e => setEditText(e.target.value)} multiline size="small" fullWidth autoFocus />
+          <Box sx={{ display: 'flex', justifyContent: 'flex-end', gap: 1 }}>
+            <Button size="small" onClick={() => setEditing(null)}>Отмена</Button>
+            <Button size="small" onClick={() => handleEdit(false)} disabled={sending || !editText.trim()}>Сохранить</Button>
+            {editing.role === 'user' && (
+              <Button size="small" variant="contained" onClick={() => handleEdit(true)} disabled={sending || !editText.trim()}>
+                Сохранить и ответить заново
+              </Button>
+            )}
+          </Box>
+        </Box>
+      )}
       <Box component="form" onSubmit={e => { e.preventDefault(); handleSend(); }} sx={{ display: 'flex', gap: 1, mt: 1 }}>
         <TextField
           value={message}
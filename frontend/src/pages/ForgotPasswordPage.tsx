@@ -0,0 +1,50 @@
+import React, { useState } from 'react';
+import { Box, Button, TextField, Typography, Link, Paper } from '@mui/material';
+import { forgotPassword } from '../api/auth';
+
+const ForgotPasswordPage: React.FC = () => {
+  const [email, setEmail] = useState('');
+  const [sent, setSent] = useState(false);
+  const [error, setError] = useState('');
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    try {
+      await forgotPassword(email);
+      setSent(true);
+    } catch (err: any) {
+      setError(err?.response?.data || 'Не удалось отправить письмо');
+    }
+  };
+
+  return (
+    <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 400, mx: 'auto' }}>
+      <Typography variant="h5" mb={2} align="center">Восстановление пароля</Typography>
+      {sent ? (
+        <Typography>Если аккаунт с адресом {email} существует, мы отправили на него ссылку для сброса пароля.</Typography>
+      ) : (
+        <form onSubmit={handleSubmit}>
+          <TextField
+            label="Email"
+            type="email"
+            value={email}
+            onChange={e => setEmail(e.target.value)}
+            fullWidth
+            margin="normal"
+            required
+          />
+          {error && <Typography color="error" variant="body2">{error}</Typography>}
+          <Button type="submit" variant="contained" color="primary" fullWidth sx={{ mt: 2 }}>
+            Отправить ссылку
+          </Button>
+        </form>
+      )}
+      <Box mt={2} textAlign="center">
+        <Link href="/login" underline="hover">Вернуться ко входу</Link>
+      </Box>
+    </Box>
+  );
+};
+
+export default ForgotPasswordPage;
@@ -1,16 +1,25 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
 import { Box, Button, TextField, Typography, Link, Paper } from '@mui/material';
-import { useNavigate } from 'react-router-dom';
-import { login } from '../api/auth';
+import { useLocation, useNavigate } from 'react-router-dom';
+import { getOIDCProviders, login, oidcLoginURL, verifyTwoFactor } from '../api/auth';
 import { useAuth } from '../context/AuthContext';
 
 const LoginPage: React.FC = () => {
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
   const [error, setError] = useState('');
+  const location = useLocation();
+  // После SSO с включённой 2FA страница открывается сразу на шаге ввода кода.
+  const [challenge, setChallenge] = useState<string>(location.state?.challenge || '');
+  const [code, setCode] = useState('');
+  const [providers, setProviders] = useState<string[]>([]);
   const navigate = useNavigate();
   const { setToken } = useAuth();
 
+  useEffect(() => {
+    getOIDCProviders().then(setProviders).catch(() => {});
+  }, []);
+
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     setError('');
@@ -19,14 +28,52 @@ const LoginPage: React.FC = () => {
       return;
     }
     try {
-      const token = await login(email, password);
-      setToken(token);
+      const result = await login(email, password);
+      if ('challenge' in result) {
+        setChallenge(result.challenge);
+        return;
+      }
+      setToken(result.token);
       navigate('/chats');
     } catch (err: any) {
       setError(err?.response?.data || 'Ошибка входа');
     }
   };
 
+  const handleCode = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    try {
+      setToken(await verifyTwoFactor(challenge, code));
+      navigate('/chats');
+    } catch (err: any) {
+      setError(err?.response?.data || 'Неверный код');
+    }
+  };
+
+  if (challenge) {
+    return (
+      <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 400, mx: 'auto' }}>
+        <Typography variant="h5" mb={2} align="center">Двухфакторная аутентификация</Typography>
+        <form onSubmit={handleCode}>
+          <TextField
+            label="Код из приложения или код восстановления"
+            value={code}
+            onChange={e => setCode(e.target.value)}
+            fullWidth
+            margin="normal"
+            autoComplete="one-time-code"
+            required
+          />
+          {error && <Typography color="error" variant="body2">{error}</Typography>}
+          <Button type="submit" variant="contained" color="primary" fullWidth sx={{ mt: 2 }}>
+            Подтвердить
+          </Button>
+        </form>
+      </Box>
+    );
+  }
+
   return (
     <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 400, mx: 'auto' }}>
       <Typography variant="h5" mb={2} align="center">Вход</Typography>
@@ -54,9 +101,17 @@ const LoginPage: React.FC = () => {
           Войти
         </Button>
       </form>
+      {providers.map(provider => (
+        <Button key={provider} href={oidcLoginURL(provider)} variant="outlined" fullWidth sx={{ mt: 1 }}>
+          Войти через {provider}
+        </Button>
+      ))}
       <Box mt={2} textAlign="center">
         <Link href="/register" underline="hover">Нет аккаунта? Зарегистрироваться</Link>
       </Box>
+      <Box mt={1} textAlign="center">
+        <Link href="/forgot-password" underline="hover">Забыли пароль?</Link>
+      </Box>
     </Box>
   );
 };
@@ -0,0 +1,44 @@
+import React, { useEffect, useState } from 'react';
+import { Box, Typography, Link, Paper } from '@mui/material';
+import { useNavigate } from 'react-router-dom';
+import { completeOIDCLogin } from '../api/auth';
+import { useAuth } from '../context/AuthContext';
+
+const errorMessages: Record<string, string> = {
+  email_not_verified: 'Провайдер не подтвердил ваш email.',
+  account_disabled: 'Аккаунт отключён.',
+  invalid_state: 'Сессия входа устарела, попробуйте ещё раз.',
+  access_denied: 'Вход отменён.',
+};
+
+const OIDCCallbackPage: React.FC = () => {
+  const [error, setError] = useState('');
+  const navigate = useNavigate();
+  const { setToken } = useAuth();
+
+  useEffect(() => {
+    const result = completeOIDCLogin(window.location.hash);
+    // Токены не должны оставаться в адресной строке и истории.
+    window.history.replaceState(null, '', window.location.pathname);
+    if ('token' in result) {
+      setToken(result.token);
+      navigate('/chats', { replace: true });
+    } else if ('challenge' in result) {
+      navigate('/login', { replace: true, state: { challenge: result.challenge } });
+    } else {
+      setError(errorMessages[result.error] || 'Не удалось войти через провайдера.');
+    }
+  }, [navigate, setToken]);
+
+  return (
+    <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 400, mx: 'auto' }}>
+      <Typography variant="h5" mb={2} align="center">Вход</Typography>
+      {error ? <Typography color="error">{error}</Typography> : <Typography>Завершаем вход...</Typography>}
+      <Box mt={2} textAlign="center">
+        <Link href="/login" underline="hover">Вернуться ко входу</Link>
+      </Box>
+    </Box>
+  );
+};
+
+export default OIDCCallbackPage;
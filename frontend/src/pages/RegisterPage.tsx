@@ -1,7 +1,7 @@
 import React, { useState } from 'react';
 import { Box, Button, TextField, Typography, Link, Paper } from '@mui/material';
 import { useNavigate } from 'react-router-dom';
-import { register } from '../api/auth';
+import { register, resendVerification } from '../api/auth';
 import { useAuth } from '../context/AuthContext';
 
 const RegisterPage: React.FC = () => {
@@ -9,6 +9,7 @@ const RegisterPage: React.FC = () => {
   const [name, setName] = useState('');
   const [password, setPassword] = useState('');
   const [error, setError] = useState('');
+  const [sent, setSent] = useState(false);
   const navigate = useNavigate();
   const { setToken } = useAuth();
 
@@ -21,6 +22,10 @@ const RegisterPage: React.FC = () => {
     }
     try {
       const token = await register(email, name, password);
+      if (!token) {
+        setSent(true);
+        return;
+      }
       setToken(token);
       navigate('/chats');
     } catch (err: any) {
@@ -28,6 +33,23 @@ const RegisterPage: React.FC = () => {
     }
   };
 
+  if (sent) {
+    return (
+      <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 400, mx: 'auto' }}>
+        <Typography variant="h5" mb={2} align="center">Проверьте почту</Typography>
+        <Typography>
+          Мы отправили письмо на {email}. Перейдите по ссылке из него, чтобы подтвердить адрес.
+        </Typography>
+        <Button fullWidth sx={{ mt: 2 }} onClick={() => resendVerification(email)}>
+          Отправить ещё раз
+        </Button>
+        <Box mt={2} textAlign="center">
+          <Link href="/login" underline="hover">Войти</Link>
+        </Box>
+      </Box>
+    );
+  }
+
   return (
     <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 400, mx: 'auto' }}>
       <Typography variant="h5" mb={2} align="center">Регистрация</Typography>
@@ -0,0 +1,48 @@
+import React, { useState } from 'react';
+import { Box, Button, TextField, Typography, Link, Paper } from '@mui/material';
+import { useNavigate, useSearchParams } from 'react-router-dom';
+import { resetPassword } from '../api/auth';
+
+const ResetPasswordPage: React.FC = () => {
+  const [params] = useSearchParams();
+  const [password, setPassword] = useState('');
+  const [error, setError] = useState('');
+  const navigate = useNavigate();
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    try {
+      await resetPassword(params.get('token') || '', password);
+      navigate('/login');
+    } catch (err: any) {
+      setError(err?.response?.data || 'Ссылка недействительна или устарела');
+    }
+  };
+
+  return (
+    <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 400, mx: 'auto' }}>
+      <Typography variant="h5" mb={2} align="center">Новый пароль</Typography>
+      <form onSubmit={handleSubmit}>
+        <TextField
+          label="Пароль"
+          type="password"
+          value={password}
+          onChange={e => setPassword(e.target.value)}
+          fullWidth
+          margin="normal"
+          required
+        />
+        {error && <Typography color="error" variant="body2">{error}</Typography>}
+        <Button type="submit" variant="contained" color="primary" fullWidth sx={{ mt: 2 }}>
+          Сохранить
+        </Button>
+      </form>
+      <Box mt={2} textAlign="center">
+        <Link href="/login" underline="hover">Вернуться ко входу</Link>
+      </Box>
+    </Box>
+  );
+};
+
+export default ResetPasswordPage;
@@ -0,0 +1,99 @@
+import React, { useState } from 'react';
+import { Box, Button, FormControlLabel, List, ListItemButton, ListItemText, MenuItem, Paper, Switch, TextField, Typography } from '@mui/material';
+import { useNavigate } from 'react-router-dom';
+import { search, SearchFilters, SearchHit, semanticSearch, SemanticResult } from '../api/search';
+
+const roleLabels: Record<string, string> = { user: 'Вы', assistant: 'Ассистент', system: 'Система' };
+
+const SearchPage: React.FC = () => {
+  const [query, setQuery] = useState('');
+  const [filters, setFilters] = useState<SearchFilters>({});
+  const [results, setResults] = useState<SearchHit[]>([]);
+  // semantic — поиск по смыслу: без фильтров и постраничной выдачи.
+  const [semantic, setSemantic] = useState(false);
+  const [similar, setSimilar] = useState<SemanticResult[]>([]);
+  const [cursor, setCursor] = useState<string | undefined>();
+  const [searched, setSearched] = useState(false);
+  const [error, setError] = useState('');
+  const navigate = useNavigate();
+
+  const load = async (next?: string) => {
+    setError('');
+    try {
+      if (semantic) {
+        const found = await semanticSearch(query);
+        setSimilar(found.results);
+        setResults([]);
+        setCursor(undefined);
+        setSearched(true);
+        return;
+      }
+      const page = await search(query, filters, next);
+      setResults(prev => (next ? [...prev, ...page.results] : page.results));
+      setSimilar([]);
+      setCursor(page.next_cursor);
+      setSearched(true);
+    } catch (err: any) {
+      setError(err?.response?.data || 'Ошибка поиска');
+    }
+  };
+
+  const handleSubmit = (e: React.FormEvent) => {
+    e.preventDefault();
+    if (query.trim()) load();
+  };
+
+  const setFilter = (key: keyof SearchFilters) => (e: React.ChangeEvent<HTMLInputElement>) =>
+    setFilters(prev => ({ ...prev, [key]: e.target.value || undefined }));
+
+  return (
+    <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 800, mx: 'auto', mt: 2 }}>
+      <Typography variant="h5" mb={2}>Поиск по беседам</Typography>
+      <form onSubmit={handleSubmit}>
+        <TextField label="Что ищем" value={query} onChange={e => setQuery(e.target.value)} fullWidth autoFocus />
+        <FormControlLabel
+          control={<Switch checked={semantic} onChange={e => setSemantic(e.target.checked)} />}
+          label="По смыслу"
+          sx={{ mt: 1 }}
+        />
+        <Box sx={{ display: 'flex', gap: 2, mt: 2 }}>
+          {!semantic && <>
+            <TextField select label="Автор" value={filters.role || ''} onChange={setFilter('role')} sx={{ minWidth: 160 }}>
+              <MenuItem value="">Все</MenuItem>
+              <MenuItem value="user">Вы</MenuItem>
+              <MenuItem value="assistant">Ассистент</MenuItem>
+            </TextField>
+            <TextField label="С" type="date" value={filters.from || ''} onChange={setFilter('from')} InputLabelProps={{ shrink: true }} />
+            <TextField label="По" type="date" value={filters.to || ''} onChange={setFilter('to')} InputLabelProps={{ shrink: true }} />
+          </>}
+          <Button type="submit" variant="contained">Найти</Button>
+        </Box>
+      </form>
+      {error && <Typography color="error" mt={2}>{error}</Typography>}
+      {searched && results.length === 0 && similar.length === 0 && <Typography mt={2}>Ничего не найдено</Typography>}
+      <List>
+        {results.map(hit => (
+          <ListItemButton key={`${hit.kind}-${hit.id}`} onClick={() => navigate(`/chat/${hit.conversation_id}`)}>
+            <ListItemText
+              primary={<span dangerouslySetInnerHTML={{ __html: hit.snippet }} />}
+              secondary={hit.kind === 'conversation'
+                ? `Название беседы · ${new Date(hit.created_at).toLocaleString()}`
+                : `${hit.conversation_title} · ${roleLabels[hit.role || ''] || hit.role} · ${new Date(hit.created_at).toLocaleString()}`}
+            />
+          </ListItemButton>
+        ))}
+        {similar.map(r => (
+          <ListItemButton key={`semantic-${r.message.id}`} onClick={() => navigate(`/chat/${r.conversation.id}`)}>
+            <ListItemText
+              primary={r.message.content}
+              secondary={`${r.conversation.title} · ${roleLabels[r.message.role] || r.message.role} · сходство ${r.score.toFixed(2)}`}
+            />
+          </ListItemButton>
+        ))}
+      </List>
+      {cursor && <Button onClick={() => load(cursor)}>Показать ещё</Button>}
+    </Box>
+  );
+};
+
+export default SearchPage;
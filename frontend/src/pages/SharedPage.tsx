@@ -0,0 +1,64 @@
+import React, { useEffect, useState } from 'react';
+import { Box, Button, List, Paper, TextField, Typography } from '@mui/material';
+import { useSearchParams } from 'react-router-dom';
+import { getShared, SharedConversation } from '../api/share';
+import ChatBubble from '../components/ChatBubble';
+
+const SharedPage: React.FC = () => {
+  const [params] = useSearchParams();
+  const token = params.get('token') || '';
+  const [shared, setShared] = useState<SharedConversation | null>(null);
+  const [needPassword, setNeedPassword] = useState(false);
+  const [password, setPassword] = useState('');
+  const [error, setError] = useState('');
+
+  const open = async (pass?: string) => {
+    setError('');
+    try {
+      setShared(await getShared(token, pass));
+      setNeedPassword(false);
+    } catch (err: any) {
+      const status = err?.response?.status;
+      if (status === 401 || status === 403) {
+        setNeedPassword(true);
+        if (status === 403) setError('Неверный пароль');
+      } else if (status === 429) {
+        setError('Слишком много неверных попыток, попробуйте позже');
+      } else if (status === 410) {
+        setError('Срок действия ссылки истёк');
+      } else {
+        setError('Ссылка недействительна или отозвана');
+      }
+    }
+  };
+
+  useEffect(() => {
+    open();
+  }, [token]);
+
+  return (
+    <Box component={Paper} elevation={3} sx={{ p: { xs: 1, sm: 3 }, maxWidth: 700, mx: 'auto', mt: 2, borderRadius: 4 }}>
+      {shared ? <>
+        <Typography variant="h6" align="center">{shared.title}</Typography>
+        <Typography variant="body2" color="text.secondary" align="center" mb={2}>
+          Снимок от {new Date(shared.shared_at).toLocaleString()}
+        </Typography>
+        <List sx={{ px: 0 }}>
+          {shared.messages.map((msg, idx) => (
+            <li key={idx} style={{ listStyle: 'none' }}>
+              <ChatBubble content={msg.content} role={msg.role as 'user' | 'assistant'} />
+            </li>
+          ))}
+        </List>
+      </> : needPassword ? (
+        <Box component="form" onSubmit={e => { e.preventDefault(); open(password); }} sx={{ display: 'flex', gap: 1 }}>
+          <TextField label="Пароль" type="password" value={password} onChange={e => setPassword(e.target.value)} fullWidth autoFocus size="small" />
+          <Button type="submit" variant="contained" disabled={!password}>Открыть</Button>
+        </Box>
+      ) : !error && <Typography align="center">Загрузка...</Typography>}
+      {error && <Typography color="error" align="center" mt={2}>{error}</Typography>}
+    </Box>
+  );
+};
+
+export default SharedPage;
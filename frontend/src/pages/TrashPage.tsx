@@ -0,0 +1,93 @@
+import React, { useEffect, useState } from 'react';
+import { Box, Button, IconButton, List, ListItem, ListItemText, Paper, Tooltip, Typography } from '@mui/material';
+import RestoreIcon from '@mui/icons-material/Restore';
+import DeleteForeverIcon from '@mui/icons-material/DeleteForever';
+import { deleteFromTrash, emptyTrash, getTrash, restoreFromTrash, TrashedChat, TrashedMessage, TrashType } from '../api/trash';
+
+const TrashPage: React.FC = () => {
+  const [chats, setChats] = useState<TrashedChat[]>([]);
+  const [messages, setMessages] = useState<TrashedMessage[]>([]);
+  const [error, setError] = useState('');
+
+  const load = async () => {
+    try {
+      const trash = await getTrash();
+      setChats(trash.conversations);
+      setMessages(trash.messages);
+    } catch (err: any) {
+      setError(err?.response?.data || 'Не удалось загрузить корзину');
+    }
+  };
+
+  useEffect(() => {
+    load();
+  }, []);
+
+  const act = (action: (type: TrashType, id: number) => Promise<void>, type: TrashType, id: number) => async () => {
+    setError('');
+    try {
+      await action(type, id);
+      await load();
+    } catch (err: any) {
+      setError(err?.response?.data || 'Ошибка');
+    }
+  };
+
+  const handleEmpty = async () => {
+    if (!window.confirm('Удалить всё содержимое корзины без возможности восстановления?')) return;
+    try {
+      await emptyTrash();
+      await load();
+    } catch (err: any) {
+      setError(err?.response?.data || 'Не удалось очистить корзину');
+    }
+  };
+
+  const actions = (type: TrashType, id: number) => (
+    <>
+      <Tooltip title="Восстановить">
+        <IconButton onClick={act(restoreFromTrash, type, id)}><RestoreIcon /></IconButton>
+      </Tooltip>
+      <Tooltip title="Удалить навсегда">
+        <IconButton onClick={act(deleteFromTrash, type, id)}><DeleteForeverIcon /></IconButton>
+      </Tooltip>
+    </>
+  );
+  const purgeDate = (purgeAt: string) => `удалится ${new Date(purgeAt).toLocaleDateString()}`;
+
+  return (
+    <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 800, mx: 'auto', mt: 2 }}>
+      <Box sx={{ display: 'flex', justifyContent: 'space-between', alignItems: 'center' }}>
+        <Typography variant="h5">Корзина</Typography>
+        <Button color="error" onClick={handleEmpty} disabled={chats.length === 0 && messages.length === 0}>
+          Очистить
+        </Button>
+      </Box>
+      {error && <Typography color="error" mt={2}>{error}</Typography>}
+      {chats.length === 0 && messages.length === 0 && <Typography mt={2}>Корзина пуста</Typography>}
+      {chats.length > 0 && <Typography variant="h6" mt={2}>Чаты</Typography>}
+      <List>
+        {chats.map(chat => (
+          <ListItem key={chat.id} secondaryAction={actions('conversation', chat.id)}>
+            <ListItemText primary={chat.title} secondary={purgeDate(chat.purge_at)} />
+          </ListItem>
+        ))}
+      </List>
+      {messages.length > 0 && <Typography variant="h6" mt={2}>Сообщения</Typography>}
+      <List>
+        {messages.map(msg => (
+          <ListItem key={msg.id} secondaryAction={actions('message', Number(msg.id))}>
+            <ListItemText
+              primary={msg.content}
+              secondary={`${msg.conversation_title} · ${purgeDate(msg.purge_at)}`}
+              primaryTypographyProps={{ noWrap: true }}
+              sx={{ pr: 10 }}
+            />
+          </ListItem>
+        ))}
+      </List>
+    </Box>
+  );
+};
+
+export default TrashPage;
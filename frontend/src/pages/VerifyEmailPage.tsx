@@ -0,0 +1,33 @@
+import React, { useEffect, useRef, useState } from 'react';
+import { Box, Typography, Link, Paper } from '@mui/material';
+import { useSearchParams } from 'react-router-dom';
+import { verifyEmail } from '../api/auth';
+
+const VerifyEmailPage: React.FC = () => {
+  const [params] = useSearchParams();
+  const [status, setStatus] = useState<'pending' | 'done' | 'error'>('pending');
+  const started = useRef(false);
+
+  useEffect(() => {
+    // Токен одноразовый: второй запрос из StrictMode вернул бы ошибку.
+    if (started.current) return;
+    started.current = true;
+    verifyEmail(params.get('token') || '')
+      .then(() => setStatus('done'))
+      .catch(() => setStatus('error'));
+  }, [params]);
+
+  return (
+    <Box component={Paper} elevation={3} sx={{ p: 4, maxWidth: 400, mx: 'auto' }}>
+      <Typography variant="h5" mb={2} align="center">Подтверждение email</Typography>
+      {status === 'pending' && <Typography>Проверяем ссылку...</Typography>}
+      {status === 'done' && <Typography>Адрес подтверждён, теперь можно войти.</Typography>}
+      {status === 'error' && <Typography color="error">Ссылка недействительна или устарела.</Typography>}
+      <Box mt={2} textAlign="center">
+        <Link href="/login" underline="hover">Войти</Link>
+      </Box>
+    </Box>
+  );
+};
+
+export default VerifyEmailPage;
@@ -0,0 +1,349 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/internal/semantic"
+	"me-ai/pkg/req"
+	"me-ai/pkg/res"
+	"net/http"
+	"strconv"
+)
+
+const (
+	ActionListUsers           = "list_users"
+	ActionDisableUser         = "disable_user"
+	ActionEnableUser          = "enable_user"
+	ActionSetRole             = "set_role"
+	ActionViewUsage           = "view_usage"
+	ActionInspectConversation = "inspect_conversation"
+	ActionViewAuditLog        = "view_audit_log"
+	ActionViewLockouts        = "view_lockouts"
+	ActionViewEmbeddings      = "view_embeddings"
+	ActionBackfillEmbeddings  = "backfill_embeddings"
+)
+
+type AdminHandlerDeps struct {
+	UserRepository         models.UserStore
+	ConversationRepository models.ConversationStore
+	MessageRepository      models.MessageStore
+	RefreshTokenRepository models.RefreshTokenStore
+	UsageRepository        models.UsageStore
+	AuditLogRepository     models.AuditLogStore
+	LoginLockoutRepository models.LoginLockoutStore
+	SemanticService        *semantic.Service
+}
+
+type AdminHandler struct {
+	AdminHandlerDeps
+}
+
+// NewAdminHandler регистрирует /api/admin на защищённом роутере. Чтение доступно
+// администраторам и аудиторам, изменения — только администраторам. Каждое действие,
+// включая просмотр, записывается в admin_audit_log до выполнения.
+func NewAdminHandler(router *http.ServeMux, deps AdminHandlerDeps) {
+	handler := &AdminHandler{AdminHandlerDeps: deps}
+	read := func(h http.HandlerFunc) http.Handler {
+		return middleware.SessionOnly(middleware.RequireRole(models.RoleAdmin, models.RoleAuditor)(h))
+	}
+	write := func(h http.HandlerFunc) http.Handler {
+		return middleware.SessionOnly(middleware.RequireRole(models.RoleAdmin)(h))
+	}
+	router.Handle("/api/admin/users", read(handler.ListUsers()))                         // GET
+	router.Handle("/api/admin/users/disable", write(handler.DisableUser()))              // POST
+	router.Handle("/api/admin/users/role", write(handler.SetRole()))                     // POST
+	router.Handle("/api/admin/usage", read(handler.Usage()))                             // GET
+	router.Handle("/api/admin/conversations", read(handler.InspectConversation()))       // GET
+	router.Handle("/api/admin/audit", read(handler.AuditLog()))                          // GET
+	router.Handle("/api/admin/lockouts", read(handler.Lockouts()))                       // GET
+	router.Handle("/api/admin/embeddings", read(handler.EmbeddingStatus()))              // GET
+	router.Handle("/api/admin/embeddings/backfill", write(handler.BackfillEmbeddings())) // POST
+}
+
+func (handler *AdminHandler) actor(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, err := handler.UserRepository.FindByEmail(middleware.GetUserEmail(r))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return nil, false
+	}
+	// Роль в токене могла устареть: решает текущая роль в базе.
+	if user.Disabled() || user.Role != middleware.GetUserRole(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
+
+func (handler *AdminHandler) audit(w http.ResponseWriter, actor *models.User, action, targetType string, targetID *int, details string) bool {
+	_, err := handler.AuditLogRepository.Create(&models.AuditEntry{
+		ActorID:    &actor.ID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+	})
+	if err != nil {
+		log.Printf("Ошибка записи в журнал аудита: %v", err)
+		http.Error(w, "Failed to record audit entry", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func (handler *AdminHandler) ListUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := handler.actor(w, r)
+		if !ok || !handler.audit(w, actor, ActionListUsers, "user", nil, "") {
+			return
+		}
+		users, err := handler.UserRepository.List()
+		if err != nil {
+			http.Error(w, "Failed to get users", http.StatusInternalServerError)
+			return
+		}
+		data := make([]UserResponse, 0, len(users))
+		for i := range users {
+			data = append(data, toUserResponse(&users[i]))
+		}
+		res.Json(w, data, 200)
+	}
+}
+
+func (handler *AdminHandler) DisableUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		actor, ok := handler.actor(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[DisableUserRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if body.ID == actor.ID {
+			http.Error(w, "Cannot disable your own account", http.StatusBadRequest)
+			return
+		}
+		action := ActionEnableUser
+		if body.Disabled {
+			action = ActionDisableUser
+		}
+		if !handler.audit(w, actor, action, "user", &body.ID, "") {
+			return
+		}
+		if err := handler.UserRepository.SetDisabled(body.ID, body.Disabled); err != nil {
+			writeError(w, err, "User not found")
+			return
+		}
+		if body.Disabled {
+			if err := handler.RefreshTokenRepository.RevokeAllForUser(body.ID); err != nil {
+				log.Printf("Ошибка отзыва сессий пользователя %d: %v", body.ID, err)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *AdminHandler) SetRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		actor, ok := handler.actor(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[SetRoleRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if body.ID == actor.ID {
+			http.Error(w, "Cannot change your own role", http.StatusBadRequest)
+			return
+		}
+		if !handler.audit(w, actor, ActionSetRole, "user", &body.ID, "role="+body.Role) {
+			return
+		}
+		if err := handler.UserRepository.SetRole(body.ID, body.Role); err != nil {
+			writeError(w, err, "User not found")
+			return
+		}
+		// Старые access token'ы несут прежнюю роль; новая роль попадёт в токен при refresh.
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *AdminHandler) Usage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := handler.actor(w, r)
+		if !ok || !handler.audit(w, actor, ActionViewUsage, "usage", nil, "") {
+			return
+		}
+		usage, err := handler.UsageRepository.ByUser()
+		if err != nil {
+			http.Error(w, "Failed to get usage", http.StatusInternalServerError)
+			return
+		}
+		if usage == nil {
+			usage = []models.UserUsage{}
+		}
+		res.Json(w, usage, 200)
+	}
+}
+
+func (handler *AdminHandler) InspectConversation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := handler.actor(w, r)
+		if !ok {
+			return
+		}
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			http.Error(w, "Invalid id", http.StatusBadRequest)
+			return
+		}
+		if !handler.audit(w, actor, ActionInspectConversation, "conversation", &id, "") {
+			return
+		}
+		convo, err := handler.ConversationRepository.FindAnyByID(id)
+		if err != nil {
+			writeError(w, err, "Conversation not found")
+			return
+		}
+		owner, err := handler.UserRepository.FindByID(convo.UserID)
+		if err != nil {
+			writeError(w, fmt.Errorf("find owner of conversation %d: %w", id, err), "")
+			return
+		}
+		msgs, err := handler.MessageRepository.ListByConversation(id)
+		if err != nil {
+			writeError(w, err, "")
+			return
+		}
+		if msgs == nil {
+			msgs = []models.Message{}
+		}
+		res.Json(w, ConversationResponse{
+			Conversation: *convo,
+			Owner:        toUserResponse(owner),
+			Messages:     msgs,
+		}, 200)
+	}
+}
+
+func (handler *AdminHandler) AuditLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := handler.actor(w, r)
+		if !ok {
+			return
+		}
+		limit := listLimit(r)
+		if !handler.audit(w, actor, ActionViewAuditLog, "audit_log", nil, "") {
+			return
+		}
+		entries, err := handler.AuditLogRepository.List(limit)
+		if err != nil {
+			http.Error(w, "Failed to get audit log", http.StatusInternalServerError)
+			return
+		}
+		if entries == nil {
+			entries = []models.AuditEntry{}
+		}
+		res.Json(w, entries, 200)
+	}
+}
+
+func (handler *AdminHandler) Lockouts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := handler.actor(w, r)
+		if !ok {
+			return
+		}
+		limit := listLimit(r)
+		if !handler.audit(w, actor, ActionViewLockouts, "login_lockout", nil, "") {
+			return
+		}
+		lockouts, err := handler.LoginLockoutRepository.List(limit)
+		if err != nil {
+			http.Error(w, "Failed to get lockouts", http.StatusInternalServerError)
+			return
+		}
+		if lockouts == nil {
+			lockouts = []models.LoginLockout{}
+		}
+		res.Json(w, lockouts, 200)
+	}
+}
+
+// EmbeddingStatus показывает, сколько сообщений проиндексировано для семантического поиска.
+func (handler *AdminHandler) EmbeddingStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := handler.actor(w, r)
+		if !ok || !handler.audit(w, actor, ActionViewEmbeddings, "message_embedding", nil, "") {
+			return
+		}
+		status, err := handler.SemanticService.Status()
+		if err != nil {
+			writeEmbeddingsError(w, err)
+			return
+		}
+		res.Json(w, status, 200)
+	}
+}
+
+// BackfillEmbeddings запускает фоновую индексацию всей истории без векторов текущей модели.
+func (handler *AdminHandler) BackfillEmbeddings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		actor, ok := handler.actor(w, r)
+		if !ok || !handler.audit(w, actor, ActionBackfillEmbeddings, "message_embedding", nil, handler.SemanticService.Config.Model) {
+			return
+		}
+		started, err := handler.SemanticService.Backfill()
+		if err != nil {
+			writeEmbeddingsError(w, err)
+			return
+		}
+		status, err := handler.SemanticService.Status()
+		if err != nil {
+			writeEmbeddingsError(w, err)
+			return
+		}
+		res.Json(w, semantic.BackfillResponse{Status: status, Started: started}, http.StatusAccepted)
+	}
+}
+
+func writeEmbeddingsError(w http.ResponseWriter, err error) {
+	if err.Error() == semantic.ErrDisabled {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writeError(w, err, "")
+}
+
+func listLimit(r *http.Request) int {
+	limit := 100
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	return limit
+}
+
+func writeError(w http.ResponseWriter, err error, notFound string) {
+	if errors.Is(err, models.ErrNotFound) && notFound != "" {
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+	log.Printf("Ошибка админки: %v", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
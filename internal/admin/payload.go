@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"me-ai/internal/models"
+	"time"
+)
+
+type UserResponse struct {
+	ID         int        `json:"id"`
+	Email      string     `json:"email"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+func toUserResponse(u *models.User) UserResponse {
+	return UserResponse{
+		ID:         u.ID,
+		Email:      u.Email,
+		Name:       u.Name,
+		Role:       u.Role,
+		DisabledAt: u.DisabledAt,
+		CreatedAt:  u.CreatedAt,
+	}
+}
+
+type DisableUserRequest struct {
+	ID       int  `json:"id" validate:"required"`
+	Disabled bool `json:"disabled"`
+}
+
+type SetRoleRequest struct {
+	ID   int    `json:"id" validate:"required"`
+	Role string `json:"role" validate:"required,oneof=user admin auditor"`
+}
+
+type ConversationResponse struct {
+	Conversation models.Conversation `json:"conversation"`
+	Owner        UserResponse        `json:"owner"`
+	Messages     []models.Message    `json:"messages"`
+}
@@ -0,0 +1,100 @@
+package apikey
+
+import (
+	"errors"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/pkg/req"
+	"me-ai/pkg/res"
+	"net/http"
+)
+
+type APIKeyHandlerDeps struct {
+	Service        *Service
+	UserRepository models.UserStore
+}
+
+type APIKeyHandler struct {
+	Service        *Service
+	UserRepository models.UserStore
+}
+
+// NewAPIKeyHandler регистрирует маршруты на защищённом роутере. Управлять ключами
+// можно только из пользовательской сессии, но не самим API-ключом.
+func NewAPIKeyHandler(router *http.ServeMux, deps APIKeyHandlerDeps) {
+	handler := &APIKeyHandler{
+		Service:        deps.Service,
+		UserRepository: deps.UserRepository,
+	}
+	router.Handle("/api/keys", middleware.SessionOnly(handler.List()))          // GET
+	router.Handle("/api/keys/create", middleware.SessionOnly(handler.Create())) // POST
+	router.Handle("/api/keys/revoke", middleware.SessionOnly(handler.Revoke())) // POST
+}
+
+func (handler *APIKeyHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		keys, err := handler.Service.List(user.ID)
+		if err != nil {
+			http.Error(w, "Failed to get API keys", http.StatusInternalServerError)
+			return
+		}
+		data := make([]APIKeyResponse, 0, len(keys))
+		for i := range keys {
+			data = append(data, toResponse(&keys[i]))
+		}
+		res.Json(w, data, 200)
+	}
+}
+
+func (handler *APIKeyHandler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[CreateRequest](&w, r)
+		if err != nil {
+			return
+		}
+		raw, key, err := handler.Service.Create(user.ID, body.Name, body.Scopes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res.Json(w, CreateResponse{Key: raw, APIKey: toResponse(key)}, http.StatusCreated)
+	}
+}
+
+func (handler *APIKeyHandler) Revoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[RevokeRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.Service.Revoke(body.ID, user.ID); err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				http.Error(w, "API key not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
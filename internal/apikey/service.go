@@ -0,0 +1,105 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidKey   = "invalid API key"
+	ErrUnknownScope = "unknown scope"
+	ErrNoScopes     = "at least one scope is required"
+)
+
+type Service struct {
+	APIKeyRepository models.APIKeyStore
+	UserRepository   models.UserStore
+}
+
+func NewService(apiKeyRepository models.APIKeyStore, userRepository models.UserStore) *Service {
+	return &Service{
+		APIKeyRepository: apiKeyRepository,
+		UserRepository:   userRepository,
+	}
+}
+
+// Create выпускает ключ вида meai_<prefix>_<secret>. Полный ключ возвращается
+// только здесь: в базе остаются prefix и SHA-256 хеш.
+func (s *Service) Create(userID int, name string, scopes []string) (string, *models.APIKey, error) {
+	if len(scopes) == 0 {
+		return "", nil, errors.New(ErrNoScopes)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(middleware.Scopes, scope) {
+			return "", nil, errors.New(ErrUnknownScope + ": " + scope)
+		}
+	}
+	prefixBytes := make([]byte, 4)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", nil, err
+	}
+	prefix := middleware.APIKeyPrefix + hex.EncodeToString(prefixBytes)
+	raw := prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	key, err := s.APIKeyRepository.Create(&models.APIKey{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  prefix,
+		KeyHash: hashKey(raw),
+		Scopes:  strings.Join(scopes, " "),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return raw, key, nil
+}
+
+// Verify реализует middleware.APIKeyVerifier.
+func (s *Service) Verify(raw string) (string, []string, error) {
+	// Секрет в base64url может содержать "_", а hex-префикс — нет.
+	id, _, ok := strings.Cut(strings.TrimPrefix(raw, middleware.APIKeyPrefix), "_")
+	if !ok || id == "" {
+		return "", nil, errors.New(ErrInvalidKey)
+	}
+	key, err := s.APIKeyRepository.FindByPrefix(middleware.APIKeyPrefix + id)
+	if err != nil {
+		return "", nil, errors.New(ErrInvalidKey)
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashKey(raw))) != 1 || key.RevokedAt != nil {
+		return "", nil, errors.New(ErrInvalidKey)
+	}
+	user, err := s.UserRepository.FindByID(key.UserID)
+	if err != nil || user.Disabled() {
+		return "", nil, errors.New(ErrInvalidKey)
+	}
+	if err := s.APIKeyRepository.TouchLastUsed(key.ID); err != nil {
+		log.Printf("Ошибка обновления last_used_at API-ключа %d: %v", key.ID, err)
+	}
+	return user.Email, key.ScopeList(), nil
+}
+
+func (s *Service) List(userID int) ([]models.APIKey, error) {
+	return s.APIKeyRepository.ListByUser(userID)
+}
+
+func (s *Service) Revoke(id, userID int) error {
+	return s.APIKeyRepository.Revoke(id, userID)
+}
+
+func hashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
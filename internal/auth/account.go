@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"me-ai/internal/models"
+	"me-ai/internal/twofactor"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// checkPassword проверяет пароль уже вошедшего пользователя. Неудачи считаются
+// тем же ограничителем, что и вход: украденный access token не даёт перебирать пароль.
+func (service *AuthService) checkPassword(user *models.User, password, ip string) error {
+	key := strings.ToLower(user.Email)
+	if wait := service.Throttle.Wait(key, ip); wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		service.loginFailed(key, ip, &user.ID)
+		return errors.New(ErrWrongPassword)
+	}
+	return nil
+}
+
+// Profile — профиль пользователя с привязанными входами через провайдеров.
+func (service *AuthService) Profile(userID int) (*ProfileResponse, error) {
+	user, err := service.UserRepository.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	profile := &ProfileResponse{
+		ID:               user.ID,
+		Email:            user.Email,
+		Name:             user.Name,
+		Role:             user.Role,
+		EmailVerified:    user.EmailVerifiedAt != nil,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+		CreatedAt:        user.CreatedAt,
+		Identities:       []Identity{},
+	}
+	if user.PendingEmail != nil {
+		profile.PendingEmail = *user.PendingEmail
+	}
+	identities, err := service.IdentityRepository.ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range identities {
+		profile.Identities = append(profile.Identities, Identity{Provider: i.Provider, Email: i.Email, CreatedAt: i.CreatedAt})
+	}
+	return profile, nil
+}
+
+func (service *AuthService) UpdateName(user *models.User, name string) error {
+	return service.UserRepository.UpdateName(user.ID, strings.TrimSpace(name))
+}
+
+// RequestEmailChange отправляет ссылку подтверждения на новый адрес. До перехода
+// по ссылке вход по-прежнему по старому адресу. Текущий адрес отменяет смену.
+func (service *AuthService) RequestEmailChange(ctx context.Context, user *models.User, email string) error {
+	email = strings.TrimSpace(email)
+	if email == user.Email {
+		if err := service.UserTokenRepository.InvalidateForUser(user.ID, models.TokenPurposeEmailChange); err != nil {
+			return err
+		}
+		return service.UserRepository.SetPendingEmail(user.ID, nil)
+	}
+	existing, err := service.findByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errors.New(ErrUserExists)
+	}
+	if err := service.UserRepository.SetPendingEmail(user.ID, &email); err != nil {
+		return err
+	}
+	raw, err := service.issueUserToken(user.ID, models.TokenPurposeEmailChange, service.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := service.AppURL + "/confirm-email?token=" + url.QueryEscape(raw)
+	return service.Mailer.Send(ctx, emailChangeMessage(email, link))
+}
+
+// ConfirmEmailChange применяет новый адрес по ссылке из письма и сообщает об этом на прежний.
+func (service *AuthService) ConfirmEmailChange(ctx context.Context, raw string) error {
+	token, err := service.consumeUserToken(raw, models.TokenPurposeEmailChange)
+	if err != nil {
+		return err
+	}
+	user, err := service.UserRepository.FindByID(token.UserID)
+	if err != nil || user.PendingEmail == nil {
+		return errors.New(ErrInvalidEmailToken)
+	}
+	email := *user.PendingEmail
+	// Адрес могли занять, пока письмо шло.
+	existing, err := service.findByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errors.New(ErrUserExists)
+	}
+	if err := service.UserRepository.ChangeEmail(user.ID, email); err != nil {
+		return err
+	}
+	log.Printf("Email изменён: user_id=%d", user.ID)
+	if err := service.Mailer.Send(ctx, emailChangedMessage(user.Email, email)); err != nil {
+		log.Printf("Не удалось уведомить о смене email: user_id=%d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ChangePassword меняет пароль по текущему и завершает все сессии, кроме этой.
+func (service *AuthService) ChangePassword(user *models.User, current, password, session, ip string) error {
+	if err := service.checkPassword(user, current, ip); err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := service.UserRepository.UpdatePassword(user.ID, string(hashedPassword)); err != nil {
+		return err
+	}
+	if err := service.UserTokenRepository.InvalidateForUser(user.ID, models.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+	log.Printf("Пароль изменён: user_id=%d", user.ID)
+	return service.RefreshTokenRepository.RevokeOtherSessions(user.ID, session)
+}
+
+func (service *AuthService) ListSessions(user *models.User) ([]models.Session, error) {
+	return service.RefreshTokenRepository.ListSessions(user.ID)
+}
+
+// RevokeSession завершает сессию: её refresh token больше не продлевается,
+// уже выданный access token доживает свой короткий срок.
+func (service *AuthService) RevokeSession(user *models.User, session string) error {
+	err := service.RefreshTokenRepository.RevokeSession(user.ID, session)
+	if errors.Is(err, models.ErrNotFound) {
+		return errors.New(ErrSessionNotFound)
+	}
+	return err
+}
+
+func (service *AuthService) RevokeOtherSessions(user *models.User, session string) error {
+	return service.RefreshTokenRepository.RevokeOtherSessions(user.ID, session)
+}
+
+// DeleteAccount безвозвратно удаляет пользователя со всеми беседами и сообщениями.
+// Нужны пароль и, если включена 2FA, код второго фактора.
+func (service *AuthService) DeleteAccount(user *models.User, password, code, ip string) error {
+	if err := service.checkPassword(user, password, ip); err != nil {
+		return err
+	}
+	if user.TwoFactorEnabled() {
+		if err := service.TwoFactor.Verify(user, code); err != nil {
+			if err.Error() != twofactor.ErrInvalidCode {
+				return err
+			}
+			service.loginFailed(strings.ToLower(user.Email), ip, &user.ID)
+			return errors.New(ErrInvalidTwoFactorCode)
+		}
+	}
+	if err := service.UserRepository.Delete(user.ID); err != nil {
+		return err
+	}
+	log.Printf("Аккаунт удалён: user_id=%d", user.ID)
+	return nil
+}
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/internal/transcript"
+	"me-ai/pkg/req"
+	"me-ai/pkg/res"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type AccountHandlerDeps struct {
+	AuthService            *AuthService
+	ConversationRepository models.ConversationStore
+	MessageRepository      models.MessageStore
+	// TrustProxyHeaders — как у входа: IP для ограничителя попыток.
+	TrustProxyHeaders bool
+}
+
+type AccountHandler struct {
+	AccountHandlerDeps
+}
+
+// NewAccountHandler регистрирует самообслуживание аккаунта на защищённом роутере.
+// Всё доступно только из пользовательской сессии: API-ключ не может сменить
+// пароль или удалить владельца. Подтверждение нового email публичное и живёт в NewAuthHandler.
+func NewAccountHandler(router *http.ServeMux, deps AccountHandlerDeps) {
+	handler := &AccountHandler{AccountHandlerDeps: deps}
+	router.Handle("/api/account", middleware.SessionOnly(handler.Profile()))                                    // GET
+	router.Handle("/api/account/update", middleware.SessionOnly(handler.UpdateProfile()))                       // POST
+	router.Handle("/api/account/password", middleware.SessionOnly(handler.ChangePassword()))                    // POST
+	router.Handle("/api/account/sessions", middleware.SessionOnly(handler.Sessions()))                          // GET
+	router.Handle("/api/account/sessions/revoke", middleware.SessionOnly(handler.RevokeSession()))              // POST
+	router.Handle("/api/account/sessions/revoke-others", middleware.SessionOnly(handler.RevokeOtherSessions())) // POST
+	router.Handle("/api/account/export", middleware.SessionOnly(handler.Export()))                              // GET
+	router.Handle("/api/account/delete", middleware.SessionOnly(handler.Delete()))                              // POST
+}
+
+func (handler *AccountHandler) currentUser(w http.ResponseWriter, r *http.Request, method string) (*models.User, bool) {
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	return middleware.CurrentUser(w, r, handler.AuthService.UserRepository)
+}
+
+func (handler *AccountHandler) ip(r *http.Request) string {
+	return req.ClientIP(r, handler.TrustProxyHeaders)
+}
+
+func (handler *AccountHandler) Profile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r, http.MethodGet)
+		if !ok {
+			return
+		}
+		handler.writeProfile(w, user.ID)
+	}
+}
+
+func (handler *AccountHandler) UpdateProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r, http.MethodPost)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[UpdateProfileRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if body.Name != nil {
+			if strings.TrimSpace(*body.Name) == "" {
+				http.Error(w, "Name must not be empty", http.StatusBadRequest)
+				return
+			}
+			if err := handler.AuthService.UpdateName(user, *body.Name); err != nil {
+				writeAccountError(w, err)
+				return
+			}
+		}
+		if body.Email != nil {
+			if err := handler.AuthService.RequestEmailChange(r.Context(), user, *body.Email); err != nil {
+				writeAccountError(w, err)
+				return
+			}
+		}
+		handler.writeProfile(w, user.ID)
+	}
+}
+
+func (handler *AccountHandler) ChangePassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r, http.MethodPost)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[ChangePasswordRequest](&w, r)
+		if err != nil {
+			return
+		}
+		err = handler.AuthService.ChangePassword(user, body.CurrentPassword, body.NewPassword, middleware.GetSessionID(r), handler.ip(r))
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *AccountHandler) Sessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r, http.MethodGet)
+		if !ok {
+			return
+		}
+		sessions, err := handler.AuthService.ListSessions(user)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		current := middleware.GetSessionID(r)
+		data := make([]SessionResponse, 0, len(sessions))
+		for _, s := range sessions {
+			data = append(data, SessionResponse{Session: s, Current: s.ID == current})
+		}
+		res.Json(w, data, 200)
+	}
+}
+
+func (handler *AccountHandler) RevokeSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r, http.MethodPost)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[RevokeSessionRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.AuthService.RevokeSession(user, body.ID); err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *AccountHandler) RevokeOtherSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r, http.MethodPost)
+		if !ok {
+			return
+		}
+		if err := handler.AuthService.RevokeOtherSessions(user, middleware.GetSessionID(r)); err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Export отдаёт профиль, беседы и сообщения файлом — чтобы сохранить данные перед удалением аккаунта.
+func (handler *AccountHandler) Export() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r, http.MethodGet)
+		if !ok {
+			return
+		}
+		export, err := handler.export(user)
+		if err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="me-ai-export.json"`)
+		res.Json(w, export, 200)
+	}
+}
+
+func (handler *AccountHandler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r, http.MethodPost)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[DeleteAccountRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.AuthService.DeleteAccount(user, body.Password, body.Code, handler.ip(r)); err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *AccountHandler) writeProfile(w http.ResponseWriter, userID int) {
+	profile, err := handler.AuthService.Profile(userID)
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	res.Json(w, profile, 200)
+}
+
+func (handler *AccountHandler) export(user *models.User) (*AccountExport, error) {
+	profile, err := handler.AuthService.Profile(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	convos, err := handler.ConversationRepository.ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	export := &AccountExport{
+		ExportedAt:    time.Now().UTC(),
+		Profile:       *profile,
+		Conversations: make([]transcript.Conversation, 0, len(convos)),
+	}
+	for _, c := range convos {
+		msgs, err := handler.MessageRepository.ListByConversation(c.ID)
+		if err != nil {
+			return nil, err
+		}
+		export.Conversations = append(export.Conversations, transcript.FromModels(c, msgs))
+	}
+	return export, nil
+}
+
+// writeAccountError: неверный пароль или код — 400, а не 401, чтобы клиент
+// не принял его за истёкшую сессию и не пошёл обновлять токен.
+func writeAccountError(w http.ResponseWriter, err error) {
+	writeThrottled(w, err)
+	switch err.Error() {
+	case ErrWrongPassword, ErrInvalidTwoFactorCode:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrUserExists:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case ErrSessionNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrTooManyAttempts:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		log.Printf("Ошибка управления аккаунтом: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
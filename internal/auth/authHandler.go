@@ -1,32 +1,85 @@
 package auth
 
 import (
+	"errors"
+	"log"
+	"math"
 	"me-ai/configs"
 	"me-ai/internal/models"
+	"me-ai/internal/twofactor"
 	"me-ai/pkg/jwt"
+	"me-ai/pkg/mailer"
+	"me-ai/pkg/oidc"
 	"me-ai/pkg/req"
 	"me-ai/pkg/res"
 	"net/http"
+	"strconv"
 )
 
 type AuthHandlerDeps struct {
 	*configs.Config
+	UserRepository         models.UserStore
+	RefreshTokenRepository models.RefreshTokenStore
+	UserTokenRepository    models.UserTokenStore
+	JWT                    *jwt.JWT
+	Mailer                 mailer.Mailer
+	LoginLockoutRepository models.LoginLockoutStore
+	TwoFactor              *twofactor.Service
+	IdentityRepository     models.IdentityStore
 }
 
 type AuthHandler struct {
 	*configs.Config
 	AuthService *AuthService
+	OIDC        *oidc.Registry
+
+	oidcFlows *oidcFlows
 }
 
-func NewAuthHandler(router *http.ServeMux, deps AuthHandlerDeps) {
-	userRepo := &models.UserRepository{}
-	service := NewAuthService(userRepo)
+func NewAuthHandler(router *http.ServeMux, deps AuthHandlerDeps) *AuthHandler {
+	service := NewAuthService(AuthServiceDeps{
+		UserRepository:         deps.UserRepository,
+		RefreshTokenRepository: deps.RefreshTokenRepository,
+		JWT:                    deps.JWT,
+		RefreshTTL:             deps.Config.Auth.RefreshTTL,
+		UserTokenRepository:    deps.UserTokenRepository,
+		Mailer:                 deps.Mailer,
+		LoginLockoutRepository: deps.LoginLockoutRepository,
+		TwoFactor:              deps.TwoFactor,
+		IdentityRepository:     deps.IdentityRepository,
+		Throttle: NewLoginThrottle(LoginThrottleConfig{
+			MaxAttempts:   deps.Config.Auth.LoginMaxAttempts,
+			MaxIPAttempts: deps.Config.Auth.LoginMaxIPAttempts,
+			BaseDelay:     deps.Config.Auth.LoginBaseDelay,
+			Lockout:       deps.Config.Auth.LoginLockout,
+		}),
+
+		AppURL:                    deps.Config.Mail.AppURL,
+		EmailVerificationRequired: deps.Config.Auth.EmailVerificationRequired,
+		VerificationTTL:           deps.Config.Auth.EmailVerificationTTL,
+		PasswordResetTTL:          deps.Config.Auth.PasswordResetTTL,
+	})
 	handler := &AuthHandler{
 		Config:      deps.Config,
 		AuthService: service,
+		OIDC:        NewOIDCRegistry(deps.Config.OIDC),
+		oidcFlows:   newOIDCFlows(),
 	}
 	router.HandleFunc("/api/auth/login", handler.Login())
 	router.HandleFunc("/api/auth/register", handler.Register())
+	router.HandleFunc("/api/auth/refresh", handler.Refresh())
+	router.HandleFunc("/api/auth/logout", handler.Logout())
+	router.HandleFunc("/api/auth/2fa/verify", handler.VerifyTwoFactor())
+	router.HandleFunc("/api/auth/verify", handler.VerifyEmail())
+	router.HandleFunc("/api/auth/verify/resend", handler.ResendVerification())
+	router.HandleFunc("/api/auth/password/forgot", handler.ForgotPassword())
+	router.HandleFunc("/api/auth/password/reset", handler.ResetPassword())
+	router.HandleFunc("/api/auth/oidc/providers", handler.OIDCProviders())
+	router.HandleFunc("/api/auth/oidc/login", handler.OIDCLogin())
+	router.HandleFunc("/api/auth/oidc/callback", handler.OIDCCallback())
+	router.HandleFunc("/api/auth/email/confirm", handler.ConfirmEmailChange())
+	router.HandleFunc("/.well-known/jwks.json", handler.JWKS())
+	return handler
 }
 
 func (handler *AuthHandler) Login() http.HandlerFunc {
@@ -35,46 +88,227 @@ func (handler *AuthHandler) Login() http.HandlerFunc {
 		if err != nil {
 			return
 		}
-		email, err := handler.AuthService.Login(body.Email, body.Password)
+		user, err := handler.AuthService.Login(body.Email, body.Password, req.ClientIP(r, handler.Config.Auth.TrustProxyHeaders))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			writeThrottled(w, err)
+			http.Error(w, err.Error(), authErrorStatus(err))
 			return
 		}
-		token, err := jwt.NewJWT(handler.Config.Auth.Secret).Create(jwt.JWTData{
-			Login: email,
-		})
+		if user.TwoFactorEnabled() {
+			challenge, err := handler.AuthService.IssueChallenge(user)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res.Json(w, LoginResponse{TwoFactorRequired: true, ChallengeToken: challenge}, 200)
+			return
+		}
+		handler.writeSession(w, r, user)
+	}
+}
+
+func (handler *AuthHandler) VerifyTwoFactor() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[TwoFactorVerifyRequest](&w, r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		data := LoginResponse{
-			Token: token,
+		user, err := handler.AuthService.VerifyChallenge(body.ChallengeToken, body.Code, req.ClientIP(r, handler.Config.Auth.TrustProxyHeaders))
+		if err != nil {
+			writeThrottled(w, err)
+			http.Error(w, err.Error(), authErrorStatus(err))
+			return
 		}
-		res.Json(w, data, 200)
+		handler.writeSession(w, r, user)
 	}
 }
 
+func (handler *AuthHandler) writeSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	tokens, err := handler.AuthService.IssueTokens(user, handler.clientInfo(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+	res.Json(w, data, 200)
+}
+
 func (handler *AuthHandler) Register() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		body, err := req.HandleBody[RegisterRequest](&w, r)
 		if err != nil {
 			return
 		}
-		email, err := handler.AuthService.Register(body.Email, body.Password, body.Name)
+		user, err := handler.AuthService.Register(body.Email, body.Password, body.Name)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
-		token, err := jwt.NewJWT(handler.Config.Auth.Secret).Create(jwt.JWTData{
-			Login: email,
-		})
+		if err := handler.AuthService.SendVerification(r.Context(), user); err != nil {
+			log.Printf("Не удалось отправить письмо подтверждения: user_id=%d: %v", user.ID, err)
+		}
+		if handler.AuthService.EmailVerificationRequired {
+			res.Json(w, RegisterResponse{VerificationRequired: true}, 200)
+			return
+		}
+		tokens, err := handler.AuthService.IssueTokens(user, handler.clientInfo(r))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		data := RegisterResponse{
-			Token: token,
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+			ExpiresIn:    tokens.ExpiresIn,
 		}
 		res.Json(w, data, 200)
 	}
 }
+
+func (handler *AuthHandler) Refresh() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[RefreshRequest](&w, r)
+		if err != nil {
+			return
+		}
+		tokens, err := handler.AuthService.Refresh(body.RefreshToken, handler.clientInfo(r))
+		if err != nil {
+			http.Error(w, err.Error(), authErrorStatus(err))
+			return
+		}
+		data := RefreshResponse{
+			Token:        tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+			ExpiresIn:    tokens.ExpiresIn,
+		}
+		res.Json(w, data, 200)
+	}
+}
+
+func (handler *AuthHandler) Logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[LogoutRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.AuthService.Logout(body.RefreshToken); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *AuthHandler) VerifyEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[VerifyEmailRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.AuthService.VerifyEmail(body.Token); err != nil {
+			http.Error(w, err.Error(), authErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ResendVerification и ForgotPassword всегда отвечают 204, чтобы по ответу
+// нельзя было узнать, зарегистрирован ли адрес. Ошибки отправки только логируются.
+func (handler *AuthHandler) ResendVerification() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[EmailRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.AuthService.ResendVerification(r.Context(), body.Email); err != nil {
+			log.Printf("Не удалось отправить письмо подтверждения: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *AuthHandler) ForgotPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[EmailRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.AuthService.RequestPasswordReset(r.Context(), body.Email); err != nil {
+			log.Printf("Не удалось отправить письмо для сброса пароля: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *AuthHandler) ResetPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[ResetPasswordRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.AuthService.ResetPassword(body.Token, body.Password); err != nil {
+			http.Error(w, err.Error(), authErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ConfirmEmailChange публичный: ссылку из письма могут открыть там, где пользователь не вошёл.
+func (handler *AuthHandler) ConfirmEmailChange() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[VerifyEmailRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.AuthService.ConfirmEmailChange(r.Context(), body.Token); err != nil {
+			http.Error(w, err.Error(), authErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *AuthHandler) JWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		res.Json(w, handler.AuthService.JWT.JWKS(), 200)
+	}
+}
+
+func (handler *AuthHandler) clientInfo(r *http.Request) ClientInfo {
+	return ClientInfo{
+		UserAgent: r.UserAgent(),
+		IP:        req.ClientIP(r, handler.Config.Auth.TrustProxyHeaders),
+	}
+}
+
+// writeThrottled выставляет Retry-After, если вход временно запрещён.
+func writeThrottled(w http.ResponseWriter, err error) {
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	}
+}
+
+func authErrorStatus(err error) int {
+	switch err.Error() {
+	case ErrAccountDisabled, ErrEmailNotVerified:
+		return http.StatusForbidden
+	case ErrInvalidEmailToken:
+		return http.StatusBadRequest
+	case ErrUserExists:
+		return http.StatusConflict
+	case ErrTooManyAttempts:
+		return http.StatusTooManyRequests
+	case ErrWrongCredetials, ErrInvalidRefreshToken, ErrRefreshTokenReused, ErrInvalidChallenge, ErrInvalidTwoFactorCode:
+		return http.StatusUnauthorized
+	}
+	log.Printf("Ошибка авторизации: %v", err)
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"me-ai/internal/models"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mailTimeout ограничивает фоновую отправку письма, которую уже не отменит запрос.
+const mailTimeout = time.Minute
+
+// SendVerification выпускает новый токен подтверждения email (старые гасятся) и отправляет письмо.
+func (service *AuthService) SendVerification(ctx context.Context, user *models.User) error {
+	raw, err := service.issueUserToken(user.ID, models.TokenPurposeEmailVerification, service.VerificationTTL)
+	if err != nil {
+		return err
+	}
+	link := service.AppURL + "/verify-email?token=" + url.QueryEscape(raw)
+	return service.Mailer.Send(ctx, verificationMessage(user.Email, link))
+}
+
+// ResendVerification повторно отправляет письмо. Для неизвестного или уже
+// подтверждённого адреса молча ничего не делает, чтобы не раскрывать наличие аккаунта.
+func (service *AuthService) ResendVerification(ctx context.Context, email string) error {
+	user, err := service.findByEmail(email)
+	if err != nil || user == nil || user.EmailVerifiedAt != nil || user.Disabled() {
+		return err
+	}
+	service.mailInBackground(ctx, "подтверждения", func(ctx context.Context) error {
+		return service.SendVerification(ctx, user)
+	})
+	return nil
+}
+
+func (service *AuthService) VerifyEmail(raw string) error {
+	token, err := service.consumeUserToken(raw, models.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	return service.UserRepository.MarkEmailVerified(token.UserID)
+}
+
+// RequestPasswordReset отправляет ссылку для сброса пароля. Как и
+// ResendVerification, не сообщает, существует ли аккаунт.
+func (service *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := service.findByEmail(email)
+	if err != nil || user == nil || user.Disabled() {
+		return err
+	}
+	service.mailInBackground(ctx, "для сброса пароля", func(ctx context.Context) error {
+		raw, err := service.issueUserToken(user.ID, models.TokenPurposePasswordReset, service.PasswordResetTTL)
+		if err != nil {
+			return err
+		}
+		link := service.AppURL + "/reset-password?token=" + url.QueryEscape(raw)
+		return service.Mailer.Send(ctx, passwordResetMessage(user.Email, link))
+	})
+	return nil
+}
+
+// mailInBackground выпускает токен и отправляет письмо уже после ответа: иначе
+// по времени ответа было бы видно, что адрес зарегистрирован. Ошибки только логируются.
+func (service *AuthService) mailInBackground(ctx context.Context, what string, send func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mailTimeout)
+	go func() {
+		defer cancel()
+		if err := send(ctx); err != nil {
+			log.Printf("Не удалось отправить письмо %s: %v", what, err)
+		}
+	}()
+}
+
+// ResetPassword меняет пароль по токену из письма и завершает все сессии пользователя.
+// Письмо дошло до владельца адреса, поэтому email заодно считается подтверждённым.
+func (service *AuthService) ResetPassword(raw, password string) error {
+	token, err := service.consumeUserToken(raw, models.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := service.UserRepository.UpdatePassword(token.UserID, string(hashedPassword)); err != nil {
+		return err
+	}
+	if err := service.UserTokenRepository.InvalidateForUser(token.UserID, models.TokenPurposePasswordReset); err != nil {
+		return err
+	}
+	if err := service.RefreshTokenRepository.RevokeAllForUser(token.UserID); err != nil {
+		return err
+	}
+	log.Printf("Пароль сброшен по email: user_id=%d", token.UserID)
+	return service.UserRepository.MarkEmailVerified(token.UserID)
+}
+
+func (service *AuthService) issueUserToken(userID int, purpose string, ttl time.Duration) (string, error) {
+	if err := service.UserTokenRepository.InvalidateForUser(userID, purpose); err != nil {
+		return "", err
+	}
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	_, err = service.UserTokenRepository.Create(&models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+func (service *AuthService) consumeUserToken(raw, purpose string) (*models.UserToken, error) {
+	token, err := service.UserTokenRepository.Consume(hashToken(raw), purpose, time.Now().UTC())
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, errors.New(ErrInvalidEmailToken)
+	}
+	return token, err
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"me-ai/configs"
+	"me-ai/pkg/jwt"
+	"slices"
+	"sort"
+)
+
+// NewJWT собирает набор ключей подписи из конфигурации.
+func NewJWT(cfg configs.AuthConfig) (*jwt.JWT, error) {
+	var signing *jwt.Key
+	var verify []*jwt.Key
+
+	switch cfg.Algorithm {
+	case "", jwt.AlgHS256:
+		if cfg.Secret == "" {
+			return nil, errors.New("TOKEN is required for HS256")
+		}
+		signing = jwt.HMACKey(cfg.KeyID, cfg.Secret)
+	case jwt.AlgRS256, jwt.AlgEdDSA:
+		if cfg.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", cfg.Algorithm)
+		}
+		key, err := jwt.LoadPrivateKey(cfg.KeyID, cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if key.Algorithm != cfg.Algorithm {
+			return nil, fmt.Errorf("JWT_ALG=%s does not match %s key in %s", cfg.Algorithm, key.Algorithm, cfg.PrivateKeyFile)
+		}
+		signing = key
+		// Старые HS256-токены принимаются, только если HS256 явно разрешён.
+		if cfg.Secret != "" && slices.Contains(cfg.Algorithms, jwt.AlgHS256) && cfg.KeyID != jwt.DefaultKeyID {
+			verify = append(verify, jwt.HMACKey(jwt.DefaultKeyID, cfg.Secret))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALG %q", cfg.Algorithm)
+	}
+
+	kids := make([]string, 0, len(cfg.VerifyKeyFiles))
+	for kid := range cfg.VerifyKeyFiles {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	for _, kid := range kids {
+		key, err := jwt.LoadPublicKey(kid, cfg.VerifyKeyFiles[kid])
+		if err != nil {
+			return nil, err
+		}
+		verify = append(verify, key)
+	}
+
+	j, err := jwt.NewKeySet(cfg.Issuer, cfg.AccessTTL, signing, verify...)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Algorithms) > 0 {
+		if !slices.Contains(cfg.Algorithms, signing.Algorithm) {
+			return nil, fmt.Errorf("JWT_ALGORITHMS must include signing algorithm %s", signing.Algorithm)
+		}
+		j.Algorithms = cfg.Algorithms
+	}
+	return j, nil
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"me-ai/configs"
+	"me-ai/pkg/mailer"
+	"os"
+)
+
+// NewMailer выбирает реализацию отправки писем по конфигурации.
+func NewMailer(cfg configs.MailConfig) (mailer.Mailer, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return mailer.NewLogMailer(os.Stdout), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.File)
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, errors.New("SMTP_HOST is required for MAILER=smtp")
+		}
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", cfg.Driver)
+	}
+}
+
+func verificationMessage(user, link string) mailer.Message {
+	return mailer.Message{
+		To:      user,
+		Subject: "Подтвердите email в me-ai",
+		Body: "Чтобы завершить регистрацию, перейдите по ссылке:\n\n" + link +
+			"\n\nЕсли вы не регистрировались в me-ai, просто проигнорируйте это письмо.",
+	}
+}
+
+func passwordResetMessage(user, link string) mailer.Message {
+	return mailer.Message{
+		To:      user,
+		Subject: "Сброс пароля в me-ai",
+		Body: "Чтобы задать новый пароль, перейдите по ссылке:\n\n" + link +
+			"\n\nСсылка одноразовая. Если вы не запрашивали сброс, проигнорируйте это письмо — пароль не изменится.",
+	}
+}
+
+func emailChangeMessage(newEmail, link string) mailer.Message {
+	return mailer.Message{
+		To:      newEmail,
+		Subject: "Подтвердите новый email в me-ai",
+		Body: "Чтобы использовать этот адрес для входа в me-ai, перейдите по ссылке:\n\n" + link +
+			"\n\nЕсли вы не меняли адрес, просто проигнорируйте это письмо.",
+	}
+}
+
+// emailChangedMessage уходит на прежний адрес, чтобы владелец заметил чужую смену.
+func emailChangedMessage(oldEmail, newEmail string) mailer.Message {
+	return mailer.Message{
+		To:      oldEmail,
+		Subject: "Email в me-ai изменён",
+		Body: "Адрес для входа в ваш аккаунт me-ai изменён на " + newEmail + "." +
+			"\n\nЕсли это были не вы, срочно свяжитесь с администратором.",
+	}
+}
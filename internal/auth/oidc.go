@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"me-ai/configs"
+	"me-ai/internal/models"
+	"me-ai/pkg/oidc"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// oidcFlowTTL — сколько ждём возвращения пользователя от провайдера.
+const oidcFlowTTL = 10 * time.Minute
+
+// NewOIDCRegistry собирает провайдеров из конфигурации; колбэк у всех общий.
+func NewOIDCRegistry(cfg configs.OIDCConfig) *oidc.Registry {
+	providers := make([]oidc.Config, 0, len(cfg.Providers))
+	for _, p := range cfg.Providers {
+		providers = append(providers, oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       p.Scopes,
+		})
+	}
+	return oidc.NewRegistry(providers...)
+}
+
+// oidcFlow — незавершённый вход через провайдера, ключ — параметр state.
+type oidcFlow struct {
+	provider string
+	nonce    string
+	verifier string
+	expires  time.Time
+}
+
+type oidcFlows struct {
+	mu    sync.Mutex
+	flows map[string]oidcFlow
+}
+
+func newOIDCFlows() *oidcFlows {
+	return &oidcFlows{flows: map[string]oidcFlow{}}
+}
+
+func (f *oidcFlows) put(state string, flow oidcFlow) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for key, existing := range f.flows {
+		if now.After(existing.expires) {
+			delete(f.flows, key)
+		}
+	}
+	f.flows[state] = flow
+}
+
+// take возвращает и удаляет flow: один state нельзя использовать дважды.
+func (f *oidcFlows) take(state string) (oidcFlow, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	flow, ok := f.flows[state]
+	delete(f.flows, state)
+	if !ok || time.Now().After(flow.expires) {
+		return oidcFlow{}, false
+	}
+	return flow, true
+}
+
+// LoginWithOIDC находит или заводит пользователя по проверенному ID token.
+// Известная пара provider+sub входит сразу. Новая привязывается к аккаунту с тем
+// же email, только если провайдер подтвердил адрес; иначе через чужой IdP можно
+// было бы войти в любой аккаунт.
+func (service *AuthService) LoginWithOIDC(provider string, claims *oidc.Claims) (*models.User, error) {
+	identity, err := service.IdentityRepository.FindBySubject(provider, claims.Subject)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+	var user *models.User
+	if identity != nil {
+		user, err = service.UserRepository.FindByID(identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		user, err = service.linkOIDCIdentity(provider, claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if user.Disabled() {
+		return nil, errors.New(ErrAccountDisabled)
+	}
+	return user, nil
+}
+
+func (service *AuthService) linkOIDCIdentity(provider string, claims *oidc.Claims) (*models.User, error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errors.New(ErrSSOEmailNotVerified)
+	}
+	user, err := service.findByEmail(claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case user == nil:
+		created, err := service.createOIDCUser(claims)
+		if err != nil {
+			return nil, err
+		}
+		user = created
+	case user.EmailVerifiedAt == nil:
+		// Неподтверждённый аккаунт мог завести кто угодно, указав чужой адрес.
+		// Владелец адреса теперь доказан провайдером, поэтому пароль и сессии
+		// того, кто регистрировался, больше не действуют.
+		if err := service.replacePassword(user.ID); err != nil {
+			return nil, err
+		}
+		if err := service.RefreshTokenRepository.RevokeAllForUser(user.ID); err != nil {
+			return nil, err
+		}
+		if err := service.UserRepository.MarkEmailVerified(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	_, err = service.IdentityRepository.Create(&models.Identity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Привязан вход через %s: user_id=%d", provider, user.ID)
+	return user, nil
+}
+
+// createOIDCUser заводит пользователя без известного ему пароля; задать пароль
+// можно позже через сброс по email.
+func (service *AuthService) createOIDCUser(claims *oidc.Claims) (*models.User, error) {
+	hashedPassword, err := randomPasswordHash()
+	if err != nil {
+		return nil, err
+	}
+	name := claims.Name
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	user := &models.User{
+		Email:    claims.Email,
+		Password: hashedPassword,
+		Name:     name,
+		Role:     models.RoleUser,
+	}
+	if _, err := service.UserRepository.Create(user); err != nil {
+		return nil, err
+	}
+	if err := service.UserRepository.MarkEmailVerified(user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (service *AuthService) replacePassword(userID int) error {
+	hashedPassword, err := randomPasswordHash()
+	if err != nil {
+		return err
+	}
+	return service.UserRepository.UpdatePassword(userID, hashedPassword)
+}
+
+func randomPasswordHash() (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(raw), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"log"
+	"me-ai/pkg/oidc"
+	"me-ai/pkg/res"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// oidcStateCookie привязывает state к браузеру, который начал вход: без него
+// злоумышленник мог бы подсунуть жертве ссылку колбэка со своим кодом (login CSRF).
+const oidcStateCookie = "oidc_state"
+
+const oidcCookiePath = "/api/auth/oidc"
+
+func (handler *AuthHandler) OIDCProviders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res.Json(w, OIDCProvidersResponse{Providers: handler.OIDC.Names()}, 200)
+	}
+}
+
+// OIDCLogin начинает authorization code flow: запоминает state, nonce и PKCE
+// verifier и отправляет браузер к провайдеру.
+func (handler *AuthHandler) OIDCLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("provider")
+		provider, err := handler.OIDC.Get(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		state, err := oidc.RandomString(32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		nonce, err := oidc.RandomString(32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		verifier, challenge, err := oidc.NewPKCE()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		target, err := provider.AuthCodeURL(r.Context(), state, nonce, challenge)
+		if err != nil {
+			log.Printf("Ошибка OIDC-провайдера %s: %v", name, err)
+			http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+			return
+		}
+		handler.oidcFlows.put(state, oidcFlow{
+			provider: name,
+			nonce:    nonce,
+			verifier: verifier,
+			expires:  time.Now().Add(oidcFlowTTL),
+		})
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    state,
+			Path:     oidcCookiePath,
+			MaxAge:   int(oidcFlowTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   strings.HasPrefix(provider.RedirectURL, "https://"),
+			// Lax: cookie должна прийти с верхнеуровневым редиректом от провайдера.
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, target, http.StatusFound)
+	}
+}
+
+// OIDCCallback завершает вход и возвращает браузер на фронтенд. Токены передаются
+// во фрагменте URL, который не уходит на сервер и не попадает в логи прокси.
+// Если у пользователя включена локальная 2FA, вместо токенов передаётся challenge.
+func (handler *AuthHandler) OIDCCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcCookiePath, MaxAge: -1, HttpOnly: true})
+
+		state := q.Get("state")
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil || state == "" || cookie.Value != state {
+			handler.oidcRedirect(w, r, url.Values{"error": {"invalid_state"}})
+			return
+		}
+		flow, ok := handler.oidcFlows.take(state)
+		if !ok {
+			handler.oidcRedirect(w, r, url.Values{"error": {"invalid_state"}})
+			return
+		}
+		if providerErr := q.Get("error"); providerErr != "" {
+			handler.oidcRedirect(w, r, url.Values{"error": {providerErr}})
+			return
+		}
+		provider, err := handler.OIDC.Get(flow.provider)
+		if err != nil {
+			handler.oidcRedirect(w, r, url.Values{"error": {"invalid_state"}})
+			return
+		}
+		rawIDToken, err := provider.Exchange(r.Context(), q.Get("code"), flow.verifier)
+		if err != nil {
+			log.Printf("Ошибка обмена кода OIDC: %v", err)
+			handler.oidcRedirect(w, r, url.Values{"error": {"exchange_failed"}})
+			return
+		}
+		claims, err := provider.VerifyIDToken(r.Context(), rawIDToken, flow.nonce)
+		if err != nil {
+			log.Printf("Ошибка проверки ID token: %v", err)
+			handler.oidcRedirect(w, r, url.Values{"error": {"invalid_id_token"}})
+			return
+		}
+		user, err := handler.AuthService.LoginWithOIDC(flow.provider, claims)
+		if err != nil {
+			handler.oidcRedirect(w, r, url.Values{"error": {oidcErrorCode(err)}})
+			return
+		}
+		if user.TwoFactorEnabled() {
+			challenge, err := handler.AuthService.IssueChallenge(user)
+			if err != nil {
+				log.Printf("Ошибка выдачи challenge 2FA: %v", err)
+				handler.oidcRedirect(w, r, url.Values{"error": {"server_error"}})
+				return
+			}
+			handler.oidcRedirect(w, r, url.Values{"challenge_token": {challenge}})
+			return
+		}
+		tokens, err := handler.AuthService.IssueTokens(user, handler.clientInfo(r))
+		if err != nil {
+			log.Printf("Ошибка выдачи токенов: %v", err)
+			handler.oidcRedirect(w, r, url.Values{"error": {"server_error"}})
+			return
+		}
+		handler.oidcRedirect(w, r, url.Values{
+			"token":         {tokens.AccessToken},
+			"refresh_token": {tokens.RefreshToken},
+			"expires_in":    {strconv.Itoa(tokens.ExpiresIn)},
+		})
+	}
+}
+
+func (handler *AuthHandler) oidcRedirect(w http.ResponseWriter, r *http.Request, fragment url.Values) {
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, handler.AuthService.AppURL+"/oidc/callback#"+fragment.Encode(), http.StatusFound)
+}
+
+func oidcErrorCode(err error) string {
+	switch err.Error() {
+	case ErrSSOEmailNotVerified:
+		return "email_not_verified"
+	case ErrAccountDisabled:
+		return "account_disabled"
+	}
+	log.Printf("Ошибка входа через OIDC: %v", err)
+	return "server_error"
+}
@@ -1,12 +1,24 @@
 package auth
 
+import (
+	"me-ai/internal/models"
+	"me-ai/internal/transcript"
+	"time"
+)
+
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 }
 
+// LoginResponse при включённой 2FA содержит только ChallengeToken: сессия
+// выдаётся после /api/auth/2fa/verify.
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token             string `json:"token,omitempty"`
+	RefreshToken      string `json:"refresh_token,omitempty"`
+	ExpiresIn         int    `json:"expires_in,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
 }
 
 type RegisterRequest struct {
@@ -15,6 +27,100 @@ type RegisterRequest struct {
 	Name     string `json:"name" validate:"required"`
 }
 
+// RegisterResponse без токенов и с VerificationRequired, если вход возможен
+// только после подтверждения email.
 type RegisterResponse struct {
-	Token string `json:"token"`
+	Token                string `json:"token,omitempty"`
+	RefreshToken         string `json:"refresh_token,omitempty"`
+	ExpiresIn            int    `json:"expires_in,omitempty"`
+	VerificationRequired bool   `json:"verification_required,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type RefreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	// Code — код из приложения или код восстановления.
+	Code string `json:"code" validate:"required"`
+}
+
+type OIDCProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type ProfileResponse struct {
+	ID               int        `json:"id"`
+	Email            string     `json:"email"`
+	Name             string     `json:"name"`
+	Role             string     `json:"role"`
+	EmailVerified    bool       `json:"email_verified"`
+	PendingEmail     string     `json:"pending_email,omitempty"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	CreatedAt        string     `json:"created_at"`
+	Identities       []Identity `json:"identities"`
+}
+
+// Identity — привязанный вход через OIDC-провайдера.
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UpdateProfileRequest — поля, которые не переданы, не меняются.
+type UpdateProfileRequest struct {
+	Name  *string `json:"name" validate:"omitempty,max=255"`
+	Email *string `json:"email" validate:"omitempty,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+type RevokeSessionRequest struct {
+	ID string `json:"id" validate:"required"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+	// Code — код 2FA, обязателен, если она включена.
+	Code string `json:"code"`
+}
+
+// AccountExport — всё, что хранится о пользователе, в одном JSON-документе.
+type AccountExport struct {
+	ExportedAt    time.Time                 `json:"exported_at"`
+	Profile       ProfileResponse           `json:"profile"`
+	Conversations []transcript.Conversation `json:"conversations"`
 }
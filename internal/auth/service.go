@@ -1,54 +1,192 @@
 package auth
 
 import (
+	"database/sql"
 	"errors"
+	"log"
 	"me-ai/internal/models"
+	"me-ai/internal/twofactor"
+	"me-ai/pkg/jwt"
+	"me-ai/pkg/mailer"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	ErrWrongCredetials = "wrong credentials"
-	ErrUserExists      = "user already exists"
+	ErrWrongCredetials      = "wrong credentials"
+	ErrUserExists           = "user already exists"
+	ErrInvalidRefreshToken  = "invalid refresh token"
+	ErrRefreshTokenReused   = "refresh token reuse detected"
+	ErrAccountDisabled      = "account disabled"
+	ErrEmailNotVerified     = "email not verified"
+	ErrInvalidEmailToken    = "invalid or expired token"
+	ErrTooManyAttempts      = "too many login attempts"
+	ErrInvalidChallenge     = "invalid or expired two-factor challenge"
+	ErrInvalidTwoFactorCode = "invalid two-factor code"
+	ErrSSOEmailNotVerified  = "email not verified by identity provider"
+	ErrWrongPassword        = "wrong password"
+	ErrSessionNotFound      = "session not found"
 )
 
+// ThrottledError — вход временно запрещён из-за серии неудачных попыток.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return ErrTooManyAttempts
+}
+
+type AuthServiceDeps struct {
+	UserRepository         models.UserStore
+	RefreshTokenRepository models.RefreshTokenStore
+	JWT                    *jwt.JWT
+	RefreshTTL             time.Duration
+	UserTokenRepository    models.UserTokenStore
+	Mailer                 mailer.Mailer
+	LoginLockoutRepository models.LoginLockoutStore
+	Throttle               *LoginThrottle
+	TwoFactor              *twofactor.Service
+	IdentityRepository     models.IdentityStore
+	// AppURL — адрес фронтенда для ссылок из писем.
+	AppURL                    string
+	EmailVerificationRequired bool
+	VerificationTTL           time.Duration
+	PasswordResetTTL          time.Duration
+}
+
 type AuthService struct {
-	UserRepository *models.UserRepository
+	UserRepository         models.UserStore
+	RefreshTokenRepository models.RefreshTokenStore
+	JWT                    *jwt.JWT
+	RefreshTTL             time.Duration
+	UserTokenRepository    models.UserTokenStore
+	Mailer                 mailer.Mailer
+	LoginLockoutRepository models.LoginLockoutStore
+	Throttle               *LoginThrottle
+	TwoFactor              *twofactor.Service
+	IdentityRepository     models.IdentityStore
+
+	AppURL                    string
+	EmailVerificationRequired bool
+	VerificationTTL           time.Duration
+	PasswordResetTTL          time.Duration
 }
 
-func NewAuthService(userRepository *models.UserRepository) *AuthService {
-	return &AuthService{UserRepository: userRepository}
+func NewAuthService(deps AuthServiceDeps) *AuthService {
+	return &AuthService{
+		UserRepository:         deps.UserRepository,
+		RefreshTokenRepository: deps.RefreshTokenRepository,
+		JWT:                    deps.JWT,
+		RefreshTTL:             deps.RefreshTTL,
+		UserTokenRepository:    deps.UserTokenRepository,
+		Mailer:                 deps.Mailer,
+		LoginLockoutRepository: deps.LoginLockoutRepository,
+		Throttle:               deps.Throttle,
+		TwoFactor:              deps.TwoFactor,
+		IdentityRepository:     deps.IdentityRepository,
+
+		AppURL:                    deps.AppURL,
+		EmailVerificationRequired: deps.EmailVerificationRequired,
+		VerificationTTL:           deps.VerificationTTL,
+		PasswordResetTTL:          deps.PasswordResetTTL,
+	}
 }
 
-func (service *AuthService) Login(email, password string) (string, error) {
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// compareDummy тратит на неизвестный адрес столько же времени, сколько bcrypt
+// на настоящий пароль, чтобы по времени ответа нельзя было перебирать email.
+func compareDummy(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// findByEmail возвращает nil без ошибки, если адрес не занят; прочие ошибки базы пробрасываются.
+func (service *AuthService) findByEmail(email string) (*models.User, error) {
+	user, err := service.UserRepository.FindByEmail(email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (service *AuthService) Login(email, password, ip string) (*models.User, error) {
+	// Счётчик по адресу не должен обходиться сменой регистра.
+	key := strings.ToLower(strings.TrimSpace(email))
+	if wait := service.Throttle.Wait(key, ip); wait > 0 {
+		return nil, &ThrottledError{RetryAfter: wait}
+	}
 	existedUser, _ := service.UserRepository.FindByEmail(email)
 	if existedUser == nil {
-		return "", errors.New(ErrWrongCredetials)
+		compareDummy(password)
+		service.loginFailed(key, ip, nil)
+		return nil, errors.New(ErrWrongCredetials)
 	}
 	err := bcrypt.CompareHashAndPassword([]byte(existedUser.Password), []byte(password))
 	if err != nil {
-		return "", errors.New(ErrWrongCredetials)
+		service.loginFailed(key, ip, &existedUser.ID)
+		return nil, errors.New(ErrWrongCredetials)
+	}
+	service.Throttle.Succeeded(key)
+	if existedUser.Disabled() {
+		return nil, errors.New(ErrAccountDisabled)
+	}
+	if service.EmailVerificationRequired && existedUser.EmailVerifiedAt == nil {
+		return nil, errors.New(ErrEmailNotVerified)
+	}
+	return existedUser, nil
+}
+
+// loginFailed учитывает неудачу и сохраняет запись о каждой наступившей блокировке.
+func (service *AuthService) loginFailed(email, ip string, userID *int) {
+	for _, lock := range service.Throttle.Failed(email, ip) {
+		log.Printf("Блокировка входа (%s): email=%s, ip=%s, до %s", lock.Scope, email, ip, lock.Until.Format(time.RFC3339))
+		lockout := &models.LoginLockout{
+			Scope:          lock.Scope,
+			Email:          email,
+			IP:             ip,
+			FailedAttempts: lock.Failures,
+			LockedUntil:    lock.Until.UTC(),
+		}
+		if lock.Scope == models.LockoutScopeAccount {
+			lockout.UserID = userID
+		}
+		if service.LoginLockoutRepository == nil {
+			continue
+		}
+		if _, err := service.LoginLockoutRepository.Create(lockout); err != nil {
+			log.Printf("Ошибка записи блокировки входа: %v", err)
+		}
 	}
-	return existedUser.Email, nil
 }
 
-func (service *AuthService) Register(email, password, name string) (string, error) {
+func (service *AuthService) Register(email, password, name string) (*models.User, error) {
 	existedUser, _ := service.UserRepository.FindByEmail(email)
 	if existedUser != nil {
-		return "", errors.New(ErrUserExists)
+		return nil, errors.New(ErrUserExists)
 	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	user := &models.User{
 		Email:    email,
 		Password: string(hashedPassword),
 		Name:     name,
+		Role:     models.RoleUser,
 	}
 	_, err = service.UserRepository.Create(user)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return user.Email, nil
+	return user, nil
 }
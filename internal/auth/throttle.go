@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"me-ai/internal/models"
+	"me-ai/pkg/throttle"
+	"time"
+)
+
+type LoginThrottleConfig struct {
+	// MaxAttempts — неудачных попыток на аккаунт до блокировки; 0 — без блокировки.
+	MaxAttempts int
+	// MaxIPAttempts — неудачных попыток с одного IP до блокировки; 0 — без блокировки.
+	MaxIPAttempts int
+	// BaseDelay — задержка после throttle.FreeAttempts неудач, дальше удваивается; 0 — без задержек.
+	BaseDelay time.Duration
+	// Lockout — длительность блокировки и окно, после которого счётчик неудач сбрасывается.
+	Lockout time.Duration
+}
+
+// LoginThrottle считает неудачные попытки входа в памяти процесса: по аккаунту
+// (экспоненциальная задержка и блокировка) и по IP (только блокировка, чтобы не
+// наказывать задержками пользователей за общим NAT).
+type LoginThrottle struct {
+	account *throttle.Throttle
+	ip      *throttle.Throttle
+}
+
+// LoginLock — блокировка, наступившая в результате очередной неудачи.
+type LoginLock struct {
+	Scope    string
+	Failures int
+	Until    time.Time
+}
+
+func NewLoginThrottle(cfg LoginThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{
+		account: throttle.New(throttle.Config{Limit: cfg.MaxAttempts, BaseDelay: cfg.BaseDelay, Lockout: cfg.Lockout}),
+		ip:      throttle.New(throttle.Config{Limit: cfg.MaxIPAttempts, Lockout: cfg.Lockout}),
+	}
+}
+
+// Wait возвращает, сколько ещё нужно ждать до следующей попытки; 0 — можно пробовать.
+func (t *LoginThrottle) Wait(email, ip string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	return max(t.account.Wait(email), t.ip.Wait(ip))
+}
+
+// Failed учитывает неудачную попытку и возвращает блокировки, которые она вызвала.
+func (t *LoginThrottle) Failed(email, ip string) []LoginLock {
+	if t == nil {
+		return nil
+	}
+	var locks []LoginLock
+	if lock, ok := t.account.Fail(email); ok {
+		locks = append(locks, LoginLock{Scope: models.LockoutScopeAccount, Failures: lock.Failures, Until: lock.Until})
+	}
+	if lock, ok := t.ip.Fail(ip); ok {
+		locks = append(locks, LoginLock{Scope: models.LockoutScopeIP, Failures: lock.Failures, Until: lock.Until})
+	}
+	return locks
+}
+
+// Succeeded сбрасывает счётчик аккаунта. Счётчик IP не сбрасывается: иначе
+// перебирающий мог бы обнулять его, периодически входя в свой аккаунт.
+func (t *LoginThrottle) Succeeded(email string) {
+	if t == nil {
+		return
+	}
+	t.account.Reset(email)
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"me-ai/internal/models"
+	"me-ai/pkg/throttle"
+	"testing"
+	"time"
+)
+
+// freeAttempts — прощаемые без задержки неудачи; локальные переменные ниже
+// называются throttle.
+const freeAttempts = throttle.FreeAttempts
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+func newThrottle(cfg LoginThrottleConfig) (*LoginThrottle, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	t := NewLoginThrottle(cfg)
+	t.account.Now, t.ip.Now = clock.Now, clock.Now
+	return t, clock
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	throttle, clock := newThrottle(LoginThrottleConfig{BaseDelay: time.Second, Lockout: time.Hour})
+
+	for i := 0; i < freeAttempts; i++ {
+		throttle.Failed("alice@example.com", "10.0.0.1")
+	}
+	if wait := throttle.Wait("alice@example.com", "10.0.0.1"); wait != 0 {
+		t.Fatalf("delay after free attempts: %s", wait)
+	}
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		throttle.Failed("alice@example.com", "10.0.0.1")
+		if wait := throttle.Wait("alice@example.com", "10.0.0.2"); wait != want {
+			t.Fatalf("wait = %s, want %s", wait, want)
+		}
+		clock.Advance(want)
+	}
+	// Задержки считаются по аккаунту, а не по IP.
+	if wait := throttle.Wait("bob@example.com", "10.0.0.1"); wait != 0 {
+		t.Fatalf("other account delayed from the same IP: %s", wait)
+	}
+
+	throttle.Succeeded("alice@example.com")
+	throttle.Failed("alice@example.com", "10.0.0.1")
+	if wait := throttle.Wait("alice@example.com", "10.0.0.1"); wait != 0 {
+		t.Fatalf("success did not reset the counter: %s", wait)
+	}
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	throttle, clock := newThrottle(LoginThrottleConfig{MaxAttempts: 3, MaxIPAttempts: 5, Lockout: 10 * time.Minute})
+
+	throttle.Failed("alice@example.com", "10.0.0.1")
+	throttle.Failed("alice@example.com", "10.0.0.1")
+	locks := throttle.Failed("alice@example.com", "10.0.0.1")
+	if len(locks) != 1 || locks[0].Scope != models.LockoutScopeAccount || locks[0].Failures != 3 {
+		t.Fatalf("unexpected locks: %+v", locks)
+	}
+	if wait := throttle.Wait("alice@example.com", "10.0.0.9"); wait != 10*time.Minute {
+		t.Fatalf("wait = %s, want lockout", wait)
+	}
+
+	throttle.Failed("bob@example.com", "10.0.0.1")
+	locks = throttle.Failed("carol@example.com", "10.0.0.1")
+	if len(locks) != 1 || locks[0].Scope != models.LockoutScopeIP {
+		t.Fatalf("IP not locked after %d failures: %+v", 5, locks)
+	}
+	if wait := throttle.Wait("dave@example.com", "10.0.0.1"); wait == 0 {
+		t.Fatal("locked IP allowed to try another account")
+	}
+
+	// Успешный вход не снимает блокировку IP.
+	throttle.Succeeded("dave@example.com")
+	if wait := throttle.Wait("dave@example.com", "10.0.0.1"); wait == 0 {
+		t.Fatal("IP lockout lifted by a successful login")
+	}
+
+	clock.Advance(10 * time.Minute)
+	if wait := throttle.Wait("alice@example.com", "10.0.0.1"); wait != 0 {
+		t.Fatalf("lockout did not expire: %s", wait)
+	}
+}
+
+func TestLoginThrottleWindow(t *testing.T) {
+	throttle, clock := newThrottle(LoginThrottleConfig{MaxAttempts: 3, Lockout: time.Minute})
+
+	throttle.Failed("alice@example.com", "10.0.0.1")
+	throttle.Failed("alice@example.com", "10.0.0.1")
+	clock.Advance(2 * time.Minute)
+	if locks := throttle.Failed("alice@example.com", "10.0.0.1"); len(locks) != 0 {
+		t.Fatalf("stale failures counted towards lockout: %+v", locks)
+	}
+}
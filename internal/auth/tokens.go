@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"me-ai/internal/models"
+	"me-ai/pkg/jwt"
+	"time"
+	"unicode/utf8"
+)
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+// ClientInfo — устройство и адрес, с которых сессия открыта или продлена.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// maxUserAgentLength — размер колонки refresh_tokens.user_agent.
+const maxUserAgentLength = 512
+
+// IssueTokens начинает новую сессию: access token и первый refresh token новой семьи.
+func (service *AuthService) IssueTokens(user *models.User, client ClientInfo) (*TokenPair, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return service.issue(user, familyID, client)
+}
+
+// Refresh обменивает refresh token на новую пару (ротация). Повторное
+// предъявление уже использованного токена считается утечкой: вся семья отзывается.
+func (service *AuthService) Refresh(raw string, client ClientInfo) (*TokenPair, error) {
+	token, err := service.RefreshTokenRepository.FindByHash(hashToken(raw))
+	if err != nil {
+		if !errors.Is(err, models.ErrNotFound) {
+			return nil, err
+		}
+		return nil, errors.New(ErrInvalidRefreshToken)
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, errors.New(ErrInvalidRefreshToken)
+	}
+	fresh, err := service.RefreshTokenRepository.MarkUsed(token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		log.Printf("Повторное использование refresh token: user_id=%d, family=%s", token.UserID, token.FamilyID)
+		if err := service.RefreshTokenRepository.RevokeFamily(token.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, errors.New(ErrRefreshTokenReused)
+	}
+	user, err := service.UserRepository.FindByID(token.UserID)
+	if err != nil {
+		return nil, errors.New(ErrInvalidRefreshToken)
+	}
+	if user.Disabled() {
+		return nil, errors.New(ErrAccountDisabled)
+	}
+	return service.issue(user, token.FamilyID, client)
+}
+
+// Logout отзывает всю семью refresh token'ов сессии. Неизвестный токен не ошибка.
+func (service *AuthService) Logout(raw string) error {
+	token, err := service.RefreshTokenRepository.FindByHash(hashToken(raw))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return service.RefreshTokenRepository.RevokeFamily(token.FamilyID)
+}
+
+func (service *AuthService) issue(user *models.User, familyID string, client ClientInfo) (*TokenPair, error) {
+	access, err := service.JWT.Create(jwt.JWTData{
+		Login:   user.Email,
+		Role:    user.Role,
+		Session: familyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	_, err = service.RefreshTokenRepository.Create(&models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(service.RefreshTTL).UTC(),
+		UserAgent: truncate(client.UserAgent, maxUserAgentLength),
+		IP:        client.IP,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: raw,
+		ExpiresIn:    int(service.JWT.TTL.Seconds()),
+	}, nil
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// Не разрезаем многобайтовый символ UTF-8.
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
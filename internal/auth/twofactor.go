@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"errors"
+	"me-ai/internal/models"
+	"me-ai/internal/twofactor"
+	"me-ai/pkg/jwt"
+	"strings"
+	"time"
+)
+
+// challengeTTL — сколько живёт токен между вводом пароля и второго фактора.
+const challengeTTL = 5 * time.Minute
+
+// IssueChallenge выдаётся вместо сессии, если у пользователя включена 2FA.
+// Токен подписан тем же ключом, но с typ=2fa_challenge, поэтому защищённые маршруты его не принимают.
+func (service *AuthService) IssueChallenge(user *models.User) (string, error) {
+	return service.JWT.CreateWithTTL(jwt.JWTData{
+		Login:   user.Email,
+		Purpose: jwt.PurposeChallenge,
+	}, challengeTTL)
+}
+
+// VerifyChallenge завершает вход: проверяет challenge и код второго фактора.
+// Неверные коды учитываются тем же ограничителем, что и неверные пароли.
+func (service *AuthService) VerifyChallenge(challenge, code, ip string) (*models.User, error) {
+	ok, data := service.JWT.Parse(challenge)
+	if !ok || data.Purpose != jwt.PurposeChallenge {
+		return nil, errors.New(ErrInvalidChallenge)
+	}
+	key := strings.ToLower(data.Login)
+	if wait := service.Throttle.Wait(key, ip); wait > 0 {
+		return nil, &ThrottledError{RetryAfter: wait}
+	}
+	user, err := service.UserRepository.FindByEmail(data.Login)
+	if err != nil {
+		return nil, errors.New(ErrInvalidChallenge)
+	}
+	if user.Disabled() {
+		return nil, errors.New(ErrAccountDisabled)
+	}
+	// 2FA могли отключить, пока challenge был жив.
+	if !user.TwoFactorEnabled() {
+		return nil, errors.New(ErrInvalidChallenge)
+	}
+	if err := service.TwoFactor.Verify(user, code); err != nil {
+		if err.Error() != twofactor.ErrInvalidCode {
+			return nil, err
+		}
+		service.loginFailed(key, ip, &user.ID)
+		return nil, errors.New(ErrInvalidTwoFactorCode)
+	}
+	service.Throttle.Succeeded(key)
+	return user, nil
+}
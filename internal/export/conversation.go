@@ -0,0 +1,28 @@
+package export
+
+import (
+	"bytes"
+	"me-ai/internal/transcript"
+	"time"
+)
+
+// Conversation собирает выгрузку одной беседы пользователя в format. Документ
+// собирается целиком до ответа, чтобы ошибка шаблона не обрывала файл.
+func (s *Service) Conversation(userID, convoID int, format string, opts transcript.Options) ([]byte, string, error) {
+	convo, err := s.ConversationRepository.FindByID(convoID, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	msgs, err := s.MessageRepository.ListByConversation(convo.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	meta := transcript.Metadata{Model: s.Model, Persona: s.Persona}
+	doc := transcript.NewDocument(*convo, msgs, meta, opts, time.Now())
+	var buf bytes.Buffer
+	if err := s.renderer.Render(&buf, doc, format); err != nil {
+		return nil, "", err
+	}
+	name := transcript.FileName(transcript.Conversation{ID: convo.ID, Title: convo.Title}, format)
+	return buf.Bytes(), name, nil
+}
@@ -0,0 +1,218 @@
+package export
+
+import (
+	"errors"
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/internal/transcript"
+	"me-ai/pkg/res"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ExportHandlerDeps struct {
+	Service        *Service
+	UserRepository models.UserStore
+}
+
+type ExportHandler struct {
+	ExportHandlerDeps
+}
+
+// NewExportHandler регистрирует выгрузку на защищённом роутере, а скачивание по
+// ссылке — на публичном: браузер переходит по ней без заголовка Authorization,
+// доступ даёт токен в самой ссылке.
+func NewExportHandler(router, protected *http.ServeMux, deps ExportHandlerDeps) {
+	handler := &ExportHandler{ExportHandlerDeps: deps}
+	protected.Handle("/api/export", middleware.SessionOnly(handler.Export()))    // GET
+	protected.Handle("/api/export/jobs", middleware.SessionOnly(handler.Jobs())) // GET ?id=, POST
+	router.Handle("/api/export/download", middleware.CORS(handler.Download()))   // GET ?token=
+	read := middleware.RequireScope(middleware.ScopeConversationsRead)
+	protected.Handle("/api/conversations/export", read(handler.ExportConversation())) // GET ?id=&format=&include=
+}
+
+// Export отдаёт архив сразу, пока данных немного. Для больших аккаунтов ставит
+// фоновое задание и отвечает 202 с его состоянием.
+func (handler *ExportHandler) Export() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		count, err := handler.Service.MessageCount(user)
+		if err != nil {
+			writeExportError(w, err)
+			return
+		}
+		if count > handler.Service.Config.SyncMaxMessages {
+			handler.startJob(w, user)
+			return
+		}
+		name := "me-ai-export-" + time.Now().UTC().Format("20060102") + ".zip"
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		w.Header().Set("Cache-Control", "no-store")
+		// Заголовки уже ушли, сообщить об ошибке клиенту можно только оборвав архив.
+		if err := handler.Service.Write(r.Context(), w, user); err != nil {
+			log.Printf("Ошибка выгрузки: user_id=%d: %v", user.ID, err)
+		}
+	}
+}
+
+func (handler *ExportHandler) Jobs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			handler.startJob(w, user)
+		case http.MethodGet:
+			id, err := strconv.Atoi(r.URL.Query().Get("id"))
+			if err != nil {
+				http.Error(w, "Invalid job id", http.StatusBadRequest)
+				return
+			}
+			job, err := handler.Service.Job(id, user.ID)
+			if err != nil {
+				writeExportError(w, err)
+				return
+			}
+			handler.writeJob(w, job, http.StatusOK)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (handler *ExportHandler) startJob(w http.ResponseWriter, user *models.User) {
+	job, err := handler.Service.Start(user)
+	if err != nil {
+		writeExportError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/export/jobs?id="+strconv.Itoa(job.ID))
+	handler.writeJob(w, job, http.StatusAccepted)
+}
+
+// writeJob к готовому заданию прикладывает свежую ссылку на скачивание.
+func (handler *ExportHandler) writeJob(w http.ResponseWriter, job *models.ExportJob, status int) {
+	data := JobResponse{ExportJob: job}
+	if job.Status == models.ExportDone {
+		token, err := handler.Service.IssueLink(job)
+		if err != nil {
+			writeExportError(w, err)
+			return
+		}
+		data.DownloadURL = "/api/export/download?token=" + url.QueryEscape(token)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	res.Json(w, data, status)
+}
+
+func (handler *ExportHandler) Download() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		f, job, err := handler.Service.Open(r.URL.Query().Get("token"))
+		if err != nil {
+			writeExportError(w, err)
+			return
+		}
+		defer f.Close()
+		name := "me-ai-export-" + job.CreatedAt.UTC().Format("20060102") + ".zip"
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		http.ServeContent(w, r, name, *job.CompletedAt, f)
+	}
+}
+
+// ExportConversation отдаёт одну беседу файлом: md (по умолчанию), html или json.
+// include через запятую добавляет reasoning — рассуждения модели — и tools —
+// служебные реплики.
+func (handler *ExportHandler) ExportConversation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		query := r.URL.Query()
+		id, err := strconv.Atoi(query.Get("id"))
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		format := query.Get("format")
+		switch format {
+		case "":
+			format = transcript.FormatMarkdown
+		case transcript.FormatMarkdown, transcript.FormatHTML, transcript.FormatJSON:
+		default:
+			http.Error(w, transcript.ErrUnknownFormat, http.StatusBadRequest)
+			return
+		}
+		var opts transcript.Options
+		for _, v := range query["include"] {
+			for _, item := range strings.Split(v, ",") {
+				switch strings.TrimSpace(item) {
+				case "reasoning":
+					opts.Reasoning = true
+				case "tools":
+					opts.Tools = true
+				case "":
+				default:
+					http.Error(w, "include must be reasoning or tools", http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		data, name, err := handler.Service.Conversation(user.ID, id, format, opts)
+		if errors.Is(err, models.ErrNotFound) {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			writeExportError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", transcript.ContentType(format))
+		// В имени может быть кириллица из названия беседы: FormatMediaType кодирует её по RFC 2231.
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(data)
+	}
+}
+
+func writeExportError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case ErrJobNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrInvalidToken:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrLinkExpired:
+		http.Error(w, err.Error(), http.StatusGone)
+	case ErrJobNotReady:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Ошибка выгрузки: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,11 @@
+package export
+
+import (
+	"me-ai/internal/models"
+)
+
+// JobResponse — состояние фоновой выгрузки. DownloadURL есть только у готовой.
+type JobResponse struct {
+	*models.ExportJob
+	DownloadURL string `json:"download_url,omitempty"`
+}
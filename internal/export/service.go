@@ -0,0 +1,443 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"me-ai/configs"
+	"me-ai/internal/auth"
+	"me-ai/internal/models"
+	"me-ai/internal/transcript"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJobNotFound  = "export job not found"
+	ErrLinkExpired  = "download link expired"
+	ErrJobNotReady  = "export is not ready yet"
+	ErrInvalidToken = "invalid download token"
+)
+
+// formatVersion меняется при несовместимых изменениях состава архива.
+const formatVersion = 1
+
+// workers — сколько архивов собирается одновременно на весь сервер.
+const workers = 2
+
+type ServiceDeps struct {
+	AuthService            *auth.AuthService
+	ConversationRepository models.ConversationStore
+	MessageRepository      models.MessageStore
+	UsageRepository        models.UsageStore
+	ExportJobRepository    models.ExportJobStore
+	Config                 configs.ExportConfig
+	// Model и Persona попадают в метаданные выгрузки беседы.
+	Model   string
+	Persona string
+}
+
+type Service struct {
+	ServiceDeps
+
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mu sync.Mutex
+	// running — задания, которые выполняет этот процесс. Активное задание вне
+	// списка осталось от прошлого запуска и уже не завершится.
+	running map[int]bool
+
+	renderer *transcript.Renderer
+}
+
+func NewService(deps ServiceDeps) *Service {
+	renderer, err := transcript.NewRenderer(deps.Config.TemplatesDir)
+	if err != nil {
+		log.Printf("Шаблоны выгрузки из %q не загружены, используются встроенные: %v", deps.Config.TemplatesDir, err)
+		if renderer, err = transcript.NewRenderer(""); err != nil {
+			panic(err)
+		}
+	}
+	return &Service{
+		ServiceDeps: deps,
+		slots:       make(chan struct{}, workers),
+		running:     map[int]bool{},
+		renderer:    renderer,
+	}
+}
+
+// Manifest описывает содержимое архива.
+type Manifest struct {
+	Format        int       `json:"format"`
+	ExportedAt    time.Time `json:"exported_at"`
+	UserID        int       `json:"user_id"`
+	Conversations int       `json:"conversations"`
+	Messages      int       `json:"messages"`
+	Files         []string  `json:"files"`
+}
+
+// Usage — статистика пользователя в целом и по беседам.
+type Usage struct {
+	models.UserUsage
+	ByConversation []ConversationUsage `json:"by_conversation"`
+}
+
+type ConversationUsage struct {
+	ConversationID    int `json:"conversation_id"`
+	Messages          int `json:"messages"`
+	UserMessages      int `json:"user_messages"`
+	AssistantMessages int `json:"assistant_messages"`
+	Characters        int `json:"characters"`
+}
+
+// Write пишет ZIP-архив в w по мере чтения бесед: сообщения каждой беседы
+// читаются один раз, и из них же собираются транскрипт, запись в
+// conversations.json и статистика, так что все файлы архива согласованы между
+// собой. conversations.json — тот же массив бесед, что в JSON-выгрузке
+// аккаунта, его принимает импорт. Вложений сервер пока не хранит, их в архиве нет.
+func (s *Service) Write(ctx context.Context, w io.Writer, user *models.User) error {
+	profile, err := s.AuthService.Profile(user.ID)
+	if err != nil {
+		return err
+	}
+	convos, err := s.ConversationRepository.ListByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	exportedAt := time.Now().UTC()
+	meta := transcript.Metadata{Model: s.Model, Persona: s.Persona}
+	manifest := Manifest{Format: formatVersion, ExportedAt: exportedAt, UserID: user.ID, Conversations: len(convos)}
+	stats := Usage{
+		UserUsage:      models.UserUsage{UserID: user.ID, Email: user.Email, Conversations: len(convos)},
+		ByConversation: make([]ConversationUsage, 0, len(convos)),
+	}
+
+	zw := zip.NewWriter(w)
+	if err := writeJSON(zw, "profile.json", profile, exportedAt); err != nil {
+		return err
+	}
+	manifest.Files = append(manifest.Files, "profile.json")
+
+	// Открытой в ZIP может быть только одна запись, поэтому транскрипты пишутся
+	// сразу, а conversations.json копится во временном файле и добавляется после.
+	if err := os.MkdirAll(s.Config.Dir, 0o700); err != nil {
+		return err
+	}
+	spool, err := os.CreateTemp(s.Config.Dir, "conversations-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if _, err := io.WriteString(spool, "["); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(spool)
+	for i, c := range convos {
+		msgs, err := s.messages(ctx, c.ID)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(spool, ","); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(transcript.FromModels(c, msgs)); err != nil {
+			return err
+		}
+
+		name := "transcripts/" + transcript.FileName(transcript.Conversation{ID: c.ID, Title: c.Title}, transcript.FormatMarkdown)
+		f, err := zw.CreateHeader(header(name, c.UpdatedAt))
+		if err != nil {
+			return err
+		}
+		// Архив — полная копия: рассуждения и служебные реплики сохраняются.
+		doc := transcript.NewDocument(c, msgs, meta, transcript.Options{Reasoning: true, Tools: true}, exportedAt)
+		if err := s.renderer.Render(f, doc, transcript.FormatMarkdown); err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, name)
+
+		manifest.Messages += len(msgs)
+		stats.Messages += len(msgs)
+		if n := len(msgs); n > 0 && (stats.LastMessageAt == nil || msgs[n-1].CreatedAt.After(*stats.LastMessageAt)) {
+			last := msgs[n-1].CreatedAt
+			stats.LastMessageAt = &last
+		}
+		stats.ByConversation = append(stats.ByConversation, conversationUsage(c.ID, msgs))
+	}
+	if _, err := io.WriteString(spool, "]\n"); err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	f, err := zw.CreateHeader(header("conversations.json", exportedAt))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, spool); err != nil {
+		return err
+	}
+	manifest.Files = append(manifest.Files, "conversations.json")
+
+	if err := writeJSON(zw, "usage.json", stats, exportedAt); err != nil {
+		return err
+	}
+	manifest.Files = append(manifest.Files, "usage.json", "manifest.json")
+	if err := writeJSON(zw, "manifest.json", manifest, exportedAt); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (s *Service) messages(ctx context.Context, convoID int) ([]models.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.MessageRepository.ListByConversation(convoID)
+}
+
+// MessageCount — сколько сообщений попадёт в выгрузку; по нему решается, собирать ли архив в фоне.
+func (s *Service) MessageCount(user *models.User) (int, error) {
+	usage, err := s.UsageRepository.ForUser(user.ID)
+	if err != nil {
+		return 0, err
+	}
+	return usage.Messages, nil
+}
+
+// Start ставит фоновую сборку архива. Пока предыдущее задание пользователя
+// не завершилось, возвращается оно, а не новое.
+func (s *Service) Start(user *models.User) (*models.ExportJob, error) {
+	s.purgeExpired()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, err := s.ExportJobRepository.FindActiveByUser(user.ID)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+	if active != nil {
+		if s.running[active.ID] {
+			return active, nil
+		}
+		s.fail(active, errors.New("interrupted by server restart"))
+	}
+	job, err := s.ExportJobRepository.Create(&models.ExportJob{UserID: user.ID, Status: models.ExportPending})
+	if err != nil {
+		return nil, err
+	}
+	s.running[job.ID] = true
+	s.wg.Add(1)
+	go s.run(*job, *user)
+	return job, nil
+}
+
+// Wait дожидается всех запущенных заданий; нужен для корректной остановки и тестов.
+func (s *Service) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Service) run(job models.ExportJob, user models.User) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+	}()
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	job.Status = models.ExportRunning
+	if err := s.ExportJobRepository.Update(&job); err != nil {
+		log.Printf("Ошибка обновления задания выгрузки %d: %v", job.ID, err)
+		return
+	}
+	path, size, err := s.writeArchive(&job, &user)
+	if err != nil {
+		log.Printf("Ошибка выгрузки: job_id=%d user_id=%d: %v", job.ID, user.ID, err)
+		s.fail(&job, err)
+		return
+	}
+	now := time.Now().UTC()
+	expires := now.Add(s.Config.TTL)
+	job.Status = models.ExportDone
+	job.FilePath = path
+	job.Size = size
+	job.CompletedAt = &now
+	job.ExpiresAt = &expires
+	if err := s.ExportJobRepository.Update(&job); err != nil {
+		log.Printf("Ошибка обновления задания выгрузки %d: %v", job.ID, err)
+		os.Remove(path)
+		return
+	}
+	log.Printf("Выгрузка готова: job_id=%d user_id=%d size=%d", job.ID, user.ID, size)
+}
+
+// writeArchive пишет во временный файл и переименовывает его только после
+// успешного завершения, чтобы по ссылке не скачался обрезанный архив.
+func (s *Service) writeArchive(job *models.ExportJob, user *models.User) (string, int64, error) {
+	if err := os.MkdirAll(s.Config.Dir, 0o700); err != nil {
+		return "", 0, err
+	}
+	suffix, err := randomToken(8)
+	if err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(s.Config.Dir, strconv.Itoa(job.ID)+"-"+suffix+".zip")
+	tmp, err := os.CreateTemp(s.Config.Dir, "export-*.part")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if err := s.Write(context.Background(), tmp, user); err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+	return path, info.Size(), nil
+}
+
+func (s *Service) fail(job *models.ExportJob, cause error) {
+	now := time.Now().UTC()
+	job.Status = models.ExportFailed
+	job.Error = cause.Error()
+	job.CompletedAt = &now
+	if err := s.ExportJobRepository.Update(job); err != nil {
+		log.Printf("Ошибка обновления задания выгрузки %d: %v", job.ID, err)
+	}
+}
+
+// Job возвращает задание пользователя.
+func (s *Service) Job(id, userID int) (*models.ExportJob, error) {
+	s.purgeExpired()
+	job, err := s.ExportJobRepository.FindByID(id, userID)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, errors.New(ErrJobNotFound)
+	}
+	return job, err
+}
+
+// IssueLink выдаёт новый токен для скачивания готового архива; прежний перестаёт
+// действовать. В базе хранится только хеш, поэтому показать старый токен нельзя.
+// Срок ссылки не продлевается: он привязан к архиву.
+func (s *Service) IssueLink(job *models.ExportJob) (string, error) {
+	if job.Status != models.ExportDone {
+		return "", errors.New(ErrJobNotReady)
+	}
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	hash := hashToken(raw)
+	job.TokenHash = &hash
+	if err := s.ExportJobRepository.Update(job); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// Open находит архив по токену из ссылки. Ссылка многоразовая до истечения срока.
+func (s *Service) Open(raw string) (*os.File, *models.ExportJob, error) {
+	job, err := s.ExportJobRepository.FindByTokenHash(hashToken(raw))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil, errors.New(ErrInvalidToken)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != models.ExportDone || job.ExpiresAt == nil || !time.Now().Before(*job.ExpiresAt) {
+		s.purgeExpired()
+		return nil, nil, errors.New(ErrLinkExpired)
+	}
+	f, err := os.Open(job.FilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, job, nil
+}
+
+// purgeExpired удаляет архивы с истёкшей ссылкой. Отдельного планировщика нет:
+// очистка идёт попутно при работе с выгрузками.
+func (s *Service) purgeExpired() {
+	jobs, err := s.ExportJobRepository.ListExpired(time.Now())
+	if err != nil {
+		log.Printf("Ошибка поиска устаревших выгрузок: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if err := os.Remove(job.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Не удалось удалить архив выгрузки %d: %v", job.ID, err)
+			continue
+		}
+		job.Status = models.ExportExpired
+		job.FilePath = ""
+		job.TokenHash = nil
+		if err := s.ExportJobRepository.Update(&job); err != nil {
+			log.Printf("Ошибка обновления задания выгрузки %d: %v", job.ID, err)
+		}
+	}
+}
+
+func conversationUsage(convoID int, msgs []models.Message) ConversationUsage {
+	u := ConversationUsage{ConversationID: convoID, Messages: len(msgs)}
+	for _, m := range msgs {
+		switch m.Role {
+		case "user":
+			u.UserMessages++
+		case "assistant":
+			u.AssistantMessages++
+		}
+		u.Characters += len([]rune(m.Content))
+	}
+	return u
+}
+
+func header(name string, modified time.Time) *zip.FileHeader {
+	return &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified}
+}
+
+func writeJSON(zw *zip.Writer, name string, v any, modified time.Time) error {
+	f, err := zw.CreateHeader(header(name, modified))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,86 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/pkg/res"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type ImportHandlerDeps struct {
+	Service        *Service
+	UserRepository models.UserStore
+	MaxBytes       int
+}
+
+type ImportHandler struct {
+	ImportHandlerDeps
+}
+
+// NewImportHandler регистрирует импорт на защищённом роутере. API-ключу нужен
+// scope conversations:write — импорт создаёт беседы.
+func NewImportHandler(router *http.ServeMux, deps ImportHandlerDeps) {
+	handler := &ImportHandler{ImportHandlerDeps: deps}
+	router.Handle("/api/import", middleware.RequireScope(middleware.ScopeConversationsWrite)(handler.Import())) // POST ?format=
+}
+
+// Import принимает файл телом запроса или полем file формы multipart/form-data.
+// Формат определяется по содержимому, если не указан в ?format=.
+func (handler *ImportHandler) Import() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format != FormatAuto && !slices.Contains(Formats, format) {
+			http.Error(w, ErrUnknownFormat, http.StatusBadRequest)
+			return
+		}
+		data, err := handler.readFile(w, r)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Failed to read file", http.StatusBadRequest)
+			return
+		}
+		report, err := handler.Service.Import(user.ID, format, data)
+		if err != nil {
+			switch err.Error() {
+			case ErrUnknownFormat, ErrInvalidFile:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				log.Printf("Ошибка импорта: user_id=%d: %v", user.ID, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+		res.Json(w, report, 200)
+	}
+}
+
+func (handler *ImportHandler) readFile(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body := http.MaxBytesReader(w, r.Body, int64(handler.MaxBytes))
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return io.ReadAll(body)
+	}
+	r.Body = body
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
@@ -0,0 +1,452 @@
+// Package importer переносит беседы из выгрузок других чатов: ChatGPT
+// (conversations.json или весь ZIP), собственной выгрузки me-ai и JSONL-логов.
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"me-ai/internal/transcript"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatAuto    = ""
+	FormatChatGPT = "chatgpt"
+	FormatMeAI    = "me-ai"
+	FormatJSONL   = "jsonl"
+)
+
+var Formats = []string{FormatChatGPT, FormatMeAI, FormatJSONL}
+
+var (
+	ErrUnknownFormat = "unknown import format"
+	ErrInvalidFile   = "file is not a supported chat export"
+)
+
+// Conversation — беседа, разобранная из исходного файла. Key — её постоянный
+// идентификатор в источнике, по нему повторный импорт распознаётся как дубликат.
+type Conversation struct {
+	Key       string
+	Title     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Messages  []transcript.Message
+}
+
+// Item — результат разбора одной беседы: ошибка в ней не мешает остальным.
+type Item struct {
+	Conversation
+	Err error
+}
+
+// Parse определяет формат, если он не задан, и разбирает файл. ZIP-архив
+// (выгрузка ChatGPT или me-ai) читается по вложенному conversations.json.
+func Parse(format string, data []byte) (string, []Item, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		inner, err := conversationsFromZip(data)
+		if err != nil {
+			return "", nil, err
+		}
+		data = inner
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if format == FormatAuto {
+		format = detect(data)
+	}
+	switch format {
+	case FormatChatGPT:
+		items, err := parseChatGPT(data)
+		return format, items, err
+	case FormatMeAI:
+		items, err := parseMeAI(data)
+		return format, items, err
+	case FormatJSONL:
+		items, err := parseJSONL(data)
+		return format, items, err
+	case "":
+		return "", nil, errors.New(ErrInvalidFile)
+	}
+	return "", nil, errors.New(ErrUnknownFormat)
+}
+
+func conversationsFromZip(data []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New(ErrInvalidFile)
+	}
+	for _, f := range zr.File {
+		if f.Name != "conversations.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, errors.New(ErrInvalidFile)
+}
+
+// detect смотрит на первую беседу или строку: у ChatGPT есть mapping, у me-ai —
+// messages, остальные объекты считаются строками JSONL.
+func detect(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return ""
+	}
+	var probe struct {
+		Mapping       json.RawMessage `json:"mapping"`
+		Messages      json.RawMessage `json:"messages"`
+		Conversations json.RawMessage `json:"conversations"`
+	}
+	switch trimmed[0] {
+	case '[':
+		var first []json.RawMessage
+		if err := json.Unmarshal(trimmed, &first); err != nil {
+			return ""
+		}
+		if len(first) == 0 {
+			return FormatMeAI
+		}
+		if json.Unmarshal(first[0], &probe) != nil {
+			return ""
+		}
+	case '{':
+		line, _, _ := bytes.Cut(trimmed, []byte("\n"))
+		if json.Unmarshal(line, &probe) != nil && json.Unmarshal(trimmed, &probe) != nil {
+			return ""
+		}
+	default:
+		return ""
+	}
+	switch {
+	case probe.Mapping != nil:
+		return FormatChatGPT
+	case probe.Conversations != nil:
+		return FormatMeAI
+	case probe.Messages != nil && trimmed[0] == '[':
+		return FormatMeAI
+	}
+	return FormatJSONL
+}
+
+// list разбирает JSON-массив поэлементно, чтобы одна битая беседа не ломала весь импорт.
+func list(data []byte) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		trimmed = append([]byte{'['}, append(trimmed, ']')...)
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(trimmed, &raw); err != nil {
+		return nil, errors.New(ErrInvalidFile)
+	}
+	return raw, nil
+}
+
+type chatgptConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     *float64               `json:"create_time"`
+	UpdateTime     *float64               `json:"update_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatgptNode `json:"mapping"`
+}
+
+type chatgptNode struct {
+	ID       string          `json:"id"`
+	Parent   *string         `json:"parent"`
+	Children []string        `json:"children"`
+	Message  *chatgptMessage `json:"message"`
+}
+
+type chatgptMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime *float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+	} `json:"content"`
+	Metadata struct {
+		Hidden bool `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+func parseChatGPT(data []byte) ([]Item, error) {
+	raw, err := list(data)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(raw))
+	for _, r := range raw {
+		var c chatgptConversation
+		if err := json.Unmarshal(r, &c); err != nil {
+			items = append(items, Item{Err: fmt.Errorf("invalid conversation: %w", err)})
+			continue
+		}
+		items = append(items, chatgptItem(c))
+	}
+	return items, nil
+}
+
+// chatgptItem восстанавливает показанную ветку беседы: mapping хранит дерево
+// со всеми перегенерациями, а current_node указывает на последний ответ.
+func chatgptItem(c chatgptConversation) Item {
+	key := c.ID
+	if key == "" {
+		key = c.ConversationID
+	}
+	convo := Conversation{Key: key, Title: c.Title, CreatedAt: unixTime(c.CreateTime), UpdatedAt: unixTime(c.UpdateTime)}
+	if len(c.Mapping) == 0 {
+		return Item{Conversation: convo, Err: errors.New("conversation has no messages")}
+	}
+	node := c.CurrentNode
+	if _, ok := c.Mapping[node]; !ok {
+		node = chatgptLastLeaf(c.Mapping)
+	}
+	var path []chatgptNode
+	seen := map[string]bool{}
+	for node != "" && !seen[node] {
+		seen[node] = true
+		n, ok := c.Mapping[node]
+		if !ok {
+			return Item{Conversation: convo, Err: fmt.Errorf("broken message tree at node %q", node)}
+		}
+		path = append(path, n)
+		if n.Parent == nil {
+			break
+		}
+		node = *n.Parent
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		m := path[i].Message
+		if m == nil || m.Metadata.Hidden {
+			continue
+		}
+		role := normalizeRole(m.Author.Role)
+		content := chatgptContent(m)
+		if role == "" || content == "" {
+			continue
+		}
+		convo.Messages = append(convo.Messages, transcript.Message{Role: role, Content: content, CreatedAt: unixTime(m.CreateTime)})
+	}
+	return Item{Conversation: convo}
+}
+
+// chatgptLastLeaf — запасной путь для выгрузок без current_node: лист, идущий
+// по последним детям от корня.
+func chatgptLastLeaf(mapping map[string]chatgptNode) string {
+	for id, n := range mapping {
+		if n.Parent != nil {
+			continue
+		}
+		seen := map[string]bool{}
+		for len(n.Children) > 0 && !seen[id] {
+			seen[id] = true
+			id = n.Children[len(n.Children)-1]
+			n = mapping[id]
+		}
+		return id
+	}
+	return ""
+}
+
+// chatgptContent собирает текст; картинки и другие вложения пропускаются.
+func chatgptContent(m *chatgptMessage) string {
+	if m.Content.Text != "" {
+		return strings.TrimSpace(m.Content.Text)
+	}
+	var parts []string
+	for _, p := range m.Content.Parts {
+		var s string
+		if json.Unmarshal(p, &s) == nil && strings.TrimSpace(s) != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+// meaiConversation — беседа из /api/account/export, /api/export или conversations.json из ZIP.
+type meaiConversation struct {
+	transcript.Conversation
+	ID json.Number `json:"id"`
+}
+
+func parseMeAI(data []byte) ([]Item, error) {
+	var wrapped struct {
+		Conversations []json.RawMessage `json:"conversations"`
+	}
+	raw := []json.RawMessage(nil)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &wrapped); err != nil {
+			return nil, errors.New(ErrInvalidFile)
+		}
+		raw = wrapped.Conversations
+	} else {
+		var err error
+		if raw, err = list(data); err != nil {
+			return nil, err
+		}
+	}
+	items := make([]Item, 0, len(raw))
+	for _, r := range raw {
+		var c meaiConversation
+		if err := json.Unmarshal(r, &c); err != nil {
+			items = append(items, Item{Err: fmt.Errorf("invalid conversation: %w", err)})
+			continue
+		}
+		convo := Conversation{Title: c.Title, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, Messages: c.Messages}
+		// ID беседы уникален только внутри одного сервера, дата создания отличает
+		// беседы разных установок с одинаковым ID.
+		if c.ID != "" {
+			convo.Key = string(c.ID) + "@" + strconv.FormatInt(c.CreatedAt.UnixMicro(), 10)
+		}
+		for i := range convo.Messages {
+			convo.Messages[i].Role = normalizeRole(convo.Messages[i].Role)
+		}
+		items = append(items, Item{Conversation: convo})
+	}
+	return items, nil
+}
+
+// jsonlLine — одна реплика. Строки с одинаковым conversation_id собираются в беседу,
+// строки без него — в одну общую беседу.
+type jsonlLine struct {
+	ConversationID json.RawMessage `json:"conversation_id"`
+	Title          string          `json:"title"`
+	Role           string          `json:"role"`
+	Content        *string         `json:"content"`
+	CreatedAt      json.RawMessage `json:"created_at"`
+	Timestamp      json.RawMessage `json:"timestamp"`
+}
+
+func parseJSONL(data []byte) ([]Item, error) {
+	var items []Item
+	index := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var line jsonlLine
+		err := json.Unmarshal(text, &line)
+		key := strings.Trim(string(line.ConversationID), `"`)
+		if key == "null" {
+			key = ""
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(items)
+			index[key] = i
+			items = append(items, Item{Conversation: Conversation{Key: key}})
+		}
+		item := &items[i]
+		if item.Err != nil {
+			continue
+		}
+		if err != nil {
+			item.Err = fmt.Errorf("line %d: %w", lineNo, err)
+			continue
+		}
+		role := normalizeRole(line.Role)
+		if role == "" {
+			item.Err = fmt.Errorf("line %d: unknown role %q", lineNo, line.Role)
+			continue
+		}
+		if line.Content == nil {
+			item.Err = fmt.Errorf("line %d: content is required", lineNo)
+			continue
+		}
+		at := line.CreatedAt
+		if at == nil {
+			at = line.Timestamp
+		}
+		created, err := parseTime(at)
+		if err != nil {
+			item.Err = fmt.Errorf("line %d: %w", lineNo, err)
+			continue
+		}
+		if item.Title == "" {
+			item.Title = line.Title
+		}
+		item.Messages = append(item.Messages, transcript.Message{Role: role, Content: *line.Content, CreatedAt: created})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New(ErrInvalidFile)
+	}
+	return items, nil
+}
+
+// normalizeRole приводит роли разных чатов к user/assistant/system; пусто — реплику не импортируем.
+func normalizeRole(role string) string {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "user", "human":
+		return "user"
+	case "assistant", "ai", "bot", "model", "gpt":
+		return "assistant"
+	case "system":
+		return "system"
+	}
+	return ""
+}
+
+// parseTime понимает RFC 3339 и Unix-время в секундах (в том числе дробное).
+func parseTime(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}, nil
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if s == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t, nil
+		}
+		raw = json.RawMessage(s)
+	}
+	f, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s", raw)
+	}
+	return unixTime(&f), nil
+}
+
+func unixTime(f *float64) time.Time {
+	if f == nil || *f <= 0 {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(*f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
+// contentKey — ключ беседы без ID в источнике: хеш её содержимого.
+func contentKey(c Conversation) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", c.Title)
+	for _, m := range c.Messages {
+		fmt.Fprintf(h, "%s\x00%s\x00%d\x00", m.Role, m.Content, m.CreatedAt.UnixMicro())
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
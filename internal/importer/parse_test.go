@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"testing"
+	"time"
+)
+
+// Выгрузка ChatGPT хранит дерево: у первого вопроса два ответа, показан второй.
+const chatgptExport = `[{
+  "id": "c-1",
+  "title": "Регенерация",
+  "create_time": 1700000000.5,
+  "update_time": 1700000100,
+  "current_node": "a2",
+  "mapping": {
+    "root": {"id": "root", "parent": null, "children": ["sys"], "message": null},
+    "sys": {"id": "sys", "parent": "root", "children": ["u1"], "message": {
+      "author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]},
+      "metadata": {"is_visually_hidden_from_conversation": true}}},
+    "u1": {"id": "u1", "parent": "sys", "children": ["a1", "a2"], "message": {
+      "author": {"role": "user"}, "create_time": 1700000001,
+      "content": {"content_type": "multimodal_text", "parts": [{"asset_pointer": "file-1"}, "Что на картинке?"]}}},
+    "a1": {"id": "a1", "parent": "u1", "children": [], "message": {
+      "author": {"role": "assistant"}, "create_time": 1700000002,
+      "content": {"content_type": "text", "parts": ["первый ответ"]}}},
+    "a2": {"id": "a2", "parent": "u1", "children": [], "message": {
+      "author": {"role": "assistant"}, "create_time": 1700000003,
+      "content": {"content_type": "text", "parts": ["второй ответ"]}}}
+  }
+}, {"id": "c-2", "title": "Битая", "current_node": "x", "mapping": {"x": {"id": "x", "parent": "missing"}}}]`
+
+func TestParseChatGPT(t *testing.T) {
+	format, items, err := Parse(FormatAuto, []byte(chatgptExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != FormatChatGPT || len(items) != 2 {
+		t.Fatalf("format %q, %d items", format, len(items))
+	}
+	c := items[0]
+	if c.Err != nil || c.Key != "c-1" || c.Title != "Регенерация" {
+		t.Fatalf("unexpected conversation: %+v", c)
+	}
+	if len(c.Messages) != 2 || c.Messages[0].Content != "Что на картинке?" || c.Messages[1].Content != "второй ответ" {
+		t.Fatalf("unexpected branch: %+v", c.Messages)
+	}
+	if want := time.Unix(1700000000, 5e8).UTC(); !c.CreatedAt.Equal(want) {
+		t.Fatalf("created_at %s, want %s", c.CreatedAt, want)
+	}
+	if items[1].Err == nil {
+		t.Fatal("broken tree parsed without error")
+	}
+}
+
+func TestParseJSONL(t *testing.T) {
+	data := `{"conversation_id": 1, "title": "Первая", "role": "human", "content": "привет", "timestamp": 1700000000}
+{"conversation_id": 1, "role": "ai", "content": "здравствуйте", "created_at": "2023-11-14T22:13:21Z"}
+
+{"conversation_id": "two", "role": "narrator", "content": "?"}
+{"conversation_id": "two", "role": "user", "content": "не попадёт"}`
+	format, items, err := Parse(FormatAuto, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != FormatJSONL || len(items) != 2 {
+		t.Fatalf("format %q, %d items", format, len(items))
+	}
+	first := items[0]
+	if first.Err != nil || first.Key != "1" || first.Title != "Первая" || len(first.Messages) != 2 ||
+		first.Messages[0].Role != "user" || first.Messages[1].Role != "assistant" {
+		t.Fatalf("unexpected conversation: %+v", first)
+	}
+	if items[1].Err == nil || items[1].Key != "two" {
+		t.Fatalf("expected error for unknown role: %+v", items[1])
+	}
+}
+
+func TestParseRejectsUnknownData(t *testing.T) {
+	for _, data := range []string{"", "hello", "[1, 2", "<html></html>"} {
+		if _, _, err := Parse(FormatAuto, []byte(data)); err == nil || err.Error() != ErrInvalidFile {
+			t.Fatalf("Parse(%q) error = %v, want %s", data, err, ErrInvalidFile)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package importer
+
+import (
+	"errors"
+	"log"
+	"me-ai/internal/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	StatusImported  = "imported"
+	StatusDuplicate = "duplicate"
+	StatusFailed    = "failed"
+)
+
+// maxTitle — длина conversations.title; derivedTitle — для названия из первой реплики.
+const (
+	maxTitle     = 255
+	derivedTitle = 80
+)
+
+type Service struct {
+	ConversationRepository models.ConversationStore
+}
+
+func NewService(conversationRepository models.ConversationStore) *Service {
+	return &Service{ConversationRepository: conversationRepository}
+}
+
+// Report — итог импорта по каждой беседе файла в исходном порядке.
+type Report struct {
+	Format        string   `json:"format"`
+	Imported      int      `json:"imported"`
+	Duplicates    int      `json:"duplicates"`
+	Failed        int      `json:"failed"`
+	Conversations []Result `json:"conversations"`
+}
+
+type Result struct {
+	Index          int    `json:"index"`
+	Title          string `json:"title"`
+	SourceID       string `json:"source_id,omitempty"`
+	Status         string `json:"status"`
+	ConversationID int    `json:"conversation_id,omitempty"`
+	Messages       int    `json:"messages"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Import разбирает файл и сохраняет беседы пользователю. Ошибка возвращается,
+// только если файл не удалось прочитать целиком; ошибки отдельных бесед — в отчёте.
+func (s *Service) Import(userID int, format string, data []byte) (*Report, error) {
+	format, items, err := Parse(format, data)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Format: format, Conversations: make([]Result, 0, len(items))}
+	for i, item := range items {
+		result := s.importOne(userID, format, item)
+		result.Index = i
+		switch result.Status {
+		case StatusImported:
+			report.Imported++
+		case StatusDuplicate:
+			report.Duplicates++
+		default:
+			report.Failed++
+		}
+		report.Conversations = append(report.Conversations, result)
+	}
+	log.Printf("Импорт %s: user_id=%d imported=%d duplicates=%d failed=%d",
+		format, userID, report.Imported, report.Duplicates, report.Failed)
+	return report, nil
+}
+
+func (s *Service) importOne(userID int, format string, item Item) Result {
+	result := Result{Title: item.Title, SourceID: item.Key, Status: StatusFailed}
+	if item.Err != nil {
+		result.Error = item.Err.Error()
+		return result
+	}
+	convo, msgs := prepare(userID, item.Conversation)
+	result.Title = convo.Title
+	result.Messages = len(msgs)
+	if len(msgs) == 0 {
+		result.Error = "conversation has no messages"
+		return result
+	}
+	key := item.Key
+	if key == "" {
+		key = contentKey(item.Conversation)
+	} else if len(key) > 255 {
+		key = contentKey(Conversation{Title: key})
+	}
+	convo.ImportSource = &format
+	convo.ImportKey = &key
+	err := s.ConversationRepository.Import(convo, msgs)
+	if errors.Is(err, models.ErrAlreadyImported) {
+		result.Status = StatusDuplicate
+		return result
+	}
+	if err != nil {
+		log.Printf("Ошибка импорта беседы: user_id=%d: %v", userID, err)
+		result.Error = "failed to save conversation"
+		return result
+	}
+	result.Status = StatusImported
+	result.ConversationID = convo.ID
+	return result
+}
+
+// prepare отбрасывает пустые реплики и восстанавливает недостающие даты. Даты
+// делаются строго возрастающими: сообщения читаются с ORDER BY created_at,
+// и при совпадении порядок был бы случайным.
+func prepare(userID int, c Conversation) (*models.Conversation, []models.Message) {
+	created := c.CreatedAt
+	if created.IsZero() {
+		for _, m := range c.Messages {
+			if !m.CreatedAt.IsZero() {
+				created = m.CreatedAt
+				break
+			}
+		}
+	}
+	if created.IsZero() {
+		created = time.Now()
+	}
+	created = created.UTC().Truncate(time.Microsecond)
+
+	msgs := make([]models.Message, 0, len(c.Messages))
+	last := created.Add(-time.Microsecond)
+	for _, m := range c.Messages {
+		if m.Role == "" || strings.TrimSpace(m.Content) == "" {
+			continue
+		}
+		at := m.CreatedAt.UTC().Truncate(time.Microsecond)
+		if at.IsZero() {
+			at = last
+		}
+		if !at.After(last) {
+			at = last.Add(time.Microsecond)
+		}
+		last = at
+		msgs = append(msgs, models.Message{UserID: userID, Role: m.Role, Content: m.Content, CreatedAt: at})
+	}
+
+	updated := c.UpdatedAt.UTC().Truncate(time.Microsecond)
+	if updated.Before(last) {
+		updated = last
+	}
+	if updated.Before(created) {
+		updated = created
+	}
+	return &models.Conversation{
+		UserID:    userID,
+		Title:     title(c.Title, msgs),
+		CreatedAt: created,
+		UpdatedAt: updated,
+	}, msgs
+}
+
+func title(t string, msgs []models.Message) string {
+	limit := maxTitle
+	t = strings.TrimSpace(t)
+	if t == "" {
+		limit = derivedTitle
+		for _, m := range msgs {
+			if m.Role == "user" {
+				t = strings.Join(strings.Fields(m.Content), " ")
+				break
+			}
+		}
+	}
+	if t == "" {
+		return "Импортированная беседа"
+	}
+	if utf8.RuneCountInString(t) > limit {
+		t = string([]rune(t)[:limit-1]) + "…"
+	}
+	return t
+}
@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"errors"
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"net/http"
+)
+
+// authorizer — единая точка проверки доступа для REST и WebSocket:
+// находит текущего пользователя и проверяет, что чат принадлежит ему.
+// Чужие ресурсы отдаются как несуществующие (404), чтобы не раскрывать их наличие.
+type authorizer struct {
+	userRepo         models.UserStore
+	conversationRepo models.ConversationStore
+}
+
+func (a *authorizer) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	return middleware.CurrentUser(w, r, a.userRepo)
+}
+
+func (a *authorizer) ownedConversation(userID, conversationID int) (*models.Conversation, error) {
+	return a.conversationRepo.FindByID(conversationID, userID)
+}
+
+// authorizeConversation пишет 404 или 500 в ответ, если доступ к чату запрещён.
+func (a *authorizer) authorizeConversation(w http.ResponseWriter, userID, conversationID int) (*models.Conversation, bool) {
+	convo, err := a.ownedConversation(userID, conversationID)
+	if err != nil {
+		writeStoreError(w, err, "Conversation not found")
+		return nil, false
+	}
+	return convo, true
+}
+
+func writeStoreError(w http.ResponseWriter, err error, notFound string) {
+	if errors.Is(err, models.ErrNotFound) {
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+	log.Printf("Ошибка репозитория: %v", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
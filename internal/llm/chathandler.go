@@ -2,21 +2,38 @@ package llm
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"me-ai/internal/middleware"
 	"me-ai/internal/models"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+type ChatHandlerDeps struct {
+	LLMService             *LLMService
+	UserRepository         models.UserStore
+	ConversationRepository models.ConversationStore
+	MessageRepository      models.MessageStore
+}
+
 type ChatHandler struct {
-	llmService *LLMService
+	authorizer
+	llmService  *LLMService
+	messageRepo models.MessageStore
 }
 
-func NewChatHandler(llmService *LLMService) *ChatHandler {
+func NewChatHandler(deps ChatHandlerDeps) *ChatHandler {
 	return &ChatHandler{
-		llmService: llmService,
+		authorizer: authorizer{
+			userRepo:         deps.UserRepository,
+			conversationRepo: deps.ConversationRepository,
+		},
+		llmService:  deps.LLMService,
+		messageRepo: deps.MessageRepository,
 	}
 }
 
@@ -26,11 +43,8 @@ func (h *ChatHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	email := middleware.GetUserEmail(r)
-	userRepo := &models.UserRepository{}
-	user, err := userRepo.FindByEmail(email)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -47,71 +61,118 @@ func (h *ChatHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Сообщение и conversation_id обязательны", http.StatusBadRequest)
 		return
 	}
-
-	msgRepo := &models.MessageRepository{}
-	userMsg := &models.Message{
-		ConversationID: req.ConversationID,
-		UserID:         user.ID,
-		Content:        req.Message,
-		Role:           "user",
-		Timestamp:      time.Now(),
+	if _, ok := h.authorizeConversation(w, user.ID, req.ConversationID); !ok {
+		return
 	}
-	_, err = msgRepo.Create(userMsg)
+
+	turn, err := h.messageRepo.CreateTurn(req.ConversationID, user.ID, req.Message)
 	if err != nil {
 		log.Printf("Ошибка сохранения сообщения пользователя: %v", err)
+		http.Error(w, "Не удалось сохранить сообщение", http.StatusInternalServerError)
+		return
 	}
+	h.respond(w, r, turn.UserMessage.ID, &turn.Reply)
+}
 
-	response, err := h.llmService.GenerateResponse(r.Context(), req.Message, req.ConversationID)
-	if err != nil {
-		log.Printf("Ошибка получения ответа от LLM: %v", err)
-		http.Error(w, "Ошибка генерации ответа", http.StatusInternalServerError)
+// Retry заново получает ответ, завершившийся ошибкой или зависший. Повторить
+// можно только последнее сообщение беседы.
+func (h *ChatHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
 		return
 	}
-
-	llmMsg := &models.Message{
-		ConversationID: req.ConversationID,
-		UserID:         user.ID,
-		Content:        response,
-		Role:           "assistant",
-		Timestamp:      time.Now(),
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.RetryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ReplyID == 0 {
+		http.Error(w, "reply_id обязателен", http.StatusBadRequest)
+		return
+	}
+	reply, err := h.messageRepo.RetryReply(req.ReplyID, user.ID, time.Now().Add(-staleReply))
+	if errors.Is(err, models.ErrNotRetryable) {
+		http.Error(w, "Ответ нельзя повторить: он не завершился ошибкой или уже не последний в чате", http.StatusConflict)
+		return
 	}
-	_, err = msgRepo.Create(llmMsg)
 	if err != nil {
-		log.Printf("Ошибка сохранения сообщения LLM: %v", err)
+		writeStoreError(w, err, "Message not found")
+		return
 	}
+	h.respond(w, r, "", reply)
+}
 
-	chatResponse := models.ChatResponse{
-		Message:   response,
-		Timestamp: time.Now().Format(time.RFC3339),
+// respond получает ответ модели для reply в статусе pending и сохраняет его.
+// При ошибке модели ответ остаётся в статусе failed, а клиент получает 500 с
+// его id для повтора.
+func (h *ChatHandler) respond(w http.ResponseWriter, r *http.Request, userMessageID string, reply *models.Message) {
+	content, genErr := h.llmService.Respond(r.Context(), reply.ConversationID)
+	if genErr != nil {
+		log.Printf("Ошибка получения ответа от LLM: %v", genErr)
+	}
+	if err := finishReply(h.messageRepo, reply, content, genErr); err != nil {
+		log.Printf("Ошибка сохранения ответа LLM: %v", err)
+		http.Error(w, "Не удалось сохранить ответ", http.StatusInternalServerError)
+		return
+	}
+	response := models.ChatResponse{
+		Message:       reply.Content,
+		Timestamp:     time.Now().Format(time.RFC3339),
+		UserMessageID: userMessageID,
+		ReplyID:       reply.ID,
+		Status:        reply.Status,
+	}
+	status := http.StatusOK
+	if genErr != nil {
+		response.Error = "Ошибка генерации ответа"
+		status = http.StatusInternalServerError
 	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(chatResponse)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
 }
 
+// ListConversations отдаёт беседы страницами, новые (по updated_at) первыми.
+// Архивные беседы по умолчанию скрыты.
 func (h *ChatHandler) ListConversations(w http.ResponseWriter, r *http.Request) {
-	email := middleware.GetUserEmail(r)
-	userRepo := &models.UserRepository{}
-	user, err := userRepo.FindByEmail(email)
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	query, limit, withTotal, err := parsePage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter, err := parseConversationFilter(r.URL.Query())
 	if err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	repo := &models.ConversationRepository{}
-	convos, err := repo.ListByUser(user.ID)
+	convos, err := h.conversationRepo.ListPage(user.ID, filter, query)
 	if err != nil {
 		http.Error(w, "Failed to get conversations", http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(convos)
+	var response ConversationPage
+	response.Conversations, response.Page = buildPage(convos, conversationCursor, query, limit, true)
+	if withTotal {
+		total, err := h.conversationRepo.CountByUser(user.ID, filter)
+		if err != nil {
+			http.Error(w, "Failed to get conversations", http.StatusInternalServerError)
+			return
+		}
+		response.Total = &total
+	}
+	if response.Conversations == nil {
+		response.Conversations = []models.Conversation{}
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *ChatHandler) CreateConversation(w http.ResponseWriter, r *http.Request) {
-	email := middleware.GetUserEmail(r)
-	userRepo := &models.UserRepository{}
-	user, err := userRepo.FindByEmail(email)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 	var req models.CreateConversationRequest
@@ -124,8 +185,7 @@ func (h *ChatHandler) CreateConversation(w http.ResponseWriter, r *http.Request)
 		Title:  req.Title,
 	}
 
-	repo := &models.ConversationRepository{}
-	created, err := repo.Create(convo)
+	created, err := h.conversationRepo.Create(convo)
 	if err != nil {
 		http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
 		return
@@ -134,11 +194,8 @@ func (h *ChatHandler) CreateConversation(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *ChatHandler) DeleteConversation(w http.ResponseWriter, r *http.Request) {
-	email := middleware.GetUserEmail(r)
-	userRepo := &models.UserRepository{}
-	user, err := userRepo.FindByEmail(email)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 	var req models.DeleteConversationRequest
@@ -146,20 +203,16 @@ func (h *ChatHandler) DeleteConversation(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	repo := &models.ConversationRepository{}
-	if err := repo.Delete(req.ID, user.ID); err != nil {
-		http.Error(w, "Failed to delete conversation", http.StatusInternalServerError)
+	if err := h.conversationRepo.Delete(req.ID, user.ID); err != nil {
+		writeStoreError(w, err, "Conversation not found")
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *ChatHandler) RenameConversation(w http.ResponseWriter, r *http.Request) {
-	email := middleware.GetUserEmail(r)
-	userRepo := &models.UserRepository{}
-	user, err := userRepo.FindByEmail(email)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 	var req struct {
@@ -174,17 +227,22 @@ func (h *ChatHandler) RenameConversation(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "id и title обязательны", http.StatusBadRequest)
 		return
 	}
-	repo := &models.ConversationRepository{}
-	if err := repo.UpdateTitle(req.ID, user.ID, req.Title); err != nil {
-		log.Printf("Ошибка обновления названия чата: %v", err)
-		http.Error(w, "Failed to rename conversation", http.StatusInternalServerError)
+	if err := h.conversationRepo.UpdateTitle(req.ID, user.ID, req.Title); err != nil {
+		writeStoreError(w, err, "Conversation not found")
 		return
 	}
 	log.Printf("Переименование чата: user_id=%d, chat_id=%d, title=%s", user.ID, req.ID, req.Title)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// ListMessages отдаёт сообщения страницами: без курсора — самые новые, before —
+// более старые для прокрутки вверх, after — появившиеся позже. Внутри страницы
+// сообщения идут по времени.
 func (h *ChatHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
 	convoID := r.URL.Query().Get("conversation_id")
 	if convoID == "" {
 		http.Error(w, "Missing conversation_id", http.StatusBadRequest)
@@ -195,21 +253,38 @@ func (h *ChatHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid conversation_id", http.StatusBadRequest)
 		return
 	}
-	repo := &models.MessageRepository{}
-	msgs, err := repo.ListByConversation(id)
+	if _, ok := h.authorizeConversation(w, user.ID, id); !ok {
+		return
+	}
+	query, limit, withTotal, err := parsePage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	msgs, err := h.messageRepo.ListPage(id, query)
 	if err != nil {
 		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(msgs)
+	var response MessagePage
+	response.Messages, response.Page = buildPage(msgs, messageCursor, query, limit, false)
+	if withTotal {
+		total, err := h.messageRepo.CountByConversation(id)
+		if err != nil {
+			http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+			return
+		}
+		response.Total = &total
+	}
+	if response.Messages == nil {
+		response.Messages = []models.Message{}
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *ChatHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
-	email := middleware.GetUserEmail(r)
-	userRepo := &models.UserRepository{}
-	user, err := userRepo.FindByEmail(email)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
 	var req models.DeleteMessageRequest
@@ -217,10 +292,112 @@ func (h *ChatHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	repo := &models.MessageRepository{}
-	if err := repo.Delete(req.ID, user.ID); err != nil {
-		http.Error(w, "Failed to delete message", http.StatusInternalServerError)
+	if err := h.messageRepo.Delete(req.ID, user.ID); err != nil {
+		writeStoreError(w, err, "Message not found")
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// EditMessage правит сообщение пользователя или ассистента, прежний текст
+// остаётся в ревизиях. С regenerate сообщения после правленого уходят в
+// корзину, и модель отвечает заново.
+func (h *ChatHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req models.EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.ID == 0 || strings.TrimSpace(req.Content) == "" {
+		http.Error(w, "id и content обязательны", http.StatusBadRequest)
+		return
+	}
+	msg, err := h.messageRepo.FindByID(req.ID, user.ID)
+	if err != nil {
+		writeStoreError(w, err, "Message not found")
+		return
+	}
+	if msg.Role != "user" && msg.Role != "assistant" {
+		http.Error(w, "Редактировать можно только сообщения пользователя и ассистента", http.StatusBadRequest)
+		return
+	}
+	if req.Regenerate && msg.Role != "user" {
+		http.Error(w, "regenerate допустим только для сообщения пользователя", http.StatusBadRequest)
+		return
+	}
+	// Незавершённый ответ перезапишет генерация, а неудачный повторяют через /api/chat/retry.
+	if msg.Status != models.MessageComplete {
+		http.Error(w, "Ответ ещё не завершён", http.StatusConflict)
+		return
+	}
+	// Новый ответ модели — то же, что отправка сообщения в чат.
+	if req.Regenerate && !middleware.HasScope(r, middleware.ScopeChatWrite) {
+		http.Error(w, "API key lacks scope "+middleware.ScopeChatWrite, http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !req.Regenerate {
+		edited, err := h.messageRepo.Edit(req.ID, user.ID, req.Content)
+		if err != nil {
+			writeStoreError(w, err, "Message not found")
+			return
+		}
+		json.NewEncoder(w).Encode(models.EditMessageResponse{Message: *edited})
+		return
+	}
+	// Правка и новый pending-ответ сохраняются вместе: при ошибке не остаётся
+	// изменённого сообщения без ответа.
+	edited, reply, err := h.messageRepo.EditAndRegenerate(req.ID, user.ID, req.Content)
+	if errors.Is(err, models.ErrReplyInProgress) {
+		http.Error(w, "Ответ ещё не завершён", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "Message not found")
+		return
+	}
+	status := http.StatusOK
+	content, genErr := h.llmService.Respond(r.Context(), reply.ConversationID)
+	if genErr != nil {
+		log.Printf("Ошибка получения ответа от LLM: %v", genErr)
+		status = http.StatusInternalServerError
+	}
+	if err := finishReply(h.messageRepo, reply, content, genErr); err != nil {
+		log.Printf("Ошибка сохранения ответа LLM: %v", err)
+		http.Error(w, "Не удалось сохранить ответ", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Ответ получен заново после правки: user_id=%d, message_id=%d, status=%s", user.ID, req.ID, reply.Status)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.EditMessageResponse{Message: *edited, Reply: reply})
+}
+
+// ListRevisions отдаёт прежние тексты сообщения, старые первыми.
+func (h *ChatHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id <= 0 {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+	revs, err := h.messageRepo.ListRevisions(id, user.ID)
+	if err != nil {
+		writeStoreError(w, err, "Message not found")
+		return
+	}
+	if revs == nil {
+		revs = []models.MessageRevision{}
+	}
+	json.NewEncoder(w).Encode(revs)
+}
@@ -12,10 +12,18 @@ import (
 	"time"
 )
 
+// Model и Persona — модель и системный промпт, с которыми отвечает ассистент.
+// Их же показывают метаданные выгрузки беседы.
+const (
+	Model   = "model9"
+	Persona = "Ты - Коротеев Степан Петрович, тебе 20 лет, ты учишься в НИЯУ МИФИ, факультет Бизнес-информатика. Отвечай только на поставленный вопрос, ничего лишнего не говори."
+)
+
 type LLMService struct {
-	URL    string
-	ApiKey string
-	Client *http.Client
+	URL               string
+	ApiKey            string
+	Client            *http.Client
+	MessageRepository models.MessageStore
 }
 
 type OllamaMessage struct {
@@ -38,19 +46,19 @@ type OllamaResponse struct {
 	Done    bool          `json:"done"`
 }
 
-func NewLLMService(url, apikey string) *LLMService {
+func NewLLMService(url, apikey string, messageRepository models.MessageStore) *LLMService {
 	return &LLMService{
 		URL:    url,
 		ApiKey: apikey,
 		Client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		MessageRepository: messageRepository,
 	}
 }
 
-func getHistory(conversationID int) ([]OllamaMessage, error) {
-	repo := &models.MessageRepository{}
-	msgs, err := repo.ListByConversation(conversationID)
+func (s *LLMService) getHistory(conversationID int) ([]OllamaMessage, error) {
+	msgs, err := s.MessageRepository.ListByConversation(conversationID)
 	if err != nil {
 		return nil, err
 	}
@@ -64,18 +72,22 @@ func getHistory(conversationID int) ([]OllamaMessage, error) {
 	return history, nil
 }
 
-func (s *LLMService) GenerateResponse(ctx context.Context, message string, conversationID int) (string, error) {
-	history, err := getHistory(conversationID)
+// Respond отвечает на историю беседы — её последнее сообщение пользователя уже
+// сохранено вместе с ответом в pending, который в историю не входит.
+func (s *LLMService) Respond(ctx context.Context, conversationID int) (string, error) {
+	history, err := s.getHistory(conversationID)
 	if err != nil {
 		return "", fmt.Errorf("ошибка получения истории: %w", err)
 	}
-	history = append(history, OllamaMessage{Role: "user", Content: message})
+	return s.complete(ctx, history)
+}
 
+func (s *LLMService) complete(ctx context.Context, history []OllamaMessage) (string, error) {
 	reqBody := OllamaRequest{
-		Model:         "model9",
+		Model:         Model,
 		Stream:        false,
 		Messages:      history,
-		System:        "Ты - Коротеев Степан Петрович, тебе 20 лет, ты учишься в НИЯУ МИФИ, факультет Бизнес-информатика. Отвечай только на поставленный вопрос, ничего лишнего не говори.",
+		System:        Persona,
 		Temperature:   0.2,
 		TopP:          0.8,
 		RepeatPenalty: 1.15,
@@ -114,19 +126,18 @@ func (s *LLMService) GenerateResponse(ctx context.Context, message string, conve
 	return ollamaResp.Message.Content, nil
 }
 
-func (s *LLMService) GenerateStreamResponse(ctx context.Context, message string, conversationID int, callback func(string)) error {
-	history, err := getHistory(conversationID)
+// RespondStream — Respond с передачей ответа по фрагментам в callback.
+func (s *LLMService) RespondStream(ctx context.Context, conversationID int, callback func(string)) error {
+	history, err := s.getHistory(conversationID)
 	if err != nil {
 		return fmt.Errorf("ошибка получения истории: %w", err)
 	}
 
-	history = append(history, OllamaMessage{Role: "user", Content: message})
-
 	reqBody := OllamaRequest{
-		Model:         "model9",
+		Model:         Model,
 		Stream:        true,
 		Messages:      history,
-		System:        "Ты - Коротеев Степан Петрович, тебе 20 лет, ты учишься в НИЯУ МИФИ, факультет \"Бизнес-информатика. Отвечай только на поставленный вопрос, ничего лишнего не говори.",
+		System:        Persona,
 		Temperature:   0.2,
 		TopP:          0.8,
 		RepeatPenalty: 1.15,
@@ -152,12 +163,18 @@ func (s *LLMService) GenerateStreamResponse(ctx context.Context, message string,
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Ollama API вернул статус %d: %s", resp.StatusCode, string(body))
+	}
+
 	dec := json.NewDecoder(resp.Body)
 	for {
 		var chunk OllamaResponse
 		if err := dec.Decode(&chunk); err != nil {
+			// Поток без done оборван: ответ неполный, и сохранять его как готовый нельзя.
 			if err == io.EOF {
-				break
+				return fmt.Errorf("поток оборвался до завершения ответа")
 			}
 			return fmt.Errorf("ошибка декодирования chunk: %w", err)
 		}
@@ -167,8 +184,54 @@ func (s *LLMService) GenerateStreamResponse(ctx context.Context, message string,
 			callback(cleaned)
 		}
 		if chunk.Done {
-			break
+			return nil
 		}
 	}
-	return nil
+}
+
+type OllamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OllamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Embed возвращает векторы текстов в том же порядке через /api/embed.
+func (s *LLMService) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	jsonData, err := json.Marshal(OllamaEmbedRequest{Model: model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка маршалинга запроса: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL+"/api/embed", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.ApiKey)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Ollama API вернул статус %d: %s", resp.StatusCode, string(body))
+	}
+
+	var embedResp OllamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("ошибка декодирования ответа: %w", err)
+	}
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ожидалось %d векторов, получено %d", len(texts), len(embedResp.Embeddings))
+	}
+	return embedResp.Embeddings, nil
 }
@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"me-ai/internal/models"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// Page — метаданные keyset-страницы. BeforeCursor передаётся в before= за более
+// старыми записями, AfterCursor — в after= за более новыми; HasBefore и HasAfter
+// говорят, есть ли они сейчас. Total заполняется только по запросу total=true.
+type Page struct {
+	BeforeCursor string `json:"before_cursor,omitempty"`
+	AfterCursor  string `json:"after_cursor,omitempty"`
+	HasBefore    bool   `json:"has_before"`
+	HasAfter     bool   `json:"has_after"`
+	Total        *int   `json:"total,omitempty"`
+}
+
+type ConversationPage struct {
+	Conversations []models.Conversation `json:"conversations"`
+	Page
+}
+
+type MessagePage struct {
+	Messages []models.Message `json:"messages"`
+	Page
+}
+
+// parsePage разбирает limit, before, after и total. Limit в ответе на единицу
+// больше запрошенного: лишняя запись показывает, есть ли продолжение.
+func parsePage(v url.Values) (models.PageQuery, int, bool, error) {
+	var q models.PageQuery
+	limit := defaultPageLimit
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return q, 0, false, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		limit = n
+	}
+	before, after := v.Get("before"), v.Get("after")
+	if before != "" && after != "" {
+		return q, 0, false, errors.New("before and after are mutually exclusive")
+	}
+	var err error
+	if before != "" {
+		if q.Before, err = decodePageCursor(before); err != nil {
+			return q, 0, false, errors.New("invalid before cursor")
+		}
+	}
+	if after != "" {
+		if q.After, err = decodePageCursor(after); err != nil {
+			return q, 0, false, errors.New("invalid after cursor")
+		}
+	}
+	total, _ := strconv.ParseBool(v.Get("total"))
+	q.Limit = limit + 1
+	return q, limit, total, nil
+}
+
+// buildPage обрезает выборку до limit, считает курсоры и возвращает записи
+// по возрастанию времени или, с newestFirst, по убыванию.
+func buildPage[T any](items []T, key func(T) models.PageCursor, q models.PageQuery, limit int, newestFirst bool) ([]T, Page) {
+	var page Page
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	if q.After != nil {
+		page.HasAfter, page.HasBefore = more, true
+	} else {
+		page.HasBefore, page.HasAfter = more, q.Before != nil
+		// Выборка шла от новых к старым.
+		slices.Reverse(items)
+	}
+	if len(items) > 0 {
+		page.BeforeCursor = encodePageCursor(key(items[0]))
+		page.AfterCursor = encodePageCursor(key(items[len(items)-1]))
+	} else {
+		// Пустая страница возвращает тот же курсор, чтобы можно было опрашивать дальше.
+		if q.Before != nil {
+			page.BeforeCursor = encodePageCursor(*q.Before)
+		}
+		if q.After != nil {
+			page.AfterCursor = encodePageCursor(*q.After)
+		}
+	}
+	if newestFirst {
+		slices.Reverse(items)
+	}
+	return items, page
+}
+
+func encodePageCursor(c models.PageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageCursor(s string) (*models.PageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c models.PageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.ID <= 0 {
+		return nil, errors.New("invalid id")
+	}
+	return &c, nil
+}
+
+// parseConversationFilter разбирает archived (false по умолчанию, true, all),
+// pinned (true, false), folder_id (id или none) и повторяемый tag.
+func parseConversationFilter(v url.Values) (models.ConversationFilter, error) {
+	var f models.ConversationFilter
+	switch v.Get("archived") {
+	case "", "false":
+		f.Archived = new(bool)
+	case "true":
+		archived := true
+		f.Archived = &archived
+	case "all":
+	default:
+		return f, errors.New("archived must be true, false or all")
+	}
+	if s := v.Get("pinned"); s != "" {
+		pinned, err := strconv.ParseBool(s)
+		if err != nil {
+			return f, errors.New("pinned must be true or false")
+		}
+		f.Pinned = &pinned
+	}
+	if s := v.Get("folder_id"); s == "none" {
+		f.NoFolder = true
+	} else if s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			return f, errors.New("invalid folder_id")
+		}
+		f.FolderID = &id
+	}
+	f.Tags = v["tag"]
+	return f, nil
+}
+
+func conversationCursor(c models.Conversation) models.PageCursor {
+	return models.PageCursor{Time: c.UpdatedAt, ID: c.ID}
+}
+
+func messageCursor(m models.Message) models.PageCursor {
+	id, _ := strconv.Atoi(m.ID)
+	return models.PageCursor{Time: m.CreatedAt, ID: id}
+}
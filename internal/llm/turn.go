@@ -0,0 +1,26 @@
+package llm
+
+import (
+	"me-ai/internal/models"
+	"strconv"
+	"time"
+)
+
+// staleReply — через сколько незавершённый ответ считается зависшим и его можно
+// повторить. Заметно больше таймаута запроса к модели.
+const staleReply = 5 * time.Minute
+
+// finishReply сохраняет итог ответа: complete или, при ошибке генерации,
+// failed с уже полученной частью текста.
+func finishReply(repo models.MessageStore, reply *models.Message, content string, genErr error) error {
+	reply.Status, reply.Content = models.MessageComplete, content
+	if genErr != nil {
+		reply.Status = models.MessageFailed
+	}
+	return repo.UpdateReply(messageID(reply), reply.Status, reply.Content)
+}
+
+func messageID(m *models.Message) int {
+	id, _ := strconv.Atoi(m.ID)
+	return id
+}
@@ -1,12 +1,13 @@
 package llm
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"sync"
 
-	"me-ai/internal/middleware"
 	"me-ai/internal/models"
-	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -19,31 +20,56 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// wsConn сериализует запись: gorilla/websocket не допускает конкурентных writer'ов,
+// а ответ модели пишется из отдельной горутины.
+type wsConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *wsConn) WriteJSON(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
+type WebSocketHandlerDeps struct {
+	LLMService             *LLMService
+	UserRepository         models.UserStore
+	ConversationRepository models.ConversationStore
+	MessageRepository      models.MessageStore
+}
+
 type WebSocketHandler struct {
-	llmService *LLMService
+	authorizer
+	llmService  *LLMService
+	messageRepo models.MessageStore
 }
 
-func NewWebSocketHandler(llmService *LLMService) *WebSocketHandler {
+func NewWebSocketHandler(deps WebSocketHandlerDeps) *WebSocketHandler {
 	return &WebSocketHandler{
-		llmService: llmService,
+		authorizer: authorizer{
+			userRepo:         deps.UserRepository,
+			conversationRepo: deps.ConversationRepository,
+		},
+		llmService:  deps.LLMService,
+		messageRepo: deps.MessageRepository,
 	}
 }
 
 func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("Ошибка обновления соединения: %v", err)
+	user, ok := h.currentUser(w, r)
+	if !ok {
 		return
 	}
-	defer conn.Close()
 
-	email := middleware.GetUserEmail(r)
-	userRepo := &models.UserRepository{}
-	user, err := userRepo.FindByEmail(email)
+	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("User not found: %v", err)
+		log.Printf("Ошибка обновления соединения: %v", err)
 		return
 	}
+	defer ws.Close()
+	conn := &wsConn{Conn: ws}
 
 	log.Println("Новое WebSocket соединение")
 
@@ -71,80 +97,107 @@ func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Reques
 				})
 				continue
 			}
-
-			msgRepo := &models.MessageRepository{}
-			userMsg := &models.Message{
-				ConversationID: msg.ConversationID,
-				UserID:         user.ID,
-				Content:        msg.Content,
-				Role:           "user",
-				Timestamp:      time.Now(),
+			if _, err := h.ownedConversation(user.ID, msg.ConversationID); err != nil {
+				errText := "Чат не найден"
+				if !errors.Is(err, models.ErrNotFound) {
+					log.Printf("Ошибка проверки доступа к чату: %v", err)
+					errText = "Не удалось проверить доступ к чату"
+				}
+				conn.WriteJSON(models.WebSocketMessage{
+					Type:    "error",
+					Content: errText,
+					Role:    "system",
+				})
+				continue
 			}
-			_, err := msgRepo.Create(userMsg)
+
+			turn, err := h.messageRepo.CreateTurn(msg.ConversationID, user.ID, msg.Content)
 			if err != nil {
 				log.Printf("Ошибка сохранения сообщения пользователя: %v", err)
+				conn.WriteJSON(models.WebSocketMessage{
+					Type:    "error",
+					Content: "Не удалось сохранить сообщение",
+					Role:    "system",
+				})
+				continue
 			}
 
 			userMsgOut := models.WebSocketMessage{
-				Type:    "user_message",
-				Content: msg.Content,
-				Role:    "user",
+				Type:      "user_message",
+				Content:   msg.Content,
+				Role:      "user",
+				MessageID: turn.UserMessage.ID,
+				ReplyID:   turn.Reply.ID,
 			}
 			conn.WriteJSON(userMsgOut)
 
-			go h.handleLLMResponse(conn, msg.Content, msg.ConversationID, user.ID)
+			go h.handleLLMResponse(r.Context(), conn, turn.Reply)
 		}
 	}
 }
 
-func (h *WebSocketHandler) handleLLMResponse(conn *websocket.Conn, message string, conversationID int, userID int) {
+// handleLLMResponse передаёт ответ модели по фрагментам и сохраняет его до
+// assistant_complete: клиент видит только то, что уже записано. Оборванный
+// поток сохраняется со статусом failed вместе с полученной частью.
+func (h *WebSocketHandler) handleLLMResponse(ctx context.Context, conn *wsConn, reply models.Message) {
 
 	typingMsg := models.WebSocketMessage{
 		Type:    "typing",
 		Content: "LLM думает...",
 		Role:    "assistant",
+		ReplyID: reply.ID,
 	}
 	conn.WriteJSON(typingMsg)
 
 	var fullResponse string
-	err := h.llmService.GenerateStreamResponse(nil, message, conversationID, func(chunk string) {
+	streaming := false
+	err := h.llmService.RespondStream(ctx, reply.ConversationID, func(chunk string) {
+		if !streaming {
+			streaming = true
+			if err := h.messageRepo.UpdateReply(messageID(&reply), models.MessageStreaming, ""); err != nil {
+				log.Printf("Ошибка обновления статуса ответа: %v", err)
+			}
+		}
 		fullResponse += chunk
 		streamMsg := models.WebSocketMessage{
 			Type:    "assistant_chunk",
 			Content: chunk,
 			Role:    "assistant",
+			ReplyID: reply.ID,
 		}
 		conn.WriteJSON(streamMsg)
 	})
-
 	if err != nil {
 		log.Printf("Ошибка генерации ответа: %v", err)
+	}
+
+	if serr := finishReply(h.messageRepo, &reply, fullResponse, err); serr != nil {
+		log.Printf("Ошибка сохранения сообщения LLM: %v", serr)
+		conn.WriteJSON(models.WebSocketMessage{
+			Type:    "error",
+			Content: "Не удалось сохранить ответ",
+			Role:    "system",
+			ReplyID: reply.ID,
+		})
+		return
+	}
+	if err != nil {
 		errorMsg := models.WebSocketMessage{
 			Type:    "error",
 			Content: "Извините, произошла ошибка при генерации ответа",
 			Role:    "system",
+			ReplyID: reply.ID,
 		}
 		conn.WriteJSON(errorMsg)
 		return
 	}
 
-	msgRepo := &models.MessageRepository{}
-	llmMsg := &models.Message{
-		ConversationID: conversationID,
-		UserID:         userID,
-		Content:        fullResponse,
-		Role:           "assistant",
-		Timestamp:      time.Now(),
-	}
-	_, err = msgRepo.Create(llmMsg)
-	if err != nil {
-		log.Printf("Ошибка сохранения сообщения LLM: %v", err)
-	}
-
 	finalMsg := models.WebSocketMessage{
-		Type:    "assistant_complete",
-		Content: fullResponse,
-		Role:    "assistant",
+		Type:      "assistant_complete",
+		Content:   fullResponse,
+		Role:      "assistant",
+		MessageID: reply.ID,
+		ReplyID:   reply.ID,
 	}
 	conn.WriteJSON(finalMsg)
 }
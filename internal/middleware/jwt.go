@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"me-ai/internal/models"
 	"me-ai/pkg/jwt"
 	"net/http"
 	"strings"
@@ -9,9 +10,23 @@ import (
 
 type contextKey string
 
-const UserEmailKey contextKey = "user_email"
+const (
+	UserEmailKey contextKey = "user_email"
+	UserRoleKey  contextKey = "user_role"
+	ScopesKey    contextKey = "scopes"
+	SessionKey   contextKey = "session"
+)
+
+// APIKeyPrefix отличает персональные API-ключи от JWT в заголовке Authorization.
+const APIKeyPrefix = "meai_"
+
+// APIKeyVerifier проверяет персональный API-ключ и возвращает владельца и его scopes.
+type APIKeyVerifier interface {
+	Verify(raw string) (email string, scopes []string, err error)
+}
 
-func JWTAuth(secret string) func(http.Handler) http.Handler {
+// JWTAuth принимает Bearer JWT, а если передан apiKeys — и персональные API-ключи.
+func JWTAuth(j *jwt.JWT, apiKeys APIKeyVerifier) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			header := r.Header.Get("Authorization")
@@ -20,12 +35,26 @@ func JWTAuth(secret string) func(http.Handler) http.Handler {
 				return
 			}
 			token := strings.TrimPrefix(header, "Bearer ")
-			ok, data := jwt.NewJWT(secret).Parse(token)
-			if !ok || data == nil {
+			if apiKeys != nil && strings.HasPrefix(token, APIKeyPrefix) {
+				email, scopes, err := apiKeys.Verify(token)
+				if err != nil {
+					http.Error(w, "Invalid API key", http.StatusUnauthorized)
+					return
+				}
+				ctx := context.WithValue(r.Context(), UserEmailKey, email)
+				ctx = context.WithValue(ctx, ScopesKey, scopes)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			ok, data := j.Parse(token)
+			// Токены без typ или с другим назначением (например, challenge 2FA) не дают доступа к API.
+			if !ok || data == nil || data.Purpose != jwt.PurposeAccess {
 				http.Error(w, "Invalid token", http.StatusUnauthorized)
 				return
 			}
 			ctx := context.WithValue(r.Context(), UserEmailKey, data.Login)
+			ctx = context.WithValue(ctx, UserRoleKey, data.Role)
+			ctx = context.WithValue(ctx, SessionKey, data.Session)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -39,3 +68,26 @@ func GetUserEmail(r *http.Request) string {
 	}
 	return ""
 }
+
+// CurrentUser находит владельца токена запроса. Если его нет или аккаунт
+// заблокирован, отвечает 401 или 403 и возвращает false.
+func CurrentUser(w http.ResponseWriter, r *http.Request, users models.UserStore) (*models.User, bool) {
+	user, err := users.FindByEmail(GetUserEmail(r))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return nil, false
+	}
+	if user.Disabled() {
+		http.Error(w, "Account disabled", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
+
+// GetSessionID возвращает ID сессии из access token; пусто для API-ключей и старых токенов.
+func GetSessionID(r *http.Request) string {
+	if session, ok := r.Context().Value(SessionKey).(string); ok {
+		return session
+	}
+	return ""
+}
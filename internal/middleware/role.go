@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+)
+
+// GetUserRole возвращает роль из access token. У API-ключей роли нет.
+func GetUserRole(r *http.Request) string {
+	if role, ok := r.Context().Value(UserRoleKey).(string); ok {
+		return role
+	}
+	return ""
+}
+
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(roles, GetUserRole(r)) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
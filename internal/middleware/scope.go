@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+)
+
+const (
+	ScopeChatWrite          = "chat:write"
+	ScopeConversationsRead  = "conversations:read"
+	ScopeConversationsWrite = "conversations:write"
+)
+
+var Scopes = []string{
+	ScopeChatWrite,
+	ScopeConversationsRead,
+	ScopeConversationsWrite,
+}
+
+// IsAPIKey сообщает, аутентифицирован ли запрос API-ключом, а не сессией.
+func IsAPIKey(r *http.Request) bool {
+	_, ok := r.Context().Value(ScopesKey).([]string)
+	return ok
+}
+
+// HasScope: сессионный JWT даёт полный доступ, API-ключ — только выданные scopes.
+func HasScope(r *http.Request, scope string) bool {
+	scopes, ok := r.Context().Value(ScopesKey).([]string)
+	if !ok {
+		return true
+	}
+	return slices.Contains(scopes, scope)
+}
+
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r, scope) {
+				http.Error(w, "API key lacks scope "+scope, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SessionOnly закрывает маршрут для API-ключей, например управление самими ключами.
+func SessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsAPIKey(r) {
+			http.Error(w, "This endpoint requires a user session", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/pkg/db"
+	"strings"
+	"time"
+)
+
+type APIKey struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Scopes     string     `json:"-" db:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+type APIKeyStore interface {
+	Create(key *APIKey) (*APIKey, error)
+	FindByPrefix(prefix string) (*APIKey, error)
+	ListByUser(userID int) ([]APIKey, error)
+	Revoke(id, userID int) error
+	TouchLastUsed(id int) error
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, last_used_at, revoked_at, created_at"
+
+type APIKeyRepository struct{}
+
+func (r *APIKeyRepository) Create(key *APIKey) (*APIKey, error) {
+	query := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, key.UserID, key.Name, key.Prefix, key.KeyHash, key.Scopes).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *APIKeyRepository) FindByPrefix(prefix string) (*APIKey, error) {
+	var key APIKey
+	err := db.DB.Get(&key, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix=$1", prefix)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) ListByUser(userID int) ([]APIKey, error) {
+	var keys []APIKey
+	err := db.DB.Select(&keys, "SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id=$1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *APIKeyRepository) Revoke(id, userID int) error {
+	result, err := db.DB.Exec("UPDATE api_keys SET revoked_at=NOW() WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL", id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *APIKeyRepository) TouchLastUsed(id int) error {
+	_, err := db.DB.Exec("UPDATE api_keys SET last_used_at=NOW() WHERE id=$1", id)
+	return err
+}
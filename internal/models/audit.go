@@ -0,0 +1,43 @@
+package models
+
+import (
+	"me-ai/pkg/db"
+	"time"
+)
+
+type AuditEntry struct {
+	ID         int       `json:"id" db:"id"`
+	ActorID    *int      `json:"actor_id" db:"actor_id"`
+	Action     string    `json:"action" db:"action"`
+	TargetType string    `json:"target_type" db:"target_type"`
+	TargetID   *int      `json:"target_id" db:"target_id"`
+	Details    string    `json:"details" db:"details"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+type AuditLogStore interface {
+	Create(entry *AuditEntry) (*AuditEntry, error)
+	List(limit int) ([]AuditEntry, error)
+}
+
+const auditColumns = "id, actor_id, action, target_type, target_id, details, created_at"
+
+type AuditLogRepository struct{}
+
+func (r *AuditLogRepository) Create(entry *AuditEntry) (*AuditEntry, error) {
+	query := `INSERT INTO admin_audit_log (actor_id, action, target_type, target_id, details) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, entry.Details).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *AuditLogRepository) List(limit int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	err := db.DB.Select(&entries, "SELECT "+auditColumns+" FROM admin_audit_log ORDER BY id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"database/sql"
+	"errors"
 	"me-ai/pkg/db"
 	"time"
 )
@@ -12,6 +14,17 @@ type Conversation struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 	Messages  []Message `json:"messages,omitempty"`
+	// ImportSource и ImportKey заданы у импортированных бесед: формат и ID в исходном чате.
+	ImportSource *string `json:"-" db:"import_source"`
+	ImportKey    *string `json:"-" db:"import_key"`
+	// PinnedAt и ArchivedAt заданы у закреплённых и архивных бесед.
+	PinnedAt   *time.Time `json:"pinned_at,omitempty" db:"pinned_at"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty" db:"archived_at"`
+	FolderID   *int       `json:"folder_id,omitempty" db:"folder_id"`
+	// DeletedAt задан у бесед в корзине.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// Tags заполняется только в списке бесед.
+	Tags []string `json:"tags,omitempty" db:"-"`
 }
 
 type CreateConversationRequest struct {
@@ -38,6 +51,38 @@ func (c *Conversation) ToResponse() ConversationResponse {
 	}
 }
 
+type ConversationStore interface {
+	Create(convo *Conversation) (*Conversation, error)
+	FindByID(id, userID int) (*Conversation, error)
+	// FindAnyByID ищет беседу без проверки владельца, в том числе в корзине, — только для модерации.
+	FindAnyByID(id int) (*Conversation, error)
+	ListByUser(userID int) ([]Conversation, error)
+	// ListPage — страница бесед пользователя по updated_at с тегами.
+	ListPage(userID int, filter ConversationFilter, page PageQuery) ([]Conversation, error)
+	CountByUser(userID int, filter ConversationFilter) (int, error)
+	// Delete переносит беседу в корзину; беседы в корзине не видны остальным методам.
+	Delete(id, userID int) error
+	UpdateTitle(id, userID int, title string) error
+	// Import сохраняет беседу с сообщениями как есть, с исходными датами, одной
+	// транзакцией. Повтор того же ImportSource+ImportKey — ErrAlreadyImported.
+	Import(convo *Conversation, msgs []Message) error
+	// Bulk применяет действие ко всем беседам одной транзакцией. Если хотя бы
+	// одна беседа или папка не принадлежит пользователю — ErrNotFound без изменений.
+	Bulk(userID int, action BulkAction) error
+	// ListDeleted — беседы пользователя в корзине, недавно удалённые первыми.
+	ListDeleted(userID int) ([]Conversation, error)
+	Restore(id, userID int) error
+	// Purge окончательно удаляет беседу из корзины.
+	Purge(id, userID int) error
+	// PurgeDeleted окончательно удаляет беседы, попавшие в корзину раньше before;
+	// userID = 0 — у всех пользователей.
+	PurgeDeleted(userID int, before time.Time) (int, error)
+}
+
+// conversationColumns перечисляет колонки явно: новые колонки таблицы (как
+// search_vector) не ломают чтение в структуру.
+const conversationColumns = "id, user_id, title, created_at, updated_at, import_source, import_key, pinned_at, archived_at, folder_id, deleted_at"
+
 type ConversationRepository struct{}
 
 func (r *ConversationRepository) Create(convo *Conversation) (*Conversation, error) {
@@ -49,21 +94,113 @@ func (r *ConversationRepository) Create(convo *Conversation) (*Conversation, err
 	return convo, nil
 }
 
+func (r *ConversationRepository) FindByID(id, userID int) (*Conversation, error) {
+	var convo Conversation
+	err := db.DB.Get(&convo, "SELECT "+conversationColumns+" FROM conversations WHERE id=$1 AND user_id=$2 AND deleted_at IS NULL", id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &convo, nil
+}
+
+func (r *ConversationRepository) FindAnyByID(id int) (*Conversation, error) {
+	var convo Conversation
+	err := db.DB.Get(&convo, "SELECT "+conversationColumns+" FROM conversations WHERE id=$1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &convo, nil
+}
+
 func (r *ConversationRepository) ListByUser(userID int) ([]Conversation, error) {
 	var convos []Conversation
-	err := db.DB.Select(&convos, "SELECT * FROM conversations WHERE user_id=$1 ORDER BY updated_at DESC", userID)
+	err := db.DB.Select(&convos, "SELECT "+conversationColumns+" FROM conversations WHERE user_id=$1 AND deleted_at IS NULL ORDER BY updated_at DESC, id DESC", userID)
 	if err != nil {
 		return nil, err
 	}
 	return convos, nil
 }
 
+func (r *ConversationRepository) ListPage(userID int, filter ConversationFilter, page PageQuery) ([]Conversation, error) {
+	where, args := filter.where(userID)
+	query, args := pageQuery("SELECT "+conversationColumns+" FROM conversations WHERE "+where, "updated_at", args, page)
+	var convos []Conversation
+	if err := db.DB.Select(&convos, query, args...); err != nil {
+		return nil, err
+	}
+	if err := attachTags(convos); err != nil {
+		return nil, err
+	}
+	return convos, nil
+}
+
+func (r *ConversationRepository) CountByUser(userID int, filter ConversationFilter) (int, error) {
+	where, args := filter.where(userID)
+	var n int
+	err := db.DB.Get(&n, "SELECT COUNT(*) FROM conversations WHERE "+where, args...)
+	return n, err
+}
+
 func (r *ConversationRepository) Delete(id, userID int) error {
-	_, err := db.DB.Exec("DELETE FROM conversations WHERE id=$1 AND user_id=$2", id, userID)
-	return err
+	result, err := db.DB.Exec("UPDATE conversations SET deleted_at=NOW() WHERE id=$1 AND user_id=$2 AND deleted_at IS NULL", id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
 }
 
 func (r *ConversationRepository) UpdateTitle(id, userID int, title string) error {
-	_, err := db.DB.Exec("UPDATE conversations SET title=$1, updated_at=NOW() WHERE id=$2 AND user_id=$3", title, id, userID)
-	return err
+	result, err := db.DB.Exec("UPDATE conversations SET title=$1, updated_at=NOW() WHERE id=$2 AND user_id=$3 AND deleted_at IS NULL", title, id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *ConversationRepository) Import(convo *Conversation, msgs []Message) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `INSERT INTO conversations (user_id, title, created_at, updated_at, import_source, import_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, import_source, import_key) DO NOTHING
+		RETURNING id`
+	err = tx.QueryRowx(query, convo.UserID, convo.Title, convo.CreatedAt, convo.UpdatedAt, convo.ImportSource, convo.ImportKey).Scan(&convo.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAlreadyImported
+	}
+	if err != nil {
+		return err
+	}
+	for i := range msgs {
+		m := &msgs[i]
+		m.ConversationID = convo.ID
+		query := `INSERT INTO messages (conversation_id, user_id, role, content, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+		if err := tx.QueryRowx(query, m.ConversationID, m.UserID, m.Role, m.Content, m.CreatedAt).Scan(&m.ID); err != nil {
+			return err
+		}
+		m.Timestamp = m.CreatedAt
+	}
+	return tx.Commit()
+}
+
+// expectAffected превращает изменение нуля строк в ErrNotFound: запросы
+// фильтруют по владельцу, поэтому чужая запись неотличима от отсутствующей.
+func expectAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/binary"
+	"math"
+	"me-ai/pkg/db"
+)
+
+// MessageEmbedding — вектор сообщения с беседой, к которой оно относится.
+type MessageEmbedding struct {
+	MessageID      int
+	ConversationID int
+	Vector         []float32
+}
+
+type EmbeddingStats struct {
+	Model string `json:"model"`
+	// Indexed — сообщения с вектором текущей модели.
+	Indexed int `json:"indexed"`
+	// Skipped — сообщения, которые провайдер отказался обработать.
+	Skipped int `json:"skipped"`
+	// Pending — сообщения, ещё ждущие индексации.
+	Pending int `json:"pending"`
+}
+
+type EmbeddingStore interface {
+	// ListPending — сообщения без вектора этой модели, старые первыми.
+	ListPending(model string, limit int) ([]Message, error)
+	// Save сохраняет вектор сообщения; пустой vector помечает сообщение пропущенным.
+	Save(messageID int, model string, vector []float32) error
+	// ListByUser — векторы сообщений пользователя; conversationID = 0 — по всем беседам.
+	ListByUser(userID int, model string, conversationID int) ([]MessageEmbedding, error)
+	Stats(model string) (*EmbeddingStats, error)
+}
+
+type EmbeddingRepository struct{}
+
+func (r *EmbeddingRepository) ListPending(model string, limit int) ([]Message, error) {
+	query := `SELECT m.id, m.conversation_id, m.role, m.content, m.created_at
+		FROM messages m
+		LEFT JOIN message_embeddings e ON e.message_id = m.id AND e.model = $1
+		WHERE e.message_id IS NULL AND m.deleted_at IS NULL AND m.status = 'complete'
+		ORDER BY m.id
+		LIMIT $2`
+	var msgs []Message
+	if err := db.DB.Select(&msgs, query, model, limit); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (r *EmbeddingRepository) Save(messageID int, model string, vector []float32) error {
+	query := `INSERT INTO message_embeddings (message_id, model, dims, vector) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (message_id) DO UPDATE
+		SET model = EXCLUDED.model, dims = EXCLUDED.dims, vector = EXCLUDED.vector, created_at = NOW()`
+	_, err := db.DB.Exec(query, messageID, model, len(vector), encodeVector(vector))
+	return err
+}
+
+func (r *EmbeddingRepository) ListByUser(userID int, model string, conversationID int) ([]MessageEmbedding, error) {
+	query := `SELECT e.message_id, m.conversation_id, e.vector
+		FROM message_embeddings e
+		JOIN messages m ON m.id = e.message_id
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE c.user_id = $1 AND c.deleted_at IS NULL AND m.deleted_at IS NULL AND e.model = $2 AND e.dims > 0 AND ($3 = 0 OR m.conversation_id = $3)`
+	rows, err := db.DB.Query(query, userID, model, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []MessageEmbedding
+	for rows.Next() {
+		var e MessageEmbedding
+		var raw []byte
+		if err := rows.Scan(&e.MessageID, &e.ConversationID, &raw); err != nil {
+			return nil, err
+		}
+		e.Vector = decodeVector(raw)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (r *EmbeddingRepository) Stats(model string) (*EmbeddingStats, error) {
+	stats := EmbeddingStats{Model: model}
+	query := `SELECT
+			COUNT(*) FILTER (WHERE e.dims > 0),
+			COUNT(*) FILTER (WHERE e.dims = 0),
+			COUNT(*) FILTER (WHERE e.message_id IS NULL)
+		FROM messages m
+		LEFT JOIN message_embeddings e ON e.message_id = m.id AND e.model = $1
+		WHERE m.deleted_at IS NULL`
+	err := db.DB.QueryRow(query, model).Scan(&stats.Indexed, &stats.Skipped, &stats.Pending)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,19 @@
+package models
+
+import "errors"
+
+// ErrNotFound возвращается репозиториями, когда запись не существует
+// или принадлежит другому пользователю.
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyImported — беседа из того же источника уже импортирована этим пользователем.
+var ErrAlreadyImported = errors.New("already imported")
+
+// ErrDuplicate — у пользователя уже есть запись с таким именем.
+var ErrDuplicate = errors.New("already exists")
+
+// ErrNotRetryable — ответ не завершился ошибкой или уже не последний в беседе.
+var ErrNotRetryable = errors.New("reply is not retryable")
+
+// ErrReplyInProgress — после сообщения в беседе ещё генерируется ответ.
+var ErrReplyInProgress = errors.New("reply in progress")
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/pkg/db"
+	"time"
+)
+
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+	// ExportExpired — архив удалён по истечении срока ссылки.
+	ExportExpired = "expired"
+)
+
+type ExportJob struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Status      string     `json:"status" db:"status"`
+	FilePath    string     `json:"-" db:"file_path"`
+	Size        int64      `json:"size" db:"size"`
+	TokenHash   *string    `json:"-" db:"token_hash"`
+	Error       string     `json:"error,omitempty" db:"error"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// Active — задание ещё выполняется или ждёт очереди.
+func (j *ExportJob) Active() bool {
+	return j.Status == ExportPending || j.Status == ExportRunning
+}
+
+type ExportJobStore interface {
+	Create(job *ExportJob) (*ExportJob, error)
+	FindByID(id, userID int) (*ExportJob, error)
+	FindByTokenHash(hash string) (*ExportJob, error)
+	// FindActiveByUser возвращает незавершённое задание пользователя или ErrNotFound.
+	FindActiveByUser(userID int) (*ExportJob, error)
+	// Update сохраняет статус, файл, токен, ошибку и сроки задания.
+	Update(job *ExportJob) error
+	// ListExpired — готовые задания с истёкшей ссылкой, чьи файлы пора удалить.
+	ListExpired(now time.Time) ([]ExportJob, error)
+}
+
+const exportJobColumns = "id, user_id, status, file_path, size, token_hash, error, expires_at, created_at, completed_at"
+
+type ExportJobRepository struct{}
+
+func (r *ExportJobRepository) Create(job *ExportJob) (*ExportJob, error) {
+	if job.Status == "" {
+		job.Status = ExportPending
+	}
+	query := `INSERT INTO export_jobs (user_id, status) VALUES ($1, $2) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, job.UserID, job.Status).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *ExportJobRepository) FindByID(id, userID int) (*ExportJob, error) {
+	return r.get("SELECT "+exportJobColumns+" FROM export_jobs WHERE id=$1 AND user_id=$2", id, userID)
+}
+
+func (r *ExportJobRepository) FindByTokenHash(hash string) (*ExportJob, error) {
+	return r.get("SELECT "+exportJobColumns+" FROM export_jobs WHERE token_hash=$1", hash)
+}
+
+func (r *ExportJobRepository) FindActiveByUser(userID int) (*ExportJob, error) {
+	return r.get("SELECT "+exportJobColumns+" FROM export_jobs WHERE user_id=$1 AND status IN ($2, $3) ORDER BY id DESC LIMIT 1",
+		userID, ExportPending, ExportRunning)
+}
+
+func (r *ExportJobRepository) get(query string, args ...any) (*ExportJob, error) {
+	var job ExportJob
+	err := db.DB.Get(&job, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *ExportJobRepository) Update(job *ExportJob) error {
+	query := `UPDATE export_jobs SET status=$1, file_path=$2, size=$3, token_hash=$4, error=$5, expires_at=$6, completed_at=$7
+		WHERE id=$8`
+	result, err := db.DB.Exec(query, job.Status, job.FilePath, job.Size, job.TokenHash, job.Error, job.ExpiresAt, job.CompletedAt, job.ID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *ExportJobRepository) ListExpired(now time.Time) ([]ExportJob, error) {
+	var jobs []ExportJob
+	err := db.DB.Select(&jobs, "SELECT "+exportJobColumns+" FROM export_jobs WHERE status=$1 AND expires_at <= $2", ExportDone, now)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"me-ai/pkg/db"
+	"time"
+)
+
+type Folder struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"-" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// Conversations — число бесед в папке, включая архивные.
+	Conversations int `json:"conversations" db:"conversations"`
+}
+
+type FolderStore interface {
+	// Create возвращает ErrDuplicate, если папка с таким именем уже есть.
+	Create(folder *Folder) (*Folder, error)
+	ListByUser(userID int) ([]Folder, error)
+	Rename(id, userID int, name string) error
+	// Delete удаляет папку; её беседы остаются вне папок.
+	Delete(id, userID int) error
+}
+
+type FolderRepository struct{}
+
+func (r *FolderRepository) Create(folder *Folder) (*Folder, error) {
+	query := `INSERT INTO folders (user_id, name) VALUES ($1, $2) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, folder.UserID, folder.Name).Scan(&folder.ID, &folder.CreatedAt)
+	if db.IsUniqueViolation(err) {
+		return nil, ErrDuplicate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+func (r *FolderRepository) ListByUser(userID int) ([]Folder, error) {
+	query := `SELECT f.id, f.user_id, f.name, f.created_at, COUNT(c.id) AS conversations
+		FROM folders f
+		LEFT JOIN conversations c ON c.folder_id = f.id AND c.deleted_at IS NULL
+		WHERE f.user_id = $1
+		GROUP BY f.id
+		ORDER BY f.name`
+	var folders []Folder
+	if err := db.DB.Select(&folders, query, userID); err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (r *FolderRepository) Rename(id, userID int, name string) error {
+	result, err := db.DB.Exec("UPDATE folders SET name=$1 WHERE id=$2 AND user_id=$3", name, id, userID)
+	if db.IsUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *FolderRepository) Delete(id, userID int) error {
+	result, err := db.DB.Exec("DELETE FROM folders WHERE id=$1 AND user_id=$2", id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
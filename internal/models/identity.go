@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/pkg/db"
+	"time"
+)
+
+// Identity связывает пользователя с учётной записью у внешнего OIDC-провайдера.
+type Identity struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Provider  string    `json:"provider" db:"provider"`
+	Subject   string    `json:"subject" db:"subject"`
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type IdentityStore interface {
+	Create(identity *Identity) (*Identity, error)
+	FindBySubject(provider, subject string) (*Identity, error)
+	ListByUser(userID int) ([]Identity, error)
+}
+
+const identityColumns = "id, user_id, provider, subject, email, created_at"
+
+type IdentityRepository struct{}
+
+func (r *IdentityRepository) Create(identity *Identity) (*Identity, error) {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, identity.UserID, identity.Provider, identity.Subject, identity.Email).Scan(&identity.ID, &identity.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (r *IdentityRepository) FindBySubject(provider, subject string) (*Identity, error) {
+	var identity Identity
+	err := db.DB.Get(&identity, "SELECT "+identityColumns+" FROM user_identities WHERE provider=$1 AND subject=$2", provider, subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *IdentityRepository) ListByUser(userID int) ([]Identity, error) {
+	var identities []Identity
+	err := db.DB.Select(&identities, "SELECT "+identityColumns+" FROM user_identities WHERE user_id=$1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"me-ai/pkg/db"
+	"time"
+)
+
+const (
+	LockoutScopeAccount = "account"
+	LockoutScopeIP      = "ip"
+)
+
+// LoginLockout — запись о временной блокировке входа. UserID пуст, если
+// перебирали несуществующий адрес или блокировка по IP.
+type LoginLockout struct {
+	ID             int       `json:"id" db:"id"`
+	Scope          string    `json:"scope" db:"scope"`
+	UserID         *int      `json:"user_id" db:"user_id"`
+	Email          string    `json:"email" db:"email"`
+	IP             string    `json:"ip" db:"ip"`
+	FailedAttempts int       `json:"failed_attempts" db:"failed_attempts"`
+	LockedUntil    time.Time `json:"locked_until" db:"locked_until"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+type LoginLockoutStore interface {
+	Create(lockout *LoginLockout) (*LoginLockout, error)
+	List(limit int) ([]LoginLockout, error)
+}
+
+const loginLockoutColumns = "id, scope, user_id, email, ip, failed_attempts, locked_until, created_at"
+
+type LoginLockoutRepository struct{}
+
+func (r *LoginLockoutRepository) Create(lockout *LoginLockout) (*LoginLockout, error) {
+	query := `INSERT INTO login_lockouts (scope, user_id, email, ip, failed_attempts, locked_until)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, lockout.Scope, lockout.UserID, lockout.Email, lockout.IP, lockout.FailedAttempts, lockout.LockedUntil).
+		Scan(&lockout.ID, &lockout.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return lockout, nil
+}
+
+func (r *LoginLockoutRepository) List(limit int) ([]LoginLockout, error) {
+	var lockouts []LoginLockout
+	err := db.DB.Select(&lockouts, "SELECT "+loginLockoutColumns+" FROM login_lockouts ORDER BY id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	return lockouts, nil
+}
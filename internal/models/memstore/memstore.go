@@ -0,0 +1,1901 @@
+// Package memstore содержит in-memory реализации репозиториев models
+// для тестов и запуска без PostgreSQL.
+package memstore
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/internal/models"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var ErrUniqueViolation = errors.New("memstore: unique constraint violation")
+
+type Store struct {
+	mu            sync.Mutex
+	users         []models.User
+	conversations []models.Conversation
+	messages      []models.Message
+	refreshTokens []models.RefreshToken
+	apiKeys       []models.APIKey
+	auditLog      []models.AuditEntry
+	userTokens    []models.UserToken
+	lockouts      []models.LoginLockout
+	recoveryCodes []models.RecoveryCode
+	identities    []models.Identity
+	exportJobs    []models.ExportJob
+	embeddings    map[int]embedding
+	folders       []models.Folder
+	tags          []models.Tag
+	// conversationTags — пары беседа–тег, как в conversation_tags.
+	conversationTags []conversationTag
+	shares           []models.Share
+	revisions        []models.MessageRevision
+	nextID           int
+}
+
+func New() *Store {
+	return &Store{}
+}
+
+func (s *Store) id() int {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Store) Users() *UserRepository {
+	return &UserRepository{store: s}
+}
+
+func (s *Store) Conversations() *ConversationRepository {
+	return &ConversationRepository{store: s}
+}
+
+func (s *Store) Messages() *MessageRepository {
+	return &MessageRepository{store: s}
+}
+
+func (s *Store) RefreshTokens() *RefreshTokenRepository {
+	return &RefreshTokenRepository{store: s}
+}
+
+func (s *Store) APIKeys() *APIKeyRepository {
+	return &APIKeyRepository{store: s}
+}
+
+func (s *Store) AuditLog() *AuditLogRepository {
+	return &AuditLogRepository{store: s}
+}
+
+func (s *Store) UserTokens() *UserTokenRepository {
+	return &UserTokenRepository{store: s}
+}
+
+func (s *Store) LoginLockouts() *LoginLockoutRepository {
+	return &LoginLockoutRepository{store: s}
+}
+
+func (s *Store) RecoveryCodes() *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{store: s}
+}
+
+func (s *Store) Identities() *IdentityRepository {
+	return &IdentityRepository{store: s}
+}
+
+func (s *Store) ExportJobs() *ExportJobRepository {
+	return &ExportJobRepository{store: s}
+}
+
+func (s *Store) Search() *SearchRepository {
+	return &SearchRepository{store: s}
+}
+
+func (s *Store) Embeddings() *EmbeddingRepository {
+	return &EmbeddingRepository{store: s}
+}
+
+func (s *Store) Folders() *FolderRepository {
+	return &FolderRepository{store: s}
+}
+
+func (s *Store) Tags() *TagRepository {
+	return &TagRepository{store: s}
+}
+
+func (s *Store) Shares() *ShareRepository {
+	return &ShareRepository{store: s}
+}
+
+func (s *Store) Usage() *UsageRepository {
+	return &UsageRepository{store: s}
+}
+
+type UserRepository struct {
+	store *Store
+}
+
+func (r *UserRepository) FindByID(id int) (*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, u := range r.store.users {
+		if u.ID == id {
+			return &u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, u := range r.store.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *UserRepository) Create(user *models.User) (*models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, u := range r.store.users {
+		if u.Email == user.Email {
+			return nil, ErrUniqueViolation
+		}
+	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	user.ID = r.store.id()
+	user.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	r.store.users = append(r.store.users, *user)
+	return user, nil
+}
+
+func (r *UserRepository) List() ([]models.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	users := make([]models.User, len(r.store.users))
+	copy(users, r.store.users)
+	return users, nil
+}
+
+func (r *UserRepository) SetDisabled(id int, disabled bool) error {
+	return r.update(id, func(u *models.User) {
+		if !disabled {
+			u.DisabledAt = nil
+		} else if u.DisabledAt == nil {
+			now := time.Now().UTC()
+			u.DisabledAt = &now
+		}
+	})
+}
+
+func (r *UserRepository) SetRole(id int, role string) error {
+	return r.update(id, func(u *models.User) { u.Role = role })
+}
+
+func (r *UserRepository) MarkEmailVerified(id int) error {
+	return r.update(id, func(u *models.User) {
+		if u.EmailVerifiedAt == nil {
+			now := time.Now().UTC()
+			u.EmailVerifiedAt = &now
+		}
+	})
+}
+
+func (r *UserRepository) UpdatePassword(id int, passwordHash string) error {
+	return r.update(id, func(u *models.User) { u.Password = passwordHash })
+}
+
+func (r *UserRepository) UpdateName(id int, name string) error {
+	return r.update(id, func(u *models.User) { u.Name = name })
+}
+
+func (r *UserRepository) SetPendingEmail(id int, email *string) error {
+	return r.update(id, func(u *models.User) { u.PendingEmail = email })
+}
+
+func (r *UserRepository) ChangeEmail(id int, email string) error {
+	r.store.mu.Lock()
+	for _, u := range r.store.users {
+		if u.Email == email && u.ID != id {
+			r.store.mu.Unlock()
+			return ErrUniqueViolation
+		}
+	}
+	r.store.mu.Unlock()
+	return r.update(id, func(u *models.User) {
+		now := time.Now().UTC()
+		u.Email = email
+		u.PendingEmail = nil
+		u.EmailVerifiedAt = &now
+	})
+}
+
+// Delete повторяет ON DELETE CASCADE / SET NULL из миграций.
+func (r *UserRepository) Delete(id int) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	s.users = slices.DeleteFunc(s.users, func(u models.User) bool {
+		if u.ID == id {
+			found = true
+		}
+		return u.ID == id
+	})
+	if !found {
+		return models.ErrNotFound
+	}
+	convos := map[int]bool{}
+	s.conversations = slices.DeleteFunc(s.conversations, func(c models.Conversation) bool {
+		if c.UserID == id {
+			convos[c.ID] = true
+		}
+		return c.UserID == id
+	})
+	s.messages = slices.DeleteFunc(s.messages, func(m models.Message) bool { return convos[m.ConversationID] })
+	s.conversationTags = slices.DeleteFunc(s.conversationTags, func(ct conversationTag) bool { return convos[ct.conversationID] })
+	s.folders = slices.DeleteFunc(s.folders, func(f models.Folder) bool { return f.UserID == id })
+	s.tags = slices.DeleteFunc(s.tags, func(t models.Tag) bool { return t.UserID == id })
+	s.refreshTokens = slices.DeleteFunc(s.refreshTokens, func(t models.RefreshToken) bool { return t.UserID == id })
+	s.apiKeys = slices.DeleteFunc(s.apiKeys, func(k models.APIKey) bool { return k.UserID == id })
+	s.userTokens = slices.DeleteFunc(s.userTokens, func(t models.UserToken) bool { return t.UserID == id })
+	s.recoveryCodes = slices.DeleteFunc(s.recoveryCodes, func(c models.RecoveryCode) bool { return c.UserID == id })
+	s.identities = slices.DeleteFunc(s.identities, func(i models.Identity) bool { return i.UserID == id })
+	s.exportJobs = slices.DeleteFunc(s.exportJobs, func(j models.ExportJob) bool { return j.UserID == id })
+	s.shares = slices.DeleteFunc(s.shares, func(sh models.Share) bool { return sh.UserID == id })
+	for i := range s.auditLog {
+		if e := &s.auditLog[i]; e.ActorID != nil && *e.ActorID == id {
+			e.ActorID = nil
+		}
+	}
+	for i := range s.lockouts {
+		if l := &s.lockouts[i]; l.UserID != nil && *l.UserID == id {
+			l.UserID = nil
+		}
+	}
+	return nil
+}
+
+func (r *UserRepository) SetTOTPSecret(id int, secret string) error {
+	return r.update(id, func(u *models.User) {
+		u.TOTPSecret = &secret
+		u.TOTPEnabledAt = nil
+	})
+}
+
+func (r *UserRepository) EnableTOTP(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.users {
+		u := &r.store.users[i]
+		if u.ID == id && u.TOTPSecret != nil {
+			now := time.Now().UTC()
+			u.TOTPEnabledAt = &now
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+func (r *UserRepository) DisableTOTP(id int) error {
+	return r.update(id, func(u *models.User) {
+		u.TOTPSecret = nil
+		u.TOTPEnabledAt = nil
+		u.TOTPLastStep = 0
+	})
+}
+
+func (r *UserRepository) UseTOTPStep(id int, step int64) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.users {
+		u := &r.store.users[i]
+		if u.ID == id && u.TOTPLastStep < step {
+			u.TOTPLastStep = step
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *UserRepository) update(id int, apply func(*models.User)) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.users {
+		if r.store.users[i].ID == id {
+			apply(&r.store.users[i])
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+type ConversationRepository struct {
+	store *Store
+}
+
+func (r *ConversationRepository) Create(convo *models.Conversation) (*models.Conversation, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	now := time.Now().UTC()
+	convo.ID = r.store.id()
+	convo.CreatedAt = now
+	convo.UpdatedAt = now
+	r.store.conversations = append(r.store.conversations, *convo)
+	return convo, nil
+}
+
+func (r *ConversationRepository) FindByID(id, userID int) (*models.Conversation, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if c := r.store.conversation(id, userID); c != nil {
+		convo := *c
+		return &convo, nil
+	}
+	return nil, models.ErrNotFound
+}
+
+func (r *ConversationRepository) FindAnyByID(id int) (*models.Conversation, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, c := range r.store.conversations {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+// conversation возвращает беседу владельца вне корзины; вызывается под s.mu.
+func (s *Store) conversation(id, userID int) *models.Conversation {
+	for i := range s.conversations {
+		c := &s.conversations[i]
+		if c.ID == id && c.UserID == userID && c.DeletedAt == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+func (r *ConversationRepository) ListByUser(userID int) ([]models.Conversation, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var convos []models.Conversation
+	for _, c := range r.store.conversations {
+		if c.UserID == userID && c.DeletedAt == nil {
+			convos = append(convos, c)
+		}
+	}
+	sort.SliceStable(convos, func(i, j int) bool {
+		return convos[i].UpdatedAt.After(convos[j].UpdatedAt)
+	})
+	return convos, nil
+}
+
+func (r *ConversationRepository) ListPage(userID int, filter models.ConversationFilter, q models.PageQuery) ([]models.Conversation, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var convos []models.Conversation
+	for _, c := range r.store.conversations {
+		if c.UserID == userID && r.store.matches(c, filter) {
+			convos = append(convos, c)
+		}
+	}
+	convos = page(convos, func(c models.Conversation) models.PageCursor {
+		return models.PageCursor{Time: c.UpdatedAt, ID: c.ID}
+	}, q)
+	for i := range convos {
+		convos[i].Tags = r.store.tagsOf(convos[i].ID)
+	}
+	return convos, nil
+}
+
+func (r *ConversationRepository) CountByUser(userID int, filter models.ConversationFilter) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	n := 0
+	for _, c := range r.store.conversations {
+		if c.UserID == userID && r.store.matches(c, filter) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// matches — аналог ConversationFilter.where; вызывается под s.mu.
+func (s *Store) matches(c models.Conversation, f models.ConversationFilter) bool {
+	if c.DeletedAt != nil {
+		return false
+	}
+	if f.Archived != nil && (c.ArchivedAt != nil) != *f.Archived {
+		return false
+	}
+	if f.Pinned != nil && (c.PinnedAt != nil) != *f.Pinned {
+		return false
+	}
+	if f.FolderID != nil && (c.FolderID == nil || *c.FolderID != *f.FolderID) {
+		return false
+	}
+	if f.FolderID == nil && f.NoFolder && c.FolderID != nil {
+		return false
+	}
+	tags := s.tagsOf(c.ID)
+	for _, tag := range f.Tags {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// tagsOf — имена тегов беседы по алфавиту; вызывается под s.mu.
+func (s *Store) tagsOf(convoID int) []string {
+	var names []string
+	for _, ct := range s.conversationTags {
+		if ct.conversationID != convoID {
+			continue
+		}
+		for _, t := range s.tags {
+			if t.ID == ct.tagID {
+				names = append(names, t.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *ConversationRepository) Bulk(userID int, action models.BulkAction) error {
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var convos []*models.Conversation
+	for _, id := range action.IDs {
+		c := s.conversation(id, userID)
+		if c == nil {
+			return models.ErrNotFound
+		}
+		convos = append(convos, c)
+	}
+	now := time.Now().UTC()
+	switch action.Action {
+	case models.BulkMove:
+		if action.FolderID != nil && !slices.ContainsFunc(s.folders, func(f models.Folder) bool {
+			return f.ID == *action.FolderID && f.UserID == userID
+		}) {
+			return models.ErrNotFound
+		}
+		for _, c := range convos {
+			c.FolderID = action.FolderID
+		}
+	case models.BulkPin, models.BulkUnpin:
+		for _, c := range convos {
+			if action.Action == models.BulkUnpin {
+				c.PinnedAt = nil
+			} else if c.PinnedAt == nil {
+				c.PinnedAt = &now
+			}
+		}
+	case models.BulkArchive, models.BulkUnarchive:
+		for _, c := range convos {
+			if action.Action == models.BulkUnarchive {
+				c.ArchivedAt = nil
+			} else if c.ArchivedAt == nil {
+				c.ArchivedAt = &now
+			}
+		}
+	case models.BulkDelete:
+		for _, c := range convos {
+			c.DeletedAt = &now
+		}
+	case models.BulkTag:
+		for _, name := range action.Tags {
+			tag := s.tag(userID, name)
+			if tag == nil {
+				s.tags = append(s.tags, models.Tag{ID: s.id(), UserID: userID, Name: name, CreatedAt: now})
+				tag = &s.tags[len(s.tags)-1]
+			}
+			for _, c := range convos {
+				link := conversationTag{conversationID: c.ID, tagID: tag.ID}
+				if !slices.Contains(s.conversationTags, link) {
+					s.conversationTags = append(s.conversationTags, link)
+				}
+			}
+		}
+	case models.BulkUntag:
+		for _, name := range action.Tags {
+			tag := s.tag(userID, name)
+			if tag == nil {
+				continue
+			}
+			s.conversationTags = slices.DeleteFunc(s.conversationTags, func(ct conversationTag) bool {
+				return ct.tagID == tag.ID && slices.ContainsFunc(convos, func(c *models.Conversation) bool { return c.ID == ct.conversationID })
+			})
+		}
+	default:
+		return errors.New("memstore: unknown bulk action " + action.Action)
+	}
+	return nil
+}
+
+// tag ищет тег пользователя по имени; вызывается под s.mu.
+func (s *Store) tag(userID int, name string) *models.Tag {
+	for i := range s.tags {
+		if s.tags[i].UserID == userID && s.tags[i].Name == name {
+			return &s.tags[i]
+		}
+	}
+	return nil
+}
+
+func (r *ConversationRepository) Delete(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	c := r.store.conversation(id, userID)
+	if c == nil {
+		return models.ErrNotFound
+	}
+	now := time.Now().UTC()
+	c.DeletedAt = &now
+	return nil
+}
+
+// live сообщает, есть ли беседа вне корзины; вызывается под s.mu.
+func (s *Store) live(convoID int) bool {
+	return slices.ContainsFunc(s.conversations, func(c models.Conversation) bool {
+		return c.ID == convoID && c.DeletedAt == nil
+	})
+}
+
+// deleteConversationData повторяет ON DELETE CASCADE беседы; вызывается под s.mu.
+func (s *Store) deleteConversationData(id int) {
+	s.messages = slices.DeleteFunc(s.messages, func(m models.Message) bool { return m.ConversationID == id })
+	s.conversationTags = slices.DeleteFunc(s.conversationTags, func(ct conversationTag) bool { return ct.conversationID == id })
+	s.shares = slices.DeleteFunc(s.shares, func(sh models.Share) bool { return sh.ConversationID == id })
+}
+
+func (r *ConversationRepository) UpdateTitle(id, userID int, title string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	c := r.store.conversation(id, userID)
+	if c == nil {
+		return models.ErrNotFound
+	}
+	c.Title = title
+	c.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (r *ConversationRepository) Import(convo *models.Conversation, msgs []models.Message) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if convo.ImportSource != nil && convo.ImportKey != nil {
+		for _, c := range r.store.conversations {
+			if c.UserID == convo.UserID && c.ImportSource != nil && c.ImportKey != nil &&
+				*c.ImportSource == *convo.ImportSource && *c.ImportKey == *convo.ImportKey {
+				return models.ErrAlreadyImported
+			}
+		}
+	}
+	convo.ID = r.store.id()
+	r.store.conversations = append(r.store.conversations, *convo)
+	for i := range msgs {
+		m := &msgs[i]
+		m.ID = strconv.Itoa(r.store.id())
+		m.ConversationID = convo.ID
+		m.Timestamp = m.CreatedAt
+		m.Status = models.MessageComplete
+		r.store.messages = append(r.store.messages, *m)
+	}
+	return nil
+}
+
+func (r *ConversationRepository) ListDeleted(userID int) ([]models.Conversation, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var convos []models.Conversation
+	for _, c := range r.store.conversations {
+		if c.UserID == userID && c.DeletedAt != nil {
+			convos = append(convos, c)
+		}
+	}
+	sort.SliceStable(convos, func(i, j int) bool {
+		return convos[i].DeletedAt.After(*convos[j].DeletedAt)
+	})
+	return convos, nil
+}
+
+func (r *ConversationRepository) Restore(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.conversations {
+		if c := &r.store.conversations[i]; c.ID == id && c.UserID == userID && c.DeletedAt != nil {
+			c.DeletedAt = nil
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+func (r *ConversationRepository) Purge(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	n := r.store.purgeConversations(func(c models.Conversation) bool {
+		return c.ID == id && c.UserID == userID && c.DeletedAt != nil
+	})
+	if n == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ConversationRepository) PurgeDeleted(userID int, before time.Time) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return r.store.purgeConversations(func(c models.Conversation) bool {
+		return c.DeletedAt != nil && c.DeletedAt.Before(before) && (userID == 0 || c.UserID == userID)
+	}), nil
+}
+
+// purgeConversations окончательно удаляет подходящие беседы с их данными;
+// вызывается под s.mu.
+func (s *Store) purgeConversations(match func(models.Conversation) bool) int {
+	var ids []int
+	s.conversations = slices.DeleteFunc(s.conversations, func(c models.Conversation) bool {
+		if match(c) {
+			ids = append(ids, c.ID)
+			return true
+		}
+		return false
+	})
+	for _, id := range ids {
+		s.deleteConversationData(id)
+	}
+	return len(ids)
+}
+
+type MessageRepository struct {
+	store *Store
+}
+
+func (r *MessageRepository) Create(msg *models.Message) (*models.Message, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	exists := false
+	for _, c := range r.store.conversations {
+		if c.ID == msg.ConversationID {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		// REFERENCES conversations(id)
+		return nil, errors.New("memstore: conversation does not exist")
+	}
+	now := time.Now().UTC()
+	msg.ID = strconv.Itoa(r.store.id())
+	msg.Timestamp = now
+	msg.CreatedAt = now
+	if msg.Status == "" {
+		msg.Status = models.MessageComplete
+	}
+	r.store.messages = append(r.store.messages, *msg)
+	return msg, nil
+}
+
+// insertMessage добавляет сообщение в беседу вне корзины; вызывается под s.mu.
+func (s *Store) insertMessage(convoID, userID int, role, content, status string) models.Message {
+	now := time.Now().UTC()
+	msg := models.Message{
+		ID: strconv.Itoa(s.id()), ConversationID: convoID, UserID: userID, Role: role, Content: content,
+		Status: status, Timestamp: now, CreatedAt: now,
+	}
+	s.messages = append(s.messages, msg)
+	return msg
+}
+
+func (r *MessageRepository) CreateTurn(convoID, userID int, content string) (*models.Turn, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if !r.store.live(convoID) {
+		return nil, errors.New("memstore: conversation does not exist")
+	}
+	return &models.Turn{
+		UserMessage: r.store.insertMessage(convoID, userID, "user", content, models.MessageComplete),
+		Reply:       r.store.insertMessage(convoID, userID, "assistant", "", models.MessagePending),
+	}, nil
+}
+
+func (r *MessageRepository) UpdateReply(id int, status, content string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	key := strconv.Itoa(id)
+	for i := range r.store.messages {
+		if m := &r.store.messages[i]; m.ID == key && m.Role == "assistant" {
+			m.Status, m.Content = status, content
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+func (r *MessageRepository) RetryReply(id, userID int, staleBefore time.Time) (*models.Message, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	reply := r.store.message(id, userID)
+	if reply == nil {
+		return nil, models.ErrNotFound
+	}
+	if !reply.Retryable(staleBefore) || r.store.hasLater(reply) {
+		return nil, models.ErrNotRetryable
+	}
+	now := time.Now().UTC()
+	reply.Status, reply.Content, reply.CreatedAt, reply.Timestamp = models.MessagePending, "", now, now
+	msg := *reply
+	return &msg, nil
+}
+
+// hasLater сообщает, есть ли в беседе сообщения после m; вызывается под s.mu.
+func (s *Store) hasLater(m *models.Message) bool {
+	for i := range s.messages {
+		if n := &s.messages[i]; n.ConversationID == m.ConversationID && n.DeletedAt == nil && after(n, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// after повторяет сравнение (created_at, id) > (created_at, id).
+func after(a, b *models.Message) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	aid, _ := strconv.Atoi(a.ID)
+	bid, _ := strconv.Atoi(b.ID)
+	return aid > bid
+}
+
+func (r *MessageRepository) ListByConversation(convoID int) ([]models.Message, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var msgs []models.Message
+	for _, m := range r.store.messages {
+		if m.ConversationID == convoID && m.DeletedAt == nil && m.Status == models.MessageComplete {
+			msgs = append(msgs, m)
+		}
+	}
+	// ORDER BY created_at: импортированные сообщения приходят со своими датами.
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].CreatedAt.Before(msgs[j].CreatedAt)
+	})
+	return msgs, nil
+}
+
+func (r *MessageRepository) ListPage(convoID int, q models.PageQuery) ([]models.Message, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var msgs []models.Message
+	for _, m := range r.store.messages {
+		if m.ConversationID == convoID && m.DeletedAt == nil {
+			msgs = append(msgs, m)
+		}
+	}
+	return page(msgs, func(m models.Message) models.PageCursor {
+		id, _ := strconv.Atoi(m.ID)
+		return models.PageCursor{Time: m.CreatedAt, ID: id}
+	}, q), nil
+}
+
+func (r *MessageRepository) CountByConversation(convoID int) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	n := 0
+	for _, m := range r.store.messages {
+		if m.ConversationID == convoID && m.DeletedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// message — сообщение пользователя вне корзины; вызывается под s.mu.
+func (s *Store) message(id, userID int) *models.Message {
+	key := strconv.Itoa(id)
+	for i := range s.messages {
+		m := &s.messages[i]
+		if m.ID == key && m.DeletedAt == nil && s.conversation(m.ConversationID, userID) != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+func (r *MessageRepository) FindByID(id, userID int) (*models.Message, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	m := r.store.message(id, userID)
+	if m == nil {
+		return nil, models.ErrNotFound
+	}
+	msg := *m
+	return &msg, nil
+}
+
+func (r *MessageRepository) Edit(id, userID int, content string) (*models.Message, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	m := r.store.message(id, userID)
+	if m == nil {
+		return nil, models.ErrNotFound
+	}
+	r.store.edit(m, content)
+	msg := *m
+	return &msg, nil
+}
+
+// edit сохраняет прежний текст m в ревизиях и заменяет его; вызывается под s.mu.
+func (s *Store) edit(m *models.Message, content string) {
+	now := time.Now().UTC()
+	id, _ := strconv.Atoi(m.ID)
+	s.revisions = append(s.revisions, models.MessageRevision{
+		ID: s.id(), MessageID: id, Content: m.Content, CreatedAt: now,
+	})
+	m.Content = content
+	m.EditedAt = &now
+	delete(s.embeddings, id)
+}
+
+func (r *MessageRepository) ListRevisions(id, userID int) ([]models.MessageRevision, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if r.store.message(id, userID) == nil {
+		return nil, models.ErrNotFound
+	}
+	var revs []models.MessageRevision
+	for _, rev := range r.store.revisions {
+		if rev.MessageID == id {
+			revs = append(revs, rev)
+		}
+	}
+	return revs, nil
+}
+
+func (r *MessageRepository) EditAndRegenerate(id, userID int, content string) (*models.Message, *models.Message, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	edited := r.store.message(id, userID)
+	if edited == nil {
+		return nil, nil, models.ErrNotFound
+	}
+	for i := range r.store.messages {
+		m := &r.store.messages[i]
+		if m.ConversationID == edited.ConversationID && m.DeletedAt == nil && after(m, edited) &&
+			(m.Status == models.MessagePending || m.Status == models.MessageStreaming) {
+			return nil, nil, models.ErrReplyInProgress
+		}
+	}
+	r.store.edit(edited, content)
+	msg := *edited
+	now := time.Now().UTC()
+	for i := range r.store.messages {
+		m := &r.store.messages[i]
+		if m.ConversationID == edited.ConversationID && m.DeletedAt == nil && after(m, edited) {
+			m.DeletedAt = &now
+		}
+	}
+	reply := r.store.insertMessage(edited.ConversationID, userID, "assistant", "", models.MessagePending)
+	return &msg, &reply, nil
+}
+
+// page повторяет keyset-запрос PostgreSQL: отбор по курсору, сортировка по
+// (время, id) от курсора и LIMIT. items должен быть собственной копией.
+func page[T any](items []T, key func(T) models.PageCursor, q models.PageQuery) []T {
+	less := func(a, b models.PageCursor) bool {
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return a.ID < b.ID
+	}
+	items = slices.DeleteFunc(items, func(it T) bool {
+		switch {
+		case q.After != nil:
+			return !less(*q.After, key(it))
+		case q.Before != nil:
+			return !less(key(it), *q.Before)
+		}
+		return false
+	})
+	sort.SliceStable(items, func(i, j int) bool {
+		if q.After != nil {
+			return less(key(items[i]), key(items[j]))
+		}
+		return less(key(items[j]), key(items[i]))
+	})
+	if len(items) > q.Limit {
+		items = items[:q.Limit]
+	}
+	return items
+}
+
+func (r *MessageRepository) Delete(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	key := strconv.Itoa(id)
+	for i := range r.store.messages {
+		m := &r.store.messages[i]
+		if m.ID == key && m.DeletedAt == nil && r.store.conversation(m.ConversationID, userID) != nil {
+			now := time.Now().UTC()
+			m.DeletedAt = &now
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+func (r *MessageRepository) ListDeleted(userID int) ([]models.Message, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var msgs []models.Message
+	for _, m := range r.store.messages {
+		if m.DeletedAt != nil && r.store.conversation(m.ConversationID, userID) != nil {
+			msgs = append(msgs, m)
+		}
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].DeletedAt.After(*msgs[j].DeletedAt)
+	})
+	return msgs, nil
+}
+
+func (r *MessageRepository) Restore(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	key := strconv.Itoa(id)
+	for i := range r.store.messages {
+		m := &r.store.messages[i]
+		if m.ID == key && m.DeletedAt != nil && r.store.conversation(m.ConversationID, userID) != nil {
+			m.DeletedAt = nil
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+func (r *MessageRepository) Purge(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	key := strconv.Itoa(id)
+	n := len(r.store.messages)
+	r.store.messages = slices.DeleteFunc(r.store.messages, func(m models.Message) bool {
+		return m.ID == key && m.DeletedAt != nil && r.store.owned(m.ConversationID, userID)
+	})
+	if len(r.store.messages) == n {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MessageRepository) PurgeDeleted(userID int, before time.Time) (int, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	n := len(r.store.messages)
+	r.store.messages = slices.DeleteFunc(r.store.messages, func(m models.Message) bool {
+		return m.DeletedAt != nil && m.DeletedAt.Before(before) && (userID == 0 || r.store.owned(m.ConversationID, userID))
+	})
+	return n - len(r.store.messages), nil
+}
+
+// owned сообщает, принадлежит ли беседа пользователю, в том числе в корзине;
+// вызывается под s.mu.
+func (s *Store) owned(convoID, userID int) bool {
+	return slices.ContainsFunc(s.conversations, func(c models.Conversation) bool {
+		return c.ID == convoID && c.UserID == userID
+	})
+}
+
+type RefreshTokenRepository struct {
+	store *Store
+}
+
+func (r *RefreshTokenRepository) Create(token *models.RefreshToken) (*models.RefreshToken, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, t := range r.store.refreshTokens {
+		if t.TokenHash == token.TokenHash {
+			return nil, ErrUniqueViolation
+		}
+	}
+	token.ID = r.store.id()
+	token.CreatedAt = time.Now().UTC()
+	r.store.refreshTokens = append(r.store.refreshTokens, *token)
+	return token, nil
+}
+
+func (r *RefreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, t := range r.store.refreshTokens {
+		if t.TokenHash == hash {
+			return &t, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (r *RefreshTokenRepository) MarkUsed(id int) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.refreshTokens {
+		t := &r.store.refreshTokens[i]
+		if t.ID == id {
+			if t.UsedAt != nil || t.RevokedAt != nil {
+				return false, nil
+			}
+			now := time.Now().UTC()
+			t.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(familyID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.revokeRefreshTokens(func(t *models.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r *RefreshTokenRepository) RevokeAllForUser(userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.revokeRefreshTokens(func(t *models.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+func (r *RefreshTokenRepository) ListSessions(userID int) ([]models.Session, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	now := time.Now().UTC()
+	started := map[string]time.Time{}
+	for _, t := range r.store.refreshTokens {
+		if first, ok := started[t.FamilyID]; !ok || t.CreatedAt.Before(first) {
+			started[t.FamilyID] = t.CreatedAt
+		}
+	}
+	sessions := []models.Session{}
+	for _, t := range r.store.refreshTokens {
+		if t.UserID != userID || !activeRefreshToken(&t, now) {
+			continue
+		}
+		sessions = append(sessions, models.Session{
+			ID:         t.FamilyID,
+			UserAgent:  t.UserAgent,
+			IP:         t.IP,
+			CreatedAt:  started[t.FamilyID],
+			LastUsedAt: t.CreatedAt,
+			ExpiresAt:  t.ExpiresAt,
+		})
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (r *RefreshTokenRepository) RevokeSession(userID int, familyID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	now := time.Now().UTC()
+	active := false
+	for _, t := range r.store.refreshTokens {
+		if t.UserID == userID && t.FamilyID == familyID && activeRefreshToken(&t, now) {
+			active = true
+		}
+	}
+	if !active {
+		return models.ErrNotFound
+	}
+	r.store.revokeRefreshTokens(func(t *models.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r *RefreshTokenRepository) RevokeOtherSessions(userID int, keepFamilyID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.revokeRefreshTokens(func(t *models.RefreshToken) bool {
+		return t.UserID == userID && t.FamilyID != keepFamilyID
+	})
+	return nil
+}
+
+func activeRefreshToken(t *models.RefreshToken, now time.Time) bool {
+	return t.UsedAt == nil && t.RevokedAt == nil && t.ExpiresAt.After(now)
+}
+
+func (s *Store) revokeRefreshTokens(match func(*models.RefreshToken) bool) {
+	now := time.Now().UTC()
+	for i := range s.refreshTokens {
+		t := &s.refreshTokens[i]
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &now
+		}
+	}
+}
+
+type UserTokenRepository struct {
+	store *Store
+}
+
+func (r *UserTokenRepository) Create(token *models.UserToken) (*models.UserToken, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, t := range r.store.userTokens {
+		if t.TokenHash == token.TokenHash {
+			return nil, ErrUniqueViolation
+		}
+	}
+	token.ID = r.store.id()
+	token.CreatedAt = time.Now().UTC()
+	r.store.userTokens = append(r.store.userTokens, *token)
+	return token, nil
+}
+
+func (r *UserTokenRepository) Consume(hash, purpose string, now time.Time) (*models.UserToken, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.userTokens {
+		t := &r.store.userTokens[i]
+		if t.TokenHash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			used := time.Now().UTC()
+			t.UsedAt = &used
+			token := *t
+			return &token, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (r *UserTokenRepository) InvalidateForUser(userID int, purpose string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	now := time.Now().UTC()
+	for i := range r.store.userTokens {
+		t := &r.store.userTokens[i]
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+type APIKeyRepository struct {
+	store *Store
+}
+
+func (r *APIKeyRepository) Create(key *models.APIKey) (*models.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, k := range r.store.apiKeys {
+		if k.Prefix == key.Prefix {
+			return nil, ErrUniqueViolation
+		}
+	}
+	key.ID = r.store.id()
+	key.CreatedAt = time.Now().UTC()
+	r.store.apiKeys = append(r.store.apiKeys, *key)
+	return key, nil
+}
+
+func (r *APIKeyRepository) FindByPrefix(prefix string) (*models.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, k := range r.store.apiKeys {
+		if k.Prefix == prefix {
+			return &k, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (r *APIKeyRepository) ListByUser(userID int) ([]models.APIKey, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var keys []models.APIKey
+	for i := len(r.store.apiKeys) - 1; i >= 0; i-- {
+		if k := r.store.apiKeys[i]; k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (r *APIKeyRepository) Revoke(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.apiKeys {
+		k := &r.store.apiKeys[i]
+		if k.ID == id && k.UserID == userID && k.RevokedAt == nil {
+			now := time.Now().UTC()
+			k.RevokedAt = &now
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+func (r *APIKeyRepository) TouchLastUsed(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.apiKeys {
+		if k := &r.store.apiKeys[i]; k.ID == id {
+			now := time.Now().UTC()
+			k.LastUsedAt = &now
+		}
+	}
+	return nil
+}
+
+type AuditLogRepository struct {
+	store *Store
+}
+
+func (r *AuditLogRepository) Create(entry *models.AuditEntry) (*models.AuditEntry, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	entry.ID = r.store.id()
+	entry.CreatedAt = time.Now().UTC()
+	r.store.auditLog = append(r.store.auditLog, *entry)
+	return entry, nil
+}
+
+func (r *AuditLogRepository) List(limit int) ([]models.AuditEntry, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var entries []models.AuditEntry
+	for i := len(r.store.auditLog) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, r.store.auditLog[i])
+	}
+	return entries, nil
+}
+
+type LoginLockoutRepository struct {
+	store *Store
+}
+
+func (r *LoginLockoutRepository) Create(lockout *models.LoginLockout) (*models.LoginLockout, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	lockout.ID = r.store.id()
+	lockout.CreatedAt = time.Now().UTC()
+	r.store.lockouts = append(r.store.lockouts, *lockout)
+	return lockout, nil
+}
+
+func (r *LoginLockoutRepository) List(limit int) ([]models.LoginLockout, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var lockouts []models.LoginLockout
+	for i := len(r.store.lockouts) - 1; i >= 0 && len(lockouts) < limit; i-- {
+		lockouts = append(lockouts, r.store.lockouts[i])
+	}
+	return lockouts, nil
+}
+
+type RecoveryCodeRepository struct {
+	store *Store
+}
+
+func (r *RecoveryCodeRepository) Replace(userID int, hashes []string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	kept := r.store.recoveryCodes[:0]
+	for _, c := range r.store.recoveryCodes {
+		if c.UserID != userID {
+			kept = append(kept, c)
+		}
+	}
+	r.store.recoveryCodes = kept
+	for _, hash := range hashes {
+		r.store.recoveryCodes = append(r.store.recoveryCodes, models.RecoveryCode{
+			ID:        r.store.id(),
+			UserID:    userID,
+			CodeHash:  hash,
+			CreatedAt: time.Now().UTC(),
+		})
+	}
+	return nil
+}
+
+func (r *RecoveryCodeRepository) Use(userID int, hash string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.recoveryCodes {
+		c := &r.store.recoveryCodes[i]
+		if c.UserID == userID && c.CodeHash == hash && c.UsedAt == nil {
+			now := time.Now().UTC()
+			c.UsedAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type IdentityRepository struct {
+	store *Store
+}
+
+func (r *IdentityRepository) Create(identity *models.Identity) (*models.Identity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, i := range r.store.identities {
+		if i.Provider == identity.Provider && i.Subject == identity.Subject {
+			return nil, ErrUniqueViolation
+		}
+	}
+	identity.ID = r.store.id()
+	identity.CreatedAt = time.Now().UTC()
+	r.store.identities = append(r.store.identities, *identity)
+	return identity, nil
+}
+
+func (r *IdentityRepository) FindBySubject(provider, subject string) (*models.Identity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, i := range r.store.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return &i, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (r *IdentityRepository) ListByUser(userID int) ([]models.Identity, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var identities []models.Identity
+	for _, i := range r.store.identities {
+		if i.UserID == userID {
+			identities = append(identities, i)
+		}
+	}
+	return identities, nil
+}
+
+type UsageRepository struct {
+	store *Store
+}
+
+func (r *UsageRepository) ByUser() ([]models.UserUsage, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	usage := make([]models.UserUsage, 0, len(r.store.users))
+	for _, u := range r.store.users {
+		usage = append(usage, r.store.usage(u))
+	}
+	sort.SliceStable(usage, func(i, j int) bool {
+		return usage[i].Messages > usage[j].Messages
+	})
+	return usage, nil
+}
+
+func (r *UsageRepository) ForUser(userID int) (*models.UserUsage, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, u := range r.store.users {
+		if u.ID == userID {
+			usage := r.store.usage(u)
+			return &usage, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (s *Store) usage(u models.User) models.UserUsage {
+	row := models.UserUsage{UserID: u.ID, Email: u.Email}
+	for _, c := range s.conversations {
+		if c.UserID != u.ID || c.DeletedAt != nil {
+			continue
+		}
+		row.Conversations++
+		for _, m := range s.messages {
+			if m.ConversationID != c.ID || m.DeletedAt != nil {
+				continue
+			}
+			row.Messages++
+			if row.LastMessageAt == nil || m.CreatedAt.After(*row.LastMessageAt) {
+				created := m.CreatedAt
+				row.LastMessageAt = &created
+			}
+		}
+	}
+	return row
+}
+
+type ExportJobRepository struct {
+	store *Store
+}
+
+func (r *ExportJobRepository) Create(job *models.ExportJob) (*models.ExportJob, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if job.Status == "" {
+		job.Status = models.ExportPending
+	}
+	job.ID = r.store.id()
+	job.CreatedAt = time.Now().UTC()
+	r.store.exportJobs = append(r.store.exportJobs, *job)
+	return job, nil
+}
+
+func (r *ExportJobRepository) FindByID(id, userID int) (*models.ExportJob, error) {
+	return r.find(func(j *models.ExportJob) bool { return j.ID == id && j.UserID == userID })
+}
+
+func (r *ExportJobRepository) FindByTokenHash(hash string) (*models.ExportJob, error) {
+	return r.find(func(j *models.ExportJob) bool { return j.TokenHash != nil && *j.TokenHash == hash })
+}
+
+func (r *ExportJobRepository) FindActiveByUser(userID int) (*models.ExportJob, error) {
+	return r.find(func(j *models.ExportJob) bool { return j.UserID == userID && j.Active() })
+}
+
+func (r *ExportJobRepository) find(match func(*models.ExportJob) bool) (*models.ExportJob, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := len(r.store.exportJobs) - 1; i >= 0; i-- {
+		if j := r.store.exportJobs[i]; match(&j) {
+			return &j, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (r *ExportJobRepository) Update(job *models.ExportJob) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.exportJobs {
+		if r.store.exportJobs[i].ID == job.ID {
+			r.store.exportJobs[i] = *job
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+func (r *ExportJobRepository) ListExpired(now time.Time) ([]models.ExportJob, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var jobs []models.ExportJob
+	for _, j := range r.store.exportJobs {
+		if j.Status == models.ExportDone && j.ExpiresAt != nil && !j.ExpiresAt.After(now) {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+type SearchRepository struct {
+	store *Store
+}
+
+// Search — упрощённая замена полнотекстового поиска PostgreSQL для тестов:
+// все слова запроса должны встречаться подстрокой без учёта регистра, без
+// морфологии и операторов. Ранг — число вхождений, сортировка та же.
+func (r *SearchRepository) Search(q models.SearchQuery) ([]models.SearchHit, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	terms := searchTerms(q.Query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	inRange := func(t time.Time) bool {
+		return (q.From == nil || !t.Before(*q.From)) && (q.To == nil || t.Before(*q.To))
+	}
+	var hits []models.SearchHit
+	for _, c := range r.store.conversations {
+		if c.UserID != q.UserID || c.DeletedAt != nil || (q.ConversationID != 0 && c.ID != q.ConversationID) {
+			continue
+		}
+		if q.Role == "" && inRange(c.CreatedAt) {
+			if rank := searchRank(c.Title, terms); rank > 0 {
+				hits = append(hits, models.SearchHit{
+					Kind: models.SearchKindConversation, ID: c.ID, ConversationID: c.ID, ConversationTitle: c.Title,
+					Snippet: searchSnippet(c.Title, terms), Rank: rank, CreatedAt: c.CreatedAt,
+				})
+			}
+		}
+		for _, m := range r.store.messages {
+			if m.ConversationID != c.ID || m.DeletedAt != nil || (q.Role != "" && m.Role != q.Role) || !inRange(m.CreatedAt) {
+				continue
+			}
+			if rank := searchRank(m.Content, terms); rank > 0 {
+				id, _ := strconv.Atoi(m.ID)
+				hits = append(hits, models.SearchHit{
+					Kind: models.SearchKindMessage, ID: id, ConversationID: c.ID, ConversationTitle: c.Title,
+					Role: m.Role, Snippet: searchSnippet(m.Content, terms), Rank: rank, CreatedAt: m.CreatedAt,
+				})
+			}
+		}
+	}
+	less := func(a, b models.SearchCursor) bool {
+		if a.Rank != b.Rank {
+			return a.Rank < b.Rank
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(hits, func(i, j int) bool { return less(hits[j].Cursor(), hits[i].Cursor()) })
+	if q.After != nil {
+		hits = slices.DeleteFunc(hits, func(h models.SearchHit) bool { return !less(h.Cursor(), *q.After) })
+	}
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+func searchTerms(query string) []string {
+	var terms []string
+	for _, f := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if f != "or" {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+func searchRank(text string, terms []string) float32 {
+	lower := strings.ToLower(text)
+	var rank float32
+	for _, t := range terms {
+		n := strings.Count(lower, t)
+		if n == 0 {
+			return 0
+		}
+		rank += float32(n)
+	}
+	return rank
+}
+
+func searchSnippet(text string, terms []string) string {
+	lower := []rune(strings.ToLower(text))
+	runes := []rune(text)
+	if len(lower) != len(runes) {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		matched := 0
+		for _, t := range terms {
+			tr := []rune(t)
+			if i+len(tr) <= len(lower) && string(lower[i:i+len(tr)]) == t && len(tr) > matched {
+				matched = len(tr)
+			}
+		}
+		if matched > 0 {
+			b.WriteString(models.SnippetStart + string(runes[i:i+matched]) + models.SnippetStop)
+			i += matched
+			continue
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	return b.String()
+}
+
+type embedding struct {
+	model  string
+	vector []float32
+}
+
+// EmbeddingRepository хранит векторы по id сообщения; векторы удалённых сообщений
+// остаются в карте, но не видны, так как все выборки идут от s.messages.
+type EmbeddingRepository struct {
+	store *Store
+}
+
+func (r *EmbeddingRepository) ListPending(model string, limit int) ([]models.Message, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var msgs []models.Message
+	for _, m := range r.store.messages {
+		if m.DeletedAt != nil || m.Status != models.MessageComplete {
+			continue
+		}
+		id, _ := strconv.Atoi(m.ID)
+		if e, ok := r.store.embeddings[id]; !ok || e.model != model {
+			msgs = append(msgs, m)
+		}
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		a, _ := strconv.Atoi(msgs[i].ID)
+		b, _ := strconv.Atoi(msgs[j].ID)
+		return a < b
+	})
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+func (r *EmbeddingRepository) Save(messageID int, model string, vector []float32) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if r.store.embeddings == nil {
+		r.store.embeddings = map[int]embedding{}
+	}
+	r.store.embeddings[messageID] = embedding{model: model, vector: slices.Clone(vector)}
+	return nil
+}
+
+func (r *EmbeddingRepository) ListByUser(userID int, model string, conversationID int) ([]models.MessageEmbedding, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var out []models.MessageEmbedding
+	for _, m := range r.store.messages {
+		if m.DeletedAt != nil || (conversationID != 0 && m.ConversationID != conversationID) {
+			continue
+		}
+		if r.store.conversation(m.ConversationID, userID) == nil {
+			continue
+		}
+		id, _ := strconv.Atoi(m.ID)
+		e, ok := r.store.embeddings[id]
+		if !ok || e.model != model || len(e.vector) == 0 {
+			continue
+		}
+		out = append(out, models.MessageEmbedding{
+			MessageID: id, ConversationID: m.ConversationID, Vector: slices.Clone(e.vector),
+		})
+	}
+	return out, nil
+}
+
+func (r *EmbeddingRepository) Stats(model string) (*models.EmbeddingStats, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	stats := models.EmbeddingStats{Model: model}
+	for _, m := range r.store.messages {
+		if m.DeletedAt != nil {
+			continue
+		}
+		id, _ := strconv.Atoi(m.ID)
+		e, ok := r.store.embeddings[id]
+		switch {
+		case !ok || e.model != model:
+			stats.Pending++
+		case len(e.vector) == 0:
+			stats.Skipped++
+		default:
+			stats.Indexed++
+		}
+	}
+	return &stats, nil
+}
+
+type conversationTag struct {
+	conversationID int
+	tagID          int
+}
+
+type FolderRepository struct {
+	store *Store
+}
+
+func (r *FolderRepository) Create(folder *models.Folder) (*models.Folder, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, f := range r.store.folders {
+		if f.UserID == folder.UserID && f.Name == folder.Name {
+			return nil, models.ErrDuplicate
+		}
+	}
+	folder.ID = r.store.id()
+	folder.CreatedAt = time.Now().UTC()
+	r.store.folders = append(r.store.folders, *folder)
+	return folder, nil
+}
+
+func (r *FolderRepository) ListByUser(userID int) ([]models.Folder, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var folders []models.Folder
+	for _, f := range r.store.folders {
+		if f.UserID != userID {
+			continue
+		}
+		for _, c := range r.store.conversations {
+			if c.FolderID != nil && *c.FolderID == f.ID && c.DeletedAt == nil {
+				f.Conversations++
+			}
+		}
+		folders = append(folders, f)
+	}
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Name < folders[j].Name })
+	return folders, nil
+}
+
+func (r *FolderRepository) Rename(id, userID int, name string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var target *models.Folder
+	for i := range r.store.folders {
+		f := &r.store.folders[i]
+		if f.UserID != userID {
+			continue
+		}
+		if f.ID == id {
+			target = f
+		} else if f.Name == name {
+			return models.ErrDuplicate
+		}
+	}
+	if target == nil {
+		return models.ErrNotFound
+	}
+	target.Name = name
+	return nil
+}
+
+// Delete повторяет ON DELETE SET NULL у conversations.folder_id.
+func (r *FolderRepository) Delete(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	n := len(r.store.folders)
+	r.store.folders = slices.DeleteFunc(r.store.folders, func(f models.Folder) bool { return f.ID == id && f.UserID == userID })
+	if len(r.store.folders) == n {
+		return models.ErrNotFound
+	}
+	for i := range r.store.conversations {
+		if c := &r.store.conversations[i]; c.FolderID != nil && *c.FolderID == id {
+			c.FolderID = nil
+		}
+	}
+	return nil
+}
+
+type TagRepository struct {
+	store *Store
+}
+
+func (r *TagRepository) ListByUser(userID int) ([]models.Tag, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var tags []models.Tag
+	for _, t := range r.store.tags {
+		if t.UserID != userID {
+			continue
+		}
+		for _, ct := range r.store.conversationTags {
+			if ct.tagID == t.ID && r.store.live(ct.conversationID) {
+				t.Conversations++
+			}
+		}
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (r *TagRepository) Rename(id, userID int, name string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var target *models.Tag
+	for i := range r.store.tags {
+		t := &r.store.tags[i]
+		if t.UserID != userID {
+			continue
+		}
+		if t.ID == id {
+			target = t
+		} else if t.Name == name {
+			return models.ErrDuplicate
+		}
+	}
+	if target == nil {
+		return models.ErrNotFound
+	}
+	target.Name = name
+	return nil
+}
+
+func (r *TagRepository) Delete(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	n := len(r.store.tags)
+	r.store.tags = slices.DeleteFunc(r.store.tags, func(t models.Tag) bool { return t.ID == id && t.UserID == userID })
+	if len(r.store.tags) == n {
+		return models.ErrNotFound
+	}
+	r.store.conversationTags = slices.DeleteFunc(r.store.conversationTags, func(ct conversationTag) bool { return ct.tagID == id })
+	return nil
+}
+
+type ShareRepository struct {
+	store *Store
+}
+
+func (r *ShareRepository) Create(share *models.Share) (*models.Share, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, sh := range r.store.shares {
+		if sh.TokenHash == share.TokenHash {
+			return nil, ErrUniqueViolation
+		}
+	}
+	share.ID = r.store.id()
+	share.CreatedAt = time.Now().UTC()
+	stored := *share
+	stored.Snapshot = slices.Clone(share.Snapshot)
+	r.store.shares = append(r.store.shares, stored)
+	return share, nil
+}
+
+func (r *ShareRepository) FindByTokenHash(hash string) (*models.Share, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, sh := range r.store.shares {
+		if sh.TokenHash == hash && r.store.live(sh.ConversationID) {
+			return &sh, nil
+		}
+	}
+	return nil, models.ErrNotFound
+}
+
+func (r *ShareRepository) ListByUser(userID, conversationID int) ([]models.Share, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var shares []models.Share
+	for _, sh := range r.store.shares {
+		if sh.UserID == userID && (conversationID == 0 || sh.ConversationID == conversationID) {
+			shares = append(shares, sh)
+		}
+	}
+	slices.Reverse(shares)
+	return shares, nil
+}
+
+func (r *ShareRepository) Revoke(id, userID int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.shares {
+		if sh := &r.store.shares[i]; sh.ID == id && sh.UserID == userID && sh.RevokedAt == nil {
+			now := time.Now().UTC()
+			sh.RevokedAt = &now
+			return nil
+		}
+	}
+	return models.ErrNotFound
+}
+
+func (r *ShareRepository) RecordView(id int) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for i := range r.store.shares {
+		if sh := &r.store.shares[i]; sh.ID == id {
+			now := time.Now().UTC()
+			sh.Views++
+			sh.LastViewedAt = &now
+		}
+	}
+	return nil
+}
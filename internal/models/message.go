@@ -13,6 +13,27 @@ type Message struct {
 	Role           string    `json:"role"`
 	Timestamp      time.Time `json:"timestamp"`
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	// DeletedAt задан у сообщений в корзине.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// EditedAt — время последней правки; прежние тексты хранятся в ревизиях.
+	EditedAt *time.Time `json:"edited_at,omitempty" db:"edited_at"`
+	// Status — состояние ответа модели; сообщения пользователя всегда complete.
+	Status string `json:"status" db:"status"`
+}
+
+// Статусы сообщения. Ответ создаётся в pending, с первым фрагментом потока
+// становится streaming и завершается complete или failed.
+const (
+	MessagePending   = "pending"
+	MessageStreaming = "streaming"
+	MessageComplete  = "complete"
+	MessageFailed    = "failed"
+)
+
+// Turn — сообщение пользователя и ответ на него, сохранённые вместе.
+type Turn struct {
+	UserMessage Message `json:"user_message"`
+	Reply       Message `json:"reply"`
 }
 
 type ChatRequest struct {
@@ -22,18 +43,92 @@ type ChatRequest struct {
 type ChatResponse struct {
 	Message   string `json:"message"`
 	Timestamp string `json:"timestamp"`
+	// UserMessageID и ReplyID — сохранённые сообщения реплики. Ответ со
+	// статусом failed повторяют по ReplyID через /api/chat/retry.
+	UserMessageID string `json:"user_message_id,omitempty"`
+	ReplyID       string `json:"reply_id"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+type RetryRequest struct {
+	ReplyID int `json:"reply_id"`
 }
 
 type WebSocketMessage struct {
 	Type    string `json:"type"`
 	Content string `json:"content"`
 	Role    string `json:"role"`
+	// MessageID — сохранённое сообщение пользователя в user_message и ответ в
+	// assistant_complete; ReplyID — ответ, к которому относится событие.
+	MessageID string `json:"message_id,omitempty"`
+	ReplyID   string `json:"reply_id,omitempty"`
 }
 
 type DeleteMessageRequest struct {
 	ID int `json:"id"`
 }
 
+type EditMessageRequest struct {
+	ID      int    `json:"id"`
+	Content string `json:"content"`
+	// Regenerate после правки сообщения пользователя убирает в корзину всё,
+	// что шло за ним, и заново получает ответ модели.
+	Regenerate bool `json:"regenerate"`
+}
+
+type EditMessageResponse struct {
+	Message Message `json:"message"`
+	// Reply — новый ответ модели, если правка была с regenerate.
+	Reply *Message `json:"reply,omitempty"`
+}
+
+type MessageStore interface {
+	Create(msg *Message) (*Message, error)
+	// CreateTurn одной транзакцией сохраняет сообщение пользователя и пустой
+	// ответ в статусе pending.
+	CreateTurn(convoID, userID int, content string) (*Turn, error)
+	// UpdateReply записывает статус и текст ответа.
+	UpdateReply(id int, status, content string) error
+	// RetryReply возвращает в pending ответ со статусом failed или зависший
+	// в pending/streaming с начала до staleBefore. Повторить можно только
+	// последнее сообщение беседы, иначе ErrNotRetryable.
+	RetryReply(id, userID int, staleBefore time.Time) (*Message, error)
+	// ListByConversation — завершённые сообщения беседы: из них строятся
+	// контекст модели и выгрузки.
+	ListByConversation(convoID int) ([]Message, error)
+	// ListPage — страница сообщений беседы по created_at, в любом статусе.
+	ListPage(convoID int, page PageQuery) ([]Message, error)
+	CountByConversation(convoID int) (int, error)
+	// FindByID — сообщение пользователя вне корзины.
+	FindByID(id, userID int) (*Message, error)
+	// Edit заменяет текст сообщения, сохраняя прежний в ревизиях.
+	Edit(id, userID int, content string) (*Message, error)
+	// ListRevisions — прежние тексты сообщения, старые первыми.
+	ListRevisions(id, userID int) ([]MessageRevision, error)
+	// EditAndRegenerate одной транзакцией делает то же, что Edit, переносит в
+	// корзину сообщения беседы, идущие после id, и создаёт на их месте пустой
+	// ответ в pending. Если после id ещё генерируется ответ — ErrReplyInProgress
+	// без изменений.
+	EditAndRegenerate(id, userID int, content string) (edited, reply *Message, err error)
+	// Delete переносит сообщение в корзину; сообщения в корзине не видны остальным методам.
+	Delete(id, userID int) error
+	// ListDeleted — сообщения пользователя в корзине из бесед вне корзины,
+	// недавно удалённые первыми.
+	ListDeleted(userID int) ([]Message, error)
+	// Restore возвращает сообщение из корзины, если его беседа не удалена.
+	Restore(id, userID int) error
+	// Purge окончательно удаляет сообщение из корзины.
+	Purge(id, userID int) error
+	// PurgeDeleted окончательно удаляет сообщения, попавшие в корзину раньше
+	// before; userID = 0 — у всех пользователей.
+	PurgeDeleted(userID int, before time.Time) (int, error)
+}
+
+// messageColumns перечисляет колонки явно: новые колонки таблицы (как
+// search_vector) не ломают чтение в структуру.
+const messageColumns = "id, conversation_id, user_id, role, content, created_at, deleted_at, edited_at, status"
+
 type MessageRepository struct{}
 
 func (r *MessageRepository) Create(msg *Message) (*Message, error) {
@@ -42,19 +137,40 @@ func (r *MessageRepository) Create(msg *Message) (*Message, error) {
 	if err != nil {
 		return nil, err
 	}
+	msg.Status = MessageComplete
 	return msg, nil
 }
 
 func (r *MessageRepository) ListByConversation(convoID int) ([]Message, error) {
 	var msgs []Message
-	err := db.DB.Select(&msgs, "SELECT * FROM messages WHERE conversation_id=$1 ORDER BY created_at ASC", convoID)
+	err := db.DB.Select(&msgs, "SELECT "+messageColumns+" FROM messages WHERE conversation_id=$1 AND deleted_at IS NULL AND status='complete' ORDER BY created_at ASC, id ASC", convoID)
 	if err != nil {
 		return nil, err
 	}
 	return msgs, nil
 }
 
+func (r *MessageRepository) ListPage(convoID int, page PageQuery) ([]Message, error) {
+	query, args := pageQuery("SELECT "+messageColumns+" FROM messages WHERE conversation_id=$1 AND deleted_at IS NULL", "created_at", []any{convoID}, page)
+	var msgs []Message
+	if err := db.DB.Select(&msgs, query, args...); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (r *MessageRepository) CountByConversation(convoID int) (int, error) {
+	var n int
+	err := db.DB.Get(&n, "SELECT COUNT(*) FROM messages WHERE conversation_id=$1 AND deleted_at IS NULL", convoID)
+	return n, err
+}
+
 func (r *MessageRepository) Delete(id, userID int) error {
-	_, err := db.DB.Exec("DELETE FROM messages WHERE id=$1 AND user_id=$2", id, userID)
-	return err
+	query := `UPDATE messages SET deleted_at=NOW() WHERE id=$1 AND deleted_at IS NULL
+		AND conversation_id IN (SELECT id FROM conversations WHERE user_id=$2 AND deleted_at IS NULL)`
+	result, err := db.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
 }
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"fmt"
+	"me-ai/pkg/db"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ConversationFilter сужает список бесед; нулевое значение — все беседы.
+type ConversationFilter struct {
+	// Archived: nil — независимо от архива, иначе только архивные или только активные.
+	Archived *bool
+	Pinned   *bool
+	// FolderID — беседы одной папки; NoFolder — беседы вне папок.
+	FolderID *int
+	NoFolder bool
+	// Tags — беседы, у которых есть все перечисленные теги.
+	Tags []string
+}
+
+func (f ConversationFilter) where(userID int) (string, []any) {
+	where := "user_id=$1 AND deleted_at IS NULL"
+	args := []any{userID}
+	if f.Archived != nil {
+		where += " AND archived_at IS " + notNull(*f.Archived)
+	}
+	if f.Pinned != nil {
+		where += " AND pinned_at IS " + notNull(*f.Pinned)
+	}
+	if f.FolderID != nil {
+		args = append(args, *f.FolderID)
+		where += fmt.Sprintf(" AND folder_id=$%d", len(args))
+	} else if f.NoFolder {
+		where += " AND folder_id IS NULL"
+	}
+	for _, tag := range f.Tags {
+		args = append(args, tag)
+		where += fmt.Sprintf(` AND EXISTS (SELECT 1 FROM conversation_tags ct JOIN tags t ON t.id = ct.tag_id
+			WHERE ct.conversation_id = conversations.id AND t.name = $%d)`, len(args))
+	}
+	return where, args
+}
+
+func notNull(set bool) string {
+	if set {
+		return "NOT NULL"
+	}
+	return "NULL"
+}
+
+// attachTags заполняет Tags у бесед одним запросом.
+func attachTags(convos []Conversation) error {
+	if len(convos) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(convos))
+	for i, c := range convos {
+		ids[i] = int64(c.ID)
+	}
+	var rows []struct {
+		ConversationID int    `db:"conversation_id"`
+		Name           string `db:"name"`
+	}
+	query, args, err := in(`SELECT ct.conversation_id, t.name FROM conversation_tags ct
+		JOIN tags t ON t.id = ct.tag_id
+		WHERE ct.conversation_id IN (?)
+		ORDER BY t.name`, ids)
+	if err != nil {
+		return err
+	}
+	if err := db.DB.Select(&rows, query, args...); err != nil {
+		return err
+	}
+	byID := map[int][]string{}
+	for _, r := range rows {
+		byID[r.ConversationID] = append(byID[r.ConversationID], r.Name)
+	}
+	for i := range convos {
+		convos[i].Tags = byID[convos[i].ID]
+	}
+	return nil
+}
+
+const (
+	BulkMove      = "move"
+	BulkTag       = "tag"
+	BulkUntag     = "untag"
+	BulkPin       = "pin"
+	BulkUnpin     = "unpin"
+	BulkArchive   = "archive"
+	BulkUnarchive = "unarchive"
+	BulkDelete    = "delete"
+)
+
+type BulkAction struct {
+	Action string
+	IDs    []int
+	// FolderID — папка для move; nil убирает беседы из папок.
+	FolderID *int
+	// Tags — имена тегов для tag и untag; недостающие теги создаются.
+	Tags []string
+}
+
+func (r *ConversationRepository) Bulk(userID int, action BulkAction) error {
+	ids := make([]int64, 0, len(action.IDs))
+	for _, id := range action.IDs {
+		if !slices.Contains(ids, int64(id)) {
+			ids = append(ids, int64(id))
+		}
+	}
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var owned []int64
+	query, args, err := in("SELECT id FROM conversations WHERE user_id=? AND id IN (?) AND deleted_at IS NULL"+db.ForUpdate(), userID, ids)
+	if err != nil {
+		return err
+	}
+	if err := tx.Select(&owned, query, args...); err != nil {
+		return err
+	}
+	if len(owned) != len(ids) {
+		return ErrNotFound
+	}
+
+	exec := func(query string, args ...any) error {
+		query, args, err := in(query, args...)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(query, args...)
+		return err
+	}
+	switch action.Action {
+	case BulkMove:
+		if action.FolderID != nil {
+			var exists bool
+			err := tx.Get(&exists, "SELECT EXISTS (SELECT 1 FROM folders WHERE id=$1 AND user_id=$2)", *action.FolderID, userID)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrNotFound
+			}
+		}
+		err = exec("UPDATE conversations SET folder_id=? WHERE id IN (?)", action.FolderID, ids)
+	case BulkPin:
+		err = exec("UPDATE conversations SET pinned_at=NOW() WHERE id IN (?) AND pinned_at IS NULL", ids)
+	case BulkUnpin:
+		err = exec("UPDATE conversations SET pinned_at=NULL WHERE id IN (?)", ids)
+	case BulkArchive:
+		err = exec("UPDATE conversations SET archived_at=NOW() WHERE id IN (?) AND archived_at IS NULL", ids)
+	case BulkUnarchive:
+		err = exec("UPDATE conversations SET archived_at=NULL WHERE id IN (?)", ids)
+	case BulkDelete:
+		err = exec("UPDATE conversations SET deleted_at=NOW() WHERE id IN (?)", ids)
+	case BulkTag:
+		for _, name := range action.Tags {
+			if err = exec("INSERT INTO tags (user_id, name) VALUES (?, ?) ON CONFLICT (user_id, name) DO NOTHING", userID, name); err != nil {
+				return err
+			}
+		}
+		err = exec(`INSERT INTO conversation_tags (conversation_id, tag_id)
+			SELECT c.id, t.id FROM conversations c, tags t
+			WHERE c.id IN (?) AND t.user_id = ? AND t.name IN (?)
+			ON CONFLICT DO NOTHING`, ids, userID, action.Tags)
+	case BulkUntag:
+		err = exec(`DELETE FROM conversation_tags WHERE conversation_id IN (?)
+			AND tag_id IN (SELECT id FROM tags WHERE user_id = ? AND name IN (?))`,
+			ids, userID, action.Tags)
+	default:
+		return fmt.Errorf("unknown bulk action %q", action.Action)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// in раскрывает в запросе с плейсхолдерами ? срезы из args в списки IN (?, ?, …)
+// и переводит плейсхолдеры в формат драйвера.
+func in(query string, args ...any) (string, []any, error) {
+	query, args, err := sqlx.In(query, args...)
+	if err != nil {
+		return "", nil, err
+	}
+	return db.DB.Rebind(query), args, nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// PageCursor — позиция записи в списке, упорядоченном по времени и id.
+type PageCursor struct {
+	Time time.Time `json:"t"`
+	ID   int       `json:"i"`
+}
+
+// PageQuery — keyset-страница: Before — записи старше курсора, After — новее,
+// без курсоров — самые новые. Записи возвращаются в порядке удаления от курсора:
+// с After по возрастанию, иначе по убыванию.
+type PageQuery struct {
+	Before *PageCursor
+	After  *PageCursor
+	Limit  int
+}
+
+// pageQuery дописывает к base (с уже занятыми плейсхолдерами args) условие
+// курсора, сортировку по (timeColumn, id) и LIMIT.
+func pageQuery(base, timeColumn string, args []any, page PageQuery) (string, []any) {
+	order := "DESC"
+	switch {
+	case page.After != nil:
+		base += fmt.Sprintf(" AND (%s, id) > ($%d, $%d)", timeColumn, len(args)+1, len(args)+2)
+		args = append(args, page.After.Time, page.After.ID)
+		order = "ASC"
+	case page.Before != nil:
+		base += fmt.Sprintf(" AND (%s, id) < ($%d, $%d)", timeColumn, len(args)+1, len(args)+2)
+		args = append(args, page.Before.Time, page.Before.ID)
+	}
+	base += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT $%d", timeColumn, order, order, len(args)+1)
+	return base, append(args, page.Limit)
+}
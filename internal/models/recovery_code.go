@@ -0,0 +1,52 @@
+package models
+
+import (
+	"me-ai/pkg/db"
+	"time"
+)
+
+type RecoveryCode struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	CodeHash  string     `json:"-" db:"code_hash"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type RecoveryCodeStore interface {
+	// Replace удаляет прежние коды пользователя и сохраняет новые.
+	Replace(userID int, hashes []string) error
+	// Use атомарно гасит неиспользованный код; false — такого кода нет.
+	Use(userID int, hash string) (bool, error)
+}
+
+type RecoveryCodeRepository struct{}
+
+func (r *RecoveryCodeRepository) Replace(userID int, hashes []string) error {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id=$1", userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *RecoveryCodeRepository) Use(userID int, hash string) (bool, error) {
+	result, err := db.DB.Exec("UPDATE recovery_codes SET used_at=NOW() WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL", userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/pkg/db"
+	"time"
+)
+
+type RefreshToken struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	FamilyID  string     `json:"family_id" db:"family_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	UserAgent string     `json:"user_agent" db:"user_agent"`
+	IP        string     `json:"ip" db:"ip"`
+}
+
+// Session — активная семья refresh token'ов, то есть один вход на одном устройстве.
+// ID — family_id, LastUsedAt — время последней ротации.
+type Session struct {
+	ID         string    `json:"id" db:"family_id"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	IP         string    `json:"ip" db:"ip"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+}
+
+type RefreshTokenStore interface {
+	Create(token *RefreshToken) (*RefreshToken, error)
+	FindByHash(hash string) (*RefreshToken, error)
+	// MarkUsed атомарно помечает токен использованным. Возвращает false,
+	// если токен уже был использован или отозван — это повторное предъявление.
+	MarkUsed(id int) (bool, error)
+	RevokeFamily(familyID string) error
+	RevokeAllForUser(userID int) error
+	// ListSessions возвращает семьи с действующим refresh token, новые первыми.
+	ListSessions(userID int) ([]Session, error)
+	// RevokeSession отзывает сессию пользователя; ErrNotFound — активной сессии с таким ID нет.
+	RevokeSession(userID int, familyID string) error
+	// RevokeOtherSessions отзывает все сессии пользователя, кроме keepFamilyID.
+	RevokeOtherSessions(userID int, keepFamilyID string) error
+}
+
+const refreshTokenColumns = "id, user_id, family_id, token_hash, expires_at, created_at, used_at, revoked_at, user_agent, ip"
+
+type RefreshTokenRepository struct{}
+
+func (r *RefreshTokenRepository) Create(token *RefreshToken) (*RefreshToken, error) {
+	query := `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, user_agent, ip) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, token.UserAgent, token.IP).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *RefreshTokenRepository) FindByHash(hash string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := db.DB.Get(&token, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE token_hash=$1", hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *RefreshTokenRepository) MarkUsed(id int) (bool, error) {
+	result, err := db.DB.Exec("UPDATE refresh_tokens SET used_at=NOW() WHERE id=$1 AND used_at IS NULL AND revoked_at IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(familyID string) error {
+	_, err := db.DB.Exec("UPDATE refresh_tokens SET revoked_at=NOW() WHERE family_id=$1 AND revoked_at IS NULL", familyID)
+	return err
+}
+
+func (r *RefreshTokenRepository) RevokeAllForUser(userID int) error {
+	_, err := db.DB.Exec("UPDATE refresh_tokens SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL", userID)
+	return err
+}
+
+func (r *RefreshTokenRepository) ListSessions(userID int) ([]Session, error) {
+	query := `SELECT t.family_id, t.user_agent, t.ip, t.created_at AS last_used_at, t.expires_at,
+			(SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = t.family_id) AS created_at
+		FROM refresh_tokens t
+		WHERE t.user_id=$1 AND t.used_at IS NULL AND t.revoked_at IS NULL AND t.expires_at > NOW()
+		ORDER BY t.created_at DESC`
+	sessions := []Session{}
+	if err := db.DB.Select(&sessions, query, userID); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *RefreshTokenRepository) RevokeSession(userID int, familyID string) error {
+	result, err := db.DB.Exec(`UPDATE refresh_tokens SET revoked_at=NOW()
+		WHERE user_id=$1 AND family_id=$2 AND revoked_at IS NULL AND used_at IS NULL AND expires_at > NOW()`, userID, familyID)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(result); err != nil {
+		return err
+	}
+	return r.RevokeFamily(familyID)
+}
+
+func (r *RefreshTokenRepository) RevokeOtherSessions(userID int, keepFamilyID string) error {
+	_, err := db.DB.Exec("UPDATE refresh_tokens SET revoked_at=NOW() WHERE user_id=$1 AND family_id<>$2 AND revoked_at IS NULL", userID, keepFamilyID)
+	return err
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/pkg/db"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MessageRevision — прежний текст отредактированного сообщения.
+type MessageRevision struct {
+	ID        int    `json:"id"`
+	MessageID int    `json:"message_id" db:"message_id"`
+	Content   string `json:"content"`
+	// CreatedAt — время правки, заменившей этот текст.
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ownedMessage отбирает сообщение пользователя вне корзины.
+const ownedMessage = `id=$1 AND deleted_at IS NULL
+	AND conversation_id IN (SELECT id FROM conversations WHERE user_id=$2 AND deleted_at IS NULL)`
+
+func (r *MessageRepository) FindByID(id, userID int) (*Message, error) {
+	var msg Message
+	err := db.DB.Get(&msg, "SELECT "+messageColumns+" FROM messages WHERE "+ownedMessage, id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Edit одной транзакцией сохраняет прежний текст в message_revisions и
+// заменяет его новым.
+func (r *MessageRepository) Edit(id, userID int, content string) (*Message, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	msg, err := editMessage(tx, id, userID, content)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// editMessage блокирует сообщение, сохраняет прежний текст в ревизиях и
+// заменяет его. Вектор сообщения удаляется: индексатор пересчитает его.
+func editMessage(tx *sqlx.Tx, id, userID int, content string) (*Message, error) {
+	var old string
+	err := tx.Get(&old, "SELECT content FROM messages WHERE "+ownedMessage+db.ForUpdate(), id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("INSERT INTO message_revisions (message_id, content) VALUES ($1, $2)", id, old); err != nil {
+		return nil, err
+	}
+	var msg Message
+	err = tx.Get(&msg, "UPDATE messages SET content=$2, edited_at=NOW() WHERE id=$1 RETURNING "+messageColumns, id, content)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM message_embeddings WHERE message_id=$1", id); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (r *MessageRepository) ListRevisions(id, userID int) ([]MessageRevision, error) {
+	if _, err := r.FindByID(id, userID); err != nil {
+		return nil, err
+	}
+	var revs []MessageRevision
+	err := db.DB.Select(&revs, "SELECT id, message_id, content, created_at FROM message_revisions WHERE message_id=$1 ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+func (r *MessageRepository) EditAndRegenerate(id, userID int, content string) (*Message, *Message, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// editMessage блокирует строку: параллельный запрос дождётся коммита и
+	// увидит уже созданный ответ в pending.
+	edited, err := editMessage(tx, id, userID, content)
+	if err != nil {
+		return nil, nil, err
+	}
+	var busy bool
+	query := `SELECT EXISTS (SELECT 1 FROM messages
+		WHERE conversation_id=$1 AND deleted_at IS NULL AND (created_at, id) > ($2, $3)
+			AND status IN ('pending', 'streaming'))`
+	if err := tx.Get(&busy, query, edited.ConversationID, edited.CreatedAt, id); err != nil {
+		return nil, nil, err
+	}
+	if busy {
+		return nil, nil, ErrReplyInProgress
+	}
+	query = `UPDATE messages SET deleted_at=NOW()
+		WHERE conversation_id=$1 AND deleted_at IS NULL AND (created_at, id) > ($2, $3)`
+	if _, err := tx.Exec(query, edited.ConversationID, edited.CreatedAt, id); err != nil {
+		return nil, nil, err
+	}
+	reply, err := insertReply(tx, edited.ConversationID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return edited, reply, nil
+}
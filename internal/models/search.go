@@ -0,0 +1,243 @@
+package models
+
+import (
+	"me-ai/pkg/db"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	SearchKindMessage      = "message"
+	SearchKindConversation = "conversation"
+)
+
+// Совпадения в Snippet обрамляются этими символами; в HTML их превращает вызывающий,
+// предварительно экранировав текст.
+const (
+	SnippetStart = "\x01"
+	SnippetStop  = "\x02"
+)
+
+type SearchQuery struct {
+	UserID int
+	Query  string
+	// ConversationID — 0, если искать во всех беседах.
+	ConversationID int
+	// Role — только сообщения с этой ролью; названия бесед тогда не ищутся.
+	Role string
+	From *time.Time
+	To   *time.Time
+	// After — позиция последнего результата предыдущей страницы.
+	After *SearchCursor
+	Limit int
+}
+
+// SearchCursor — ключ сортировки результата: rank, created_at, kind, id по убыванию.
+type SearchCursor struct {
+	Rank      float32   `json:"r"`
+	CreatedAt time.Time `json:"t"`
+	Kind      string    `json:"k"`
+	ID        int       `json:"i"`
+}
+
+type SearchHit struct {
+	Kind              string    `json:"kind" db:"kind"`
+	ID                int       `json:"id" db:"id"`
+	ConversationID    int       `json:"conversation_id" db:"conversation_id"`
+	ConversationTitle string    `json:"conversation_title" db:"conversation_title"`
+	Role              string    `json:"role,omitempty" db:"role"`
+	Snippet           string    `json:"snippet" db:"snippet"`
+	Rank              float32   `json:"rank" db:"rank"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+func (h *SearchHit) Cursor() SearchCursor {
+	return SearchCursor{Rank: h.Rank, CreatedAt: h.CreatedAt, Kind: h.Kind, ID: h.ID}
+}
+
+type SearchStore interface {
+	Search(q SearchQuery) ([]SearchHit, error)
+}
+
+type SearchRepository struct{}
+
+// searchQuery ищет по сообщениям и названиям бесед одним запросом. Запрос
+// пользователя разбирается websearch_to_tsquery в обоих словарях. ts_headline
+// дорогой, поэтому считается только для строк текущей страницы.
+const searchQuery = `
+WITH q AS (
+	SELECT websearch_to_tsquery('russian', $2) || websearch_to_tsquery('english', $2) AS query
+),
+hits AS (
+	SELECT 'message' AS kind, m.id, m.conversation_id, c.title AS conversation_title, m.role,
+		m.content AS body, ts_rank_cd(m.search_vector, q.query) AS rank, m.created_at
+	FROM messages m
+	JOIN conversations c ON c.id = m.conversation_id, q
+	WHERE c.user_id = $1 AND m.search_vector @@ q.query
+		AND c.deleted_at IS NULL AND m.deleted_at IS NULL
+		AND ($3 = 0 OR m.conversation_id = $3)
+		AND ($4 = '' OR m.role = $4)
+		AND ($5::timestamp IS NULL OR m.created_at >= $5)
+		AND ($6::timestamp IS NULL OR m.created_at < $6)
+	UNION ALL
+	SELECT 'conversation', c.id, c.id, c.title, '',
+		c.title, ts_rank_cd(c.search_vector, q.query), c.created_at
+	FROM conversations c, q
+	WHERE c.user_id = $1 AND c.search_vector @@ q.query
+		AND c.deleted_at IS NULL
+		AND $4 = ''
+		AND ($3 = 0 OR c.id = $3)
+		AND ($5::timestamp IS NULL OR c.created_at >= $5)
+		AND ($6::timestamp IS NULL OR c.created_at < $6)
+),
+page AS (
+	SELECT * FROM hits
+	WHERE $7::real IS NULL OR (rank, created_at, kind, id) < ($7::real, $8::timestamp, $9::text, $10::int)
+	ORDER BY rank DESC, created_at DESC, kind DESC, id DESC
+	LIMIT $11
+)
+SELECT kind, id, conversation_id, conversation_title, role, rank, created_at,
+	ts_headline('russian', body, q.query,
+		'StartSel=' || chr(1) || ', StopSel=' || chr(2) || ', MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "') AS snippet
+FROM page, q
+ORDER BY rank DESC, created_at DESC, kind DESC, id DESC`
+
+// sqliteSearchQuery — тот же поиск на FTS5 (таблицы messages_fts и
+// conversations_fts). Ранг — число совпадений в тексте, как ts_rank_cd без
+// нормализации: целое число точно представимо в float32 курсора, и страницы не
+// теряют и не повторяют строк, а bm25 на маленькой базе почти не различает строки.
+const sqliteSearchQuery = `
+WITH hits AS (
+	SELECT 'message' AS kind, m.id, m.conversation_id, c.title AS conversation_title, m.role,
+		snippet(messages_fts, 0, char(1), char(2), ' … ', 35) AS snippet,
+		length(highlight(messages_fts, 0, char(1), '')) - length(m.content) AS rank, m.created_at
+	FROM messages_fts
+	JOIN messages m ON m.id = messages_fts.rowid
+	JOIN conversations c ON c.id = m.conversation_id
+	WHERE messages_fts MATCH $2 AND c.user_id = $1
+		AND c.deleted_at IS NULL AND m.deleted_at IS NULL
+		AND ($3 = 0 OR m.conversation_id = $3)
+		AND ($4 = '' OR m.role = $4)
+		AND ($5 IS NULL OR m.created_at >= $5)
+		AND ($6 IS NULL OR m.created_at < $6)
+	UNION ALL
+	SELECT 'conversation', c.id, c.id, c.title, '',
+		snippet(conversations_fts, 0, char(1), char(2), ' … ', 35),
+		length(highlight(conversations_fts, 0, char(1), '')) - length(c.title), c.created_at
+	FROM conversations_fts
+	JOIN conversations c ON c.id = conversations_fts.rowid
+	WHERE conversations_fts MATCH $2 AND c.user_id = $1
+		AND c.deleted_at IS NULL
+		AND $4 = ''
+		AND ($3 = 0 OR c.id = $3)
+		AND ($5 IS NULL OR c.created_at >= $5)
+		AND ($6 IS NULL OR c.created_at < $6)
+)
+SELECT kind, id, conversation_id, conversation_title, role, snippet, rank, created_at
+FROM hits
+WHERE $7 IS NULL OR (rank, created_at, kind, id) < ($7, $8, $9, $10)
+ORDER BY rank DESC, created_at DESC, kind DESC, id DESC
+LIMIT $11`
+
+func (r *SearchRepository) Search(q SearchQuery) ([]SearchHit, error) {
+	query, text := searchQuery, q.Query
+	if db.Dialect() == db.SQLite {
+		query, text = sqliteSearchQuery, ftsQuery(q.Query)
+		if text == "" {
+			return nil, nil
+		}
+	}
+	var (
+		rank      *float32
+		createdAt *time.Time
+		kind      *string
+		id        *int
+	)
+	if q.After != nil {
+		rank, createdAt, kind, id = &q.After.Rank, &q.After.CreatedAt, &q.After.Kind, &q.After.ID
+	}
+	var hits []SearchHit
+	err := db.DB.Select(&hits, query, q.UserID, text, q.ConversationID, q.Role, q.From, q.To,
+		rank, createdAt, kind, id, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// ftsQuery переводит запрос в синтаксисе websearch_to_tsquery в запрос FTS5.
+// Словарей со стеммингом в SQLite нет, поэтому слова ищутся по префиксу
+// ("канал" находит «каналы»), "фраза в кавычках" — целиком. or связывает
+// соседние условия, -слово исключает. Пустая строка — искать нечего.
+func ftsQuery(query string) string {
+	var (
+		groups   [][]string // через AND, внутри группы — через OR
+		excluded []string
+		or       bool
+		negate   bool
+	)
+	add := func(term string) {
+		switch {
+		case negate:
+			excluded = append(excluded, term)
+		case or:
+			groups[len(groups)-1] = append(groups[len(groups)-1], term)
+		default:
+			groups = append(groups, []string{term})
+		}
+		or, negate = false, false
+	}
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if words := ftsWords(string(runes[i+1 : min(end, len(runes))])); len(words) > 0 {
+				add(`"` + strings.Join(words, " ") + `"`)
+			}
+			i = end + 1
+		case isWordRune(r):
+			end := i
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+			word := strings.ToLower(string(runes[i:end]))
+			if word == "or" && len(groups) > 0 && !negate {
+				or = true
+			} else {
+				add(`"` + word + `"*`)
+			}
+			i = end
+		default:
+			negate = r == '-' && (i == 0 || unicode.IsSpace(runes[i-1]))
+			i++
+		}
+	}
+	if len(groups) == 0 {
+		return ""
+	}
+	parts := make([]string, len(groups))
+	for i, g := range groups {
+		parts[i] = strings.Join(g, " OR ")
+		if len(g) > 1 {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	fts := strings.Join(parts, " AND ")
+	for _, term := range excluded {
+		fts += " NOT " + term
+	}
+	return fts
+}
+
+func ftsWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !isWordRune(r) })
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/pkg/db"
+	"time"
+)
+
+// Share — публичная ссылка на снимок беседы.
+type Share struct {
+	ID             int    `json:"id" db:"id"`
+	UserID         int    `json:"-" db:"user_id"`
+	ConversationID int    `json:"conversation_id" db:"conversation_id"`
+	TokenHash      string `json:"-" db:"token_hash"`
+	Title          string `json:"title" db:"title"`
+	// Snapshot — JSON сообщений на момент создания ссылки.
+	Snapshot     []byte     `json:"-" db:"snapshot"`
+	MessageCount int        `json:"message_count" db:"message_count"`
+	PasswordHash *string    `json:"-" db:"password_hash"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	Views        int        `json:"views" db:"views"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty" db:"last_viewed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+type ShareStore interface {
+	Create(share *Share) (*Share, error)
+	// FindByTokenHash не находит ссылки бесед в корзине: они снова работают после восстановления.
+	FindByTokenHash(hash string) (*Share, error)
+	// ListByUser — ссылки пользователя, новые первыми; conversationID = 0 — по всем беседам.
+	ListByUser(userID, conversationID int) ([]Share, error)
+	Revoke(id, userID int) error
+	RecordView(id int) error
+}
+
+const shareColumns = "id, user_id, conversation_id, token_hash, title, snapshot, message_count, password_hash, expires_at, revoked_at, views, last_viewed_at, created_at"
+
+type ShareRepository struct{}
+
+func (r *ShareRepository) Create(share *Share) (*Share, error) {
+	query := `INSERT INTO conversation_shares (user_id, conversation_id, token_hash, title, snapshot, message_count, password_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, share.UserID, share.ConversationID, share.TokenHash, share.Title, share.Snapshot,
+		share.MessageCount, share.PasswordHash, share.ExpiresAt).Scan(&share.ID, &share.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+func (r *ShareRepository) FindByTokenHash(hash string) (*Share, error) {
+	var share Share
+	query := `SELECT ` + shareColumns + ` FROM conversation_shares
+		WHERE token_hash = $1 AND conversation_id IN (SELECT id FROM conversations WHERE deleted_at IS NULL)`
+	err := db.DB.Get(&share, query, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *ShareRepository) ListByUser(userID, conversationID int) ([]Share, error) {
+	var shares []Share
+	query := `SELECT ` + shareColumns + ` FROM conversation_shares WHERE user_id = $1 AND ($2 = 0 OR conversation_id = $2)
+		ORDER BY created_at DESC, id DESC`
+	if err := db.DB.Select(&shares, query, userID, conversationID); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+func (r *ShareRepository) Revoke(id, userID int) error {
+	result, err := db.DB.Exec("UPDATE conversation_shares SET revoked_at=NOW() WHERE id=$1 AND user_id=$2 AND revoked_at IS NULL", id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *ShareRepository) RecordView(id int) error {
+	_, err := db.DB.Exec("UPDATE conversation_shares SET views = views + 1, last_viewed_at = NOW() WHERE id=$1", id)
+	return err
+}
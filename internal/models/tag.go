@@ -0,0 +1,60 @@
+package models
+
+import (
+	"me-ai/pkg/db"
+	"time"
+)
+
+type Tag struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"-" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// Conversations — число бесед с этим тегом.
+	Conversations int `json:"conversations" db:"conversations"`
+}
+
+// TagStore — теги создаются при первом назначении через ConversationStore.Bulk.
+type TagStore interface {
+	ListByUser(userID int) ([]Tag, error)
+	// Rename возвращает ErrDuplicate, если тег с таким именем уже есть.
+	Rename(id, userID int, name string) error
+	// Delete удаляет тег у всех бесед.
+	Delete(id, userID int) error
+}
+
+type TagRepository struct{}
+
+func (r *TagRepository) ListByUser(userID int) ([]Tag, error) {
+	query := `SELECT t.id, t.user_id, t.name, t.created_at, COUNT(c.id) AS conversations
+		FROM tags t
+		LEFT JOIN conversation_tags ct ON ct.tag_id = t.id
+		LEFT JOIN conversations c ON c.id = ct.conversation_id AND c.deleted_at IS NULL
+		WHERE t.user_id = $1
+		GROUP BY t.id
+		ORDER BY t.name`
+	var tags []Tag
+	if err := db.DB.Select(&tags, query, userID); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *TagRepository) Rename(id, userID int, name string) error {
+	result, err := db.DB.Exec("UPDATE tags SET name=$1 WHERE id=$2 AND user_id=$3", name, id, userID)
+	if db.IsUniqueViolation(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *TagRepository) Delete(id, userID int) error {
+	result, err := db.DB.Exec("DELETE FROM tags WHERE id=$1 AND user_id=$2", id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"me-ai/pkg/db"
+	"time"
+)
+
+func (r *ConversationRepository) ListDeleted(userID int) ([]Conversation, error) {
+	var convos []Conversation
+	err := db.DB.Select(&convos, "SELECT "+conversationColumns+" FROM conversations WHERE user_id=$1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	return convos, nil
+}
+
+func (r *ConversationRepository) Restore(id, userID int) error {
+	result, err := db.DB.Exec("UPDATE conversations SET deleted_at=NULL WHERE id=$1 AND user_id=$2 AND deleted_at IS NOT NULL", id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *ConversationRepository) Purge(id, userID int) error {
+	result, err := db.DB.Exec("DELETE FROM conversations WHERE id=$1 AND user_id=$2 AND deleted_at IS NOT NULL", id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *ConversationRepository) PurgeDeleted(userID int, before time.Time) (int, error) {
+	result, err := db.DB.Exec("DELETE FROM conversations WHERE deleted_at < $1 AND ($2 = 0 OR user_id = $2)", before, userID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func (r *MessageRepository) ListDeleted(userID int) ([]Message, error) {
+	query := `SELECT m.id, m.conversation_id, m.user_id, m.role, m.content, m.created_at, m.deleted_at, m.edited_at, m.status
+		FROM messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE c.user_id = $1 AND c.deleted_at IS NULL AND m.deleted_at IS NOT NULL
+		ORDER BY m.deleted_at DESC, m.id DESC`
+	var msgs []Message
+	if err := db.DB.Select(&msgs, query, userID); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+func (r *MessageRepository) Restore(id, userID int) error {
+	query := `UPDATE messages SET deleted_at=NULL WHERE id=$1 AND deleted_at IS NOT NULL
+		AND conversation_id IN (SELECT id FROM conversations WHERE user_id=$2 AND deleted_at IS NULL)`
+	result, err := db.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *MessageRepository) Purge(id, userID int) error {
+	query := `DELETE FROM messages WHERE id=$1 AND deleted_at IS NOT NULL
+		AND conversation_id IN (SELECT id FROM conversations WHERE user_id=$2)`
+	result, err := db.DB.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *MessageRepository) PurgeDeleted(userID int, before time.Time) (int, error) {
+	query := `DELETE FROM messages WHERE deleted_at < $1
+		AND ($2 = 0 OR conversation_id IN (SELECT id FROM conversations WHERE user_id = $2))`
+	result, err := db.DB.Exec(query, before, userID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/pkg/db"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// insertReply создаёт пустой ответ ассистента в pending.
+func insertReply(tx *sqlx.Tx, convoID, userID int) (*Message, error) {
+	var reply Message
+	query := `INSERT INTO messages (conversation_id, user_id, role, content, status)
+		VALUES ($1, $2, 'assistant', '', 'pending') RETURNING ` + messageColumns
+	if err := tx.Get(&reply, query, convoID, userID); err != nil {
+		return nil, err
+	}
+	reply.Timestamp = reply.CreatedAt
+	return &reply, nil
+}
+
+func (r *MessageRepository) CreateTurn(convoID, userID int, content string) (*Turn, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var turn Turn
+	query := `INSERT INTO messages (conversation_id, user_id, role, content) VALUES ($1, $2, 'user', $3) RETURNING ` + messageColumns
+	if err := tx.Get(&turn.UserMessage, query, convoID, userID, content); err != nil {
+		return nil, err
+	}
+	turn.UserMessage.Timestamp = turn.UserMessage.CreatedAt
+	reply, err := insertReply(tx, convoID, userID)
+	if err != nil {
+		return nil, err
+	}
+	turn.Reply = *reply
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &turn, nil
+}
+
+func (r *MessageRepository) UpdateReply(id int, status, content string) error {
+	result, err := db.DB.Exec("UPDATE messages SET status=$2, content=$3 WHERE id=$1 AND role='assistant'", id, status, content)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *MessageRepository) RetryReply(id, userID int, staleBefore time.Time) (*Message, error) {
+	tx, err := db.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var reply Message
+	err = tx.Get(&reply, "SELECT "+messageColumns+" FROM messages WHERE "+ownedMessage+db.ForUpdate(), id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !reply.Retryable(staleBefore) {
+		return nil, ErrNotRetryable
+	}
+	var later bool
+	query := `SELECT EXISTS (SELECT 1 FROM messages
+		WHERE conversation_id=$1 AND deleted_at IS NULL AND (created_at, id) > ($2, $3))`
+	if err := tx.Get(&later, query, reply.ConversationID, reply.CreatedAt, id); err != nil {
+		return nil, err
+	}
+	if later {
+		return nil, ErrNotRetryable
+	}
+	// Новый created_at отсчитывает срок зависания заново и оставляет ответ последним.
+	query = "UPDATE messages SET status='pending', content='', created_at=NOW() WHERE id=$1 RETURNING " + messageColumns
+	if err := tx.Get(&reply, query, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	reply.Timestamp = reply.CreatedAt
+	return &reply, nil
+}
+
+// Retryable: повторить можно ответ, завершившийся ошибкой, или зависший с
+// начала до staleBefore — например, если процесс упал посреди генерации.
+func (m *Message) Retryable(staleBefore time.Time) bool {
+	if m.Role != "assistant" {
+		return false
+	}
+	switch m.Status {
+	case MessageFailed:
+		return true
+	case MessagePending, MessageStreaming:
+		return m.CreatedAt.Before(staleBefore)
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/pkg/db"
+	"time"
+)
+
+type UserUsage struct {
+	UserID        int        `json:"user_id" db:"user_id"`
+	Email         string     `json:"email" db:"email"`
+	Conversations int        `json:"conversations" db:"conversations"`
+	Messages      int        `json:"messages" db:"messages"`
+	LastMessageAt *time.Time `json:"last_message_at" db:"last_message_at"`
+}
+
+type UsageStore interface {
+	ByUser() ([]UserUsage, error)
+	// ForUser — статистика одного пользователя; нули, если сообщений нет.
+	ForUser(userID int) (*UserUsage, error)
+}
+
+type UsageRepository struct{}
+
+func (r *UsageRepository) ByUser() ([]UserUsage, error) {
+	query := `SELECT u.id AS user_id, u.email,
+			COUNT(DISTINCT c.id) AS conversations,
+			COUNT(m.id) AS messages,
+			MAX(m.created_at) AS last_message_at
+		FROM users u
+		LEFT JOIN conversations c ON c.user_id = u.id AND c.deleted_at IS NULL
+		LEFT JOIN messages m ON m.conversation_id = c.id AND m.deleted_at IS NULL
+		GROUP BY u.id, u.email
+		ORDER BY messages DESC, u.id`
+	var usage []UserUsage
+	if err := db.DB.Select(&usage, query); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+func (r *UsageRepository) ForUser(userID int) (*UserUsage, error) {
+	query := `SELECT u.id AS user_id, u.email,
+			COUNT(DISTINCT c.id) AS conversations,
+			COUNT(m.id) AS messages,
+			MAX(m.created_at) AS last_message_at
+		FROM users u
+		LEFT JOIN conversations c ON c.user_id = u.id AND c.deleted_at IS NULL
+		LEFT JOIN messages m ON m.conversation_id = c.id AND m.deleted_at IS NULL
+		WHERE u.id = $1
+		GROUP BY u.id, u.email`
+	var usage UserUsage
+	if err := db.DB.Get(&usage, query, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &usage, nil
+}
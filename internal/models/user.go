@@ -2,18 +2,79 @@ package models
 
 import (
 	"me-ai/pkg/db"
+	"time"
 )
 
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleAuditor = "auditor"
+)
+
+var Roles = []string{RoleUser, RoleAdmin, RoleAuditor}
+
 type User struct {
-	ID        int    `json:"id" db:"id"`
-	Email     string `json:"email" db:"email"`
-	Password  string `json:"password,omitempty" db:"password"`
-	Name      string `json:"name" db:"name"`
-	CreatedAt string `json:"created_at" db:"created_at"`
+	ID         int        `json:"id" db:"id"`
+	Email      string     `json:"email" db:"email"`
+	Password   string     `json:"password,omitempty" db:"password"`
+	Name       string     `json:"name" db:"name"`
+	Role       string     `json:"role" db:"role"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	CreatedAt  string     `json:"created_at" db:"created_at"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	// PendingEmail — новый адрес, который ещё не подтверждён письмом.
+	PendingEmail *string `json:"pending_email,omitempty" db:"pending_email"`
+
+	TOTPSecret    *string    `json:"-" db:"totp_secret"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at,omitempty" db:"totp_enabled_at"`
+	TOTPLastStep  int64      `json:"-" db:"totp_last_step"`
+}
+
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+type UserStore interface {
+	FindByID(id int) (*User, error)
+	FindByEmail(email string) (*User, error)
+	Create(user *User) (*User, error)
+	List() ([]User, error)
+	SetDisabled(id int, disabled bool) error
+	SetRole(id int, role string) error
+	MarkEmailVerified(id int) error
+	UpdatePassword(id int, passwordHash string) error
+	UpdateName(id int, name string) error
+	// SetPendingEmail запоминает адрес до подтверждения; nil отменяет смену.
+	SetPendingEmail(id int, email *string) error
+	// ChangeEmail применяет подтверждённый адрес и сбрасывает pending_email.
+	ChangeEmail(id int, email string) error
+	// Delete удаляет пользователя вместе с беседами, сообщениями, сессиями и ключами.
+	Delete(id int) error
+	// SetTOTPSecret сохраняет секрет ещё не подтверждённого подключения 2FA.
+	SetTOTPSecret(id int, secret string) error
+	EnableTOTP(id int) error
+	DisableTOTP(id int) error
+	// UseTOTPStep атомарно запоминает интервал принятого кода. Возвращает false,
+	// если код этого или более позднего интервала уже использовался.
+	UseTOTPStep(id int, step int64) (bool, error)
 }
 
 type UserRepository struct{}
 
+func (r *UserRepository) FindByID(id int) (*User, error) {
+	var user User
+	err := db.DB.Get(&user, "SELECT * FROM users WHERE id=$1", id)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *UserRepository) FindByEmail(email string) (*User, error) {
 	var user User
 	err := db.DB.Get(&user, "SELECT * FROM users WHERE email=$1", email)
@@ -24,10 +85,129 @@ func (r *UserRepository) FindByEmail(email string) (*User, error) {
 }
 
 func (r *UserRepository) Create(user *User) (*User, error) {
-	query := `INSERT INTO users (email, password, name) VALUES ($1, $2, $3) RETURNING id, created_at`
-	err := db.DB.QueryRowx(query, user.Email, user.Password, user.Name).Scan(&user.ID, &user.CreatedAt)
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+	query := `INSERT INTO users (email, password, name, role) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, user.Email, user.Password, user.Name, user.Role).Scan(&user.ID, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
+
+func (r *UserRepository) List() ([]User, error) {
+	var users []User
+	err := db.DB.Select(&users, "SELECT * FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *UserRepository) SetDisabled(id int, disabled bool) error {
+	query := "UPDATE users SET disabled_at=NULL WHERE id=$1"
+	if disabled {
+		query = "UPDATE users SET disabled_at=COALESCE(disabled_at, NOW()) WHERE id=$1"
+	}
+	result, err := db.DB.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) SetRole(id int, role string) error {
+	result, err := db.DB.Exec("UPDATE users SET role=$1 WHERE id=$2", role, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) MarkEmailVerified(id int) error {
+	result, err := db.DB.Exec("UPDATE users SET email_verified_at=COALESCE(email_verified_at, NOW()) WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) UpdatePassword(id int, passwordHash string) error {
+	result, err := db.DB.Exec("UPDATE users SET password=$1 WHERE id=$2", passwordHash, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) UpdateName(id int, name string) error {
+	result, err := db.DB.Exec("UPDATE users SET name=$1 WHERE id=$2", name, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) SetPendingEmail(id int, email *string) error {
+	result, err := db.DB.Exec("UPDATE users SET pending_email=$1 WHERE id=$2", email, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) ChangeEmail(id int, email string) error {
+	result, err := db.DB.Exec("UPDATE users SET email=$1, pending_email=NULL, email_verified_at=NOW() WHERE id=$2", email, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// Delete полагается на ON DELETE CASCADE во внешних ключах: беседы (а с ними
+// сообщения), refresh token'ы, API-ключи, токены из писем и коды восстановления
+// удаляются вместе с пользователем, в журнале аудита и блокировках ссылка обнуляется.
+func (r *UserRepository) Delete(id int) error {
+	result, err := db.DB.Exec("DELETE FROM users WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) SetTOTPSecret(id int, secret string) error {
+	result, err := db.DB.Exec("UPDATE users SET totp_secret=$1, totp_enabled_at=NULL WHERE id=$2", secret, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) EnableTOTP(id int) error {
+	result, err := db.DB.Exec("UPDATE users SET totp_enabled_at=NOW() WHERE id=$1 AND totp_secret IS NOT NULL", id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) DisableTOTP(id int) error {
+	result, err := db.DB.Exec("UPDATE users SET totp_secret=NULL, totp_enabled_at=NULL, totp_last_step=0 WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *UserRepository) UseTOTPStep(id int, step int64) (bool, error) {
+	result, err := db.DB.Exec("UPDATE users SET totp_last_step=$1 WHERE id=$2 AND totp_last_step < $1", step, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
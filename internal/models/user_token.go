@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"me-ai/pkg/db"
+	"time"
+)
+
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailChange       = "email_change"
+)
+
+// UserToken — одноразовый токен из письма. Сам токен не хранится, только хэш.
+type UserToken struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	Purpose   string     `json:"purpose" db:"purpose"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type UserTokenStore interface {
+	Create(token *UserToken) (*UserToken, error)
+	// Consume атомарно гасит действующий токен. ErrNotFound — токен неизвестен,
+	// выдан для другой цели, истёк или уже использован.
+	Consume(hash, purpose string, now time.Time) (*UserToken, error)
+	// InvalidateForUser гасит все неиспользованные токены пользователя с этой целью.
+	InvalidateForUser(userID int, purpose string) error
+}
+
+const userTokenColumns = "id, user_id, purpose, token_hash, expires_at, used_at, created_at"
+
+type UserTokenRepository struct{}
+
+func (r *UserTokenRepository) Create(token *UserToken) (*UserToken, error) {
+	query := `INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := db.DB.QueryRowx(query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *UserTokenRepository) Consume(hash, purpose string, now time.Time) (*UserToken, error) {
+	var token UserToken
+	query := `UPDATE user_tokens SET used_at=NOW()
+		WHERE token_hash=$1 AND purpose=$2 AND used_at IS NULL AND expires_at > $3
+		RETURNING ` + userTokenColumns
+	err := db.DB.Get(&token, query, hash, purpose, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *UserTokenRepository) InvalidateForUser(userID int, purpose string) error {
+	_, err := db.DB.Exec("UPDATE user_tokens SET used_at=NOW() WHERE user_id=$1 AND purpose=$2 AND used_at IS NULL", userID, purpose)
+	return err
+}
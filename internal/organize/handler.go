@@ -0,0 +1,287 @@
+// Package organize — папки, теги и массовые действия над беседами.
+package organize
+
+import (
+	"errors"
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/pkg/req"
+	"me-ai/pkg/res"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxFolderName = 255
+	maxTagName    = 64
+	// maxBulk ограничивает число бесед в одном массовом действии.
+	maxBulk = 500
+)
+
+var bulkActions = []string{
+	models.BulkMove, models.BulkTag, models.BulkUntag, models.BulkPin, models.BulkUnpin,
+	models.BulkArchive, models.BulkUnarchive, models.BulkDelete,
+}
+
+type OrganizeHandlerDeps struct {
+	ConversationRepository models.ConversationStore
+	FolderRepository       models.FolderStore
+	TagRepository          models.TagStore
+	UserRepository         models.UserStore
+}
+
+type OrganizeHandler struct {
+	OrganizeHandlerDeps
+}
+
+func NewOrganizeHandler(router *http.ServeMux, deps OrganizeHandlerDeps) {
+	handler := &OrganizeHandler{OrganizeHandlerDeps: deps}
+	read := middleware.RequireScope(middleware.ScopeConversationsRead)
+	write := middleware.RequireScope(middleware.ScopeConversationsWrite)
+	router.Handle("/api/folders", read(handler.ListFolders()))          // GET
+	router.Handle("/api/folders/create", write(handler.CreateFolder())) // POST
+	router.Handle("/api/folders/rename", write(handler.RenameFolder())) // POST
+	router.Handle("/api/folders/delete", write(handler.DeleteFolder())) // POST
+	router.Handle("/api/tags", read(handler.ListTags()))                // GET
+	router.Handle("/api/tags/rename", write(handler.RenameTag()))       // POST
+	router.Handle("/api/tags/delete", write(handler.DeleteTag()))       // POST
+	router.Handle("/api/conversations/bulk", write(handler.Bulk()))     // POST
+}
+
+// post проверяет метод и возвращает текущего пользователя.
+func (handler *OrganizeHandler) post(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	return middleware.CurrentUser(w, r, handler.UserRepository)
+}
+
+func (handler *OrganizeHandler) ListFolders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		folders, err := handler.FolderRepository.ListByUser(user.ID)
+		if err != nil {
+			writeError(w, err, "")
+			return
+		}
+		if folders == nil {
+			folders = []models.Folder{}
+		}
+		res.Json(w, folders, 200)
+	}
+}
+
+func (handler *OrganizeHandler) CreateFolder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.post(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[NameRequest](&w, r)
+		if err != nil {
+			return
+		}
+		name, err := cleanName(body.Name, maxFolderName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		folder, err := handler.FolderRepository.Create(&models.Folder{UserID: user.ID, Name: name})
+		if err != nil {
+			writeError(w, err, "")
+			return
+		}
+		res.Json(w, folder, http.StatusCreated)
+	}
+}
+
+func (handler *OrganizeHandler) RenameFolder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.post(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[RenameRequest](&w, r)
+		if err != nil {
+			return
+		}
+		name, err := cleanName(body.Name, maxFolderName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := handler.FolderRepository.Rename(body.ID, user.ID, name); err != nil {
+			writeError(w, err, "Folder not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *OrganizeHandler) DeleteFolder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.post(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[DeleteRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.FolderRepository.Delete(body.ID, user.ID); err != nil {
+			writeError(w, err, "Folder not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *OrganizeHandler) ListTags() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		tags, err := handler.TagRepository.ListByUser(user.ID)
+		if err != nil {
+			writeError(w, err, "")
+			return
+		}
+		if tags == nil {
+			tags = []models.Tag{}
+		}
+		res.Json(w, tags, 200)
+	}
+}
+
+func (handler *OrganizeHandler) RenameTag() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.post(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[RenameRequest](&w, r)
+		if err != nil {
+			return
+		}
+		name, err := cleanName(body.Name, maxTagName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := handler.TagRepository.Rename(body.ID, user.ID, name); err != nil {
+			writeError(w, err, "Tag not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *OrganizeHandler) DeleteTag() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.post(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[DeleteRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.TagRepository.Delete(body.ID, user.ID); err != nil {
+			writeError(w, err, "Tag not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Bulk применяет одно действие к списку бесед: всё или ничего. Чужая или
+// несуществующая беседа либо папка отменяет действие целиком с 404.
+func (handler *OrganizeHandler) Bulk() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.post(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[BulkRequest](&w, r)
+		if err != nil {
+			return
+		}
+		action, err := toAction(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := handler.ConversationRepository.Bulk(user.ID, action); err != nil {
+			writeError(w, err, "Conversation or folder not found")
+			return
+		}
+		log.Printf("Массовое действие: user_id=%d, action=%s, conversations=%d", user.ID, action.Action, len(action.IDs))
+		res.Json(w, BulkResponse{Action: action.Action, Updated: len(action.IDs)}, 200)
+	}
+}
+
+func toAction(body *BulkRequest) (models.BulkAction, error) {
+	action := models.BulkAction{Action: body.Action, FolderID: body.FolderID}
+	if !slices.Contains(bulkActions, body.Action) {
+		return action, errors.New("action must be one of " + strings.Join(bulkActions, ", "))
+	}
+	for _, id := range body.IDs {
+		if id <= 0 {
+			return action, errors.New("invalid conversation id")
+		}
+		if !slices.Contains(action.IDs, id) {
+			action.IDs = append(action.IDs, id)
+		}
+	}
+	if len(action.IDs) == 0 || len(action.IDs) > maxBulk {
+		return action, errors.New("ids must contain from 1 to " + strconv.Itoa(maxBulk) + " conversations")
+	}
+	if body.Action == models.BulkTag || body.Action == models.BulkUntag {
+		for _, tag := range body.Tags {
+			name, err := cleanName(tag, maxTagName)
+			if err != nil {
+				return action, err
+			}
+			if !slices.Contains(action.Tags, name) {
+				action.Tags = append(action.Tags, name)
+			}
+		}
+		if len(action.Tags) == 0 {
+			return action, errors.New("tags are required")
+		}
+	}
+	return action, nil
+}
+
+// cleanName обрезает пробелы и проверяет длину имени папки или тега.
+func cleanName(name string, max int) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+	if utf8.RuneCountInString(name) > max {
+		return "", errors.New("name is too long")
+	}
+	return name, nil
+}
+
+func writeError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, models.ErrNotFound) && notFound != "":
+		http.Error(w, notFound, http.StatusNotFound)
+	case errors.Is(err, models.ErrDuplicate):
+		http.Error(w, "Name already exists", http.StatusConflict)
+	default:
+		log.Printf("Ошибка организации бесед: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,28 @@
+package organize
+
+type NameRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type RenameRequest struct {
+	ID   int    `json:"id" validate:"required"`
+	Name string `json:"name" validate:"required"`
+}
+
+type DeleteRequest struct {
+	ID int `json:"id" validate:"required"`
+}
+
+// BulkRequest — действие над несколькими беседами. FolderID = null в move
+// убирает беседы из папок.
+type BulkRequest struct {
+	Action   string   `json:"action" validate:"required"`
+	IDs      []int    `json:"ids" validate:"required"`
+	FolderID *int     `json:"folder_id"`
+	Tags     []string `json:"tags"`
+}
+
+type BulkResponse struct {
+	Action  string `json:"action"`
+	Updated int    `json:"updated"`
+}
@@ -0,0 +1,170 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/pkg/res"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+	maxQuery     = 256
+)
+
+var roles = []string{"user", "assistant", "system"}
+
+type SearchHandlerDeps struct {
+	SearchRepository models.SearchStore
+	UserRepository   models.UserStore
+}
+
+type SearchHandler struct {
+	SearchHandlerDeps
+}
+
+func NewSearchHandler(router *http.ServeMux, deps SearchHandlerDeps) {
+	handler := &SearchHandler{SearchHandlerDeps: deps}
+	router.Handle("/api/search", middleware.RequireScope(middleware.ScopeConversationsRead)(handler.Search())) // GET
+}
+
+// Search ищет по сообщениям и названиям бесед пользователя. Результаты идут по
+// релевантности; следующая страница запрашивается с cursor из предыдущего ответа.
+func (handler *SearchHandler) Search() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		query, err := parseQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.UserID = user.ID
+		limit := query.Limit
+		// Лишняя строка показывает, есть ли следующая страница.
+		query.Limit++
+		hits, err := handler.SearchRepository.Search(query)
+		if err != nil {
+			log.Printf("Ошибка поиска: user_id=%d: %v", user.ID, err)
+			http.Error(w, "Search failed", http.StatusInternalServerError)
+			return
+		}
+		response := SearchResponse{Results: make([]models.SearchHit, 0, len(hits))}
+		if len(hits) > limit {
+			hits = hits[:limit]
+			response.NextCursor = encodeCursor(hits[len(hits)-1].Cursor())
+		}
+		for _, h := range hits {
+			h.Snippet = highlight(h.Snippet)
+			response.Results = append(response.Results, h)
+		}
+		res.Json(w, response, 200)
+	}
+}
+
+func parseQuery(v url.Values) (models.SearchQuery, error) {
+	q := models.SearchQuery{Query: strings.TrimSpace(v.Get("q")), Role: v.Get("role"), Limit: defaultLimit}
+	if q.Query == "" {
+		return q, errors.New("query parameter q is required")
+	}
+	if utf8.RuneCountInString(q.Query) > maxQuery {
+		return q, errors.New("query is too long")
+	}
+	if q.Role != "" && !slices.Contains(roles, q.Role) {
+		return q, errors.New("role must be one of " + strings.Join(roles, ", "))
+	}
+	if s := v.Get("conversation_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			return q, errors.New("invalid conversation_id")
+		}
+		q.ConversationID = id
+	}
+	if s := v.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return q, errors.New("limit must be between 1 and " + strconv.Itoa(maxLimit))
+		}
+		q.Limit = limit
+	}
+	var err error
+	if q.From, err = parseDate(v.Get("from"), false); err != nil {
+		return q, errors.New("invalid from: " + err.Error())
+	}
+	if q.To, err = parseDate(v.Get("to"), true); err != nil {
+		return q, errors.New("invalid to: " + err.Error())
+	}
+	if s := v.Get("cursor"); s != "" {
+		cursor, err := decodeCursor(s)
+		if err != nil {
+			return q, errors.New("invalid cursor")
+		}
+		q.After = cursor
+	}
+	return q, nil
+}
+
+// parseDate принимает RFC 3339 или дату YYYY-MM-DD. Дата в to включается целиком.
+func parseDate(s string, end bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		day, dayErr := time.Parse(time.DateOnly, s)
+		if dayErr != nil {
+			return nil, err
+		}
+		t = day
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+func encodeCursor(c models.SearchCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*models.SearchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c models.SearchCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if c.Kind != models.SearchKindMessage && c.Kind != models.SearchKindConversation {
+		return nil, errors.New("unknown kind")
+	}
+	return &c, nil
+}
+
+// highlight экранирует текст и превращает маркеры совпадений в <mark>.
+func highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, models.SnippetStart, "<mark>")
+	return strings.ReplaceAll(escaped, models.SnippetStop, "</mark>")
+}
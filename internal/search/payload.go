@@ -0,0 +1,10 @@
+package search
+
+import "me-ai/internal/models"
+
+// SearchResponse — страница результатов. Snippet — HTML: текст экранирован,
+// совпадения обёрнуты в <mark>. NextCursor пуст на последней странице.
+type SearchResponse struct {
+	Results    []models.SearchHit `json:"results"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,110 @@
+package semantic
+
+import (
+	"errors"
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/pkg/res"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultLimit   = 10
+	maxLimit       = 50
+	defaultContext = 2
+	maxContext     = 5
+	maxQuery       = 1000
+)
+
+type SemanticHandlerDeps struct {
+	Service        *Service
+	UserRepository models.UserStore
+}
+
+type SemanticHandler struct {
+	SemanticHandlerDeps
+}
+
+func NewSemanticHandler(router *http.ServeMux, deps SemanticHandlerDeps) {
+	handler := &SemanticHandler{SemanticHandlerDeps: deps}
+	router.Handle("/api/search/semantic", middleware.RequireScope(middleware.ScopeConversationsRead)(handler.Search())) // GET
+}
+
+// Search ищет сообщения, близкие к запросу по смыслу, а не по словам. Находятся
+// только уже проиндексированные сообщения: свежие появляются с задержкой индексатора.
+func (handler *SemanticHandler) Search() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		query, err := parseQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.UserID = user.ID
+		results, err := handler.Service.Search(r.Context(), query)
+		if err != nil {
+			switch err.Error() {
+			case ErrDisabled:
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			case ErrEmbeddingQuery:
+				http.Error(w, err.Error(), http.StatusBadGateway)
+			default:
+				log.Printf("Ошибка семантического поиска: user_id=%d: %v", user.ID, err)
+				http.Error(w, "Search failed", http.StatusInternalServerError)
+			}
+			return
+		}
+		res.Json(w, SemanticResponse{Model: handler.Service.Config.Model, Results: results}, 200)
+	}
+}
+
+func parseQuery(v url.Values) (Query, error) {
+	q := Query{Text: strings.TrimSpace(v.Get("q")), Limit: defaultLimit, Context: defaultContext, MinScore: -1}
+	if q.Text == "" {
+		return q, errors.New("query parameter q is required")
+	}
+	if utf8.RuneCountInString(q.Text) > maxQuery {
+		return q, errors.New("query is too long")
+	}
+	if s := v.Get("conversation_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			return q, errors.New("invalid conversation_id")
+		}
+		q.ConversationID = id
+	}
+	if s := v.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return q, errors.New("limit must be between 1 and " + strconv.Itoa(maxLimit))
+		}
+		q.Limit = limit
+	}
+	if s := v.Get("context"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxContext {
+			return q, errors.New("context must be between 0 and " + strconv.Itoa(maxContext))
+		}
+		q.Context = n
+	}
+	if s := v.Get("min_score"); s != "" {
+		score, err := strconv.ParseFloat(s, 32)
+		if err != nil || score < -1 || score > 1 {
+			return q, errors.New("min_score must be between -1 and 1")
+		}
+		q.MinScore = float32(score)
+	}
+	return q, nil
+}
@@ -0,0 +1,14 @@
+package semantic
+
+// SemanticResponse — ближайшие сообщения по убыванию сходства.
+type SemanticResponse struct {
+	Model   string   `json:"model"`
+	Results []Result `json:"results"`
+}
+
+// BackfillResponse — состояние индекса после запроса backfill; Started = false,
+// если backfill уже шёл.
+type BackfillResponse struct {
+	*Status
+	Started bool `json:"started"`
+}
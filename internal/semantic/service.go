@@ -0,0 +1,345 @@
+// Package semantic индексирует сообщения векторами модели эмбеддингов и ищет
+// ближайшие к запросу по косинусному сходству.
+package semantic
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"me-ai/configs"
+	"me-ai/internal/models"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDisabled       = "semantic search is disabled"
+	ErrEmbeddingQuery = "failed to embed query"
+)
+
+// maxInputRunes — длинные сообщения обрезаются: модели эмбеддингов принимают
+// ограниченный контекст, а смысл обычно задаёт начало текста.
+const maxInputRunes = 8000
+
+// maxBackoff ограничивает паузу фонового индексатора после ошибок провайдера.
+const maxBackoff = 5 * time.Minute
+
+type Embedder interface {
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
+
+type ServiceDeps struct {
+	Embedder               Embedder
+	EmbeddingRepository    models.EmbeddingStore
+	ConversationRepository models.ConversationStore
+	MessageRepository      models.MessageStore
+	Config                 configs.EmbeddingsConfig
+}
+
+type Service struct {
+	ServiceDeps
+
+	// indexing допускает только один проход индексации одновременно.
+	indexing sync.Mutex
+
+	mu          sync.Mutex
+	backfilling bool
+	lastError   string
+}
+
+func NewService(deps ServiceDeps) *Service {
+	if deps.Config.BatchSize <= 0 {
+		deps.Config.BatchSize = 32
+	}
+	return &Service{ServiceDeps: deps}
+}
+
+func (s *Service) Enabled() bool {
+	return s.Config.Model != ""
+}
+
+// Status — состояние индекса для администратора.
+type Status struct {
+	models.EmbeddingStats
+	Backfilling bool   `json:"backfilling"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+func (s *Service) Status() (*Status, error) {
+	if !s.Enabled() {
+		return nil, errors.New(ErrDisabled)
+	}
+	stats, err := s.EmbeddingRepository.Stats(s.Config.Model)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &Status{EmbeddingStats: *stats, Backfilling: s.backfilling, LastError: s.lastError}, nil
+}
+
+// Run периодически индексирует новые сообщения, пока не отменён ctx. При ошибках
+// провайдера пауза удваивается до maxBackoff.
+func (s *Service) Run(ctx context.Context) {
+	if !s.Enabled() || s.Config.Interval <= 0 {
+		return
+	}
+	delay := s.Config.Interval
+	for {
+		if _, err := s.drain(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Ошибка индексации эмбеддингов: %v", err)
+			delay = min(delay*2, maxBackoff)
+		} else {
+			delay = s.Config.Interval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Backfill в фоне индексирует всю историю без векторов текущей модели.
+// Возвращает false, если backfill уже идёт.
+func (s *Service) Backfill() (bool, error) {
+	if !s.Enabled() {
+		return false, errors.New(ErrDisabled)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backfilling {
+		return false, nil
+	}
+	s.backfilling = true
+	go func() {
+		n, err := s.drain(context.Background())
+		if err != nil {
+			log.Printf("Backfill эмбеддингов прерван после %d сообщений: %v", n, err)
+		} else {
+			log.Printf("Backfill эмбеддингов завершён: %d сообщений", n)
+		}
+		s.mu.Lock()
+		s.backfilling = false
+		s.mu.Unlock()
+	}()
+	return true, nil
+}
+
+// drain индексирует пачки, пока очередь не опустеет.
+func (s *Service) drain(ctx context.Context) (int, error) {
+	s.indexing.Lock()
+	defer s.indexing.Unlock()
+	total := 0
+	for {
+		n, err := s.indexBatch(ctx)
+		total += n
+		s.mu.Lock()
+		if err != nil {
+			s.lastError = err.Error()
+		} else {
+			s.lastError = ""
+		}
+		s.mu.Unlock()
+		if err != nil || n == 0 {
+			return total, err
+		}
+	}
+}
+
+func (s *Service) indexBatch(ctx context.Context) (int, error) {
+	msgs, err := s.EmbeddingRepository.ListPending(s.Config.Model, s.Config.BatchSize)
+	if err != nil || len(msgs) == 0 {
+		return 0, err
+	}
+	var ids []int
+	var texts []string
+	for _, m := range msgs {
+		id, _ := strconv.Atoi(m.ID)
+		text := input(m.Content)
+		if text == "" {
+			// Пустому сообщению нечего искать: помечаем пропущенным без запроса к модели.
+			if err := s.EmbeddingRepository.Save(id, s.Config.Model, nil); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		ids = append(ids, id)
+		texts = append(texts, text)
+	}
+	if len(texts) == 0 {
+		return len(msgs), nil
+	}
+	vectors, err := s.Embedder.Embed(ctx, s.Config.Model, texts)
+	if err != nil {
+		return s.indexOneByOne(ctx, ids, texts, err)
+	}
+	for i, id := range ids {
+		if err := s.EmbeddingRepository.Save(id, s.Config.Model, vectors[i]); err != nil {
+			return 0, err
+		}
+	}
+	return len(msgs), nil
+}
+
+// indexOneByOne повторяет отклонённую пачку по одному сообщению, чтобы одно
+// неподходящее сообщение не останавливало очередь. Если не прошло ни одно,
+// вероятнее недоступен сам провайдер: ничего не помечаем и возвращаем ошибку.
+func (s *Service) indexOneByOne(ctx context.Context, ids []int, texts []string, batchErr error) (int, error) {
+	if len(texts) == 1 {
+		return 0, batchErr
+	}
+	vectors := make([][]float32, len(texts))
+	ok := false
+	for i, text := range texts {
+		v, err := s.Embedder.Embed(ctx, s.Config.Model, []string{text})
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			continue
+		}
+		vectors[i], ok = v[0], true
+	}
+	if !ok {
+		return 0, batchErr
+	}
+	for i, id := range ids {
+		if vectors[i] == nil {
+			log.Printf("Сообщение %d пропущено при индексации эмбеддингов", id)
+		}
+		if err := s.EmbeddingRepository.Save(id, s.Config.Model, vectors[i]); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+func input(content string) string {
+	text := strings.TrimSpace(content)
+	if r := []rune(text); len(r) > maxInputRunes {
+		text = string(r[:maxInputRunes])
+	}
+	return text
+}
+
+type Query struct {
+	UserID int
+	Text   string
+	// ConversationID — 0, если искать во всех беседах.
+	ConversationID int
+	Limit          int
+	// Context — сколько соседних сообщений вернуть до и после найденного.
+	Context  int
+	MinScore float32
+}
+
+type ConversationRef struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+type Result struct {
+	Score        float32          `json:"score"`
+	Conversation ConversationRef  `json:"conversation"`
+	Message      models.Message   `json:"message"`
+	Before       []models.Message `json:"before"`
+	After        []models.Message `json:"after"`
+}
+
+// Search возвращает сообщения пользователя, ближайшие к тексту запроса, с
+// соседними репликами. Векторы перебираются целиком: для истории одного
+// пользователя это дешевле отдельного векторного индекса.
+func (s *Service) Search(ctx context.Context, q Query) ([]Result, error) {
+	if !s.Enabled() {
+		return nil, errors.New(ErrDisabled)
+	}
+	vectors, err := s.Embedder.Embed(ctx, s.Config.Model, []string{input(q.Text)})
+	if err != nil {
+		log.Printf("Ошибка эмбеддинга запроса: user_id=%d: %v", q.UserID, err)
+		return nil, errors.New(ErrEmbeddingQuery)
+	}
+	query := vectors[0]
+	stored, err := s.EmbeddingRepository.ListByUser(q.UserID, s.Config.Model, q.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		models.MessageEmbedding
+		score float32
+	}
+	var hits []scored
+	for _, e := range stored {
+		// Векторы другой размерности остались от прежней модели с тем же именем.
+		if len(e.Vector) != len(query) {
+			continue
+		}
+		if score := cosine(query, e.Vector); score >= q.MinScore {
+			hits = append(hits, scored{MessageEmbedding: e, score: score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].MessageID > hits[j].MessageID
+	})
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+
+	type thread struct {
+		convo *models.Conversation
+		msgs  []models.Message
+	}
+	threads := map[int]*thread{}
+	results := make([]Result, 0, len(hits))
+	for _, h := range hits {
+		t, ok := threads[h.ConversationID]
+		if !ok {
+			convo, err := s.ConversationRepository.FindByID(h.ConversationID, q.UserID)
+			if err != nil {
+				return nil, err
+			}
+			msgs, err := s.MessageRepository.ListByConversation(h.ConversationID)
+			if err != nil {
+				return nil, err
+			}
+			t = &thread{convo: convo, msgs: msgs}
+			threads[h.ConversationID] = t
+		}
+		key := strconv.Itoa(h.MessageID)
+		i := slices.IndexFunc(t.msgs, func(m models.Message) bool { return m.ID == key })
+		if i < 0 {
+			// Сообщение удалили между выборками.
+			continue
+		}
+		results = append(results, Result{
+			Score:        h.score,
+			Conversation: ConversationRef{ID: t.convo.ID, Title: t.convo.Title},
+			Message:      t.msgs[i],
+			Before:       t.msgs[max(0, i-q.Context):i],
+			After:        t.msgs[i+1 : min(len(t.msgs), i+1+q.Context)],
+		})
+	}
+	return results, nil
+}
+
+func cosine(a, b []float32) float32 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(na) * math.Sqrt(nb)))
+}
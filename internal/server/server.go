@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"me-ai/configs"
+	"me-ai/internal/admin"
+	"me-ai/internal/apikey"
+	"me-ai/internal/auth"
+	"me-ai/internal/export"
+	"me-ai/internal/importer"
+	"me-ai/internal/llm"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/internal/organize"
+	"me-ai/internal/search"
+	"me-ai/internal/semantic"
+	"me-ai/internal/share"
+	"me-ai/internal/trash"
+	"me-ai/internal/twofactor"
+	"me-ai/pkg/jwt"
+	"me-ai/pkg/mailer"
+	"me-ai/pkg/throttle"
+	"net/http"
+)
+
+type Deps struct {
+	Config                 *configs.Config
+	JWT                    *jwt.JWT
+	UserRepository         models.UserStore
+	ConversationRepository models.ConversationStore
+	MessageRepository      models.MessageStore
+	RefreshTokenRepository models.RefreshTokenStore
+	APIKeyRepository       models.APIKeyStore
+	UsageRepository        models.UsageStore
+	AuditLogRepository     models.AuditLogStore
+	UserTokenRepository    models.UserTokenStore
+	LoginLockoutRepository models.LoginLockoutStore
+	RecoveryCodeRepository models.RecoveryCodeStore
+	IdentityRepository     models.IdentityStore
+	SearchRepository       models.SearchStore
+	ExportJobRepository    models.ExportJobStore
+	EmbeddingRepository    models.EmbeddingStore
+	FolderRepository       models.FolderStore
+	TagRepository          models.TagStore
+	ShareRepository        models.ShareStore
+	Mailer                 mailer.Mailer
+	// Background останавливает фоновые задачи (очистка корзины, индексация); nil — работают всегда.
+	Background context.Context
+}
+
+func NewRouter(deps Deps) http.Handler {
+	cfg := deps.Config
+	background := deps.Background
+	if background == nil {
+		background = context.Background()
+	}
+
+	llmService := llm.NewLLMService(cfg.LLM.URL, cfg.LLM.ApiKey, deps.MessageRepository)
+	chatHandler := llm.NewChatHandler(llm.ChatHandlerDeps{
+		LLMService:             llmService,
+		UserRepository:         deps.UserRepository,
+		ConversationRepository: deps.ConversationRepository,
+		MessageRepository:      deps.MessageRepository,
+	})
+	wsHandler := llm.NewWebSocketHandler(llm.WebSocketHandlerDeps{
+		LLMService:             llmService,
+		UserRepository:         deps.UserRepository,
+		ConversationRepository: deps.ConversationRepository,
+		MessageRepository:      deps.MessageRepository,
+	})
+
+	router := http.NewServeMux()
+
+	twoFactorService := twofactor.NewService(deps.UserRepository, deps.RecoveryCodeRepository, deps.JWT.Issuer)
+
+	authHandler := auth.NewAuthHandler(router, auth.AuthHandlerDeps{
+		Config:                 cfg,
+		UserRepository:         deps.UserRepository,
+		RefreshTokenRepository: deps.RefreshTokenRepository,
+		UserTokenRepository:    deps.UserTokenRepository,
+		JWT:                    deps.JWT,
+		Mailer:                 deps.Mailer,
+		LoginLockoutRepository: deps.LoginLockoutRepository,
+		TwoFactor:              twoFactorService,
+		IdentityRepository:     deps.IdentityRepository,
+	})
+
+	apiKeyService := apikey.NewService(deps.APIKeyRepository, deps.UserRepository)
+
+	jwtMw := middleware.JWTAuth(deps.JWT, apiKeyService)
+	corsMw := middleware.CORS
+
+	protected := http.NewServeMux()
+	scoped := func(pattern, scope string, handler http.HandlerFunc) {
+		protected.Handle(pattern, middleware.RequireScope(scope)(handler))
+	}
+	scoped("/api/chat", middleware.ScopeChatWrite, chatHandler.HandleChat)
+	scoped("/api/chat/retry", middleware.ScopeChatWrite, chatHandler.Retry) // POST
+	scoped("/api/ws", middleware.ScopeChatWrite, wsHandler.HandleWebSocket)
+	scoped("/api/conversations", middleware.ScopeConversationsRead, chatHandler.ListConversations)          // GET
+	scoped("/api/conversations/create", middleware.ScopeConversationsWrite, chatHandler.CreateConversation) // POST
+	scoped("/api/conversations/delete", middleware.ScopeConversationsWrite, chatHandler.DeleteConversation) // POST
+	scoped("/api/conversations/rename", middleware.ScopeConversationsWrite, chatHandler.RenameConversation) // POST
+	scoped("/api/messages", middleware.ScopeConversationsRead, chatHandler.ListMessages)                    // GET
+	scoped("/api/messages/delete", middleware.ScopeConversationsWrite, chatHandler.DeleteMessage)           // POST
+	scoped("/api/messages/edit", middleware.ScopeConversationsWrite, chatHandler.EditMessage)               // POST
+	scoped("/api/messages/revisions", middleware.ScopeConversationsRead, chatHandler.ListRevisions)         // GET ?id=
+
+	organize.NewOrganizeHandler(protected, organize.OrganizeHandlerDeps{
+		ConversationRepository: deps.ConversationRepository,
+		FolderRepository:       deps.FolderRepository,
+		TagRepository:          deps.TagRepository,
+		UserRepository:         deps.UserRepository,
+	})
+
+	trashService := trash.NewService(trash.ServiceDeps{
+		ConversationRepository: deps.ConversationRepository,
+		MessageRepository:      deps.MessageRepository,
+		Config:                 cfg.Trash,
+	})
+	// Удалённое окончательно стирается после срока хранения в корзине.
+	go trashService.Run(background)
+	trash.NewTrashHandler(protected, trash.TrashHandlerDeps{
+		Service:        trashService,
+		UserRepository: deps.UserRepository,
+	})
+
+	share.NewShareHandler(router, protected, share.ShareHandlerDeps{
+		Service: share.NewService(share.ServiceDeps{
+			ShareRepository:        deps.ShareRepository,
+			ConversationRepository: deps.ConversationRepository,
+			MessageRepository:      deps.MessageRepository,
+			AppURL:                 cfg.Mail.AppURL,
+			IPAttempts: throttle.New(throttle.Config{
+				Limit:   cfg.Share.PasswordMaxAttempts,
+				Lockout: cfg.Share.PasswordLockout,
+			}),
+			LinkAttempts: throttle.New(throttle.Config{
+				Limit:   cfg.Share.PasswordMaxLinkAttempts,
+				Lockout: cfg.Share.PasswordLockout,
+			}),
+		}),
+		UserRepository:    deps.UserRepository,
+		TrustProxyHeaders: cfg.Auth.TrustProxyHeaders,
+	})
+
+	search.NewSearchHandler(protected, search.SearchHandlerDeps{
+		SearchRepository: deps.SearchRepository,
+		UserRepository:   deps.UserRepository,
+	})
+
+	semanticService := semantic.NewService(semantic.ServiceDeps{
+		Embedder:               llmService,
+		EmbeddingRepository:    deps.EmbeddingRepository,
+		ConversationRepository: deps.ConversationRepository,
+		MessageRepository:      deps.MessageRepository,
+		Config:                 cfg.Embeddings,
+	})
+	// Новые сообщения индексируются в фоне; без интервала — только через backfill.
+	go semanticService.Run(background)
+	semantic.NewSemanticHandler(protected, semantic.SemanticHandlerDeps{
+		Service:        semanticService,
+		UserRepository: deps.UserRepository,
+	})
+
+	apikey.NewAPIKeyHandler(protected, apikey.APIKeyHandlerDeps{
+		Service:        apiKeyService,
+		UserRepository: deps.UserRepository,
+	})
+
+	auth.NewAccountHandler(protected, auth.AccountHandlerDeps{
+		AuthService:            authHandler.AuthService,
+		ConversationRepository: deps.ConversationRepository,
+		MessageRepository:      deps.MessageRepository,
+		TrustProxyHeaders:      cfg.Auth.TrustProxyHeaders,
+	})
+
+	export.NewExportHandler(router, protected, export.ExportHandlerDeps{
+		Service: export.NewService(export.ServiceDeps{
+			AuthService:            authHandler.AuthService,
+			ConversationRepository: deps.ConversationRepository,
+			MessageRepository:      deps.MessageRepository,
+			UsageRepository:        deps.UsageRepository,
+			ExportJobRepository:    deps.ExportJobRepository,
+			Config:                 cfg.Export,
+			Model:                  llm.Model,
+			Persona:                llm.Persona,
+		}),
+		UserRepository: deps.UserRepository,
+	})
+
+	importer.NewImportHandler(protected, importer.ImportHandlerDeps{
+		Service:        importer.NewService(deps.ConversationRepository),
+		UserRepository: deps.UserRepository,
+		MaxBytes:       cfg.Import.MaxBytes,
+	})
+
+	twofactor.NewTwoFactorHandler(protected, twofactor.TwoFactorHandlerDeps{
+		Service:        twoFactorService,
+		UserRepository: deps.UserRepository,
+	})
+
+	admin.NewAdminHandler(protected, admin.AdminHandlerDeps{
+		UserRepository:         deps.UserRepository,
+		ConversationRepository: deps.ConversationRepository,
+		MessageRepository:      deps.MessageRepository,
+		RefreshTokenRepository: deps.RefreshTokenRepository,
+		UsageRepository:        deps.UsageRepository,
+		AuditLogRepository:     deps.AuditLogRepository,
+		LoginLockoutRepository: deps.LoginLockoutRepository,
+		SemanticService:        semanticService,
+	})
+
+	router.Handle("/api/", corsMw(jwtMw(protected)))
+
+	return router
+}
@@ -0,0 +1,2622 @@
+package server_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"me-ai/configs"
+	"me-ai/internal/importer"
+	"me-ai/internal/llm"
+	"me-ai/internal/models"
+	"me-ai/internal/models/memstore"
+	"me-ai/internal/server"
+	"me-ai/internal/share"
+	"me-ai/internal/transcript"
+	"me-ai/internal/trash"
+	"me-ai/migrations"
+	"me-ai/pkg/db"
+	"me-ai/pkg/fakeoidc"
+	"me-ai/pkg/fakeollama"
+	"me-ai/pkg/jwt"
+	"me-ai/pkg/mailer"
+	"me-ai/pkg/migrate"
+	"me-ai/pkg/totp"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	jwtlib "github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+const testSecret = "test-secret-test-secret-test-secret"
+
+type testEnv struct {
+	t     *testing.T
+	srv   *httptest.Server
+	llm   *fakeollama.Server
+	store testStores
+	mail  *mailer.LogMailer
+}
+
+func newTestEnv(t *testing.T, configure ...func(*configs.Config)) *testEnv {
+	t.Helper()
+	llm := fakeollama.New()
+	llmSrv := httptest.NewServer(llm)
+	t.Cleanup(llmSrv.Close)
+
+	deps := memstoreDeps()
+	if os.Getenv("TEST_STORE") == "sqlite" {
+		deps = sqliteDeps(t)
+	}
+	cfg := &configs.Config{
+		LLM: configs.LLMConfig{URL: llmSrv.URL},
+		Auth: configs.AuthConfig{
+			Secret:     testSecret,
+			AccessTTL:  time.Minute,
+			RefreshTTL: time.Hour,
+
+			EmailVerificationTTL: time.Hour,
+			PasswordResetTTL:     time.Hour,
+		},
+		Mail:   configs.MailConfig{AppURL: "http://app.test"},
+		Export: configs.ExportConfig{Dir: t.TempDir(), TTL: time.Hour, SyncMaxMessages: 1000},
+		Import: configs.ImportConfig{MaxBytes: 1 << 20},
+		// Без интервала фоновый индексатор не запускается: тесты индексируют через backfill.
+		Embeddings: configs.EmbeddingsConfig{Model: "test-embed", BatchSize: 2},
+		// Без интервала корзина не очищается в фоне.
+		Trash: configs.TrashConfig{Retention: time.Hour},
+	}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	deps.Config = cfg
+	deps.JWT = jwt.NewJWT(testSecret, "", time.Minute)
+	mail := mailer.NewLogMailer(nil)
+	deps.Mailer = mail
+	background, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+	deps.Background = background
+	router := server.NewRouter(deps)
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	return &testEnv{t: t, srv: srv, llm: llm, store: testStores{deps}, mail: mail}
+}
+
+// testStores даёт тестам прямой доступ к репозиториям сервера.
+type testStores struct {
+	server.Deps
+}
+
+func (s testStores) Users() models.UserStore                 { return s.UserRepository }
+func (s testStores) Conversations() models.ConversationStore { return s.ConversationRepository }
+func (s testStores) Messages() models.MessageStore           { return s.MessageRepository }
+func (s testStores) LoginLockouts() models.LoginLockoutStore { return s.LoginLockoutRepository }
+func (s testStores) Identities() models.IdentityStore        { return s.IdentityRepository }
+func (s testStores) ExportJobs() models.ExportJobStore       { return s.ExportJobRepository }
+
+func memstoreDeps() server.Deps {
+	store := memstore.New()
+	return server.Deps{
+		UserRepository:         store.Users(),
+		ConversationRepository: store.Conversations(),
+		MessageRepository:      store.Messages(),
+		RefreshTokenRepository: store.RefreshTokens(),
+		APIKeyRepository:       store.APIKeys(),
+		UsageRepository:        store.Usage(),
+		AuditLogRepository:     store.AuditLog(),
+		UserTokenRepository:    store.UserTokens(),
+		LoginLockoutRepository: store.LoginLockouts(),
+		RecoveryCodeRepository: store.RecoveryCodes(),
+		IdentityRepository:     store.Identities(),
+		SearchRepository:       store.Search(),
+		ExportJobRepository:    store.ExportJobs(),
+		EmbeddingRepository:    store.Embeddings(),
+		FolderRepository:       store.Folders(),
+		TagRepository:          store.Tags(),
+		ShareRepository:        store.Shares(),
+	}
+}
+
+// sqliteDeps — SQL-репозитории на отдельной для каждого теста базе SQLite
+// (TEST_STORE=sqlite). Репозитории работают через глобальный db.DB, поэтому
+// тесты не должны выполняться параллельно.
+func sqliteDeps(t *testing.T) server.Deps {
+	t.Helper()
+	conn, err := db.Open("sqlite:" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := migrations.For(db.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, err := migrate.New(conn, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	// db.DB не возвращается к прежнему nil: фоновая задача, не успевшая
+	// остановиться, получит ошибку закрытой базы, а не панику.
+	db.DB = conn
+	t.Cleanup(func() { conn.Close() })
+	return server.Deps{
+		UserRepository:         &models.UserRepository{},
+		ConversationRepository: &models.ConversationRepository{},
+		MessageRepository:      &models.MessageRepository{},
+		RefreshTokenRepository: &models.RefreshTokenRepository{},
+		APIKeyRepository:       &models.APIKeyRepository{},
+		UsageRepository:        &models.UsageRepository{},
+		AuditLogRepository:     &models.AuditLogRepository{},
+		UserTokenRepository:    &models.UserTokenRepository{},
+		LoginLockoutRepository: &models.LoginLockoutRepository{},
+		RecoveryCodeRepository: &models.RecoveryCodeRepository{},
+		IdentityRepository:     &models.IdentityRepository{},
+		SearchRepository:       &models.SearchRepository{},
+		ExportJobRepository:    &models.ExportJobRepository{},
+		EmbeddingRepository:    &models.EmbeddingRepository{},
+		FolderRepository:       &models.FolderRepository{},
+		TagRepository:          &models.TagRepository{},
+		ShareRepository:        &models.ShareRepository{},
+	}
+}
+
+func (e *testEnv) do(method, path, token string, body any) *http.Response {
+	e.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatalf("marshal body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, e.srv.URL+path, reader)
+	if err != nil {
+		e.t.Fatalf("new request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := e.srv.Client().Do(req)
+	if err != nil {
+		e.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	e.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func expectStatus(t *testing.T, resp *http.Response, want int) {
+	t.Helper()
+	if resp.StatusCode != want {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("%s %s: status %d, want %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, want, body)
+	}
+}
+
+func decode[T any](t *testing.T, resp *http.Response) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatalf("decode %s: %v", resp.Request.URL.Path, err)
+	}
+	return v
+}
+
+func (e *testEnv) register(email, name string) string {
+	e.t.Helper()
+	resp := e.do(http.MethodPost, "/api/auth/register", "", map[string]string{
+		"email":    email,
+		"password": "password-" + name,
+		"name":     name,
+	})
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[struct{ Token string }](e.t, resp).Token
+}
+
+func (e *testEnv) createConversation(token, title string) models.Conversation {
+	e.t.Helper()
+	resp := e.do(http.MethodPost, "/api/conversations/create", token, map[string]string{"title": title})
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[models.Conversation](e.t, resp)
+}
+
+func (e *testEnv) listConversations(token string) []models.Conversation {
+	e.t.Helper()
+	resp := e.do(http.MethodGet, "/api/conversations", token, nil)
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[llm.ConversationPage](e.t, resp).Conversations
+}
+
+func (e *testEnv) listMessages(token string, convoID int) []models.Message {
+	e.t.Helper()
+	resp := e.do(http.MethodGet, "/api/messages?conversation_id="+strconv.Itoa(convoID), token, nil)
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[llm.MessagePage](e.t, resp).Messages
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	e := newTestEnv(t)
+	if token := e.register("alice@example.com", "alice"); token == "" {
+		t.Fatal("register returned empty token")
+	}
+
+	resp := e.do(http.MethodPost, "/api/auth/register", "", map[string]string{
+		"email": "alice@example.com", "password": "other", "name": "alice",
+	})
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	resp = e.do(http.MethodPost, "/api/auth/login", "", map[string]string{
+		"email": "alice@example.com", "password": "password-alice",
+	})
+	expectStatus(t, resp, http.StatusOK)
+	if decode[struct{ Token string }](t, resp).Token == "" {
+		t.Fatal("login returned empty token")
+	}
+
+	resp = e.do(http.MethodPost, "/api/auth/login", "", map[string]string{
+		"email": "alice@example.com", "password": "wrong",
+	})
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	resp = e.do(http.MethodPost, "/api/auth/login", "", map[string]string{
+		"email": "nobody@example.com", "password": "wrong",
+	})
+	expectStatus(t, resp, http.StatusUnauthorized)
+}
+
+func TestConversationLifecycle(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+
+	convo := e.createConversation(token, "first")
+	if convo.ID == 0 || convo.Title != "first" {
+		t.Fatalf("unexpected conversation: %+v", convo)
+	}
+
+	resp := e.do(http.MethodPost, "/api/conversations/rename", token, map[string]any{"id": convo.ID, "title": "renamed"})
+	expectStatus(t, resp, http.StatusNoContent)
+
+	convos := e.listConversations(token)
+	if len(convos) != 1 || convos[0].Title != "renamed" {
+		t.Fatalf("unexpected conversations after rename: %+v", convos)
+	}
+
+	resp = e.do(http.MethodPost, "/api/conversations/delete", token, map[string]any{"id": convo.ID})
+	expectStatus(t, resp, http.StatusNoContent)
+	if convos := e.listConversations(token); len(convos) != 0 {
+		t.Fatalf("conversation not deleted: %+v", convos)
+	}
+}
+
+func TestChatOverREST(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	convo := e.createConversation(token, "chat")
+
+	e.llm.Enqueue(fakeollama.Reply{Content: "Привет, Алиса"})
+	resp := e.do(http.MethodPost, "/api/chat", token, map[string]any{
+		"conversation_id": convo.ID,
+		"message":         "Привет",
+	})
+	expectStatus(t, resp, http.StatusOK)
+	chat := decode[models.ChatResponse](t, resp)
+	if chat.Message != "Привет, Алиса" {
+		t.Fatalf("unexpected reply: %q", chat.Message)
+	}
+
+	msgs := e.listMessages(token, convo.ID)
+	if len(msgs) != 2 || msgs[0].Role != "user" || msgs[1].Role != "assistant" || msgs[1].Content != "Привет, Алиса" {
+		t.Fatalf("unexpected stored messages: %+v", msgs)
+	}
+
+	reqs := e.llm.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected one LLM request, got %d", len(reqs))
+	}
+	last := reqs[0].Messages[len(reqs[0].Messages)-1]
+	if last.Role != "user" || last.Content != "Привет" {
+		t.Fatalf("unexpected last message sent to LLM: %+v", last)
+	}
+}
+
+func TestChatOverRESTLLMFailure(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	convo := e.createConversation(token, "chat")
+
+	e.llm.Enqueue(fakeollama.Reply{Status: http.StatusInternalServerError, Error: "model crashed"})
+	resp := e.do(http.MethodPost, "/api/chat", token, map[string]any{
+		"conversation_id": convo.ID,
+		"message":         "Привет",
+	})
+	expectStatus(t, resp, http.StatusInternalServerError)
+}
+
+func (e *testEnv) dialWS(token string) *websocket.Conn {
+	e.t.Helper()
+	url := "ws" + strings.TrimPrefix(e.srv.URL, "http") + "/api/ws"
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		e.t.Fatalf("dial websocket: %v (status %d)", err, status)
+	}
+	e.t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readWS(t *testing.T, conn *websocket.Conn) models.WebSocketMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg models.WebSocketMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read websocket: %v", err)
+	}
+	return msg
+}
+
+func TestChatOverWebSocket(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	convo := e.createConversation(token, "ws")
+	conn := e.dialWS(token)
+
+	e.llm.Enqueue(fakeollama.Reply{Chunks: []string{"Раз", ", два", ", три"}})
+	err := conn.WriteJSON(map[string]any{
+		"type":            "user_message",
+		"content":         "Считай",
+		"conversation_id": convo.ID,
+	})
+	if err != nil {
+		t.Fatalf("write websocket: %v", err)
+	}
+
+	var chunks []string
+	var complete string
+	for complete == "" {
+		msg := readWS(t, conn)
+		switch msg.Type {
+		case "user_message", "typing":
+		case "assistant_chunk":
+			chunks = append(chunks, msg.Content)
+		case "assistant_complete":
+			complete = msg.Content
+		default:
+			t.Fatalf("unexpected websocket message: %+v", msg)
+		}
+	}
+	if strings.Join(chunks, "") != "Раз, два, три" || complete != "Раз, два, три" {
+		t.Fatalf("unexpected stream: chunks=%q complete=%q", chunks, complete)
+	}
+
+	// Ответ сохраняется до отправки assistant_complete.
+	msgs := e.listMessages(token, convo.ID)
+	if len(msgs) != 2 || msgs[1].Content != "Раз, два, три" || msgs[1].Status != models.MessageComplete {
+		t.Fatalf("assistant message not persisted: %+v", msgs)
+	}
+}
+
+func TestChatOverWebSocketStreamFailure(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	convo := e.createConversation(token, "ws")
+	conn := e.dialWS(token)
+
+	e.llm.Enqueue(fakeollama.Reply{Chunks: []string{"Раз", ", два"}, FailAfter: 1})
+	conn.WriteJSON(map[string]any{
+		"type":            "user_message",
+		"content":         "Считай",
+		"conversation_id": convo.ID,
+	})
+	var replyID string
+	for {
+		msg := readWS(t, conn)
+		if msg.Type == "user_message" {
+			replyID = msg.ReplyID
+		}
+		if msg.Type == "error" {
+			if msg.ReplyID != replyID || replyID == "" {
+				t.Fatalf("error does not reference the reply: %+v", msg)
+			}
+			break
+		}
+		if msg.Type == "assistant_complete" {
+			t.Fatalf("stream failure reported as complete: %+v", msg)
+		}
+	}
+	// Полученная часть сохраняется вместе со статусом failed.
+	msgs := e.listMessages(token, convo.ID)
+	if len(msgs) != 2 || msgs[1].ID != replyID || msgs[1].Status != models.MessageFailed || msgs[1].Content != "Раз" {
+		t.Fatalf("unexpected messages after stream failure: %+v", msgs)
+	}
+}
+
+func TestAuthenticationRequired(t *testing.T) {
+	e := newTestEnv(t)
+	for _, path := range []string{"/api/conversations", "/api/messages?conversation_id=1", "/api/ws"} {
+		resp := e.do(http.MethodGet, path, "", nil)
+		expectStatus(t, resp, http.StatusUnauthorized)
+
+		resp = e.do(http.MethodGet, path, "not-a-jwt", nil)
+		expectStatus(t, resp, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthorizationBoundaries(t *testing.T) {
+	e := newTestEnv(t)
+	alice := e.register("alice@example.com", "alice")
+	bob := e.register("bob@example.com", "bob")
+
+	convo := e.createConversation(alice, "alice only")
+	e.do(http.MethodPost, "/api/chat", alice, map[string]any{"conversation_id": convo.ID, "message": "секрет"})
+
+	if convos := e.listConversations(bob); len(convos) != 0 {
+		t.Fatalf("bob sees alice's conversations: %+v", convos)
+	}
+
+	resp := e.do(http.MethodPost, "/api/conversations/rename", bob, map[string]any{"id": convo.ID, "title": "hacked"})
+	expectStatus(t, resp, http.StatusNotFound)
+	resp = e.do(http.MethodPost, "/api/conversations/delete", bob, map[string]any{"id": convo.ID})
+	expectStatus(t, resp, http.StatusNotFound)
+
+	msgs := e.listMessages(alice, convo.ID)
+	for _, m := range msgs {
+		resp := e.do(http.MethodPost, "/api/messages/delete", bob, map[string]any{"id": atoi(t, m.ID)})
+		expectStatus(t, resp, http.StatusNotFound)
+	}
+
+	convos := e.listConversations(alice)
+	if len(convos) != 1 || convos[0].Title != "alice only" {
+		t.Fatalf("bob modified alice's conversation: %+v", convos)
+	}
+	if after := e.listMessages(alice, convo.ID); len(after) != len(msgs) {
+		t.Fatalf("bob deleted alice's messages: before=%d after=%d", len(msgs), len(after))
+	}
+}
+
+func TestOwnershipEnforcedPerRoute(t *testing.T) {
+	e := newTestEnv(t)
+	alice := e.register("alice@example.com", "alice")
+	bob := e.register("bob@example.com", "bob")
+	convo := e.createConversation(alice, "alice only")
+	resp := e.do(http.MethodPost, "/api/chat", alice, map[string]any{"conversation_id": convo.ID, "message": "секрет"})
+	expectStatus(t, resp, http.StatusOK)
+	msgs := e.listMessages(alice, convo.ID)
+	e.llm.Reset()
+
+	const missing = 1 << 20
+	routes := []struct {
+		name   string
+		method string
+		path   string
+		body   func(id int) any
+	}{
+		{"list messages", http.MethodGet, "/api/messages?conversation_id=%d", nil},
+		{"chat", http.MethodPost, "/api/chat", func(id int) any {
+			return map[string]any{"conversation_id": id, "message": "покажи историю"}
+		}},
+		{"rename conversation", http.MethodPost, "/api/conversations/rename", func(id int) any {
+			return map[string]any{"id": id, "title": "hacked"}
+		}},
+		{"delete conversation", http.MethodPost, "/api/conversations/delete", func(id int) any {
+			return map[string]any{"id": id}
+		}},
+	}
+	for _, route := range routes {
+		for _, target := range []struct {
+			name string
+			id   int
+		}{{"foreign", convo.ID}, {"missing", missing}} {
+			t.Run(route.name+"/"+target.name, func(t *testing.T) {
+				path := route.path
+				var body any
+				if route.body != nil {
+					body = route.body(target.id)
+				} else {
+					path = fmt.Sprintf(path, target.id)
+				}
+				resp := e.do(route.method, path, bob, body)
+				expectStatus(t, resp, http.StatusNotFound)
+			})
+		}
+	}
+
+	t.Run("delete message/foreign", func(t *testing.T) {
+		resp := e.do(http.MethodPost, "/api/messages/delete", bob, map[string]any{"id": atoi(t, msgs[0].ID)})
+		expectStatus(t, resp, http.StatusNotFound)
+	})
+	t.Run("delete message/missing", func(t *testing.T) {
+		resp := e.do(http.MethodPost, "/api/messages/delete", bob, map[string]any{"id": missing})
+		expectStatus(t, resp, http.StatusNotFound)
+	})
+
+	t.Run("websocket/foreign", func(t *testing.T) {
+		conn := e.dialWS(bob)
+		conn.WriteJSON(map[string]any{
+			"type":            "user_message",
+			"content":         "покажи историю",
+			"conversation_id": convo.ID,
+		})
+		if msg := readWS(t, conn); msg.Type != "error" {
+			t.Fatalf("expected error for foreign conversation, got %+v", msg)
+		}
+	})
+
+	if reqs := e.llm.Requests(); len(reqs) != 0 {
+		t.Fatalf("foreign conversation history was sent to the LLM: %+v", reqs)
+	}
+	if after := e.listMessages(alice, convo.ID); len(after) != len(msgs) {
+		t.Fatalf("bob changed alice's messages: before=%d after=%d", len(msgs), len(after))
+	}
+}
+
+func atoi(t *testing.T, s string) int {
+	t.Helper()
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatalf("atoi %q: %v", s, err)
+	}
+	return i
+}
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func (e *testEnv) login(email, password string) tokenResponse {
+	e.t.Helper()
+	resp := e.do(http.MethodPost, "/api/auth/login", "", map[string]string{"email": email, "password": password})
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[tokenResponse](e.t, resp)
+}
+
+func (e *testEnv) refresh(refreshToken string) *http.Response {
+	e.t.Helper()
+	return e.do(http.MethodPost, "/api/auth/refresh", "", map[string]string{"refresh_token": refreshToken})
+}
+
+func TestAccessTokenClaims(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("alice@example.com", "alice")
+	tokens := e.login("alice@example.com", "password-alice")
+	if tokens.RefreshToken == "" || tokens.ExpiresIn != 60 {
+		t.Fatalf("unexpected token response: %+v", tokens)
+	}
+
+	claims := jwtlib.MapClaims{}
+	if _, _, err := jwtlib.NewParser().ParseUnverified(tokens.Token, claims); err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	for _, claim := range []string{"exp", "iat", "jti", "iss"} {
+		if _, ok := claims[claim]; !ok {
+			t.Errorf("access token has no %q claim: %v", claim, claims)
+		}
+	}
+
+	// Токены старого формата без exp больше не принимаются.
+	legacy, err := jwtlib.NewWithClaims(jwtlib.SigningMethodHS256, jwtlib.MapClaims{
+		"email": "alice@example.com",
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("sign legacy token: %v", err)
+	}
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations", legacy, nil), http.StatusUnauthorized)
+
+	expired, err := jwtlib.NewWithClaims(jwtlib.SigningMethodHS256, jwtlib.MapClaims{
+		"email": "alice@example.com",
+		"iss":   "me-ai",
+		"iat":   time.Now().Add(-time.Hour).Unix(),
+		"exp":   time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("sign expired token: %v", err)
+	}
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations", expired, nil), http.StatusUnauthorized)
+
+	resp := e.do(http.MethodGet, "/.well-known/jwks.json", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if jwks := decode[jwt.JWKSet](t, resp); jwks.Keys == nil || len(jwks.Keys) != 0 {
+		t.Fatalf("HMAC secret must not be published: %+v", jwks)
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("alice@example.com", "alice")
+	first := e.login("alice@example.com", "password-alice")
+
+	resp := e.refresh(first.RefreshToken)
+	expectStatus(t, resp, http.StatusOK)
+	second := decode[tokenResponse](t, resp)
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh token was not rotated: %+v", second)
+	}
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations", second.Token, nil), http.StatusOK)
+
+	resp = e.refresh(second.RefreshToken)
+	expectStatus(t, resp, http.StatusOK)
+	third := decode[tokenResponse](t, resp)
+
+	// Повторное использование старого токена отзывает всю семью, включая свежий.
+	expectStatus(t, e.refresh(first.RefreshToken), http.StatusUnauthorized)
+	expectStatus(t, e.refresh(third.RefreshToken), http.StatusUnauthorized)
+
+	// Другие сессии пользователя не затронуты.
+	other := e.login("alice@example.com", "password-alice")
+	expectStatus(t, e.refresh(other.RefreshToken), http.StatusOK)
+
+	expectStatus(t, e.refresh("garbage"), http.StatusUnauthorized)
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("alice@example.com", "alice")
+	session := e.login("alice@example.com", "password-alice")
+
+	resp := e.refresh(session.RefreshToken)
+	expectStatus(t, resp, http.StatusOK)
+	rotated := decode[tokenResponse](t, resp)
+
+	resp = e.do(http.MethodPost, "/api/auth/logout", "", map[string]string{"refresh_token": rotated.RefreshToken})
+	expectStatus(t, resp, http.StatusNoContent)
+	expectStatus(t, e.refresh(rotated.RefreshToken), http.StatusUnauthorized)
+
+	resp = e.do(http.MethodPost, "/api/auth/logout", "", map[string]string{"refresh_token": "unknown"})
+	expectStatus(t, resp, http.StatusNoContent)
+}
+
+type apiKeyResponse struct {
+	Key    string `json:"key"`
+	APIKey struct {
+		ID         int        `json:"id"`
+		Prefix     string     `json:"prefix"`
+		Scopes     []string   `json:"scopes"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+	} `json:"api_key"`
+}
+
+func (e *testEnv) createAPIKey(token, name string, scopes ...string) apiKeyResponse {
+	e.t.Helper()
+	resp := e.do(http.MethodPost, "/api/keys/create", token, map[string]any{"name": name, "scopes": scopes})
+	expectStatus(e.t, resp, http.StatusCreated)
+	return decode[apiKeyResponse](e.t, resp)
+}
+
+func TestAPIKeys(t *testing.T) {
+	e := newTestEnv(t)
+	session := e.register("alice@example.com", "alice")
+	convo := e.createConversation(session, "scripts")
+
+	readOnly := e.createAPIKey(session, "reader", "conversations:read")
+	if !strings.HasPrefix(readOnly.Key, readOnly.APIKey.Prefix+"_") || !strings.HasPrefix(readOnly.Key, "meai_") {
+		t.Fatalf("key %q does not start with its visible prefix %q", readOnly.Key, readOnly.APIKey.Prefix)
+	}
+
+	if convos := e.listConversations(readOnly.Key); len(convos) != 1 || convos[0].ID != convo.ID {
+		t.Fatalf("unexpected conversations via API key: %+v", convos)
+	}
+	resp := e.do(http.MethodPost, "/api/chat", readOnly.Key, map[string]any{"conversation_id": convo.ID, "message": "hi"})
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = e.do(http.MethodPost, "/api/conversations/create", readOnly.Key, map[string]string{"title": "nope"})
+	expectStatus(t, resp, http.StatusForbidden)
+
+	writer := e.createAPIKey(session, "bot", "chat:write", "conversations:read")
+	resp = e.do(http.MethodPost, "/api/chat", writer.Key, map[string]any{"conversation_id": convo.ID, "message": "hi"})
+	expectStatus(t, resp, http.StatusOK)
+
+	// API-ключ не может управлять ключами.
+	resp = e.do(http.MethodPost, "/api/keys/create", writer.Key, map[string]any{"name": "escalate", "scopes": []string{"conversations:write"}})
+	expectStatus(t, resp, http.StatusForbidden)
+	expectStatus(t, e.do(http.MethodGet, "/api/keys", writer.Key, nil), http.StatusForbidden)
+
+	resp = e.do(http.MethodPost, "/api/keys/create", session, map[string]any{"name": "bad", "scopes": []string{"admin:everything"}})
+	expectStatus(t, resp, http.StatusBadRequest)
+
+	resp = e.do(http.MethodGet, "/api/keys", session, nil)
+	expectStatus(t, resp, http.StatusOK)
+	listed := decode[[]struct {
+		ID         int        `json:"id"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+	}](t, resp)
+	if len(listed) != 2 {
+		t.Fatalf("expected 2 keys, got %+v", listed)
+	}
+	for _, k := range listed {
+		if k.LastUsedAt == nil {
+			t.Fatalf("last_used_at not recorded for key %d", k.ID)
+		}
+	}
+
+	resp = e.do(http.MethodPost, "/api/keys/revoke", session, map[string]int{"id": readOnly.APIKey.ID})
+	expectStatus(t, resp, http.StatusNoContent)
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations", readOnly.Key, nil), http.StatusUnauthorized)
+
+	// Подделанный секрет с настоящим префиксом отклоняется.
+	forged := writer.APIKey.Prefix + "_forged"
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations", forged, nil), http.StatusUnauthorized)
+
+	bob := e.register("bob@example.com", "bob")
+	resp = e.do(http.MethodPost, "/api/keys/revoke", bob, map[string]int{"id": writer.APIKey.ID})
+	expectStatus(t, resp, http.StatusNotFound)
+}
+
+func (e *testEnv) setRole(email, role string) {
+	e.t.Helper()
+	user, err := e.store.Users().FindByEmail(email)
+	if err != nil {
+		e.t.Fatalf("find %s: %v", email, err)
+	}
+	if err := e.store.Users().SetRole(user.ID, role); err != nil {
+		e.t.Fatalf("set role: %v", err)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("root@example.com", "root")
+	e.register("audit@example.com", "audit")
+	bobSession := e.register("bob@example.com", "bob")
+	e.setRole("root@example.com", models.RoleAdmin)
+	e.setRole("audit@example.com", models.RoleAuditor)
+	admin := e.login("root@example.com", "password-root").Token
+	auditor := e.login("audit@example.com", "password-audit").Token
+	bobTokens := e.login("bob@example.com", "password-bob")
+
+	claims := jwtlib.MapClaims{}
+	jwtlib.NewParser().ParseUnverified(admin, claims)
+	if claims["role"] != models.RoleAdmin {
+		t.Fatalf("role claim = %v, want admin", claims["role"])
+	}
+
+	convo := e.createConversation(bobSession, "bob's chat")
+	e.do(http.MethodPost, "/api/chat", bobSession, map[string]any{"conversation_id": convo.ID, "message": "привет"})
+	bob, _ := e.store.Users().FindByEmail("bob@example.com")
+
+	// Обычный пользователь не видит админку.
+	expectStatus(t, e.do(http.MethodGet, "/api/admin/users", bobSession, nil), http.StatusForbidden)
+
+	resp := e.do(http.MethodGet, "/api/admin/users", auditor, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if users := decode[[]map[string]any](t, resp); len(users) != 3 || users[0]["password"] != nil {
+		t.Fatalf("unexpected users listing: %+v", users)
+	}
+
+	resp = e.do(http.MethodGet, "/api/admin/usage", auditor, nil)
+	expectStatus(t, resp, http.StatusOK)
+	usage := decode[[]models.UserUsage](t, resp)
+	if usage[0].UserID != bob.ID || usage[0].Messages != 2 || usage[0].Conversations != 1 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+
+	resp = e.do(http.MethodGet, "/api/admin/conversations?id="+strconv.Itoa(convo.ID), auditor, nil)
+	expectStatus(t, resp, http.StatusOK)
+	inspected := decode[struct {
+		Owner    struct{ Email string }
+		Messages []models.Message
+	}](t, resp)
+	if inspected.Owner.Email != "bob@example.com" || len(inspected.Messages) != 2 {
+		t.Fatalf("unexpected inspection: %+v", inspected)
+	}
+
+	// Аудитор только читает.
+	resp = e.do(http.MethodPost, "/api/admin/users/disable", auditor, map[string]any{"id": bob.ID, "disabled": true})
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = e.do(http.MethodPost, "/api/admin/users/disable", admin, map[string]any{"id": bob.ID, "disabled": true})
+	expectStatus(t, resp, http.StatusNoContent)
+
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations", bobSession, nil), http.StatusForbidden)
+	resp = e.do(http.MethodPost, "/api/auth/login", "", map[string]string{"email": "bob@example.com", "password": "password-bob"})
+	expectStatus(t, resp, http.StatusForbidden)
+	expectStatus(t, e.refresh(bobTokens.RefreshToken), http.StatusUnauthorized)
+
+	resp = e.do(http.MethodPost, "/api/admin/users/disable", admin, map[string]any{"id": bob.ID, "disabled": false})
+	expectStatus(t, resp, http.StatusNoContent)
+	e.login("bob@example.com", "password-bob")
+
+	resp = e.do(http.MethodGet, "/api/admin/audit", admin, nil)
+	expectStatus(t, resp, http.StatusOK)
+	entries := decode[[]models.AuditEntry](t, resp)
+	var actions []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.ActorID == nil || entry.CreatedAt.IsZero() {
+			t.Fatalf("audit entry without actor or time: %+v", entry)
+		}
+		actions = append(actions, entry.Action)
+	}
+	want := "list_users,view_usage,inspect_conversation,disable_user,enable_user,view_audit_log"
+	if got := strings.Join(actions, ","); got != want {
+		t.Fatalf("audit actions = %s, want %s", got, want)
+	}
+	if entries[2].TargetID == nil || *entries[2].TargetID != bob.ID {
+		t.Fatalf("disable/enable entries must target bob: %+v", entries[2])
+	}
+}
+
+// waitMail ждёт, пока писем станет больше n: ссылки для сброса пароля и
+// повторного подтверждения отправляются в фоне.
+func (e *testEnv) waitMail(n int) {
+	e.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(e.mail.Sent()) <= n {
+		if time.Now().After(deadline) {
+			e.t.Fatalf("no mail after %d sent", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// mailedToken достаёт токен из ссылки в последнем письме на адрес to.
+func (e *testEnv) mailedToken(to, path string) string {
+	e.t.Helper()
+	sent := e.mail.Sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].To != to {
+			continue
+		}
+		prefix := "http://app.test" + path + "?token="
+		start := strings.Index(sent[i].Body, prefix)
+		if start < 0 {
+			e.t.Fatalf("no %s link in mail to %s: %q", path, to, sent[i].Body)
+		}
+		token, _, _ := strings.Cut(sent[i].Body[start+len(prefix):], "\n")
+		return token
+	}
+	e.t.Fatalf("no mail sent to %s", to)
+	return ""
+}
+
+func TestEmailVerification(t *testing.T) {
+	e := newTestEnv(t, func(cfg *configs.Config) { cfg.Auth.EmailVerificationRequired = true })
+
+	resp := e.do(http.MethodPost, "/api/auth/register", "", map[string]string{
+		"email": "alice@example.com", "password": "password-alice", "name": "alice",
+	})
+	expectStatus(t, resp, http.StatusOK)
+	reg := decode[struct {
+		Token                string
+		VerificationRequired bool `json:"verification_required"`
+	}](t, resp)
+	if reg.Token != "" || !reg.VerificationRequired {
+		t.Fatalf("unverified account got a session: %+v", reg)
+	}
+
+	credentials := map[string]string{"email": "alice@example.com", "password": "password-alice"}
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/login", "", credentials), http.StatusForbidden)
+
+	// Повторная отправка гасит первую ссылку.
+	first := e.mailedToken("alice@example.com", "/verify-email")
+	sent := len(e.mail.Sent())
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/verify/resend", "", map[string]string{"email": "alice@example.com"}), http.StatusNoContent)
+	e.waitMail(sent)
+	second := e.mailedToken("alice@example.com", "/verify-email")
+	if first == second {
+		t.Fatal("resend reused the previous token")
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/verify", "", map[string]string{"token": first}), http.StatusBadRequest)
+
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/verify", "", map[string]string{"token": second}), http.StatusNoContent)
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/verify", "", map[string]string{"token": second}), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/login", "", credentials), http.StatusOK)
+
+	// Для подтверждённого и неизвестного адреса письма не уходят, а ответ тот же.
+	before := len(e.mail.Sent())
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/verify/resend", "", map[string]string{"email": "alice@example.com"}), http.StatusNoContent)
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/verify/resend", "", map[string]string{"email": "nobody@example.com"}), http.StatusNoContent)
+	if after := len(e.mail.Sent()); after != before {
+		t.Fatalf("unexpected mail: %d -> %d", before, after)
+	}
+}
+
+func TestEmailTokenExpiry(t *testing.T) {
+	e := newTestEnv(t, func(cfg *configs.Config) { cfg.Auth.PasswordResetTTL = -time.Minute })
+	e.register("alice@example.com", "alice")
+
+	sent := len(e.mail.Sent())
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/password/forgot", "", map[string]string{"email": "alice@example.com"}), http.StatusNoContent)
+	e.waitMail(sent)
+	token := e.mailedToken("alice@example.com", "/reset-password")
+	resp := e.do(http.MethodPost, "/api/auth/password/reset", "", map[string]string{"token": token, "password": "new-password"})
+	expectStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestPasswordReset(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("alice@example.com", "alice")
+	session := e.login("alice@example.com", "password-alice")
+	verifyToken := e.mailedToken("alice@example.com", "/verify-email")
+
+	before := len(e.mail.Sent())
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/password/forgot", "", map[string]string{"email": "nobody@example.com"}), http.StatusNoContent)
+	if len(e.mail.Sent()) != before {
+		t.Fatal("reset mail sent for unknown address")
+	}
+
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/password/forgot", "", map[string]string{"email": "alice@example.com"}), http.StatusNoContent)
+	e.waitMail(before)
+	token := e.mailedToken("alice@example.com", "/reset-password")
+
+	// Токен подтверждения email не годится для сброса пароля.
+	resp := e.do(http.MethodPost, "/api/auth/password/reset", "", map[string]string{"token": verifyToken, "password": "new-password"})
+	expectStatus(t, resp, http.StatusBadRequest)
+
+	resp = e.do(http.MethodPost, "/api/auth/password/reset", "", map[string]string{"token": token, "password": "new-password"})
+	expectStatus(t, resp, http.StatusNoContent)
+	resp = e.do(http.MethodPost, "/api/auth/password/reset", "", map[string]string{"token": token, "password": "other-password"})
+	expectStatus(t, resp, http.StatusBadRequest)
+
+	expectStatus(t, e.refresh(session.RefreshToken), http.StatusUnauthorized)
+	resp = e.do(http.MethodPost, "/api/auth/login", "", map[string]string{"email": "alice@example.com", "password": "password-alice"})
+	expectStatus(t, resp, http.StatusUnauthorized)
+	e.login("alice@example.com", "new-password")
+}
+
+func TestLoginLockout(t *testing.T) {
+	e := newTestEnv(t, func(cfg *configs.Config) {
+		cfg.Auth.LoginMaxAttempts = 3
+		cfg.Auth.LoginLockout = time.Minute
+	})
+	e.register("alice@example.com", "alice")
+
+	wrong := map[string]string{"email": "alice@example.com", "password": "wrong"}
+	for i := 0; i < 3; i++ {
+		expectStatus(t, e.do(http.MethodPost, "/api/auth/login", "", wrong), http.StatusUnauthorized)
+	}
+
+	// Во время блокировки не помогает даже верный пароль, в том числе в другом регистре адреса.
+	resp := e.do(http.MethodPost, "/api/auth/login", "", map[string]string{"email": "Alice@Example.com", "password": "password-alice"})
+	expectStatus(t, resp, http.StatusTooManyRequests)
+	if retry, _ := strconv.Atoi(resp.Header.Get("Retry-After")); retry <= 0 || retry > 60 {
+		t.Fatalf("unexpected Retry-After: %q", resp.Header.Get("Retry-After"))
+	}
+
+	// Другой аккаунт с того же IP не затронут.
+	e.register("bob@example.com", "bob")
+	e.login("bob@example.com", "password-bob")
+
+	lockouts, _ := e.store.LoginLockouts().List(10)
+	if len(lockouts) != 1 {
+		t.Fatalf("expected one lockout record, got %+v", lockouts)
+	}
+	l := lockouts[0]
+	if l.Scope != models.LockoutScopeAccount || l.Email != "alice@example.com" || l.IP != "127.0.0.1" ||
+		l.UserID == nil || l.FailedAttempts != 3 || !l.LockedUntil.After(time.Now()) {
+		t.Fatalf("unexpected lockout record: %+v", l)
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	e := newTestEnv(t)
+	session := e.register("alice@example.com", "alice")
+	credentials := map[string]string{"email": "alice@example.com", "password": "password-alice"}
+
+	resp := e.do(http.MethodPost, "/api/auth/2fa/enroll", session, nil)
+	expectStatus(t, resp, http.StatusOK)
+	enroll := decode[struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}](t, resp)
+	if !strings.HasPrefix(enroll.OTPAuthURI, "otpauth://totp/me-ai:alice@example.com?") {
+		t.Fatalf("unexpected otpauth URI: %s", enroll.OTPAuthURI)
+	}
+
+	// До подтверждения 2FA не действует.
+	if e.login("alice@example.com", "password-alice").Token == "" {
+		t.Fatal("unconfirmed 2FA required on login")
+	}
+
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/2fa/confirm", session, map[string]string{"code": "000000"}), http.StatusBadRequest)
+	now := time.Now()
+	code, _ := totp.Code(enroll.Secret, now)
+	resp = e.do(http.MethodPost, "/api/auth/2fa/confirm", session, map[string]string{"code": code})
+	expectStatus(t, resp, http.StatusOK)
+	recovery := decode[struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}](t, resp).RecoveryCodes
+	if len(recovery) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %v", recovery)
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/2fa/enroll", session, nil), http.StatusConflict)
+
+	challenge := func() string {
+		t.Helper()
+		resp := e.do(http.MethodPost, "/api/auth/login", "", credentials)
+		expectStatus(t, resp, http.StatusOK)
+		body := decode[struct {
+			Token             string `json:"token"`
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			ChallengeToken    string `json:"challenge_token"`
+		}](t, resp)
+		if body.Token != "" || !body.TwoFactorRequired || body.ChallengeToken == "" {
+			t.Fatalf("login skipped the second factor: %+v", body)
+		}
+		return body.ChallengeToken
+	}
+	verify := func(challenge, code string) *http.Response {
+		return e.do(http.MethodPost, "/api/auth/2fa/verify", "", map[string]string{"challenge_token": challenge, "code": code})
+	}
+
+	c := challenge()
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations", c, nil), http.StatusUnauthorized)
+	expectStatus(t, verify(session, code), http.StatusUnauthorized)
+	expectStatus(t, verify(c, "000000"), http.StatusUnauthorized)
+	// Код, уже использованный при подтверждении, повторно не принимается.
+	expectStatus(t, verify(c, code), http.StatusUnauthorized)
+
+	next, _ := totp.Code(enroll.Secret, now.Add(totp.Period))
+	resp = verify(c, next)
+	expectStatus(t, resp, http.StatusOK)
+	e.listConversations(decode[tokenResponse](t, resp).Token)
+
+	resp = verify(challenge(), recovery[0])
+	expectStatus(t, resp, http.StatusOK)
+	expectStatus(t, verify(challenge(), recovery[0]), http.StatusUnauthorized)
+
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/2fa/disable", session, map[string]string{"code": strings.ToUpper(recovery[1])}), http.StatusNoContent)
+	if e.login("alice@example.com", "password-alice").Token == "" {
+		t.Fatal("2FA still required after disable")
+	}
+}
+
+const oidcRedirectURL = "http://me-ai.test/api/auth/oidc/callback"
+
+// newOIDCEnv поднимает тестовый IdP и настраивает его как провайдера "corp".
+func newOIDCEnv(t *testing.T) (*testEnv, *fakeoidc.Server) {
+	t.Helper()
+	idp := fakeoidc.New("me-ai", "corp-secret")
+	idpSrv := httptest.NewServer(idp)
+	t.Cleanup(idpSrv.Close)
+	e := newTestEnv(t, func(cfg *configs.Config) {
+		cfg.OIDC = configs.OIDCConfig{
+			RedirectURL: oidcRedirectURL,
+			Providers: []configs.OIDCProviderConfig{{
+				Name:         "corp",
+				Issuer:       idpSrv.URL,
+				ClientID:     "me-ai",
+				ClientSecret: "corp-secret",
+			}},
+		}
+	})
+	return e, idp
+}
+
+// noRedirect возвращает ответ с Location, не переходя по нему.
+func (e *testEnv) noRedirect(req *http.Request) *http.Response {
+	e.t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		e.t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+	}
+	e.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func (e *testEnv) location(resp *http.Response) *url.URL {
+	e.t.Helper()
+	expectStatus(e.t, resp, http.StatusFound)
+	loc, err := resp.Location()
+	if err != nil {
+		e.t.Fatalf("redirect without Location: %v", err)
+	}
+	return loc
+}
+
+// oidcLogin проходит вход через провайдера, как браузер, и возвращает параметры
+// из фрагмента адреса, на который бэкенд вернул пользователя. withCookie=false
+// имитирует колбэк, открытый не в том браузере, который начал вход.
+func (e *testEnv) oidcLogin(withCookie bool) url.Values {
+	e.t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, e.srv.URL+"/api/auth/oidc/login?provider=corp", nil)
+	resp := e.noRedirect(req)
+	authorize := e.location(resp)
+	var state *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "oidc_state" {
+			state = c
+		}
+	}
+	if state == nil || !state.HttpOnly || state.SameSite != http.SameSiteLaxMode {
+		e.t.Fatalf("login did not set a proper state cookie: %+v", state)
+	}
+	if authorize.Query().Get("code_challenge_method") != "S256" || authorize.Query().Get("nonce") == "" {
+		e.t.Fatalf("authorize request without PKCE or nonce: %s", authorize)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, authorize.String(), nil)
+	callback := e.location(e.noRedirect(req))
+	if !strings.HasPrefix(callback.String(), oidcRedirectURL+"?") {
+		e.t.Fatalf("IdP redirected to %s", callback)
+	}
+	req, _ = http.NewRequest(http.MethodGet, e.srv.URL+callback.RequestURI(), nil)
+	if withCookie {
+		req.AddCookie(&http.Cookie{Name: state.Name, Value: state.Value})
+	}
+	done := e.location(e.noRedirect(req))
+	if got := done.Scheme + "://" + done.Host + done.Path; got != "http://app.test/oidc/callback" {
+		e.t.Fatalf("callback redirected to %s", done)
+	}
+	fragment, err := url.ParseQuery(done.Fragment)
+	if err != nil {
+		e.t.Fatalf("parse fragment: %v", err)
+	}
+	return fragment
+}
+
+func TestOIDCLogin(t *testing.T) {
+	e, idp := newOIDCEnv(t)
+
+	resp := e.do(http.MethodGet, "/api/auth/oidc/providers", "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if got := decode[struct{ Providers []string }](t, resp).Providers; len(got) != 1 || got[0] != "corp" {
+		t.Fatalf("unexpected providers: %v", got)
+	}
+	expectStatus(t, e.do(http.MethodGet, "/api/auth/oidc/login?provider=other", "", nil), http.StatusNotFound)
+
+	// Новый пользователь заводится с подтверждённым email.
+	idp.SetUser(fakeoidc.User{Subject: "corp-carol", Email: "carol@example.com", EmailVerified: true, Name: "Carol"})
+	session := e.oidcLogin(true)
+	if session.Get("token") == "" || session.Get("refresh_token") == "" {
+		t.Fatalf("no session after SSO: %v", session)
+	}
+	e.listConversations(session.Get("token"))
+	carol, err := e.store.Users().FindByEmail("carol@example.com")
+	if err != nil || carol.Name != "Carol" || carol.EmailVerifiedAt == nil {
+		t.Fatalf("SSO user not provisioned: %+v, %v", carol, err)
+	}
+
+	// Вход определяется по sub, а не по email: смена адреса у провайдера не создаёт нового пользователя.
+	idp.SetUser(fakeoidc.User{Subject: "corp-carol", Email: "carol.new@example.com", EmailVerified: true})
+	e.oidcLogin(true)
+	if users, _ := e.store.Users().List(); len(users) != 1 {
+		t.Fatalf("expected a single user, got %d", len(users))
+	}
+
+	if got := e.oidcLogin(false).Get("error"); got != "invalid_state" {
+		t.Fatalf("callback without state cookie: error=%q", got)
+	}
+
+	idp.SetUser(fakeoidc.User{Subject: "corp-dave", Email: "dave@example.com", EmailVerified: false})
+	if got := e.oidcLogin(true).Get("error"); got != "email_not_verified" {
+		t.Fatalf("unverified IdP email: error=%q", got)
+	}
+	if _, err := e.store.Users().FindByEmail("dave@example.com"); err == nil {
+		t.Fatal("user provisioned from an unverified email")
+	}
+}
+
+func TestOIDCAccountLinking(t *testing.T) {
+	e, idp := newOIDCEnv(t)
+
+	// Подтверждённый аккаунт с паролем привязывается и продолжает пускать по паролю.
+	e.register("alice@example.com", "alice")
+	alice, _ := e.store.Users().FindByEmail("alice@example.com")
+	e.store.Users().MarkEmailVerified(alice.ID)
+	idp.SetUser(fakeoidc.User{Subject: "corp-alice", Email: "alice@example.com", EmailVerified: true})
+	token := e.oidcLogin(true).Get("token")
+	ok, data := jwt.NewJWT(testSecret, "", time.Minute).Parse(token)
+	if !ok || data.Login != "alice@example.com" {
+		t.Fatalf("SSO did not sign in as the existing user: %+v", data)
+	}
+	e.login("alice@example.com", "password-alice")
+	identities, _ := e.store.Identities().ListByUser(alice.ID)
+	if len(identities) != 1 || identities[0].Provider != "corp" || identities[0].Subject != "corp-alice" {
+		t.Fatalf("unexpected identities: %+v", identities)
+	}
+
+	// Неподтверждённый аккаунт мог зарегистрировать не владелец адреса:
+	// после входа владельца через IdP прежние пароль и сессии недействительны.
+	resp := e.do(http.MethodPost, "/api/auth/register", "", map[string]string{
+		"email": "bob@example.com", "password": "squatter-password", "name": "bob",
+	})
+	expectStatus(t, resp, http.StatusOK)
+	squatter := decode[tokenResponse](t, resp)
+	idp.SetUser(fakeoidc.User{Subject: "corp-bob", Email: "bob@example.com", EmailVerified: true})
+	e.listConversations(e.oidcLogin(true).Get("token"))
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/login", "", map[string]string{"email": "bob@example.com", "password": "squatter-password"}), http.StatusUnauthorized)
+	expectStatus(t, e.refresh(squatter.RefreshToken), http.StatusUnauthorized)
+
+	// Отключённый пользователь не входит и через SSO.
+	e.store.Users().SetDisabled(alice.ID, true)
+	idp.SetUser(fakeoidc.User{Subject: "corp-alice", Email: "alice@example.com", EmailVerified: true})
+	if got := e.oidcLogin(true).Get("error"); got != "account_disabled" {
+		t.Fatalf("disabled user: error=%q", got)
+	}
+}
+
+type profileResponse struct {
+	Email         string `json:"email"`
+	Name          string `json:"name"`
+	EmailVerified bool   `json:"email_verified"`
+	PendingEmail  string `json:"pending_email"`
+}
+
+func TestAccountProfileAndEmailChange(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	e.register("bob@example.com", "bob")
+
+	resp := e.do(http.MethodGet, "/api/account", token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if p := decode[profileResponse](t, resp); p.Email != "alice@example.com" || p.Name != "alice" {
+		t.Fatalf("unexpected profile: %+v", p)
+	}
+
+	resp = e.do(http.MethodPost, "/api/account/update", token, map[string]string{"name": "Alice Liddell"})
+	expectStatus(t, resp, http.StatusOK)
+	if p := decode[profileResponse](t, resp); p.Name != "Alice Liddell" {
+		t.Fatalf("name not updated: %+v", p)
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/account/update", token, map[string]string{"name": "  "}), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodPost, "/api/account/update", token, map[string]string{"email": "bob@example.com"}), http.StatusConflict)
+
+	// Новый адрес действует только после перехода по ссылке из письма на него.
+	resp = e.do(http.MethodPost, "/api/account/update", token, map[string]string{"email": "alice@new.example.com"})
+	expectStatus(t, resp, http.StatusOK)
+	if p := decode[profileResponse](t, resp); p.Email != "alice@example.com" || p.PendingEmail != "alice@new.example.com" {
+		t.Fatalf("email changed before confirmation: %+v", p)
+	}
+	e.login("alice@example.com", "password-alice")
+	link := e.mailedToken("alice@new.example.com", "/confirm-email")
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/verify", "", map[string]string{"token": link}), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/email/confirm", "", map[string]string{"token": link}), http.StatusNoContent)
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/email/confirm", "", map[string]string{"token": link}), http.StatusBadRequest)
+
+	session := e.login("alice@new.example.com", "password-alice")
+	resp = e.do(http.MethodGet, "/api/account", session.Token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if p := decode[profileResponse](t, resp); p.Email != "alice@new.example.com" || p.PendingEmail != "" || !p.EmailVerified {
+		t.Fatalf("email change not applied: %+v", p)
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/login", "", map[string]string{"email": "alice@example.com", "password": "password-alice"}), http.StatusUnauthorized)
+	sent := e.mail.Sent()
+	if last := sent[len(sent)-1]; last.To != "alice@example.com" || !strings.Contains(last.Body, "alice@new.example.com") {
+		t.Fatalf("old address not notified: %+v", last)
+	}
+}
+
+func TestAccountPasswordAndSessions(t *testing.T) {
+	e := newTestEnv(t)
+	e.register("alice@example.com", "alice")
+	laptop := e.login("alice@example.com", "password-alice")
+	phone := e.login("alice@example.com", "password-alice")
+
+	resp := e.do(http.MethodGet, "/api/account/sessions", laptop.Token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	sessions := decode[[]struct {
+		ID        string `json:"id"`
+		UserAgent string `json:"user_agent"`
+		IP        string `json:"ip"`
+		Current   bool   `json:"current"`
+	}](t, resp)
+	// Сессия регистрации, ноутбук и телефон.
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %+v", sessions)
+	}
+	var current, other string
+	for _, s := range sessions {
+		if s.IP != "127.0.0.1" || s.UserAgent == "" {
+			t.Fatalf("session without client info: %+v", s)
+		}
+		if s.Current {
+			current = s.ID
+		} else {
+			other = s.ID
+		}
+	}
+	if current == "" {
+		t.Fatal("current session not marked")
+	}
+
+	expectStatus(t, e.do(http.MethodPost, "/api/account/sessions/revoke", laptop.Token, map[string]string{"id": "unknown"}), http.StatusNotFound)
+	expectStatus(t, e.do(http.MethodPost, "/api/account/sessions/revoke", laptop.Token, map[string]string{"id": other}), http.StatusNoContent)
+	expectStatus(t, e.do(http.MethodPost, "/api/account/sessions/revoke", laptop.Token, map[string]string{"id": other}), http.StatusNotFound)
+
+	// Неверный текущий пароль — 400, чтобы клиент не пытался обновить токен.
+	change := func(current string) *http.Response {
+		return e.do(http.MethodPost, "/api/account/password", laptop.Token, map[string]string{
+			"current_password": current, "new_password": "new-password",
+		})
+	}
+	expectStatus(t, change("wrong"), http.StatusBadRequest)
+	expectStatus(t, change("password-alice"), http.StatusNoContent)
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/login", "", map[string]string{"email": "alice@example.com", "password": "password-alice"}), http.StatusUnauthorized)
+	e.login("alice@example.com", "new-password")
+
+	// Смена пароля завершает остальные сессии, текущая продолжает обновляться.
+	expectStatus(t, e.refresh(phone.RefreshToken), http.StatusUnauthorized)
+	expectStatus(t, e.refresh(laptop.RefreshToken), http.StatusOK)
+
+	expectStatus(t, e.do(http.MethodPost, "/api/account/sessions/revoke-others", laptop.Token, nil), http.StatusNoContent)
+	resp = e.do(http.MethodGet, "/api/account/sessions", laptop.Token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if left := decode[[]struct{ Current bool }](t, resp); len(left) != 1 || !left[0].Current {
+		t.Fatalf("expected only the current session, got %+v", left)
+	}
+}
+
+func TestAccountExportAndDeletion(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	bobToken := e.register("bob@example.com", "bob")
+	convo := e.createConversation(token, "Notes")
+	expectStatus(t, e.do(http.MethodPost, "/api/chat", token, map[string]any{"conversation_id": convo.ID, "message": "hello"}), http.StatusOK)
+	e.createConversation(bobToken, "Bob's")
+
+	resp := e.do(http.MethodGet, "/api/account/export", token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if cd := resp.Header.Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") {
+		t.Fatalf("export is not a download: %q", cd)
+	}
+	export := decode[struct {
+		Profile       profileResponse `json:"profile"`
+		Conversations []struct {
+			Title    string `json:"title"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		} `json:"conversations"`
+	}](t, resp)
+	if export.Profile.Email != "alice@example.com" || len(export.Conversations) != 1 ||
+		export.Conversations[0].Title != "Notes" || len(export.Conversations[0].Messages) != 2 ||
+		export.Conversations[0].Messages[0].Content != "hello" {
+		t.Fatalf("unexpected export: %+v", export)
+	}
+
+	expectStatus(t, e.do(http.MethodPost, "/api/account/delete", token, map[string]string{"password": "wrong"}), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodPost, "/api/account/delete", token, map[string]string{"password": "password-alice"}), http.StatusNoContent)
+
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations", token, nil), http.StatusUnauthorized)
+	expectStatus(t, e.do(http.MethodPost, "/api/auth/login", "", map[string]string{"email": "alice@example.com", "password": "password-alice"}), http.StatusUnauthorized)
+	if _, err := e.store.Conversations().FindAnyByID(convo.ID); err == nil {
+		t.Fatal("conversation survived account deletion")
+	}
+	if msgs, _ := e.store.Messages().ListByConversation(convo.ID); len(msgs) != 0 {
+		t.Fatalf("messages survived account deletion: %+v", msgs)
+	}
+	if got := e.listConversations(bobToken); len(got) != 1 {
+		t.Fatalf("other user's data affected: %+v", got)
+	}
+	// Адрес снова свободен.
+	e.register("alice@example.com", "alice")
+}
+
+type exportJob struct {
+	ID          int    `json:"id"`
+	Status      string `json:"status"`
+	Error       string `json:"error"`
+	DownloadURL string `json:"download_url"`
+}
+
+func readZip(t *testing.T, resp *http.Response) map[string]string {
+	t.Helper()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/zip" {
+		t.Fatalf("content type %q, want application/zip", ct)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func (e *testEnv) waitExport(token string, id int) exportJob {
+	e.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp := e.do(http.MethodGet, "/api/export/jobs?id="+strconv.Itoa(id), token, nil)
+		expectStatus(e.t, resp, http.StatusOK)
+		job := decode[exportJob](e.t, resp)
+		if job.Status != models.ExportPending && job.Status != models.ExportRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			e.t.Fatalf("export job %d still %s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExportArchive(t *testing.T) {
+	e := newTestEnv(t, func(cfg *configs.Config) { cfg.Export.SyncMaxMessages = 2 })
+	token := e.register("alice@example.com", "alice")
+	bobToken := e.register("bob@example.com", "bob")
+	convo := e.createConversation(token, "Заметки по Go")
+	expectStatus(t, e.do(http.MethodPost, "/api/chat", token, map[string]any{"conversation_id": convo.ID, "message": "hello"}), http.StatusOK)
+
+	// Небольшой аккаунт выгружается сразу.
+	resp := e.do(http.MethodGet, "/api/export", token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	files := readZip(t, resp)
+	transcriptName := fmt.Sprintf("transcripts/%d-заметки-по-go.md", convo.ID)
+	for _, name := range []string{"manifest.json", "profile.json", "conversations.json", "usage.json", transcriptName} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("archive has no %s: %v", name, files)
+		}
+	}
+	var convos []struct {
+		Title    string `json:"title"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(files["conversations.json"]), &convos); err != nil {
+		t.Fatalf("conversations.json: %v", err)
+	}
+	if len(convos) != 1 || len(convos[0].Messages) != 2 || convos[0].Messages[0].Content != "hello" {
+		t.Fatalf("unexpected conversations: %+v", convos)
+	}
+	md := files[transcriptName]
+	if !strings.HasPrefix(md, "# Заметки по Go") || !strings.Contains(md, "**Пользователь**") || !strings.Contains(md, "hello") {
+		t.Fatalf("unexpected transcript:\n%s", md)
+	}
+	var usage struct {
+		Messages int `json:"messages"`
+	}
+	json.Unmarshal([]byte(files["usage.json"]), &usage)
+	if usage.Messages != 2 {
+		t.Fatalf("usage messages = %d, want 2", usage.Messages)
+	}
+	if !strings.Contains(files["profile.json"], "alice@example.com") {
+		t.Fatalf("unexpected profile: %s", files["profile.json"])
+	}
+
+	// Большой — в фоне, с заданием и ссылкой.
+	expectStatus(t, e.do(http.MethodPost, "/api/chat", token, map[string]any{"conversation_id": convo.ID, "message": "again"}), http.StatusOK)
+	resp = e.do(http.MethodGet, "/api/export", token, nil)
+	expectStatus(t, resp, http.StatusAccepted)
+	job := decode[exportJob](t, resp)
+	if resp.Header.Get("Location") == "" || job.ID == 0 {
+		t.Fatalf("unexpected job response: %+v", job)
+	}
+	expectStatus(t, e.do(http.MethodGet, "/api/export/jobs?id="+strconv.Itoa(job.ID), bobToken, nil), http.StatusNotFound)
+
+	job = e.waitExport(token, job.ID)
+	if job.Status != models.ExportDone || job.DownloadURL == "" {
+		t.Fatalf("unexpected finished job: %+v", job)
+	}
+	resp = e.do(http.MethodGet, job.DownloadURL, "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	files = readZip(t, resp)
+	if !strings.Contains(files["conversations.json"], "again") || !strings.Contains(files[transcriptName], "again") {
+		t.Fatalf("background archive misses new messages: %v", files)
+	}
+	// Счётчики манифеста и статистики совпадают с содержимым архива.
+	var manifest struct {
+		Messages int      `json:"messages"`
+		Files    []string `json:"files"`
+	}
+	json.Unmarshal([]byte(files["manifest.json"]), &manifest)
+	json.Unmarshal([]byte(files["usage.json"]), &usage)
+	if manifest.Messages != 4 || usage.Messages != 4 || len(manifest.Files) != len(files) {
+		t.Fatalf("archive counters disagree: manifest %+v, usage %d, files %d", manifest, usage.Messages, len(files))
+	}
+
+	// Новая ссылка отменяет прежнюю.
+	fresh := e.waitExport(token, job.ID)
+	expectStatus(t, e.do(http.MethodGet, job.DownloadURL, "", nil), http.StatusNotFound)
+	expectStatus(t, e.do(http.MethodGet, fresh.DownloadURL, "", nil), http.StatusOK)
+
+	// Истёкшая ссылка не работает, архив удаляется.
+	alice, _ := e.store.Users().FindByEmail("alice@example.com")
+	stored, err := e.store.ExportJobs().FindByID(job.ID, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute)
+	stored.ExpiresAt = &past
+	if err := e.store.ExportJobs().Update(stored); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, e.do(http.MethodGet, fresh.DownloadURL, "", nil), http.StatusGone)
+	if _, err := os.Stat(stored.FilePath); !os.IsNotExist(err) {
+		t.Fatalf("expired archive not removed: %v", err)
+	}
+	if job := e.waitExport(token, job.ID); job.Status != models.ExportExpired || job.DownloadURL != "" {
+		t.Fatalf("unexpected expired job: %+v", job)
+	}
+
+	// Явный запуск задания.
+	resp = e.do(http.MethodPost, "/api/export/jobs", token, nil)
+	expectStatus(t, resp, http.StatusAccepted)
+	if job := e.waitExport(token, decode[exportJob](t, resp).ID); job.Status != models.ExportDone {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+}
+
+func (e *testEnv) importFile(token, format, data string) *http.Response {
+	e.t.Helper()
+	req, err := http.NewRequest(http.MethodPost, e.srv.URL+"/api/import?format="+format, strings.NewReader(data))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := e.srv.Client().Do(req)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	e.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+const chatgptExport = `[{
+  "id": "6f1c", "title": "Рецепт", "create_time": 1700000000, "update_time": 1700000500, "current_node": "a",
+  "mapping": {
+    "root": {"id": "root", "parent": null, "children": ["u"], "message": null},
+    "u": {"id": "u", "parent": "root", "children": ["a"], "message": {"author": {"role": "user"},
+      "create_time": 1700000100, "content": {"content_type": "text", "parts": ["Как сварить борщ?"]}}},
+    "a": {"id": "a", "parent": "u", "children": [], "message": {"author": {"role": "assistant"},
+      "create_time": 1700000200, "content": {"content_type": "text", "parts": ["Нужна свёкла."]}}}
+  }
+}, {"id": "empty", "title": "Пустая", "mapping": {}}]`
+
+func TestImportConversations(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+
+	resp := e.importFile(token, "", chatgptExport)
+	expectStatus(t, resp, http.StatusOK)
+	report := decode[importer.Report](t, resp)
+	if report.Format != importer.FormatChatGPT || report.Imported != 1 || report.Failed != 1 ||
+		report.Conversations[1].Status != importer.StatusFailed || report.Conversations[1].Error == "" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	convos := e.listConversations(token)
+	if len(convos) != 1 || convos[0].Title != "Рецепт" || !convos[0].CreatedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("unexpected conversations: %+v", convos)
+	}
+	msgs := e.listMessages(token, convos[0].ID)
+	if len(msgs) != 2 || msgs[0].Role != "user" || msgs[1].Content != "Нужна свёкла." ||
+		!msgs[1].CreatedAt.Equal(time.Unix(1700000200, 0)) {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+
+	// Повторный импорт не создаёт копий.
+	resp = e.importFile(token, importer.FormatChatGPT, chatgptExport)
+	expectStatus(t, resp, http.StatusOK)
+	if report := decode[importer.Report](t, resp); report.Imported != 0 || report.Duplicates != 1 {
+		t.Fatalf("unexpected re-import report: %+v", report)
+	}
+	if got := e.listConversations(token); len(got) != 1 {
+		t.Fatalf("re-import duplicated conversations: %+v", got)
+	}
+
+	// Собственная выгрузка переносится в другой аккаунт формой multipart.
+	bobToken := e.register("bob@example.com", "bob")
+	convo := e.createConversation(bobToken, "Bob's notes")
+	expectStatus(t, e.do(http.MethodPost, "/api/chat", bobToken, map[string]any{"conversation_id": convo.ID, "message": "hello"}), http.StatusOK)
+	resp = e.do(http.MethodGet, "/api/account/export", bobToken, nil)
+	expectStatus(t, resp, http.StatusOK)
+	exported, _ := io.ReadAll(resp.Body)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("file", "me-ai-export.json")
+	fw.Write(exported)
+	mw.Close()
+	req, _ := http.NewRequest(http.MethodPost, e.srv.URL+"/api/import", &form)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := e.srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	expectStatus(t, resp, http.StatusOK)
+	if report := decode[importer.Report](t, resp); report.Format != importer.FormatMeAI || report.Imported != 1 {
+		t.Fatalf("unexpected me-ai import report: %+v", report)
+	}
+	if got := e.listConversations(token); len(got) != 2 {
+		t.Fatalf("expected 2 conversations, got %+v", got)
+	}
+
+	// JSONL: ошибка в одной беседе не мешает другой.
+	jsonl := `{"conversation_id": "a", "role": "user", "content": "первый вопрос", "created_at": "2024-01-02T03:04:05Z"}
+{"conversation_id": "a", "role": "assistant", "content": "ответ"}
+{"conversation_id": "b", "role": "user", "content": "ok", "created_at": "вчера"}`
+	resp = e.importFile(token, "", jsonl)
+	expectStatus(t, resp, http.StatusOK)
+	report = decode[importer.Report](t, resp)
+	if report.Format != importer.FormatJSONL || report.Imported != 1 || report.Failed != 1 ||
+		report.Conversations[0].Title != "первый вопрос" || !strings.Contains(report.Conversations[1].Error, "line 3") {
+		t.Fatalf("unexpected jsonl report: %+v", report)
+	}
+
+	expectStatus(t, e.importFile(token, "", "not a chat export"), http.StatusBadRequest)
+	expectStatus(t, e.importFile(token, "telegram", "[]"), http.StatusBadRequest)
+	readOnly := e.createAPIKey(token, "ro", "conversations:read")
+	expectStatus(t, e.importFile(readOnly.Key, "", chatgptExport), http.StatusForbidden)
+}
+
+type searchResponse struct {
+	Results []struct {
+		Kind           string `json:"kind"`
+		ID             int    `json:"id"`
+		ConversationID int    `json:"conversation_id"`
+		Role           string `json:"role"`
+		Snippet        string `json:"snippet"`
+	} `json:"results"`
+	NextCursor string `json:"next_cursor"`
+}
+
+func (e *testEnv) search(token, query string) searchResponse {
+	e.t.Helper()
+	resp := e.do(http.MethodGet, "/api/search?"+query, token, nil)
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[searchResponse](e.t, resp)
+}
+
+func TestSearch(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	bobToken := e.register("bob@example.com", "bob")
+	jsonl := `{"conversation_id": "go", "title": "Каналы в Go", "role": "user", "content": "как закрыть канал <chan>?", "created_at": "2024-01-10T10:00:00Z"}
+{"conversation_id": "go", "role": "assistant", "content": "Канал закрывает отправитель: close(ch). Канал канал.", "created_at": "2024-01-10T10:00:05Z"}
+{"conversation_id": "cook", "title": "Ужин", "role": "user", "content": "рецепт супа, а не канал", "created_at": "2024-03-01T18:00:00Z"}`
+	expectStatus(t, e.importFile(token, "", jsonl), http.StatusOK)
+	expectStatus(t, e.importFile(bobToken, "", `{"role": "user", "content": "мой канал"}`), http.StatusOK)
+
+	all := e.search(token, "q=канал")
+	if len(all.Results) != 4 || all.NextCursor != "" {
+		t.Fatalf("unexpected results: %+v", all)
+	}
+	// Ответ с тремя совпадениями ранжируется выше, название беседы тоже находится.
+	if all.Results[0].Role != "assistant" {
+		t.Fatalf("best match should be the assistant answer: %+v", all.Results)
+	}
+	kinds := map[string]int{}
+	for _, r := range all.Results {
+		kinds[r.Kind]++
+	}
+	if kinds["conversation"] != 1 || kinds["message"] != 3 {
+		t.Fatalf("unexpected kinds: %v", kinds)
+	}
+	var question string
+	for _, r := range all.Results {
+		if r.Role == "user" && strings.Contains(r.Snippet, "закрыть") {
+			question = r.Snippet
+		}
+	}
+	if question != "как закрыть <mark>канал</mark> &lt;chan&gt;?" {
+		t.Fatalf("snippet is not highlighted and escaped: %q", question)
+	}
+
+	// Фильтры.
+	if got := e.search(token, "q=канал&role=user"); len(got.Results) != 2 {
+		t.Fatalf("role filter: %+v", got)
+	}
+	if got := e.search(token, "q=канал&from=2024-02-01"); len(got.Results) != 1 || got.Results[0].Role != "user" {
+		t.Fatalf("from filter: %+v", got)
+	}
+	if got := e.search(token, "q=канал&to=2024-01-10&role=assistant"); len(got.Results) != 1 {
+		t.Fatalf("to filter includes the whole day: %+v", got)
+	}
+	convoID := all.Results[0].ConversationID
+	if got := e.search(token, "q=канал&conversation_id="+strconv.Itoa(convoID)); len(got.Results) != 3 {
+		t.Fatalf("conversation filter: %+v", got)
+	}
+
+	// Постраничная выдача без пропусков и повторов.
+	seen := map[string]bool{}
+	query := "q=канал&limit=3"
+	for pages := 0; ; pages++ {
+		page := e.search(token, query)
+		for _, r := range page.Results {
+			key := r.Kind + strconv.Itoa(r.ID)
+			if seen[key] {
+				t.Fatalf("duplicate result %s", key)
+			}
+			seen[key] = true
+		}
+		if page.NextCursor == "" {
+			if pages != 1 {
+				t.Fatalf("expected 2 pages, got %d", pages+1)
+			}
+			break
+		}
+		query = "q=канал&limit=3&cursor=" + page.NextCursor
+	}
+	if len(seen) != 4 {
+		t.Fatalf("pagination returned %d results, want 4", len(seen))
+	}
+
+	// У Боба название беседы взято из первой реплики, поэтому совпадений два.
+	if got := e.search(bobToken, "q=канал"); len(got.Results) != 2 {
+		t.Fatalf("search leaks other users' data: %+v", got)
+	}
+	expectStatus(t, e.do(http.MethodGet, "/api/search", token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, "/api/search?q=x&role=robot", token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, "/api/search?q=x&cursor=bm9wZQ", token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, "/api/search?q=x&from=yesterday", token, nil), http.StatusBadRequest)
+}
+
+type semanticResponse struct {
+	Model   string `json:"model"`
+	Results []struct {
+		Score        float32 `json:"score"`
+		Conversation struct {
+			ID    int    `json:"id"`
+			Title string `json:"title"`
+		} `json:"conversation"`
+		Message models.Message   `json:"message"`
+		Before  []models.Message `json:"before"`
+		After   []models.Message `json:"after"`
+	} `json:"results"`
+}
+
+type embeddingStatus struct {
+	Indexed     int  `json:"indexed"`
+	Skipped     int  `json:"skipped"`
+	Pending     int  `json:"pending"`
+	Backfilling bool `json:"backfilling"`
+	Started     bool `json:"started"`
+}
+
+func (e *testEnv) semanticSearch(token, query string) semanticResponse {
+	e.t.Helper()
+	resp := e.do(http.MethodGet, "/api/search/semantic?"+query, token, nil)
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[semanticResponse](e.t, resp)
+}
+
+// backfillEmbeddings запускает backfill и ждёт, пока очередь не опустеет.
+func (e *testEnv) backfillEmbeddings(adminToken string) embeddingStatus {
+	e.t.Helper()
+	resp := e.do(http.MethodPost, "/api/admin/embeddings/backfill", adminToken, nil)
+	expectStatus(e.t, resp, http.StatusAccepted)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp := e.do(http.MethodGet, "/api/admin/embeddings", adminToken, nil)
+		expectStatus(e.t, resp, http.StatusOK)
+		status := decode[embeddingStatus](e.t, resp)
+		if !status.Backfilling && status.Pending == 0 {
+			return status
+		}
+		if time.Now().After(deadline) {
+			e.t.Fatalf("backfill did not finish: %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSemanticSearch(t *testing.T) {
+	e := newTestEnv(t)
+	// Больше измерений — меньше случайных совпадений слов в фейковых векторах.
+	e.llm.EmbeddingDim = 512
+	e.register("root@example.com", "root")
+	e.setRole("root@example.com", models.RoleAdmin)
+	admin := e.login("root@example.com", "password-root").Token
+	token := e.register("alice@example.com", "alice")
+	bobToken := e.register("bob@example.com", "bob")
+
+	jsonl := `{"conversation_id": "garden", "title": "Огород", "role": "user", "content": "how to water tomato plants", "created_at": "2024-05-01T10:00:00Z"}
+{"conversation_id": "garden", "role": "assistant", "content": "water them deeply twice a week", "created_at": "2024-05-01T10:00:05Z"}
+{"conversation_id": "garden", "role": "user", "content": "and cucumbers?", "created_at": "2024-05-01T10:01:00Z"}
+{"conversation_id": "garden", "role": "assistant", "content": "cucumbers need more water", "created_at": "2024-05-01T10:01:05Z"}
+{"conversation_id": "go", "title": "Go", "role": "user", "content": "who closes a golang channel", "created_at": "2024-05-02T10:00:00Z"}`
+	expectStatus(t, e.importFile(token, "", jsonl), http.StatusOK)
+	expectStatus(t, e.importFile(bobToken, "", `{"role": "user", "content": "tomato plants soup"}`), http.StatusOK)
+
+	// До индексации искать не по чему.
+	if got := e.semanticSearch(token, "q=tomato+plants"); len(got.Results) != 0 || got.Model != "test-embed" {
+		t.Fatalf("results before indexing: %+v", got)
+	}
+
+	expectStatus(t, e.do(http.MethodPost, "/api/admin/embeddings/backfill", token, nil), http.StatusForbidden)
+	status := e.backfillEmbeddings(admin)
+	if status.Indexed != 6 || status.Skipped != 0 {
+		t.Fatalf("unexpected index status: %+v", status)
+	}
+
+	got := e.semanticSearch(token, "q=tomato+plants")
+	if len(got.Results) != 5 {
+		t.Fatalf("expected all of alice's messages, got %d", len(got.Results))
+	}
+	best := got.Results[0]
+	if best.Message.Content != "how to water tomato plants" || best.Conversation.Title != "Огород" {
+		t.Fatalf("unexpected best match: %+v", best)
+	}
+	if len(best.Before) != 0 || len(best.After) != 2 || best.After[0].Content != "water them deeply twice a week" {
+		t.Fatalf("unexpected context: before=%+v after=%+v", best.Before, best.After)
+	}
+	for i := 1; i < len(got.Results); i++ {
+		if got.Results[i].Score > got.Results[i-1].Score {
+			t.Fatalf("results are not sorted by score: %+v", got.Results)
+		}
+	}
+
+	narrow := e.semanticSearch(token, "q=tomato+plants&limit=1&context=1&min_score=0.1")
+	if len(narrow.Results) != 1 || len(narrow.Results[0].After) != 1 {
+		t.Fatalf("limit/context not applied: %+v", narrow)
+	}
+	goID := 0
+	for _, r := range got.Results {
+		if r.Conversation.Title == "Go" {
+			goID = r.Conversation.ID
+		}
+	}
+	inGo := e.semanticSearch(token, "q=tomato+plants&conversation_id="+strconv.Itoa(goID))
+	if len(inGo.Results) != 1 || inGo.Results[0].Message.Content != "who closes a golang channel" {
+		t.Fatalf("conversation filter: %+v", inGo)
+	}
+
+	// Чужие сообщения не находятся.
+	bob := e.semanticSearch(bobToken, "q=tomato+plants")
+	if len(bob.Results) != 1 || bob.Results[0].Message.Content != "tomato plants soup" {
+		t.Fatalf("bob sees foreign messages: %+v", bob)
+	}
+
+	// Новые сообщения попадают в очередь и индексируются следующим проходом.
+	convo := e.createConversation(token, "Новая")
+	resp := e.do(http.MethodPost, "/api/chat", token, map[string]any{"conversation_id": convo.ID, "message": "tomato plants again"})
+	expectStatus(t, resp, http.StatusOK)
+	if status := e.backfillEmbeddings(admin); status.Indexed != 8 {
+		t.Fatalf("new messages were not indexed: %+v", status)
+	}
+
+	expectStatus(t, e.do(http.MethodGet, "/api/search/semantic", token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, "/api/search/semantic?q=x&limit=0", token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, "/api/search/semantic?q=x&min_score=2", token, nil), http.StatusBadRequest)
+
+	off := newTestEnv(t, func(cfg *configs.Config) { cfg.Embeddings.Model = "" })
+	offToken := off.register("carol@example.com", "carol")
+	expectStatus(t, off.do(http.MethodGet, "/api/search/semantic?q=x", offToken, nil), http.StatusServiceUnavailable)
+}
+
+func (e *testEnv) messagePage(token string, convoID int, query string) llm.MessagePage {
+	e.t.Helper()
+	resp := e.do(http.MethodGet, "/api/messages?conversation_id="+strconv.Itoa(convoID)+"&"+query, token, nil)
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[llm.MessagePage](e.t, resp)
+}
+
+func contents(msgs []models.Message) string {
+	var parts []string
+	for _, m := range msgs {
+		parts = append(parts, m.Content)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestPagination(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+
+	var jsonl strings.Builder
+	for i := 1; i <= 7; i++ {
+		fmt.Fprintf(&jsonl, `{"conversation_id": "c", "title": "Длинная", "role": "user", "content": "m%d", "created_at": "2024-01-01T10:00:0%dZ"}`+"\n", i, i)
+	}
+	expectStatus(t, e.importFile(token, "jsonl", jsonl.String()), http.StatusOK)
+	convoID := e.listConversations(token)[0].ID
+
+	// Без курсора — самые новые сообщения, внутри страницы по времени.
+	latest := e.messagePage(token, convoID, "limit=3&total=true")
+	if contents(latest.Messages) != "m5,m6,m7" || !latest.HasBefore || latest.HasAfter {
+		t.Fatalf("unexpected latest page: %+v", latest)
+	}
+	if latest.Total == nil || *latest.Total != 7 {
+		t.Fatalf("total: %v", latest.Total)
+	}
+	older := e.messagePage(token, convoID, "limit=3&before="+latest.BeforeCursor)
+	if contents(older.Messages) != "m2,m3,m4" || !older.HasBefore || !older.HasAfter || older.Total != nil {
+		t.Fatalf("unexpected older page: %+v", older)
+	}
+	oldest := e.messagePage(token, convoID, "limit=3&before="+older.BeforeCursor)
+	if contents(oldest.Messages) != "m1" || oldest.HasBefore {
+		t.Fatalf("unexpected oldest page: %+v", oldest)
+	}
+	forward := e.messagePage(token, convoID, "limit=4&after="+oldest.AfterCursor)
+	if contents(forward.Messages) != "m2,m3,m4,m5" || !forward.HasAfter {
+		t.Fatalf("unexpected forward page: %+v", forward)
+	}
+
+	// after с последнего курсора ждёт новые сообщения, пустой ответ сохраняет курсор.
+	empty := e.messagePage(token, convoID, "after="+latest.AfterCursor)
+	if len(empty.Messages) != 0 || empty.AfterCursor != latest.AfterCursor || empty.HasAfter {
+		t.Fatalf("unexpected empty page: %+v", empty)
+	}
+	resp := e.do(http.MethodPost, "/api/chat", token, map[string]any{"conversation_id": convoID, "message": "m8"})
+	expectStatus(t, resp, http.StatusOK)
+	fresh := e.messagePage(token, convoID, "after="+empty.AfterCursor)
+	if contents(fresh.Messages) != "m8,echo: m8" {
+		t.Fatalf("new messages after cursor: %+v", fresh)
+	}
+
+	// Беседы: новые первыми, страницы без пропусков и повторов.
+	for i := 0; i < 4; i++ {
+		e.createConversation(token, "чат "+strconv.Itoa(i))
+	}
+	seen := map[int]bool{}
+	var last *models.Conversation
+	query := "limit=2"
+	for pages := 0; ; pages++ {
+		resp := e.do(http.MethodGet, "/api/conversations?"+query, token, nil)
+		expectStatus(t, resp, http.StatusOK)
+		page := decode[llm.ConversationPage](t, resp)
+		for _, c := range page.Conversations {
+			if seen[c.ID] {
+				t.Fatalf("duplicate conversation %d", c.ID)
+			}
+			if last != nil && c.UpdatedAt.After(last.UpdatedAt) {
+				t.Fatalf("conversations are not newest first: %+v", page.Conversations)
+			}
+			seen[c.ID] = true
+			last = &c
+		}
+		if !page.HasBefore {
+			break
+		}
+		if pages > 5 {
+			t.Fatal("pagination does not terminate")
+		}
+		query = "limit=2&before=" + page.BeforeCursor
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected 5 conversations, got %d", len(seen))
+	}
+	resp = e.do(http.MethodGet, "/api/conversations?limit=1&total=1", token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if page := decode[llm.ConversationPage](t, resp); page.Total == nil || *page.Total != 5 || len(page.Conversations) != 1 {
+		t.Fatalf("unexpected total page: %+v", page)
+	}
+
+	base := "/api/messages?conversation_id=" + strconv.Itoa(convoID)
+	expectStatus(t, e.do(http.MethodGet, base+"&limit=0", token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, base+"&limit=201", token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, base+"&before=bm9wZQ", token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, base+"&before="+latest.BeforeCursor+"&after="+latest.AfterCursor, token, nil), http.StatusBadRequest)
+}
+
+func (e *testEnv) conversationsWhere(token, query string) []models.Conversation {
+	e.t.Helper()
+	resp := e.do(http.MethodGet, "/api/conversations?"+query, token, nil)
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[llm.ConversationPage](e.t, resp).Conversations
+}
+
+func titles(convos []models.Conversation) string {
+	var names []string
+	for _, c := range convos {
+		names = append(names, c.Title)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (e *testEnv) bulk(token string, body map[string]any) *http.Response {
+	e.t.Helper()
+	return e.do(http.MethodPost, "/api/conversations/bulk", token, body)
+}
+
+func TestConversationOrganization(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	bobToken := e.register("bob@example.com", "bob")
+	ids := map[string]int{}
+	for _, title := range []string{"a", "b", "c", "d"} {
+		ids[title] = e.createConversation(token, title).ID
+	}
+	bobConvo := e.createConversation(bobToken, "bob")
+
+	resp := e.do(http.MethodPost, "/api/folders/create", token, map[string]string{"name": " Работа "})
+	expectStatus(t, resp, http.StatusCreated)
+	folder := decode[models.Folder](t, resp)
+	if folder.Name != "Работа" {
+		t.Fatalf("folder name is not trimmed: %q", folder.Name)
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/folders/create", token, map[string]string{"name": "Работа"}), http.StatusConflict)
+	resp = e.do(http.MethodPost, "/api/folders/create", bobToken, map[string]string{"name": "Работа"})
+	expectStatus(t, resp, http.StatusCreated)
+	bobFolder := decode[models.Folder](t, resp)
+
+	expectStatus(t, e.bulk(token, map[string]any{"action": "move", "ids": []int{ids["a"], ids["b"]}, "folder_id": folder.ID}), http.StatusOK)
+	if got := titles(e.conversationsWhere(token, "folder_id="+strconv.Itoa(folder.ID))); got != "a,b" {
+		t.Fatalf("folder filter: %s", got)
+	}
+	if got := titles(e.conversationsWhere(token, "folder_id=none")); got != "c,d" {
+		t.Fatalf("no folder filter: %s", got)
+	}
+
+	expectStatus(t, e.bulk(token, map[string]any{"action": "tag", "ids": []int{ids["a"], ids["c"]}, "tags": []string{"go", " важное "}}), http.StatusOK)
+	expectStatus(t, e.bulk(token, map[string]any{"action": "tag", "ids": []int{ids["d"]}, "tags": []string{"go"}}), http.StatusOK)
+	if got := titles(e.conversationsWhere(token, "tag=go")); got != "a,c,d" {
+		t.Fatalf("tag filter: %s", got)
+	}
+	tagged := e.conversationsWhere(token, "tag=go&tag=важное")
+	if titles(tagged) != "a,c" || strings.Join(tagged[0].Tags, ",") != "go,важное" {
+		t.Fatalf("all tags filter: %+v", tagged)
+	}
+	expectStatus(t, e.bulk(token, map[string]any{"action": "untag", "ids": []int{ids["d"]}, "tags": []string{"go"}}), http.StatusOK)
+
+	expectStatus(t, e.bulk(token, map[string]any{"action": "pin", "ids": []int{ids["d"]}}), http.StatusOK)
+	pinned := e.conversationsWhere(token, "pinned=true")
+	if titles(pinned) != "d" || pinned[0].PinnedAt == nil {
+		t.Fatalf("pinned filter: %+v", pinned)
+	}
+
+	// Архивные беседы скрыты из обычного списка.
+	expectStatus(t, e.bulk(token, map[string]any{"action": "archive", "ids": []int{ids["c"]}}), http.StatusOK)
+	if got := titles(e.listConversations(token)); got != "a,b,d" {
+		t.Fatalf("archived conversation is listed: %s", got)
+	}
+	if got := titles(e.conversationsWhere(token, "archived=true")); got != "c" {
+		t.Fatalf("archived filter: %s", got)
+	}
+	if got := titles(e.conversationsWhere(token, "archived=all")); got != "a,b,c,d" {
+		t.Fatalf("archived=all: %s", got)
+	}
+
+	// Чужая беседа или папка отменяют действие целиком.
+	expectStatus(t, e.bulk(token, map[string]any{"action": "archive", "ids": []int{ids["a"], bobConvo.ID}}), http.StatusNotFound)
+	expectStatus(t, e.bulk(token, map[string]any{"action": "move", "ids": []int{ids["d"]}, "folder_id": bobFolder.ID}), http.StatusNotFound)
+	expectStatus(t, e.bulk(token, map[string]any{"action": "delete", "ids": []int{ids["a"], 999999}}), http.StatusNotFound)
+	if got := titles(e.listConversations(token)); got != "a,b,d" {
+		t.Fatalf("failed bulk action changed data: %s", got)
+	}
+
+	resp = e.do(http.MethodGet, "/api/folders", token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if folders := decode[[]models.Folder](t, resp); len(folders) != 1 || folders[0].Conversations != 2 {
+		t.Fatalf("unexpected folders: %+v", folders)
+	}
+	resp = e.do(http.MethodGet, "/api/tags", token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	tags := decode[[]models.Tag](t, resp)
+	if len(tags) != 2 || tags[0].Name != "go" || tags[0].Conversations != 2 {
+		t.Fatalf("unexpected tags: %+v", tags)
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/tags/rename", token, map[string]any{"id": tags[0].ID, "name": "важное"}), http.StatusConflict)
+	expectStatus(t, e.do(http.MethodPost, "/api/tags/rename", token, map[string]any{"id": tags[0].ID, "name": "golang"}), http.StatusNoContent)
+	if got := titles(e.conversationsWhere(token, "tag=golang&archived=all")); got != "a,c" {
+		t.Fatalf("renamed tag: %s", got)
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/tags/delete", bobToken, map[string]any{"id": tags[0].ID}), http.StatusNotFound)
+	expectStatus(t, e.do(http.MethodPost, "/api/tags/delete", token, map[string]any{"id": tags[0].ID}), http.StatusNoContent)
+
+	// Удаление папки оставляет беседы вне папок.
+	expectStatus(t, e.do(http.MethodPost, "/api/folders/delete", token, map[string]any{"id": folder.ID}), http.StatusNoContent)
+	if got := titles(e.conversationsWhere(token, "folder_id=none")); got != "a,b,d" {
+		t.Fatalf("conversations after folder delete: %s", got)
+	}
+
+	expectStatus(t, e.bulk(token, map[string]any{"action": "delete", "ids": []int{ids["a"], ids["b"]}}), http.StatusOK)
+	if got := titles(e.conversationsWhere(token, "archived=all")); got != "c,d" {
+		t.Fatalf("bulk delete: %s", got)
+	}
+
+	expectStatus(t, e.bulk(token, map[string]any{"action": "explode", "ids": []int{ids["c"]}}), http.StatusBadRequest)
+	expectStatus(t, e.bulk(token, map[string]any{"action": "tag", "ids": []int{ids["c"]}, "tags": []string{" "}}), http.StatusBadRequest)
+	expectStatus(t, e.bulk(token, map[string]any{"action": "pin", "ids": []int{0}}), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations?archived=maybe", token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, "/api/conversations?folder_id=x", token, nil), http.StatusBadRequest)
+}
+
+func (e *testEnv) trash(token string) trash.TrashResponse {
+	e.t.Helper()
+	resp := e.do(http.MethodGet, "/api/trash", token, nil)
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[trash.TrashResponse](e.t, resp)
+}
+
+func TestTrash(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	bobToken := e.register("bob@example.com", "bob")
+	jsonl := `{"conversation_id": "a", "title": "Первая", "role": "user", "content": "alpha", "created_at": "2024-01-01T10:00:00Z"}
+{"conversation_id": "a", "role": "assistant", "content": "beta secret", "created_at": "2024-01-01T10:00:01Z"}
+{"conversation_id": "a", "role": "user", "content": "gamma", "created_at": "2024-01-01T10:00:02Z"}
+{"conversation_id": "b", "title": "Вторая", "role": "user", "content": "delta", "created_at": "2024-01-02T10:00:00Z"}`
+	expectStatus(t, e.importFile(token, "jsonl", jsonl), http.StatusOK)
+	convos := map[string]int{}
+	for _, c := range e.listConversations(token) {
+		convos[c.Title] = c.ID
+	}
+	first := e.listMessages(token, convos["Первая"])
+	second := e.listMessages(token, convos["Вторая"])
+
+	// Удалённое сообщение уходит в корзину и пропадает из беседы и поиска.
+	expectStatus(t, e.do(http.MethodPost, "/api/messages/delete", token, map[string]any{"id": atoi(t, first[1].ID)}), http.StatusNoContent)
+	if got := contents(e.listMessages(token, convos["Первая"])); got != "alpha,gamma" {
+		t.Fatalf("deleted message is listed: %s", got)
+	}
+	if got := e.search(token, "q=secret"); len(got.Results) != 0 {
+		t.Fatalf("deleted message is searchable: %+v", got)
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/messages/delete", token, map[string]any{"id": atoi(t, first[1].ID)}), http.StatusNotFound)
+	bin := e.trash(token)
+	if len(bin.Conversations) != 0 || len(bin.Messages) != 1 {
+		t.Fatalf("unexpected trash: %+v", bin)
+	}
+	item := bin.Messages[0]
+	if item.Content != "beta secret" || item.ConversationTitle != "Первая" || item.DeletedAt == nil || !item.PurgeAt.Equal(item.DeletedAt.Add(time.Hour)) {
+		t.Fatalf("unexpected trashed message: %+v", item)
+	}
+	restore := func(token, itemType string, id int) *http.Response {
+		return e.do(http.MethodPost, "/api/trash/restore", token, map[string]any{"type": itemType, "id": id})
+	}
+	expectStatus(t, restore(bobToken, "message", atoi(t, item.ID)), http.StatusNotFound)
+	expectStatus(t, restore(token, "message", atoi(t, item.ID)), http.StatusNoContent)
+	if got := contents(e.listMessages(token, convos["Первая"])); got != "alpha,beta secret,gamma" {
+		t.Fatalf("restored message: %s", got)
+	}
+
+	// Сообщения удалённой беседы восстанавливаются только вместе с ней.
+	expectStatus(t, e.do(http.MethodPost, "/api/messages/delete", token, map[string]any{"id": atoi(t, second[0].ID)}), http.StatusNoContent)
+	expectStatus(t, e.do(http.MethodPost, "/api/conversations/delete", token, map[string]any{"id": convos["Вторая"]}), http.StatusNoContent)
+	if got := titles(e.listConversations(token)); got != "Первая" {
+		t.Fatalf("deleted conversation is listed: %s", got)
+	}
+	expectStatus(t, e.do(http.MethodGet, "/api/messages?conversation_id="+strconv.Itoa(convos["Вторая"]), token, nil), http.StatusNotFound)
+	bin = e.trash(token)
+	if len(bin.Conversations) != 1 || bin.Conversations[0].Title != "Вторая" || len(bin.Messages) != 0 {
+		t.Fatalf("unexpected trash: %+v", bin)
+	}
+	expectStatus(t, restore(token, "message", atoi(t, second[0].ID)), http.StatusNotFound)
+	expectStatus(t, restore(bobToken, "conversation", convos["Вторая"]), http.StatusNotFound)
+	expectStatus(t, restore(token, "folder", convos["Вторая"]), http.StatusBadRequest)
+	expectStatus(t, restore(token, "conversation", convos["Вторая"]), http.StatusNoContent)
+	expectStatus(t, restore(token, "conversation", convos["Вторая"]), http.StatusNotFound)
+	if got := titles(e.listConversations(token)); got != "Вторая,Первая" {
+		t.Fatalf("restored conversation: %s", got)
+	}
+	if bin = e.trash(token); len(bin.Messages) != 1 || bin.Messages[0].ConversationTitle != "Вторая" {
+		t.Fatalf("message of restored conversation: %+v", bin)
+	}
+
+	// Окончательное удаление: по одному элементу и всей корзины.
+	expectStatus(t, e.bulk(token, map[string]any{"action": "delete", "ids": []int{convos["Первая"]}}), http.StatusOK)
+	del := func(itemType string, id int) *http.Response {
+		return e.do(http.MethodPost, "/api/trash/delete", token, map[string]any{"type": itemType, "id": id})
+	}
+	expectStatus(t, del("conversation", convos["Вторая"]), http.StatusNotFound)
+	expectStatus(t, del("conversation", convos["Первая"]), http.StatusNoContent)
+	expectStatus(t, restore(token, "conversation", convos["Первая"]), http.StatusNotFound)
+	resp := e.do(http.MethodPost, "/api/trash/empty", token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if got := decode[trash.EmptyResponse](t, resp); got.Conversations != 0 || got.Messages != 1 {
+		t.Fatalf("unexpected empty result: %+v", got)
+	}
+	if bin = e.trash(token); len(bin.Conversations) != 0 || len(bin.Messages) != 0 {
+		t.Fatalf("trash is not empty: %+v", bin)
+	}
+	if got := titles(e.listConversations(token)); got != "Вторая" {
+		t.Fatalf("conversations after purge: %s", got)
+	}
+}
+
+func TestTrashPurge(t *testing.T) {
+	e := newTestEnv(t, func(cfg *configs.Config) {
+		cfg.Trash = configs.TrashConfig{Retention: 200 * time.Millisecond, PurgeInterval: 10 * time.Millisecond}
+	})
+	token := e.register("alice@example.com", "alice")
+	convo := e.createConversation(token, "old")
+	expectStatus(t, e.do(http.MethodPost, "/api/conversations/delete", token, map[string]any{"id": convo.ID}), http.StatusNoContent)
+	if bin := e.trash(token); len(bin.Conversations) != 1 {
+		t.Fatalf("conversation is not in trash: %+v", bin)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(e.trash(token).Conversations) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expired conversation was not purged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (e *testEnv) createShare(token string, body map[string]any) share.ShareResponse {
+	e.t.Helper()
+	resp := e.do(http.MethodPost, "/api/shares/create", token, body)
+	expectStatus(e.t, resp, http.StatusCreated)
+	return decode[share.ShareResponse](e.t, resp)
+}
+
+func (e *testEnv) shares(token string) []share.ShareResponse {
+	e.t.Helper()
+	resp := e.do(http.MethodGet, "/api/shares", token, nil)
+	expectStatus(e.t, resp, http.StatusOK)
+	return decode[[]share.ShareResponse](e.t, resp)
+}
+
+func TestConversationShares(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	bobToken := e.register("bob@example.com", "bob")
+	jsonl := `{"conversation_id": "a", "title": "Рецепт", "role": "user", "content": "как сварить борщ?", "created_at": "2024-01-01T10:00:00Z"}
+{"conversation_id": "a", "role": "assistant", "content": "Сначала бульон.", "created_at": "2024-01-01T10:00:05Z"}`
+	expectStatus(t, e.importFile(token, "jsonl", jsonl), http.StatusOK)
+	convo := e.listConversations(token)[0]
+	msgs := e.listMessages(token, convo.ID)
+
+	created := e.createShare(token, map[string]any{"conversation_id": convo.ID})
+	if created.Token == "" || created.URL != "http://app.test/shared?token="+created.Token || created.HasPassword || created.MessageCount != 2 {
+		t.Fatalf("unexpected share: %+v", created)
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/shares/create", bobToken, map[string]any{"conversation_id": convo.ID}), http.StatusNotFound)
+
+	// Снимок не меняется вместе с беседой и не раскрывает ID и email.
+	expectStatus(t, e.do(http.MethodPost, "/api/messages/delete", token, map[string]any{"id": atoi(t, msgs[1].ID)}), http.StatusNoContent)
+	expectStatus(t, e.do(http.MethodPost, "/api/conversations/rename", token, map[string]any{"id": convo.ID, "title": "Другое"}), http.StatusNoContent)
+	view := func(query string) *http.Response {
+		return e.do(http.MethodGet, "/api/shared?"+query, "", nil)
+	}
+	resp := view("token=" + url.QueryEscape(created.Token))
+	expectStatus(t, resp, http.StatusOK)
+	raw, _ := io.ReadAll(resp.Body)
+	var shared share.SharedConversation
+	if err := json.Unmarshal(raw, &shared); err != nil {
+		t.Fatalf("decode shared conversation: %v", err)
+	}
+	if shared.Title != "Рецепт" || len(shared.Messages) != 2 || shared.Messages[1].Content != "Сначала бульон." {
+		t.Fatalf("snapshot changed: %+v", shared)
+	}
+	for _, leak := range []string{"alice@example.com", `"id"`, "user_id", "conversation_id"} {
+		if strings.Contains(string(raw), leak) {
+			t.Fatalf("shared conversation exposes %s: %s", leak, raw)
+		}
+	}
+	expectStatus(t, view("token="+url.QueryEscape(created.Token)), http.StatusOK)
+	list := e.shares(token)
+	if len(list) != 1 || list[0].Views != 2 || list[0].LastViewedAt == nil || list[0].Token != "" || list[0].URL != "" {
+		t.Fatalf("unexpected shares: %+v", list)
+	}
+	if len(e.shares(bobToken)) != 0 {
+		t.Fatal("bob sees alice's shares")
+	}
+
+	// Ссылка с паролем открывается только POST-запросом с паролем.
+	protected := e.createShare(token, map[string]any{"conversation_id": convo.ID, "password": "hunter2"})
+	if !protected.HasPassword || protected.MessageCount != 1 {
+		t.Fatalf("unexpected protected share: %+v", protected)
+	}
+	expectStatus(t, view("token="+url.QueryEscape(protected.Token)), http.StatusUnauthorized)
+	expectStatus(t, e.do(http.MethodPost, "/api/shared", "", map[string]string{"token": protected.Token, "password": "wrong"}), http.StatusForbidden)
+	expectStatus(t, e.do(http.MethodPost, "/api/shared", "", map[string]string{"token": protected.Token, "password": "hunter2"}), http.StatusOK)
+
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	expectStatus(t, e.do(http.MethodPost, "/api/shares/create", token, map[string]any{"conversation_id": convo.ID, "expires_at": past}), http.StatusBadRequest)
+	soon := time.Now().Add(100 * time.Millisecond).UTC().Format(time.RFC3339Nano)
+	expiring := e.createShare(token, map[string]any{"conversation_id": convo.ID, "expires_at": soon})
+	expectStatus(t, view("token="+url.QueryEscape(expiring.Token)), http.StatusOK)
+	time.Sleep(150 * time.Millisecond)
+	expectStatus(t, view("token="+url.QueryEscape(expiring.Token)), http.StatusGone)
+
+	// Ссылки беседы в корзине не открываются, пока её не восстановят.
+	expectStatus(t, e.do(http.MethodPost, "/api/conversations/delete", token, map[string]any{"id": convo.ID}), http.StatusNoContent)
+	expectStatus(t, view("token="+url.QueryEscape(created.Token)), http.StatusNotFound)
+	expectStatus(t, e.do(http.MethodPost, "/api/trash/restore", token, map[string]any{"type": "conversation", "id": convo.ID}), http.StatusNoContent)
+	expectStatus(t, view("token="+url.QueryEscape(created.Token)), http.StatusOK)
+
+	revoke := func(token string, id int) *http.Response {
+		return e.do(http.MethodPost, "/api/shares/revoke", token, map[string]any{"id": id})
+	}
+	expectStatus(t, revoke(bobToken, created.ID), http.StatusNotFound)
+	expectStatus(t, revoke(token, created.ID), http.StatusNoContent)
+	expectStatus(t, revoke(token, created.ID), http.StatusNotFound)
+	expectStatus(t, view("token="+url.QueryEscape(created.Token)), http.StatusNotFound)
+	expectStatus(t, view("token=unknown"), http.StatusNotFound)
+	expectStatus(t, view(""), http.StatusBadRequest)
+	if list := e.shares(token); len(list) != 3 || list[2].RevokedAt == nil {
+		t.Fatalf("revoked share is not marked: %+v", list)
+	}
+}
+
+func TestSharePasswordLockout(t *testing.T) {
+	e := newTestEnv(t, func(cfg *configs.Config) {
+		cfg.Share.PasswordMaxAttempts = 3
+		cfg.Share.PasswordMaxLinkAttempts = 5
+		cfg.Share.PasswordLockout = time.Minute
+		cfg.Auth.TrustProxyHeaders = true
+	})
+	token := e.register("alice@example.com", "alice")
+	convo := e.createConversation(token, "Секрет")
+	locked := e.createShare(token, map[string]any{"conversation_id": convo.ID, "password": "hunter2"})
+	other := e.createShare(token, map[string]any{"conversation_id": convo.ID, "password": "hunter2"})
+	open := func(token, password, ip string) *http.Response {
+		t.Helper()
+		data, _ := json.Marshal(map[string]string{"token": token, "password": password})
+		req, err := http.NewRequest(http.MethodPost, e.srv.URL+"/api/shared", bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", ip)
+		resp, err := e.srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	for i := 0; i < 3; i++ {
+		expectStatus(t, open(locked.Token, "wrong", "10.0.0.1"), http.StatusForbidden)
+	}
+	// Во время блокировки не помогает и верный пароль.
+	resp := open(locked.Token, "hunter2", "10.0.0.1")
+	expectStatus(t, resp, http.StatusTooManyRequests)
+	if retry, _ := strconv.Atoi(resp.Header.Get("Retry-After")); retry <= 0 || retry > 60 {
+		t.Fatalf("unexpected Retry-After: %q", resp.Header.Get("Retry-After"))
+	}
+	// С другого IP ссылка открывается, а удачный вход сбрасывает счётчик этого IP.
+	expectStatus(t, open(locked.Token, "wrong", "10.0.0.2"), http.StatusForbidden)
+	expectStatus(t, open(locked.Token, "hunter2", "10.0.0.2"), http.StatusOK)
+
+	// Перебор с разных IP упирается в общий предел ссылки.
+	expectStatus(t, open(locked.Token, "wrong", "10.0.0.3"), http.StatusForbidden)
+	expectStatus(t, open(locked.Token, "hunter2", "10.0.0.4"), http.StatusTooManyRequests)
+
+	// Другая ссылка не затронута.
+	expectStatus(t, open(other.Token, "hunter2", "10.0.0.1"), http.StatusOK)
+}
+
+func (e *testEnv) exportConversation(token string, id int, query string) (*http.Response, string) {
+	e.t.Helper()
+	resp := e.do(http.MethodGet, fmt.Sprintf("/api/conversations/export?id=%d&%s", id, query), token, nil)
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+const exportJSONL = `{"conversation_id": "x", "role": "system", "content": "будь краток", "created_at": "2024-01-02T03:04:05Z"}
+{"conversation_id": "x", "role": "user", "content": "Что такое <script>alert(1)</script>?", "created_at": "2024-01-02T03:04:06Z"}
+{"conversation_id": "x", "role": "assistant", "content": "<think>пользователь спрашивает про тег</think>Это тег HTML.", "created_at": "2024-01-02T03:04:07Z"}`
+
+func TestConversationExport(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	expectStatus(t, e.importFile(token, importer.FormatJSONL, exportJSONL), http.StatusOK)
+	convo := e.listConversations(token)[0]
+
+	resp, md := e.exportConversation(token, convo.ID, "")
+	expectStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/markdown") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") || !strings.Contains(cd, fmt.Sprintf("%d-", convo.ID)) {
+		t.Fatalf("unexpected content disposition %q", cd)
+	}
+	if !strings.Contains(md, "Модель: "+llm.Model) || !strings.Contains(md, "Это тег HTML.") ||
+		strings.Contains(md, "пользователь спрашивает") || strings.Contains(md, "будь краток") {
+		t.Fatalf("unexpected markdown:\n%s", md)
+	}
+
+	resp, html := e.exportConversation(token, convo.ID, "format=html&include=reasoning")
+	expectStatus(t, resp, http.StatusOK)
+	if !strings.HasPrefix(html, "<!DOCTYPE html>") || strings.Contains(html, "<script>") ||
+		!strings.Contains(html, "&lt;script&gt;") || !strings.Contains(html, "пользователь спрашивает") {
+		t.Fatalf("unexpected html:\n%s", html)
+	}
+
+	resp, body := e.exportConversation(token, convo.ID, "format=json&include=reasoning,tools")
+	expectStatus(t, resp, http.StatusOK)
+	var doc transcript.Document
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Schema != transcript.Schema || doc.Conversation.ID != convo.ID || doc.Conversation.Persona != llm.Persona ||
+		len(doc.Messages) != 3 || doc.Messages[0].Role != "system" ||
+		doc.Messages[2].Content != "Это тег HTML." || doc.Messages[2].Reasoning != "пользователь спрашивает про тег" {
+		t.Fatalf("unexpected json export: %+v", doc)
+	}
+
+	expectStatus(t, e.do(http.MethodGet, fmt.Sprintf("/api/conversations/export?id=%d&format=pdf", convo.ID), token, nil), http.StatusBadRequest)
+	expectStatus(t, e.do(http.MethodGet, fmt.Sprintf("/api/conversations/export?id=%d&include=images", convo.ID), token, nil), http.StatusBadRequest)
+	bobToken := e.register("bob@example.com", "bob")
+	expectStatus(t, e.do(http.MethodGet, fmt.Sprintf("/api/conversations/export?id=%d", convo.ID), bobToken, nil), http.StatusNotFound)
+
+	// Беседа в корзине не выгружается.
+	expectStatus(t, e.do(http.MethodPost, "/api/conversations/delete", token, map[string]any{"id": convo.ID}), http.StatusNoContent)
+	expectStatus(t, e.do(http.MethodGet, fmt.Sprintf("/api/conversations/export?id=%d", convo.ID), token, nil), http.StatusNotFound)
+}
+
+func TestConversationExportCustomTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := "{{.Conversation.Title}}: {{len .Messages}} сообщ.\n"
+	if err := os.WriteFile(filepath.Join(dir, "conversation.md.tmpl"), []byte(tmpl), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := newTestEnv(t, func(cfg *configs.Config) { cfg.Export.TemplatesDir = dir })
+	token := e.register("alice@example.com", "alice")
+	expectStatus(t, e.importFile(token, importer.FormatJSONL, exportJSONL), http.StatusOK)
+	convo := e.listConversations(token)[0]
+
+	resp, md := e.exportConversation(token, convo.ID, "format=md")
+	expectStatus(t, resp, http.StatusOK)
+	if md != convo.Title+": 2 сообщ.\n" {
+		t.Fatalf("custom template ignored: %q", md)
+	}
+	// HTML не переопределён и собирается встроенным шаблоном.
+	resp, html := e.exportConversation(token, convo.ID, "format=html")
+	expectStatus(t, resp, http.StatusOK)
+	if !strings.HasPrefix(html, "<!DOCTYPE html>") {
+		t.Fatalf("unexpected html:\n%s", html)
+	}
+}
+
+func (e *testEnv) editMessage(token string, body map[string]any) *http.Response {
+	e.t.Helper()
+	return e.do(http.MethodPost, "/api/messages/edit", token, body)
+}
+
+func TestMessageEditing(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	convo := e.createConversation(token, "chat")
+	e.llm.Enqueue(fakeollama.Reply{Content: "Ответ 1"}, fakeollama.Reply{Content: "Ответ 2"})
+	for _, text := range []string{"Вопрос 1", "Вопрос 2"} {
+		expectStatus(t, e.do(http.MethodPost, "/api/chat", token, map[string]any{"conversation_id": convo.ID, "message": text}), http.StatusOK)
+	}
+	msgs := e.listMessages(token, convo.ID)
+	question, answer := atoi(t, msgs[0].ID), atoi(t, msgs[1].ID)
+
+	// Правка ответа ассистента сохраняет каждую прежнюю версию.
+	for _, text := range []string{"Ответ 1, исправленный", "Ответ 1, финальный"} {
+		resp := e.editMessage(token, map[string]any{"id": answer, "content": text})
+		expectStatus(t, resp, http.StatusOK)
+		edited := decode[models.EditMessageResponse](t, resp)
+		if edited.Message.Content != text || edited.Message.EditedAt == nil || edited.Reply != nil {
+			t.Fatalf("unexpected edit response: %+v", edited)
+		}
+	}
+	resp := e.do(http.MethodGet, fmt.Sprintf("/api/messages/revisions?id=%d", answer), token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	revs := decode[[]models.MessageRevision](t, resp)
+	if len(revs) != 2 || revs[0].Content != "Ответ 1" || revs[1].Content != "Ответ 1, исправленный" {
+		t.Fatalf("unexpected revisions: %+v", revs)
+	}
+	msgs = e.listMessages(token, convo.ID)
+	if msgs[0].EditedAt != nil || msgs[1].EditedAt == nil || msgs[1].Content != "Ответ 1, финальный" {
+		t.Fatalf("unexpected messages after edit: %+v", msgs)
+	}
+
+	// Перезапуск модели с правленого вопроса убирает последующие сообщения в корзину.
+	e.llm.Enqueue(fakeollama.Reply{Content: "Новый ответ"})
+	resp = e.editMessage(token, map[string]any{"id": question, "content": "Вопрос 1, уточнённый", "regenerate": true})
+	expectStatus(t, resp, http.StatusOK)
+	edited := decode[models.EditMessageResponse](t, resp)
+	if edited.Reply == nil || edited.Reply.Content != "Новый ответ" || edited.Reply.Role != "assistant" {
+		t.Fatalf("unexpected regenerate response: %+v", edited)
+	}
+	reqs := e.llm.Requests()
+	sent := reqs[len(reqs)-1].Messages
+	if len(sent) != 1 || sent[0].Content != "Вопрос 1, уточнённый" {
+		t.Fatalf("unexpected history sent to LLM: %+v", sent)
+	}
+	if got := contents(e.listMessages(token, convo.ID)); got != "Вопрос 1, уточнённый,Новый ответ" {
+		t.Fatalf("unexpected messages after regenerate: %s", got)
+	}
+	if got := e.trash(token).Messages; len(got) != 3 {
+		t.Fatalf("expected 3 trashed messages, got %+v", got)
+	}
+
+	expectStatus(t, e.editMessage(token, map[string]any{"id": atoi(t, edited.Reply.ID), "content": "x", "regenerate": true}), http.StatusBadRequest)
+	expectStatus(t, e.editMessage(token, map[string]any{"id": question, "content": "  "}), http.StatusBadRequest)
+	expectStatus(t, e.editMessage(token, map[string]any{"id": answer, "content": "из корзины"}), http.StatusNotFound)
+
+	bob := e.register("bob@example.com", "bob")
+	expectStatus(t, e.editMessage(bob, map[string]any{"id": question, "content": "чужое"}), http.StatusNotFound)
+	expectStatus(t, e.do(http.MethodGet, fmt.Sprintf("/api/messages/revisions?id=%d", answer), bob, nil), http.StatusNotFound)
+
+	// Без chat:write ключ может править, но не запускать модель.
+	key := e.createAPIKey(token, "editor", "conversations:write")
+	expectStatus(t, e.editMessage(key.Key, map[string]any{"id": question, "content": "Вопрос 1"}), http.StatusOK)
+	expectStatus(t, e.editMessage(key.Key, map[string]any{"id": question, "content": "Вопрос 1", "regenerate": true}), http.StatusForbidden)
+
+	// Пока после сообщения генерируется ответ, перезапуск ничего не меняет:
+	// ни текст, ни ревизии, ни последующие сообщения.
+	alice, err := e.store.Users().FindByEmail("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.store.Messages().CreateTurn(convo.ID, alice.ID, "Вопрос 2"); err != nil {
+		t.Fatal(err)
+	}
+	before := contents(e.listMessages(token, convo.ID))
+	revs, _ = e.store.Messages().ListRevisions(question, alice.ID)
+	expectStatus(t, e.editMessage(token, map[string]any{"id": question, "content": "Вопрос 1, снова", "regenerate": true}), http.StatusConflict)
+	if got := contents(e.listMessages(token, convo.ID)); got != before {
+		t.Fatalf("conflicting regenerate changed messages: %s, was %s", got, before)
+	}
+	if after, _ := e.store.Messages().ListRevisions(question, alice.ID); len(after) != len(revs) {
+		t.Fatalf("conflicting regenerate saved a revision: %+v", after)
+	}
+}
+
+func (e *testEnv) chat(token string, convoID int, text string) (*http.Response, models.ChatResponse) {
+	e.t.Helper()
+	resp := e.do(http.MethodPost, "/api/chat", token, map[string]any{"conversation_id": convoID, "message": text})
+	return resp, decode[models.ChatResponse](e.t, resp)
+}
+
+func TestChatTurns(t *testing.T) {
+	e := newTestEnv(t)
+	token := e.register("alice@example.com", "alice")
+	convo := e.createConversation(token, "chat")
+
+	e.llm.Enqueue(fakeollama.Reply{Content: "Привет"})
+	resp, chat := e.chat(token, convo.ID, "Здравствуй")
+	expectStatus(t, resp, http.StatusOK)
+	msgs := e.listMessages(token, convo.ID)
+	if chat.Status != models.MessageComplete || chat.UserMessageID != msgs[0].ID || chat.ReplyID != msgs[1].ID {
+		t.Fatalf("unexpected chat response %+v for messages %+v", chat, msgs)
+	}
+
+	// Ошибка модели оставляет реплику в беседе с ответом failed.
+	e.llm.Enqueue(fakeollama.Reply{Status: http.StatusInternalServerError, Error: "model crashed"})
+	resp, failed := e.chat(token, convo.ID, "Как дела?")
+	expectStatus(t, resp, http.StatusInternalServerError)
+	if failed.Status != models.MessageFailed || failed.ReplyID == "" || failed.Error == "" {
+		t.Fatalf("unexpected failed chat response: %+v", failed)
+	}
+	msgs = e.listMessages(token, convo.ID)
+	if len(msgs) != 4 || msgs[3].ID != failed.ReplyID || msgs[3].Status != models.MessageFailed {
+		t.Fatalf("failed turn not visible: %+v", msgs)
+	}
+
+	// Повтор отвечает на ту же историю: без неудачного ответа и без дублей.
+	e.llm.Enqueue(fakeollama.Reply{Content: "Отлично"})
+	resp = e.do(http.MethodPost, "/api/chat/retry", token, map[string]any{"reply_id": atoi(t, failed.ReplyID)})
+	expectStatus(t, resp, http.StatusOK)
+	if retried := decode[models.ChatResponse](t, resp); retried.Status != models.MessageComplete || retried.ReplyID != failed.ReplyID || retried.Message != "Отлично" {
+		t.Fatalf("unexpected retry response: %+v", retried)
+	}
+	reqs := e.llm.Requests()
+	if got := reqs[len(reqs)-1].Messages; len(got) != 3 || got[2].Content != "Как дела?" {
+		t.Fatalf("unexpected history sent on retry: %+v", got)
+	}
+	if got := contents(e.listMessages(token, convo.ID)); got != "Здравствуй,Привет,Как дела?,Отлично" {
+		t.Fatalf("unexpected messages after retry: %s", got)
+	}
+	expectStatus(t, e.do(http.MethodPost, "/api/chat/retry", token, map[string]any{"reply_id": atoi(t, failed.ReplyID)}), http.StatusConflict)
+
+	// Неудачный ответ, за которым беседа продолжилась, не повторяется.
+	e.llm.Enqueue(fakeollama.Reply{Status: http.StatusInternalServerError, Error: "model crashed"}, fakeollama.Reply{Content: "ok"})
+	_, failed = e.chat(token, convo.ID, "Ещё вопрос")
+	resp, _ = e.chat(token, convo.ID, "И ещё")
+	expectStatus(t, resp, http.StatusOK)
+	expectStatus(t, e.do(http.MethodPost, "/api/chat/retry", token, map[string]any{"reply_id": atoi(t, failed.ReplyID)}), http.StatusConflict)
+
+	bob := e.register("bob@example.com", "bob")
+	expectStatus(t, e.do(http.MethodPost, "/api/chat/retry", bob, map[string]any{"reply_id": atoi(t, failed.ReplyID)}), http.StatusNotFound)
+	expectStatus(t, e.do(http.MethodPost, "/api/chat/retry", token, map[string]any{}), http.StatusBadRequest)
+}
@@ -0,0 +1,176 @@
+package share
+
+import (
+	"errors"
+	"log"
+	"math"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/pkg/req"
+	"me-ai/pkg/res"
+	"net/http"
+	"strconv"
+)
+
+type ShareHandlerDeps struct {
+	Service        *Service
+	UserRepository models.UserStore
+	// TrustProxyHeaders — как у входа: IP для ограничителя попыток.
+	TrustProxyHeaders bool
+}
+
+type ShareHandler struct {
+	ShareHandlerDeps
+}
+
+// NewShareHandler регистрирует управление ссылками на защищённом роутере, а
+// просмотр снимка — на публичном: его открывают без входа в приложение.
+func NewShareHandler(router, protected *http.ServeMux, deps ShareHandlerDeps) {
+	handler := &ShareHandler{ShareHandlerDeps: deps}
+	read := middleware.RequireScope(middleware.ScopeConversationsRead)
+	write := middleware.RequireScope(middleware.ScopeConversationsWrite)
+	protected.Handle("/api/shares", read(handler.List()))           // GET ?conversation_id=
+	protected.Handle("/api/shares/create", write(handler.Create())) // POST
+	protected.Handle("/api/shares/revoke", write(handler.Revoke())) // POST
+	router.Handle("/api/shared", middleware.CORS(handler.View()))   // GET ?token=, POST с паролем
+}
+
+func (handler *ShareHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		convoID := 0
+		if s := r.URL.Query().Get("conversation_id"); s != "" {
+			id, err := strconv.Atoi(s)
+			if err != nil || id <= 0 {
+				http.Error(w, "invalid conversation_id", http.StatusBadRequest)
+				return
+			}
+			convoID = id
+		}
+		shares, err := handler.Service.List(user.ID, convoID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res.Json(w, shares, 200)
+	}
+}
+
+func (handler *ShareHandler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[CreateRequest](&w, r)
+		if err != nil {
+			return
+		}
+		share, err := handler.Service.Create(CreateParams{
+			UserID:         user.ID,
+			ConversationID: body.ConversationID,
+			ExpiresAt:      body.ExpiresAt,
+			Password:       body.Password,
+		})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		log.Printf("Создана публичная ссылка: user_id=%d, conversation_id=%d, share_id=%d", user.ID, body.ConversationID, share.ID)
+		res.Json(w, share, http.StatusCreated)
+	}
+}
+
+func (handler *ShareHandler) Revoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[RevokeRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.Service.Revoke(user.ID, body.ID); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// View отдаёт снимок без авторизации. Ссылку с паролем открывают POST-запросом
+// с паролем в теле, чтобы он не попадал в адресную строку и логи.
+func (handler *ShareHandler) View() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var token, password string
+		switch r.Method {
+		case http.MethodGet:
+			token = r.URL.Query().Get("token")
+		case http.MethodPost:
+			body, err := req.HandleBody[ViewRequest](&w, r)
+			if err != nil {
+				return
+			}
+			token, password = body.Token, body.Password
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token == "" {
+			http.Error(w, "token is required", http.StatusBadRequest)
+			return
+		}
+		shared, err := handler.Service.View(token, password, req.ClientIP(r, handler.TrustProxyHeaders))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Robots-Tag", "noindex")
+		res.Json(w, shared, 200)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var throttled *ThrottledError
+	if errors.As(err, &throttled) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	switch err.Error() {
+	case ErrShareNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrShareExpired:
+		http.Error(w, err.Error(), http.StatusGone)
+	case ErrPasswordRequired:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case ErrWrongPassword:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case ErrInvalidExpiry, ErrInvalidPassword:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Ошибка публичной ссылки: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,37 @@
+package share
+
+import (
+	"me-ai/internal/models"
+	"time"
+)
+
+type CreateRequest struct {
+	ConversationID int        `json:"conversation_id" validate:"required"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	Password       string     `json:"password"`
+}
+
+type RevokeRequest struct {
+	ID int `json:"id" validate:"required"`
+}
+
+type ViewRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password"`
+}
+
+// ShareResponse — ссылка для владельца. Token и URL есть только в ответе на создание.
+type ShareResponse struct {
+	*models.Share
+	HasPassword bool   `json:"has_password"`
+	Token       string `json:"token,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+// SharedConversation — снимок беседы для публичного просмотра.
+type SharedConversation struct {
+	Title     string          `json:"title"`
+	SharedAt  time.Time       `json:"shared_at"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	Messages  []SharedMessage `json:"messages"`
+}
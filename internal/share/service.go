@@ -0,0 +1,211 @@
+// Package share — публичные ссылки на неизменяемый снимок беседы.
+package share
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"me-ai/internal/models"
+	"me-ai/pkg/throttle"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrShareNotFound    = "share not found"
+	ErrShareExpired     = "share has expired"
+	ErrPasswordRequired = "password required"
+	ErrWrongPassword    = "wrong password"
+	ErrInvalidExpiry    = "expires_at must be in the future"
+	ErrInvalidPassword  = "password must be at most 72 bytes"
+	ErrTooManyAttempts  = "too many password attempts"
+)
+
+// ThrottledError — пароль ссылки временно нельзя вводить из-за серии неудач.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return ErrTooManyAttempts
+}
+
+// maxPassword — bcrypt учитывает только первые 72 байта.
+const maxPassword = 72
+
+type ServiceDeps struct {
+	ShareRepository        models.ShareStore
+	ConversationRepository models.ConversationStore
+	MessageRepository      models.MessageStore
+	// AppURL — адрес фронтенда, на котором открывается ссылка.
+	AppURL string
+	// IPAttempts считает неверные пароли к ссылке с одного IP, LinkAttempts —
+	// со всех IP сразу, чтобы перебор с многих адресов тоже упирался в предел;
+	// nil — без ограничения.
+	IPAttempts   *throttle.Throttle
+	LinkAttempts *throttle.Throttle
+}
+
+type Service struct {
+	ServiceDeps
+}
+
+func NewService(deps ServiceDeps) *Service {
+	return &Service{ServiceDeps: deps}
+}
+
+// SharedMessage — сообщение снимка: без ID, автора и беседы.
+type SharedMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateParams struct {
+	UserID         int
+	ConversationID int
+	ExpiresAt      *time.Time
+	Password       string
+}
+
+// Create сохраняет снимок беседы и возвращает ссылку с токеном. Токен
+// показывается только здесь: в базе остаётся его хеш.
+func (s *Service) Create(p CreateParams) (*ShareResponse, error) {
+	if p.ExpiresAt != nil && !p.ExpiresAt.After(time.Now()) {
+		return nil, errors.New(ErrInvalidExpiry)
+	}
+	if len(p.Password) > maxPassword {
+		return nil, errors.New(ErrInvalidPassword)
+	}
+	convo, err := s.ConversationRepository.FindByID(p.ConversationID, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := s.MessageRepository.ListByConversation(convo.ID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make([]SharedMessage, 0, len(msgs))
+	for _, m := range msgs {
+		snapshot = append(snapshot, SharedMessage{Role: m.Role, Content: m.Content, CreatedAt: m.CreatedAt})
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	share := &models.Share{
+		UserID:         p.UserID,
+		ConversationID: convo.ID,
+		TokenHash:      hashToken(token),
+		Title:          convo.Title,
+		Snapshot:       data,
+		MessageCount:   len(snapshot),
+		ExpiresAt:      p.ExpiresAt,
+	}
+	if p.Password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(p.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		hash := string(hashed)
+		share.PasswordHash = &hash
+	}
+	if _, err := s.ShareRepository.Create(share); err != nil {
+		return nil, err
+	}
+	out := toResponse(share)
+	out.Token = token
+	out.URL = s.AppURL + "/shared?token=" + url.QueryEscape(token)
+	return &out, nil
+}
+
+func (s *Service) List(userID, conversationID int) ([]ShareResponse, error) {
+	shares, err := s.ShareRepository.ListByUser(userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ShareResponse, 0, len(shares))
+	for i := range shares {
+		out = append(out, toResponse(&shares[i]))
+	}
+	return out, nil
+}
+
+func (s *Service) Revoke(userID, id int) error {
+	err := s.ShareRepository.Revoke(id, userID)
+	if errors.Is(err, models.ErrNotFound) {
+		return errors.New(ErrShareNotFound)
+	}
+	return err
+}
+
+// View открывает снимок по токену и засчитывает просмотр. Отозванная ссылка
+// неотличима от несуществующей. Неверные пароли считаются по ссылке и IP:
+// утёкшая ссылка не даёт перебирать пароль.
+func (s *Service) View(token, password, ip string) (*SharedConversation, error) {
+	tokenHash := hashToken(token)
+	share, err := s.ShareRepository.FindByTokenHash(tokenHash)
+	if errors.Is(err, models.ErrNotFound) || (err == nil && share.RevokedAt != nil) {
+		return nil, errors.New(ErrShareNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if share.ExpiresAt != nil && !share.ExpiresAt.After(time.Now()) {
+		return nil, errors.New(ErrShareExpired)
+	}
+	if share.PasswordHash != nil {
+		if password == "" {
+			return nil, errors.New(ErrPasswordRequired)
+		}
+		ipKey := tokenHash + ":" + ip
+		if wait := max(s.IPAttempts.Wait(ipKey), s.LinkAttempts.Wait(tokenHash)); wait > 0 {
+			return nil, &ThrottledError{RetryAfter: wait}
+		}
+		if bcrypt.CompareHashAndPassword([]byte(*share.PasswordHash), []byte(password)) != nil {
+			s.IPAttempts.Fail(ipKey)
+			if lock, ok := s.LinkAttempts.Fail(tokenHash); ok {
+				log.Printf("Ссылка заблокирована после перебора пароля: share_id=%d, неудач=%d, до %s", share.ID, lock.Failures, lock.Until.Format(time.RFC3339))
+			}
+			return nil, errors.New(ErrWrongPassword)
+		}
+		// Счётчик ссылки не сбрасывается: иначе перебор обнулялся бы каждым
+		// просмотром владельца пароля.
+		s.IPAttempts.Reset(ipKey)
+	}
+	var msgs []SharedMessage
+	if err := json.Unmarshal(share.Snapshot, &msgs); err != nil {
+		return nil, err
+	}
+	if err := s.ShareRepository.RecordView(share.ID); err != nil {
+		return nil, err
+	}
+	return &SharedConversation{Title: share.Title, SharedAt: share.CreatedAt, ExpiresAt: share.ExpiresAt, Messages: msgs}, nil
+}
+
+func toResponse(share *models.Share) ShareResponse {
+	return ShareResponse{Share: share, HasPassword: share.PasswordHash != nil}
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
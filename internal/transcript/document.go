@@ -0,0 +1,82 @@
+package transcript
+
+import (
+	"me-ai/internal/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Schema — версия JSON-выгрузки одной беседы. Меняется только при
+// несовместимых изменениях полей.
+const Schema = "me-ai.conversation.v1"
+
+// Document — беседа для выгрузки в одном файле: метаданные и реплики. Из него
+// же собираются Markdown и HTML по шаблонам.
+type Document struct {
+	Schema       string            `json:"schema"`
+	ExportedAt   time.Time         `json:"exported_at"`
+	Conversation Metadata          `json:"conversation"`
+	Messages     []DocumentMessage `json:"messages"`
+}
+
+type Metadata struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Model     string    `json:"model"`
+	Persona   string    `json:"persona"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type DocumentMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Reasoning string    `json:"reasoning,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Options — что, кроме диалога пользователя с ассистентом, попадает в выгрузку.
+type Options struct {
+	// Reasoning — рассуждения модели из блоков <think>; без него они вырезаются.
+	Reasoning bool
+	// Tools — служебные реплики: системные сообщения и вызовы инструментов.
+	Tools bool
+}
+
+var thinkBlock = regexp.MustCompile(`(?s)<think>(.*?)</think>`)
+
+func NewDocument(convo models.Conversation, msgs []models.Message, meta Metadata, opts Options, now time.Time) Document {
+	meta.ID = convo.ID
+	meta.Title = title(Conversation{ID: convo.ID, Title: convo.Title})
+	meta.CreatedAt = convo.CreatedAt
+	meta.UpdatedAt = convo.UpdatedAt
+	doc := Document{Schema: Schema, ExportedAt: now.UTC(), Conversation: meta, Messages: make([]DocumentMessage, 0, len(msgs))}
+	for _, m := range msgs {
+		if m.Role != "user" && m.Role != "assistant" && !opts.Tools {
+			continue
+		}
+		content, reasoning := splitReasoning(m.Content)
+		dm := DocumentMessage{Role: m.Role, Content: content, CreatedAt: m.CreatedAt}
+		if opts.Reasoning {
+			dm.Reasoning = reasoning
+		}
+		doc.Messages = append(doc.Messages, dm)
+	}
+	return doc
+}
+
+// splitReasoning отделяет рассуждения модели от ответа.
+func splitReasoning(content string) (answer, reasoning string) {
+	blocks := thinkBlock.FindAllStringSubmatch(content, -1)
+	if len(blocks) == 0 {
+		return content, ""
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		if t := strings.TrimSpace(b[1]); t != "" {
+			parts = append(parts, t)
+		}
+	}
+	return strings.TrimSpace(thinkBlock.ReplaceAllString(content, "")), strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,111 @@
+package transcript
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+)
+
+var ErrUnknownFormat = "format must be md, html or json"
+
+// Имена шаблонов: встроенных и тех, что переопределяются в каталоге шаблонов.
+const (
+	markdownTemplate = "conversation.md.tmpl"
+	htmlTemplate     = "conversation.html.tmpl"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Renderer собирает Document в файл выгрузки. Markdown и HTML строятся по
+// шаблонам, JSON — всегда по схеме Schema.
+type Renderer struct {
+	markdown *texttemplate.Template
+	html     *htmltemplate.Template
+}
+
+var funcs = map[string]any{
+	"role": roleLabel,
+	"time": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"trim": func(s string) string { return strings.TrimRight(s, "\n") },
+}
+
+// NewRenderer берёт шаблоны из dir, если они там есть, остальные — встроенные.
+// Пустой dir — только встроенные.
+func NewRenderer(dir string) (*Renderer, error) {
+	md, err := readTemplate(dir, markdownTemplate)
+	if err != nil {
+		return nil, err
+	}
+	html, err := readTemplate(dir, htmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+	r := &Renderer{}
+	if r.markdown, err = texttemplate.New(markdownTemplate).Funcs(funcs).Parse(md); err != nil {
+		return nil, err
+	}
+	if r.html, err = htmltemplate.New(htmlTemplate).Funcs(funcs).Parse(html); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func readTemplate(dir, name string) (string, error) {
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	data, err := defaultTemplates.ReadFile("templates/" + name)
+	return string(data), err
+}
+
+func (r *Renderer) Render(w io.Writer, doc Document, format string) error {
+	switch format {
+	case FormatMarkdown:
+		return r.markdown.Execute(w, doc)
+	case FormatHTML:
+		return r.html.Execute(w, doc)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	}
+	return errors.New(ErrUnknownFormat)
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	}
+	return "application/json"
+}
+
+func roleLabel(role string) string {
+	if label, ok := roleLabels[role]; ok {
+		return label
+	}
+	return role
+}
@@ -0,0 +1,84 @@
+// Package transcript — переносимое представление беседы для выгрузки и импорта:
+// JSON без внутренних полей, читаемый Markdown и HTML по шаблонам.
+package transcript
+
+import (
+	"fmt"
+	"me-ai/internal/models"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Conversation struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Messages  []Message `json:"messages"`
+}
+
+type Message struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func FromModels(convo models.Conversation, msgs []models.Message) Conversation {
+	c := Conversation{
+		ID:        convo.ID,
+		Title:     convo.Title,
+		CreatedAt: convo.CreatedAt,
+		UpdatedAt: convo.UpdatedAt,
+		Messages:  make([]Message, 0, len(msgs)),
+	}
+	for _, m := range msgs {
+		c.Messages = append(c.Messages, Message{Role: m.Role, Content: m.Content, CreatedAt: m.CreatedAt})
+	}
+	return c
+}
+
+var roleLabels = map[string]string{
+	"user":      "Пользователь",
+	"assistant": "Ассистент",
+	"system":    "Система",
+	"tool":      "Инструмент",
+}
+
+// FileName — безопасное имя файла вида "12-nazvanie.md": id гарантирует
+// уникальность, остаток нужен только человеку.
+func FileName(c Conversation, ext string) string {
+	slug := Slug(c.Title)
+	if slug == "" {
+		return fmt.Sprintf("%d.%s", c.ID, ext)
+	}
+	return fmt.Sprintf("%d-%s.%s", c.ID, slug, ext)
+}
+
+// Slug оставляет буквы и цифры любого алфавита, остальное сворачивает в дефисы.
+func Slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+			if b.Len() >= 60 {
+				break
+			}
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+func title(c Conversation) string {
+	if t := strings.TrimSpace(c.Title); t != "" {
+		return t
+	}
+	return fmt.Sprintf("Беседа %d", c.ID)
+}
@@ -0,0 +1,124 @@
+package trash
+
+import (
+	"errors"
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/pkg/req"
+	"me-ai/pkg/res"
+	"net/http"
+)
+
+type TrashHandlerDeps struct {
+	Service        *Service
+	UserRepository models.UserStore
+}
+
+type TrashHandler struct {
+	TrashHandlerDeps
+}
+
+func NewTrashHandler(router *http.ServeMux, deps TrashHandlerDeps) {
+	handler := &TrashHandler{TrashHandlerDeps: deps}
+	read := middleware.RequireScope(middleware.ScopeConversationsRead)
+	write := middleware.RequireScope(middleware.ScopeConversationsWrite)
+	router.Handle("/api/trash", read(handler.List()))             // GET
+	router.Handle("/api/trash/restore", write(handler.Restore())) // POST
+	router.Handle("/api/trash/delete", write(handler.Delete()))   // POST
+	router.Handle("/api/trash/empty", write(handler.Empty()))     // POST
+}
+
+// post проверяет метод и возвращает текущего пользователя.
+func (handler *TrashHandler) post(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	return middleware.CurrentUser(w, r, handler.UserRepository)
+}
+
+func (handler *TrashHandler) List() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user, ok := middleware.CurrentUser(w, r, handler.UserRepository)
+		if !ok {
+			return
+		}
+		trash, err := handler.Service.List(user.ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res.Json(w, trash, 200)
+	}
+}
+
+// Restore возвращает беседу или сообщение из корзины. 404 — элемента нет в
+// корзине или сообщение лежит в удалённой беседе.
+func (handler *TrashHandler) Restore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.post(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[ItemRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.Service.Restore(user.ID, body.Type, body.ID); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Delete окончательно удаляет элемент корзины.
+func (handler *TrashHandler) Delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.post(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[ItemRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.Service.Delete(user.ID, body.Type, body.ID); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *TrashHandler) Empty() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.post(w, r)
+		if !ok {
+			return
+		}
+		out, err := handler.Service.Empty(user.ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res.Json(w, out, 200)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		http.Error(w, "Item not found in trash", http.StatusNotFound)
+	case err.Error() == ErrUnknownType:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Ошибка корзины: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
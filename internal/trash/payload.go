@@ -0,0 +1,34 @@
+package trash
+
+import (
+	"me-ai/internal/models"
+	"time"
+)
+
+// TrashedConversation — беседа в корзине; PurgeAt — когда она будет удалена окончательно.
+type TrashedConversation struct {
+	models.Conversation
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+type TrashedMessage struct {
+	models.Message
+	ConversationTitle string    `json:"conversation_title"`
+	PurgeAt           time.Time `json:"purge_at"`
+}
+
+type TrashResponse struct {
+	Conversations []TrashedConversation `json:"conversations"`
+	Messages      []TrashedMessage      `json:"messages"`
+}
+
+type ItemRequest struct {
+	Type string `json:"type" validate:"required"`
+	ID   int    `json:"id" validate:"required"`
+}
+
+// EmptyResponse — сколько бесед и сообщений удалено окончательно.
+type EmptyResponse struct {
+	Conversations int `json:"conversations"`
+	Messages      int `json:"messages"`
+}
@@ -0,0 +1,145 @@
+// Package trash — корзина удалённых бесед и сообщений: просмотр, восстановление
+// и окончательное удаление по истечении срока хранения.
+package trash
+
+import (
+	"context"
+	"errors"
+	"log"
+	"me-ai/configs"
+	"me-ai/internal/models"
+	"time"
+)
+
+const (
+	TypeConversation = "conversation"
+	TypeMessage      = "message"
+)
+
+var ErrUnknownType = "type must be conversation or message"
+
+type ServiceDeps struct {
+	ConversationRepository models.ConversationStore
+	MessageRepository      models.MessageStore
+	Config                 configs.TrashConfig
+}
+
+type Service struct {
+	ServiceDeps
+}
+
+func NewService(deps ServiceDeps) *Service {
+	return &Service{ServiceDeps: deps}
+}
+
+// Run периодически окончательно удаляет просроченное содержимое корзины, пока
+// не отменён ctx.
+func (s *Service) Run(ctx context.Context) {
+	if s.Config.PurgeInterval <= 0 {
+		return
+	}
+	for {
+		convos, msgs, err := s.PurgeExpired(time.Now())
+		if err != nil {
+			log.Printf("Ошибка очистки корзины: %v", err)
+		} else if convos > 0 || msgs > 0 {
+			log.Printf("Корзина очищена: бесед %d, сообщений %d", convos, msgs)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.Config.PurgeInterval):
+		}
+	}
+}
+
+// PurgeExpired окончательно удаляет то, что пролежало в корзине дольше Retention
+// к моменту now.
+func (s *Service) PurgeExpired(now time.Time) (convos, msgs int, err error) {
+	before := now.Add(-s.Config.Retention)
+	if convos, err = s.ConversationRepository.PurgeDeleted(0, before); err != nil {
+		return 0, 0, err
+	}
+	msgs, err = s.MessageRepository.PurgeDeleted(0, before)
+	return convos, msgs, err
+}
+
+// List — содержимое корзины пользователя с датами окончательного удаления.
+func (s *Service) List(userID int) (*TrashResponse, error) {
+	convos, err := s.ConversationRepository.ListDeleted(userID)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := s.MessageRepository.ListDeleted(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := &TrashResponse{
+		Conversations: make([]TrashedConversation, 0, len(convos)),
+		Messages:      make([]TrashedMessage, 0, len(msgs)),
+	}
+	for _, c := range convos {
+		out.Conversations = append(out.Conversations, TrashedConversation{Conversation: c, PurgeAt: s.purgeAt(c.DeletedAt)})
+	}
+	titles := map[int]string{}
+	for _, m := range msgs {
+		title, ok := titles[m.ConversationID]
+		if !ok {
+			convo, err := s.ConversationRepository.FindByID(m.ConversationID, userID)
+			if err != nil {
+				return nil, err
+			}
+			title = convo.Title
+			titles[m.ConversationID] = title
+		}
+		out.Messages = append(out.Messages, TrashedMessage{Message: m, ConversationTitle: title, PurgeAt: s.purgeAt(m.DeletedAt)})
+	}
+	return out, nil
+}
+
+func (s *Service) purgeAt(deletedAt *time.Time) time.Time {
+	if deletedAt == nil {
+		return time.Time{}
+	}
+	return deletedAt.Add(s.Config.Retention)
+}
+
+// Restore возвращает элемент из корзины. Сообщение из беседы в корзине
+// восстанавливается только вместе с беседой.
+func (s *Service) Restore(userID int, itemType string, id int) error {
+	switch itemType {
+	case TypeConversation:
+		return s.ConversationRepository.Restore(id, userID)
+	case TypeMessage:
+		return s.MessageRepository.Restore(id, userID)
+	}
+	return errors.New(ErrUnknownType)
+}
+
+// Delete окончательно удаляет элемент, не дожидаясь срока хранения.
+func (s *Service) Delete(userID int, itemType string, id int) error {
+	switch itemType {
+	case TypeConversation:
+		return s.ConversationRepository.Purge(id, userID)
+	case TypeMessage:
+		return s.MessageRepository.Purge(id, userID)
+	}
+	return errors.New(ErrUnknownType)
+}
+
+// Empty окончательно удаляет всё содержимое корзины пользователя.
+func (s *Service) Empty(userID int) (*EmptyResponse, error) {
+	// Граница в будущем захватывает всё, что уже в корзине, независимо от
+	// расхождения часов приложения и базы.
+	before := time.Now().AddDate(1, 0, 0)
+	convos, err := s.ConversationRepository.PurgeDeleted(userID, before)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := s.MessageRepository.PurgeDeleted(userID, before)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Корзина очищена пользователем: user_id=%d, бесед %d, сообщений %d", userID, convos, msgs)
+	return &EmptyResponse{Conversations: convos, Messages: msgs}, nil
+}
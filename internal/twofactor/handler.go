@@ -0,0 +1,125 @@
+package twofactor
+
+import (
+	"log"
+	"me-ai/internal/middleware"
+	"me-ai/internal/models"
+	"me-ai/pkg/req"
+	"me-ai/pkg/res"
+	"net/http"
+)
+
+type TwoFactorHandlerDeps struct {
+	Service        *Service
+	UserRepository models.UserStore
+}
+
+type TwoFactorHandler struct {
+	Service        *Service
+	UserRepository models.UserStore
+}
+
+// NewTwoFactorHandler регистрирует управление 2FA на защищённом роутере.
+// Второй шаг входа (/api/auth/2fa/verify) публичный и живёт в пакете auth.
+func NewTwoFactorHandler(router *http.ServeMux, deps TwoFactorHandlerDeps) {
+	handler := &TwoFactorHandler{
+		Service:        deps.Service,
+		UserRepository: deps.UserRepository,
+	}
+	router.Handle("/api/auth/2fa/enroll", middleware.SessionOnly(handler.Enroll()))                // POST
+	router.Handle("/api/auth/2fa/confirm", middleware.SessionOnly(handler.Confirm()))              // POST
+	router.Handle("/api/auth/2fa/disable", middleware.SessionOnly(handler.Disable()))              // POST
+	router.Handle("/api/auth/2fa/recovery-codes", middleware.SessionOnly(handler.RecoveryCodes())) // POST
+}
+
+func (handler *TwoFactorHandler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	return middleware.CurrentUser(w, r, handler.UserRepository)
+}
+
+func (handler *TwoFactorHandler) Enroll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r)
+		if !ok {
+			return
+		}
+		secret, uri, err := handler.Service.Enroll(user)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res.Json(w, EnrollResponse{Secret: secret, OTPAuthURI: uri}, 200)
+	}
+}
+
+func (handler *TwoFactorHandler) Confirm() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[CodeRequest](&w, r)
+		if err != nil {
+			return
+		}
+		codes, err := handler.Service.Confirm(user, body.Code)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res.Json(w, RecoveryCodesResponse{RecoveryCodes: codes}, 200)
+	}
+}
+
+func (handler *TwoFactorHandler) Disable() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[CodeRequest](&w, r)
+		if err != nil {
+			return
+		}
+		if err := handler.Service.Disable(user, body.Code); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (handler *TwoFactorHandler) RecoveryCodes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := handler.currentUser(w, r)
+		if !ok {
+			return
+		}
+		body, err := req.HandleBody[CodeRequest](&w, r)
+		if err != nil {
+			return
+		}
+		codes, err := handler.Service.RegenerateRecoveryCodes(user, body.Code)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res.Json(w, RecoveryCodesResponse{RecoveryCodes: codes}, 200)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case ErrAlreadyEnabled:
+		http.Error(w, err.Error(), http.StatusConflict)
+	// Неверный код — 400, а не 401: сессия действительна, и клиент не должен обновлять токен.
+	case ErrNotEnrolled, ErrNotEnabled, ErrInvalidCode:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("Ошибка 2FA: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
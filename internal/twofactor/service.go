@@ -0,0 +1,157 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"me-ai/internal/models"
+	"me-ai/pkg/totp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAlreadyEnabled = "two-factor authentication is already enabled"
+	ErrNotEnrolled    = "two-factor enrollment not started"
+	ErrNotEnabled     = "two-factor authentication is not enabled"
+	ErrInvalidCode    = "invalid two-factor code"
+)
+
+const (
+	recoveryCodeCount = 10
+	// skew — допуск в интервалах на расхождение часов телефона и сервера.
+	skew = 1
+)
+
+type Service struct {
+	UserRepository         models.UserStore
+	RecoveryCodeRepository models.RecoveryCodeStore
+	// Issuer — название сервиса в приложении-аутентификаторе.
+	Issuer string
+	Now    func() time.Time
+}
+
+func NewService(userRepository models.UserStore, recoveryCodeRepository models.RecoveryCodeStore, issuer string) *Service {
+	return &Service{
+		UserRepository:         userRepository,
+		RecoveryCodeRepository: recoveryCodeRepository,
+		Issuer:                 issuer,
+		Now:                    time.Now,
+	}
+}
+
+// Enroll начинает подключение 2FA: создаёт новый секрет, который заработает
+// только после Confirm. Повторный вызов заменяет неподтверждённый секрет.
+func (s *Service) Enroll(user *models.User) (secret, uri string, err error) {
+	if user.TwoFactorEnabled() {
+		return "", "", errors.New(ErrAlreadyEnabled)
+	}
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.UserRepository.SetTOTPSecret(user.ID, secret); err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(s.Issuer, user.Email, secret), nil
+}
+
+// Confirm включает 2FA по первому коду из приложения и возвращает коды
+// восстановления. Они показываются один раз, в базе остаются только хеши.
+func (s *Service) Confirm(user *models.User, code string) ([]string, error) {
+	if user.TwoFactorEnabled() {
+		return nil, errors.New(ErrAlreadyEnabled)
+	}
+	if user.TOTPSecret == nil {
+		return nil, errors.New(ErrNotEnrolled)
+	}
+	if err := s.checkTOTP(user, code); err != nil {
+		return nil, err
+	}
+	if err := s.UserRepository.EnableTOTP(user.ID); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(user.ID)
+}
+
+// Verify принимает код из приложения или один из кодов восстановления.
+func (s *Service) Verify(user *models.User, code string) error {
+	if !user.TwoFactorEnabled() {
+		return errors.New(ErrNotEnabled)
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return s.checkTOTP(user, code)
+	}
+	ok, err := s.RecoveryCodeRepository.Use(user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New(ErrInvalidCode)
+	}
+	return nil
+}
+
+func (s *Service) Disable(user *models.User, code string) error {
+	if err := s.Verify(user, code); err != nil {
+		return err
+	}
+	if err := s.UserRepository.DisableTOTP(user.ID); err != nil {
+		return err
+	}
+	return s.RecoveryCodeRepository.Replace(user.ID, nil)
+}
+
+// RegenerateRecoveryCodes выдаёт новый набор кодов, прежние перестают действовать.
+func (s *Service) RegenerateRecoveryCodes(user *models.User, code string) ([]string, error) {
+	if err := s.Verify(user, code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(user.ID)
+}
+
+func (s *Service) checkTOTP(user *models.User, code string) error {
+	if user.TOTPSecret == nil {
+		return errors.New(ErrNotEnrolled)
+	}
+	step, ok := totp.Validate(*user.TOTPSecret, code, s.Now(), skew)
+	if !ok {
+		return errors.New(ErrInvalidCode)
+	}
+	// Один и тот же код (и коды более ранних интервалов) принимается только один раз.
+	fresh, err := s.UserRepository.UseTOTPStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return errors.New(ErrInvalidCode)
+	}
+	return nil
+}
+
+func (s *Service) replaceRecoveryCodes(userID int) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(b)
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	if err := s.RecoveryCodeRepository.Replace(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode не зависит от регистра и дефиса: коды часто вводят вручную.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,17 @@
+-- Фоновые задания на выгрузку данных пользователя в ZIP.
+-- Ссылка на скачивание одноразово выдаётся клиенту, в базе только SHA-256 хеш токена.
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    file_path TEXT NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    token_hash VARCHAR(64),
+    error TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_user_id ON export_jobs(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_export_jobs_token_hash ON export_jobs(token_hash);
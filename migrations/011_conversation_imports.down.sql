@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_conversations_import;
+
+ALTER TABLE conversations DROP COLUMN IF EXISTS import_key;
+ALTER TABLE conversations DROP COLUMN IF EXISTS import_source;
@@ -0,0 +1,6 @@
+-- Импорт бесед из других чатов: источник и ключ исходной беседы защищают от повторного импорта.
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS import_source VARCHAR(32);
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS import_key VARCHAR(255);
+
+-- NULL не конфликтуют между собой, поэтому обычные беседы индекс не затрагивает.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_import ON conversations(user_id, import_source, import_key);
@@ -0,0 +1,10 @@
+-- Полнотекстовый поиск: русский и английский словари одновременно, чтобы находились
+-- обе формы слов в смешанных беседах. Колонки вычисляемые и обновляются сами.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('russian', content) || to_tsvector('english', content)) STORED;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (setweight(to_tsvector('russian', title) || to_tsvector('english', title), 'A')) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_conversations_search ON conversations USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_conversations_user_id ON conversations(user_id);
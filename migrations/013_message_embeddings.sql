@@ -0,0 +1,13 @@
+-- Векторы сообщений для семантического поиска. Одна строка на сообщение: при смене
+-- модели вектор пересчитывается и перезаписывается. Вектор — float32 little-endian;
+-- dims = 0 отмечает сообщение, которое провайдер не смог обработать.
+CREATE TABLE IF NOT EXISTS message_embeddings (
+    message_id INTEGER PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    model VARCHAR(255) NOT NULL,
+    dims INTEGER NOT NULL,
+    vector BYTEA NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_embeddings_model ON message_embeddings(model);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages(conversation_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_messages_conversation_created;
+DROP INDEX IF EXISTS idx_conversations_user_updated;
@@ -0,0 +1,3 @@
+-- Индексы под keyset-пагинацию: (время, id) в том же порядке, что и курсор.
+CREATE INDEX IF NOT EXISTS idx_conversations_user_updated ON conversations(user_id, updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_created ON messages(conversation_id, created_at, id);
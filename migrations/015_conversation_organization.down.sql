@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS conversation_tags;
+
+ALTER TABLE conversations DROP COLUMN IF EXISTS folder_id;
+ALTER TABLE conversations DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE conversations DROP COLUMN IF EXISTS pinned_at;
+
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS folders;
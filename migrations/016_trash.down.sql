@@ -0,0 +1,7 @@
+-- Записи, лежавшие в корзине, после отката станут видимыми: перед откатом
+-- очистите корзину, если они не нужны.
+DROP INDEX IF EXISTS idx_messages_deleted_at;
+DROP INDEX IF EXISTS idx_conversations_deleted_at;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE conversations DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,7 @@
+-- Корзина: удалённые беседы и сообщения помечаются deleted_at и окончательно
+-- удаляются фоновой очисткой после срока хранения.
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_conversations_deleted_at ON conversations(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,20 @@
+-- Публичные ссылки на снимок беседы. Снимок (название и сообщения без ID и
+-- авторов) сохраняется при создании ссылки и дальше не меняется. В базе только
+-- SHA-256 хеш токена ссылки.
+CREATE TABLE IF NOT EXISTS conversation_shares (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    title VARCHAR(255) NOT NULL,
+    snapshot JSONB NOT NULL,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    password_hash TEXT,
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    views INTEGER NOT NULL DEFAULT 0,
+    last_viewed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_shares_user_id ON conversation_shares(user_id, conversation_id);
@@ -0,0 +1,5 @@
+-- Незавершённые ответы без статуса выглядели бы как настоящие пустые ответы модели.
+DELETE FROM messages WHERE status <> 'complete';
+
+DROP INDEX IF EXISTS idx_messages_unfinished;
+ALTER TABLE messages DROP COLUMN IF EXISTS status;
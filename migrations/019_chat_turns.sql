@@ -0,0 +1,5 @@
+-- Статус ответа модели: реплика сохраняется до запроса к модели с пустым
+-- ответом в pending, который затем становится complete или failed.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'complete';
+
+CREATE INDEX IF NOT EXISTS idx_messages_unfinished ON messages(conversation_id) WHERE status <> 'complete';
@@ -0,0 +1,27 @@
+// Package migrations содержит SQL-миграции схемы. Файлы встраиваются в бинарник,
+// применяет их pkg/migrate (команда cmd/migrate).
+//
+// Миграция NNN_name.sql и её откат NNN_name.down.sql; NNN — номер версии.
+// Миграции PostgreSQL лежат в корне, SQLite — в sqlite/ со своей нумерацией.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"me-ai/pkg/db"
+)
+
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
+
+// For возвращает миграции для драйвера db.Postgres или db.SQLite.
+func For(driver string) (fs.FS, error) {
+	switch driver {
+	case db.Postgres:
+		return FS, nil
+	case db.SQLite:
+		return fs.Sub(FS, "sqlite")
+	}
+	return nil, fmt.Errorf("no migrations for driver %q", driver)
+}
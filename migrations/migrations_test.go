@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"me-ai/pkg/db"
+	"me-ai/pkg/migrate"
+	"strings"
+	"testing"
+)
+
+// Каждую миграцию должно быть можно откатить: иначе down и redo остановятся на ней.
+func TestEmbeddedMigrations(t *testing.T) {
+	for _, driver := range []string{db.Postgres, db.SQLite} {
+		fsys, err := For(driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		migrations, err := migrate.Load(fsys)
+		if err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("%s: no migrations embedded", driver)
+		}
+		for i, m := range migrations {
+			if m.Version != int64(i+1) {
+				t.Errorf("%s: %s: expected version %d", driver, m, i+1)
+			}
+			if strings.TrimSpace(m.Down) == "" {
+				t.Errorf("%s: %s: no down step", driver, m)
+			}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS message_revisions;
+DROP TABLE IF EXISTS conversation_shares;
+DROP TABLE IF EXISTS conversation_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS message_embeddings;
+DROP TABLE IF EXISTS export_jobs;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS login_lockouts;
+DROP TABLE IF EXISTS user_tokens;
+DROP TABLE IF EXISTS admin_audit_log;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS conversations_fts;
+DROP TABLE IF EXISTS messages_fts;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS conversations;
+DROP TABLE IF EXISTS folders;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+)
+
+// Время в SQLite хранится текстом в UTC с миллисекундами фиксированной ширины:
+// так строки сравниваются и сортируются как время. В том же виде его пишут
+// DEFAULT в миграциях (strftime('%Y-%m-%d %H:%M:%f', 'now')) и функция NOW().
+const sqliteTimeLayout = "2006-01-02 15:04:05.000"
+
+// sqliteOptions: внешние ключи (для ON DELETE CASCADE), ожидание занятой базы
+// вместо ошибки, WAL для чтения во время записи и BEGIN IMMEDIATE, чтобы
+// транзакция сразу брала блокировку на запись и не падала при её повышении.
+var sqliteOptions = []string{
+	"_pragma=foreign_keys(1)",
+	"_pragma=busy_timeout(5000)",
+	"_pragma=journal_mode(WAL)",
+	"_txlock=immediate",
+}
+
+func init() {
+	// Запросы пишутся под PostgreSQL с $1, $2…; SQLite понимает их так же.
+	sqlx.BindDriver(SQLite, sqlx.DOLLAR)
+	sqlite.MustRegisterScalarFunction("now", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeLayout), nil
+	})
+}
+
+func openSQLite(path string) (*sqlx.DB, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	// Зарегистрированный драйвер, а не новый sqlite.Driver: функции вроде NOW()
+	// хранятся в нём.
+	lite, err := sql.Open(SQLite, "")
+	if err != nil {
+		return nil, err
+	}
+	connector := &sqliteConnector{dsn: path + sep + strings.Join(sqliteOptions, "&"), driver: lite.Driver()}
+	db := sqlx.NewDb(sql.OpenDB(connector), SQLite)
+	if path == ":memory:" || strings.Contains(path, "mode=memory") {
+		// У каждого соединения была бы своя пустая база.
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to db: %w", err)
+	}
+	return db, nil
+}
+
+type sqliteConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn}, nil
+}
+
+func (c *sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// sqliteConn приводит время к sqliteTimeLayout в обе стороны. Сам драйвер
+// пишет time.Time в зоне значения, и строки с разными смещениями уже нельзя
+// сравнивать, а время из выражений (MAX(created_at), COALESCE…) отдаёт строкой.
+type sqliteConn struct {
+	driver.Conn
+}
+
+func (c *sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := v.(time.Time); ok {
+		v = t.UTC().Format(sqliteTimeLayout)
+	}
+	nv.Value = v
+	return nil
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteStmt{stmt}, nil
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteRows{rows}, nil
+}
+
+func (c *sqliteConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c *sqliteConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c *sqliteConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+type sqliteStmt struct {
+	driver.Stmt
+}
+
+func (s *sqliteStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+}
+
+func (s *sqliteStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteRows{rows}, nil
+}
+
+type sqliteRows struct {
+	driver.Rows
+}
+
+// Next превращает обратно во время строки sqliteTimeLayout из колонок без
+// объявленного типа; колонки TIMESTAMP драйвер разбирает сам.
+func (r *sqliteRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	typed, _ := r.Rows.(driver.RowsColumnTypeDatabaseTypeName)
+	for i, v := range dest {
+		s, ok := v.(string)
+		if !ok || len(s) != len(sqliteTimeLayout) || typed == nil || typed.ColumnTypeDatabaseTypeName(i) != "" {
+			continue
+		}
+		if t, err := time.Parse(sqliteTimeLayout, s); err == nil {
+			dest[i] = t
+		}
+	}
+	return nil
+}
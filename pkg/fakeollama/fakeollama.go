@@ -0,0 +1,370 @@
+// Package fakeollama реализует детерминированный фейковый Ollama API
+// для тестов и локальной разработки без запущенной модели.
+package fakeollama
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultModel        = "model9"
+	DefaultEmbeddingDim = 16
+)
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ChatRequest struct {
+	Model    string    `json:"model"`
+	Stream   *bool     `json:"stream,omitempty"`
+	Messages []Message `json:"messages"`
+	System   string    `json:"system,omitempty"`
+}
+
+type EmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+// EmbedRequest — тело /api/embed: input может быть строкой или массивом строк.
+type EmbedRequest struct {
+	Model string          `json:"model"`
+	Input json.RawMessage `json:"input"`
+}
+
+// Reply описывает один заскриптованный ответ сервера.
+// Нулевое значение означает ответ по умолчанию (эхо последнего сообщения).
+type Reply struct {
+	// Content — полный текст ответа. Если задан Chunks, Content игнорируется.
+	Content string `json:"content,omitempty"`
+	// Chunks — части ответа для потокового режима.
+	Chunks []string `json:"chunks,omitempty"`
+	// Status и Error — HTTP-ошибка вместо ответа.
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// FailAfter > 0 обрывает поток после указанного числа chunk'ов.
+	FailAfter int `json:"fail_after,omitempty"`
+	// Delay — задержка перед ответом, ChunkDelay — между chunk'ами.
+	Delay      Duration `json:"delay,omitempty"`
+	ChunkDelay Duration `json:"chunk_delay,omitempty"`
+}
+
+// Duration принимает в JSON как строки вида "150ms", так и наносекунды.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(v)
+		return nil
+	}
+	var n int64
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*d = Duration(n)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+type Server struct {
+	// Models возвращается из /api/tags.
+	Models []string
+	// EmbeddingDim — размерность векторов /api/embeddings и /api/embed.
+	EmbeddingDim int
+	// Latency добавляется к каждому запросу; отменённый клиентом запрос не ждёт.
+	Latency time.Duration
+	// Responder, если задан, формирует ответ, когда очередь скрипта пуста.
+	Responder func(ChatRequest) Reply
+
+	mu       sync.Mutex
+	script   []Reply
+	requests []ChatRequest
+}
+
+func New() *Server {
+	return &Server{
+		Models:       []string{DefaultModel},
+		EmbeddingDim: DefaultEmbeddingDim,
+	}
+}
+
+// Enqueue добавляет ответы, которые будут выданы по очереди на /api/chat.
+func (s *Server) Enqueue(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, replies...)
+}
+
+// Requests возвращает копию всех полученных запросов /api/chat.
+func (s *Server) Requests() []ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ChatRequest, len(s.requests))
+	copy(out, s.requests)
+	return out
+}
+
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = nil
+	s.requests = nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Latency > 0 {
+		select {
+		case <-time.After(s.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	switch r.URL.Path {
+	case "/api/chat":
+		s.handleChat(w, r)
+	case "/api/tags":
+		s.handleTags(w, r)
+	case "/api/embeddings":
+		s.handleEmbeddings(w, r)
+	case "/api/embed":
+		s.handleEmbed(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) next(req ChatRequest) Reply {
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	if len(s.script) > 0 {
+		reply := s.script[0]
+		s.script = s.script[1:]
+		s.mu.Unlock()
+		return reply
+	}
+	s.mu.Unlock()
+	if s.Responder != nil {
+		return s.Responder(req)
+	}
+	return Echo(req)
+}
+
+// Echo — ответ по умолчанию: повторяет последнее сообщение пользователя.
+func Echo(req ChatRequest) Reply {
+	last := ""
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			last = req.Messages[i].Content
+			break
+		}
+	}
+	return Reply{Content: "echo: " + last}
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if req.Model == "" {
+		req.Model = DefaultModel
+	}
+	reply := s.next(req)
+	if reply.Delay > 0 {
+		select {
+		case <-time.After(time.Duration(reply.Delay)):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if reply.Status != 0 {
+		writeError(w, reply.Status, reply.Error)
+		return
+	}
+
+	// Как и настоящий Ollama, по умолчанию отвечаем потоком.
+	stream := req.Stream == nil || *req.Stream
+	if !stream {
+		if reply.FailAfter > 0 {
+			panic(http.ErrAbortHandler)
+		}
+		writeJSON(w, http.StatusOK, chatChunk(req.Model, reply.text(), true))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for i, part := range reply.chunks() {
+		if reply.FailAfter > 0 && i >= reply.FailAfter {
+			// Обрываем соединение посреди потока.
+			panic(http.ErrAbortHandler)
+		}
+		if i > 0 && reply.ChunkDelay > 0 {
+			select {
+			case <-time.After(time.Duration(reply.ChunkDelay)):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		enc.Encode(chatChunk(req.Model, part, false))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if reply.FailAfter > 0 {
+		panic(http.ErrAbortHandler)
+	}
+	enc.Encode(chatChunk(req.Model, "", true))
+}
+
+func (r Reply) text() string {
+	if len(r.Chunks) > 0 {
+		return strings.Join(r.Chunks, "")
+	}
+	return r.Content
+}
+
+func (r Reply) chunks() []string {
+	if len(r.Chunks) > 0 {
+		return r.Chunks
+	}
+	// Делим ответ по словам, сохраняя пробелы, чтобы склейка давала исходный текст.
+	var parts []string
+	for _, word := range strings.SplitAfter(r.Content, " ") {
+		if word != "" {
+			parts = append(parts, word)
+		}
+	}
+	return parts
+}
+
+func chatChunk(model, content string, done bool) map[string]any {
+	chunk := map[string]any{
+		"model":      model,
+		"created_at": time.Unix(0, 0).UTC().Format(time.RFC3339),
+		"message":    Message{Role: "assistant", Content: content},
+		"done":       done,
+	}
+	if done {
+		chunk["done_reason"] = "stop"
+	}
+	return chunk
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	models := make([]map[string]any, 0, len(s.Models))
+	for _, name := range s.Models {
+		models = append(models, map[string]any{
+			"name":  name,
+			"model": name,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"models": models})
+}
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req EmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"embedding": Embed(req.Prompt, s.EmbeddingDim),
+	})
+}
+
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req EmbedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	var inputs []string
+	if err := json.Unmarshal(req.Input, &inputs); err != nil {
+		var one string
+		if err := json.Unmarshal(req.Input, &one); err != nil {
+			writeError(w, http.StatusBadRequest, "input must be a string or an array of strings")
+			return
+		}
+		inputs = []string{one}
+	}
+	embeddings := make([][]float64, 0, len(inputs))
+	for _, text := range inputs {
+		embeddings = append(embeddings, Embed(text, s.EmbeddingDim))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"model":      req.Model,
+		"embeddings": embeddings,
+	})
+}
+
+// Embed строит детерминированный нормированный вектор по словам текста,
+// так что тексты с общими словами оказываются близки по косинусу.
+func Embed(text string, dim int) []float64 {
+	if dim <= 0 {
+		dim = DefaultEmbeddingDim
+	}
+	vec := make([]float64, dim)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		sum := h.Sum32()
+		sign := 1.0
+		if sum&1 == 1 {
+			sign = -1
+		}
+		vec[int(sum>>1)%dim] += sign
+	}
+	var norm float64
+	for _, v := range vec {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vec {
+			vec[i] /= norm
+		}
+	}
+	return vec
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
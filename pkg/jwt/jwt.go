@@ -1,26 +1,116 @@
 package jwt
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DefaultIssuer = "me-ai"
+	DefaultTTL    = 15 * time.Minute
+	DefaultKeyID  = "default"
+)
+
+// Назначение токена (claim typ). Защищённые маршруты принимают только access;
+// challenge выдаётся после пароля и годится лишь для ввода второго фактора.
+const (
+	PurposeAccess    = "access"
+	PurposeChallenge = "2fa_challenge"
+)
 
 type JWTData struct {
 	Login string
+	Role  string
+	// Purpose — пустое значение при создании означает PurposeAccess.
+	Purpose string
+	// Session — идентификатор сессии (claim sid), по нему пользователь видит текущую сессию в списке.
+	Session string
 }
 
+// JWT подписывает токены одним активным ключом и проверяет их любым ключом
+// из набора по заголовку kid. Это позволяет ротировать ключ без простоя:
+// новый ключ подписывает, старый остаётся в наборе, пока не истекут его токены.
 type JWT struct {
-	Secret string
+	Issuer string
+	TTL    time.Duration
+	// Algorithms — разрешённые алгоритмы; токен с любым другим alg отклоняется.
+	Algorithms []string
+
+	signing *Key
+	keys    map[string]*Key
 }
 
-func NewJWT(secret string) *JWT {
-	return &JWT{
-		Secret: secret,
+// NewJWT создаёт HS256-подписчик с общим секретом.
+func NewJWT(secret, issuer string, ttl time.Duration) *JWT {
+	j, _ := NewKeySet(issuer, ttl, HMACKey(DefaultKeyID, secret))
+	return j
+}
+
+// NewKeySet создаёт подписчик с ключом signing и дополнительными ключами проверки.
+// Список разрешённых алгоритмов по умолчанию — алгоритмы переданных ключей.
+func NewKeySet(issuer string, ttl time.Duration, signing *Key, verify ...*Key) (*JWT, error) {
+	if issuer == "" {
+		issuer = DefaultIssuer
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if signing == nil || !signing.CanSign() {
+		return nil, errors.New("jwt: signing key must contain a private part")
 	}
+	j := &JWT{
+		Issuer:  issuer,
+		TTL:     ttl,
+		signing: signing,
+		keys:    map[string]*Key{},
+	}
+	for _, key := range append([]*Key{signing}, verify...) {
+		if _, exists := j.keys[key.ID]; exists {
+			return nil, fmt.Errorf("jwt: duplicate key id %q", key.ID)
+		}
+		j.keys[key.ID] = key
+		if !slices.Contains(j.Algorithms, key.Algorithm) {
+			j.Algorithms = append(j.Algorithms, key.Algorithm)
+		}
+	}
+	return j, nil
 }
 
 func (j *JWT) Create(data JWTData) (string, error) {
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return j.CreateWithTTL(data, j.TTL)
+}
+
+func (j *JWT) CreateWithTTL(data JWTData, ttl time.Duration) (string, error) {
+	if data.Purpose == "" {
+		data.Purpose = PurposeAccess
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
 		"email": data.Login,
-	})
-	s, err := t.SignedString([]byte(j.Secret))
+		"role":  data.Role,
+		"typ":   data.Purpose,
+		"iss":   j.Issuer,
+		"iat":   now.Unix(),
+		"nbf":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+		"jti":   hex.EncodeToString(jti),
+	}
+	if data.Session != "" {
+		claims["sid"] = data.Session
+	}
+	t := jwt.NewWithClaims(signingMethod(j.signing.Algorithm), claims)
+	t.Header["kid"] = j.signing.ID
+	s, err := t.SignedString(j.signing.signKey)
 	if err != nil {
 		return "", err
 	}
@@ -28,14 +118,71 @@ func (j *JWT) Create(data JWTData) (string, error) {
 }
 
 func (j *JWT) Parse(token string) (bool, *JWTData) {
-	t, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		return []byte(j.Secret), nil
-	})
+	t, err := jwt.Parse(token, j.keyFunc,
+		jwt.WithValidMethods(j.Algorithms),
+		jwt.WithIssuer(j.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil {
 		return false, nil
 	}
-	login := t.Claims.(jwt.MapClaims)["email"]
+	claims := t.Claims.(jwt.MapClaims)
+	login, ok := claims["email"].(string)
+	if !ok {
+		return false, nil
+	}
+	role, _ := claims["role"].(string)
+	purpose, _ := claims["typ"].(string)
+	session, _ := claims["sid"].(string)
 	return t.Valid, &JWTData{
-		Login: login.(string),
+		Login:   login,
+		Role:    role,
+		Purpose: purpose,
+		Session: session,
+	}
+}
+
+// keyFunc выбирает ключ по kid и требует, чтобы alg токена совпадал с алгоритмом
+// ключа: иначе публичный RSA-ключ можно было бы использовать как HMAC-секрет.
+func (j *JWT) keyFunc(t *jwt.Token) (interface{}, error) {
+	// Токены без kid выпущены до появления ротации одним HS256-секретом.
+	key := j.keys[DefaultKeyID]
+	if kid, ok := t.Header["kid"].(string); ok {
+		key = j.keys[kid]
+	}
+	if key == nil {
+		return nil, fmt.Errorf("jwt: unknown key id %v", t.Header["kid"])
+	}
+	if t.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("jwt: algorithm %s does not match key %s", t.Method.Alg(), key.ID)
+	}
+	return key.verifyKey, nil
+}
+
+// JWKS возвращает публичные ключи набора для /.well-known/jwks.json.
+func (j *JWT) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	ids := make([]string, 0, len(j.keys))
+	for id := range j.keys {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if jwk, ok := j.keys[id].JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func signingMethod(alg string) jwt.SigningMethod {
+	switch alg {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
 	}
 }
@@ -0,0 +1,145 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func ed25519Files(t *testing.T) (private, public string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	return writePEM(t, "ed.key", "PRIVATE KEY", privDER), writePEM(t, "ed.pub", "PUBLIC KEY", pubDER)
+}
+
+func rsaFiles(t *testing.T) (private, public string) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	return writePEM(t, "rsa.key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv)), writePEM(t, "rsa.pub", "PUBLIC KEY", pubDER)
+}
+
+func TestAsymmetricRoundTrip(t *testing.T) {
+	edPriv, _ := ed25519Files(t)
+	rsaPriv, _ := rsaFiles(t)
+	for _, tc := range []struct {
+		path string
+		alg  string
+	}{{edPriv, AlgEdDSA}, {rsaPriv, AlgRS256}} {
+		key, err := LoadPrivateKey("k1", tc.path)
+		if err != nil {
+			t.Fatalf("load %s: %v", tc.alg, err)
+		}
+		if key.Algorithm != tc.alg {
+			t.Fatalf("algorithm = %s, want %s", key.Algorithm, tc.alg)
+		}
+		j, err := NewKeySet("", time.Minute, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := j.Create(JWTData{Login: "alice@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, data := j.Parse(token)
+		if !ok || data.Login != "alice@example.com" {
+			t.Fatalf("%s token rejected", tc.alg)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldPriv, oldPub := ed25519Files(t)
+	newPriv, _ := ed25519Files(t)
+
+	oldKey, _ := LoadPrivateKey("2024-01", oldPriv)
+	oldSigner, _ := NewKeySet("", time.Minute, oldKey)
+	oldToken, _ := oldSigner.Create(JWTData{Login: "alice@example.com"})
+
+	newKey, _ := LoadPrivateKey("2024-02", newPriv)
+	withoutOld, _ := NewKeySet("", time.Minute, newKey)
+	if ok, _ := withoutOld.Parse(oldToken); ok {
+		t.Fatal("token with unknown kid accepted")
+	}
+
+	verifyOld, err := LoadPublicKey("2024-01", oldPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := NewKeySet("", time.Minute, newKey, verifyOld)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := rotated.Parse(oldToken); !ok {
+		t.Fatal("token signed by previous key rejected during rotation")
+	}
+	newToken, _ := rotated.Create(JWTData{Login: "alice@example.com"})
+	if ok, _ := rotated.Parse(newToken); !ok {
+		t.Fatal("token signed by current key rejected")
+	}
+
+	jwks := rotated.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "2024-01" || jwks.Keys[1].Kid != "2024-02" {
+		t.Fatalf("unexpected JWKS: %+v", jwks)
+	}
+	if jwks.Keys[0].Kty != "OKP" || jwks.Keys[0].Crv != "Ed25519" || jwks.Keys[0].X == "" {
+		t.Fatalf("unexpected Ed25519 JWK: %+v", jwks.Keys[0])
+	}
+}
+
+func TestAlgorithmAllowlist(t *testing.T) {
+	rsaPriv, rsaPub := rsaFiles(t)
+	key, _ := LoadPrivateKey("rsa", rsaPriv)
+	j, _ := NewKeySet("", time.Minute, key)
+	claims := jwt.MapClaims{
+		"email": "mallory@example.com",
+		"iss":   DefaultIssuer,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}
+
+	none := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	none.Header["kid"] = "rsa"
+	unsigned, _ := none.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if ok, _ := j.Parse(unsigned); ok {
+		t.Fatal("alg=none token accepted")
+	}
+
+	// Классическая подмена: HS256, подписанный публичным RSA-ключом как секретом.
+	pubPEM, _ := os.ReadFile(rsaPub)
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	confused.Header["kid"] = "rsa"
+	forged, _ := confused.SignedString(pubPEM)
+	if ok, _ := j.Parse(forged); ok {
+		t.Fatal("HS256 token signed with RSA public key accepted")
+	}
+
+	if jwks := NewJWT("secret", "", time.Minute).JWKS(); len(jwks.Keys) != 0 {
+		t.Fatalf("HMAC secret published in JWKS: %+v", jwks)
+	}
+}
@@ -0,0 +1,28 @@
+// Package mailer отправляет служебные письма: подтверждение email, сброс пароля.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var ErrInvalidHeader = errors.New("mailer: header contains line break")
+
+// validate не даёт подставить дополнительные заголовки через адрес или тему.
+func (m Message) validate() error {
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+// Package migrate применяет версионированные SQL-миграции PostgreSQL и SQLite.
+//
+// Применённые версии хранятся в schema_migrations вместе с SHA-256 файла, поэтому
+// миграция, изменённая после применения, видна в статусе и останавливает up.
+// Каждая миграция выполняется в своей транзакции вместе с записью о ней, а весь
+// запуск в PostgreSQL — под advisory lock: параллельные деплои ждут друг друга,
+// а не применяют одно и то же дважды.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"me-ai/pkg/db"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrModified — применённая миграция изменилась: её надо откатить (down, redo)
+// или вернуть файл как был.
+var ErrModified = errors.New("migration changed after it was applied")
+
+// ErrNoDown — у миграции нет файла отката.
+var ErrNoDown = errors.New("migration has no down step")
+
+// ErrMissing — миграция применена, но её файла нет.
+var ErrMissing = errors.New("applied migration not found")
+
+// lockKey — произвольный ключ pg_advisory_lock, общий для всех запусков.
+const lockKey int64 = 4_270_190_318
+
+// Файлы: 001_init.sql — миграция, 001_init.down.sql — её откат.
+var fileName = regexp.MustCompile(`^(\d+)_([\w-]+?)(\.down)?\.sql$`)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%03d_%s", m.Version, m.Name)
+}
+
+type State string
+
+const (
+	Pending  State = "pending"
+	Applied  State = "applied"
+	Modified State = "modified" // применена, но файл с тех пор изменился
+	Missing  State = "missing"  // применена, но файла больше нет
+)
+
+type Status struct {
+	Version   int64
+	Name      string
+	State     State
+	AppliedAt *time.Time
+}
+
+// Load читает миграции из корня fsys и сортирует их по версии.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	downs := map[int64]string{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		parts := fileName.FindStringSubmatch(e.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("%s: expected NNN_name.sql or NNN_name.down.sql", e.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		}
+		if m.Name != parts[2] {
+			return nil, fmt.Errorf("%s: version %d is already used by %s", e.Name(), version, m)
+		}
+		if parts[3] != "" {
+			if _, ok := downs[version]; ok {
+				return nil, fmt.Errorf("%s: duplicate down step", e.Name())
+			}
+			downs[version] = e.Name()
+			m.Down = string(content)
+			continue
+		}
+		if m.Checksum != "" {
+			return nil, fmt.Errorf("%s: duplicate migration", e.Name())
+		}
+		sum := sha256.Sum256(content)
+		m.Up = string(content)
+		m.Checksum = hex.EncodeToString(sum[:])
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("%s: down step without migration", downs[m.Version])
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type Runner struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+func New(conn *sqlx.DB, fsys fs.FS) (*Runner, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{db: conn, migrations: migrations}, nil
+}
+
+type record struct {
+	Version   int64     `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+// Status возвращает все миграции — из файлов и из базы — по возрастанию версии.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := r.session(ctx, func(conn *sqlx.Conn, applied map[int64]record) error {
+		for _, m := range r.migrations {
+			s := Status{Version: m.Version, Name: m.Name, State: Pending}
+			if rec, ok := applied[m.Version]; ok {
+				s.State, s.AppliedAt = Applied, &rec.AppliedAt
+				if rec.Checksum != m.Checksum {
+					s.State = Modified
+				}
+				delete(applied, m.Version)
+			}
+			statuses = append(statuses, s)
+		}
+		for _, rec := range applied {
+			at := rec.AppliedAt
+			statuses = append(statuses, Status{Version: rec.Version, Name: rec.Name, State: Missing, AppliedAt: &at})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// Up применяет n ещё не применённых миграций по возрастанию версии, при n <= 0 — все.
+// Возвращает применённые, в том числе когда следующая завершилась ошибкой.
+func (r *Runner) Up(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := r.session(ctx, func(conn *sqlx.Conn, applied map[int64]record) error {
+		for _, m := range r.migrations {
+			if rec, ok := applied[m.Version]; ok && rec.Checksum != m.Checksum {
+				return fmt.Errorf("%s: %w", m, ErrModified)
+			}
+		}
+		for _, m := range r.migrations {
+			if n > 0 && len(done) == n {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := r.apply(ctx, conn, m); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down откатывает n последних применённых миграций, начиная с самой новой,
+// при n <= 0 — все. Изменённые миграции откатываются текущим файлом отката.
+func (r *Runner) Down(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := r.session(ctx, func(conn *sqlx.Conn, applied map[int64]record) error {
+		targets, err := r.latest(applied, n)
+		if err != nil {
+			return err
+		}
+		for _, m := range targets {
+			if err := r.rollback(ctx, conn, m); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Redo откатывает и заново применяет последнюю миграцию — обычно после её правки.
+func (r *Runner) Redo(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := r.session(ctx, func(conn *sqlx.Conn, applied map[int64]record) error {
+		targets, err := r.latest(applied, 1)
+		if err != nil || len(targets) == 0 {
+			return err
+		}
+		m := targets[0]
+		if err := r.rollback(ctx, conn, m); err != nil {
+			return err
+		}
+		if err := r.apply(ctx, conn, m); err != nil {
+			return err
+		}
+		done = append(done, m)
+		return nil
+	})
+	return done, err
+}
+
+// latest подбирает n последних применённых миграций для отката и заранее
+// проверяет, что все они откатываемы, чтобы не остановиться на полпути.
+func (r *Runner) latest(applied map[int64]record, n int) ([]Migration, error) {
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	if n > 0 && n < len(versions) {
+		versions = versions[:n]
+	}
+
+	files := make(map[int64]Migration, len(r.migrations))
+	for _, m := range r.migrations {
+		files[m.Version] = m
+	}
+	targets := make([]Migration, 0, len(versions))
+	for _, v := range versions {
+		m, ok := files[v]
+		if !ok {
+			return nil, fmt.Errorf("%03d_%s: %w", v, applied[v].Name, ErrMissing)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("%s: %w", m, ErrNoDown)
+		}
+		targets = append(targets, m)
+	}
+	return targets, nil
+}
+
+// session выполняет fn на одном соединении: advisory lock принадлежит сессии
+// PostgreSQL и отпускается на том же соединении.
+func (r *Runner) session(ctx context.Context, fn func(conn *sqlx.Conn, applied map[int64]record) error) error {
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// В SQLite advisory lock нет; параллельный запуск там упрётся в первичный
+	// ключ schema_migrations, и его транзакция откатится.
+	if r.db.DriverName() == db.Postgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+			return fmt.Errorf("lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+	}
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return err
+	}
+	var records []record
+	if err := conn.SelectContext(ctx, &records, `SELECT version, name, checksum, applied_at FROM schema_migrations`); err != nil {
+		return err
+	}
+	applied := make(map[int64]record, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+	return fn(conn, applied)
+}
+
+func (r *Runner) apply(ctx context.Context, conn *sqlx.Conn, m Migration) error {
+	return inTx(ctx, conn, m, m.Up,
+		`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`, m.Version, m.Name, m.Checksum)
+}
+
+func (r *Runner) rollback(ctx context.Context, conn *sqlx.Conn, m Migration) error {
+	return inTx(ctx, conn, m, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+}
+
+// inTx выполняет SQL миграции и изменение schema_migrations в одной транзакции.
+func inTx(ctx context.Context, conn *sqlx.Conn, m Migration, script, query string, args ...any) error {
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("%s: %w", m, err)
+	}
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("%s: %w", m, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"002_posts.sql":      {Data: []byte("CREATE TABLE posts (id INT);")},
+		"001_init.sql":       {Data: []byte("CREATE TABLE users (id INT);")},
+		"001_init.down.sql":  {Data: []byte("DROP TABLE users;")},
+		"010_add-index.sql":  {Data: []byte("CREATE INDEX i ON posts(id);")},
+		"README.md":          {Data: []byte("не миграция")},
+		"embed.go":           {Data: []byte("package migrations")},
+		"sqlite/001_x.sql":   {Data: []byte("вложенные каталоги не читаются")},
+		"002_posts.down.sql": {Data: []byte("DROP TABLE posts;")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range migrations {
+		names = append(names, m.String())
+	}
+	if got := strings.Join(names, " "); got != "001_init 002_posts 010_add-index" {
+		t.Fatalf("migrations %s", got)
+	}
+	if migrations[0].Down != "DROP TABLE users;" || migrations[2].Down != "" {
+		t.Fatalf("down steps %q, %q", migrations[0].Down, migrations[2].Down)
+	}
+	if len(migrations[0].Checksum) != 64 || migrations[0].Checksum == migrations[1].Checksum {
+		t.Fatalf("checksums %q, %q", migrations[0].Checksum, migrations[1].Checksum)
+	}
+}
+
+func TestLoadChecksumIgnoresDown(t *testing.T) {
+	load := func(down string) string {
+		migrations, err := Load(fstest.MapFS{
+			"001_init.sql":      {Data: []byte("CREATE TABLE users (id INT);")},
+			"001_init.down.sql": {Data: []byte(down)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return migrations[0].Checksum
+	}
+	if load("DROP TABLE users;") != load("DROP TABLE IF EXISTS users;") {
+		t.Fatal("checksum depends on the down step")
+	}
+}
+
+func TestLoadRejectsInvalidSets(t *testing.T) {
+	for name, files := range map[string]fstest.MapFS{
+		"bad name":       {"init.sql": {}},
+		"version reused": {"001_init.sql": {}, "001_users.sql": {}},
+		"down name":      {"001_init.sql": {}, "001_users.down.sql": {}},
+		"down only":      {"001_init.down.sql": {}},
+		"same version":   {"1_init.sql": {}, "001_init.sql": {}},
+		"duplicate down": {"1_init.sql": {}, "1_init.down.sql": {}, "01_init.down.sql": {}},
+	} {
+		if _, err := Load(files); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
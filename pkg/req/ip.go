@@ -0,0 +1,25 @@
+package req
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP возвращает адрес клиента. X-Forwarded-For учитывается только при
+// trustProxy: берётся последний адрес, его дописал наш прокси, остальное мог подставить клиент.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,128 @@
+// Package throttle считает неудачные попытки по произвольным ключам в памяти
+// процесса: экспоненциальная задержка после нескольких неудач и блокировка по
+// достижении порога.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// FreeAttempts — сколько неудачных попыток подряд прощается без задержки.
+const FreeAttempts = 3
+
+// maxEntries — после этого размера устаревшие счётчики вычищаются.
+const maxEntries = 10000
+
+type Config struct {
+	// Limit — неудачных попыток до блокировки; 0 — без блокировки.
+	Limit int
+	// BaseDelay — задержка после FreeAttempts неудач, дальше удваивается; 0 — без задержек.
+	BaseDelay time.Duration
+	// Lockout — длительность блокировки и окно, после которого счётчик неудач сбрасывается.
+	Lockout time.Duration
+}
+
+// Lock — блокировка, наступившая в результате очередной неудачи.
+type Lock struct {
+	Failures int
+	Until    time.Time
+}
+
+// Throttle безопасен для параллельного использования; методы nil-Throttle
+// ничего не ограничивают.
+type Throttle struct {
+	cfg Config
+	// Now — источник времени, подменяется в тестах.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*attempts
+}
+
+type attempts struct {
+	failures     int
+	last         time.Time
+	blockedUntil time.Time
+}
+
+func New(cfg Config) *Throttle {
+	return &Throttle{
+		cfg:     cfg,
+		Now:     time.Now,
+		entries: map[string]*attempts{},
+	}
+}
+
+// Wait возвращает, сколько ещё нужно ждать до следующей попытки по key; 0 — можно пробовать.
+func (t *Throttle) Wait(key string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Now()
+	if a, ok := t.entries[key]; ok && a.blockedUntil.After(now) {
+		return a.blockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// Fail учитывает неудачную попытку по key и сообщает, вызвала ли она блокировку.
+func (t *Throttle) Fail(key string) (Lock, bool) {
+	if t == nil {
+		return Lock{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Now()
+	t.prune(now)
+
+	a, ok := t.entries[key]
+	if !ok || t.expired(a, now) {
+		a = &attempts{}
+		t.entries[key] = a
+	}
+	a.failures++
+	a.last = now
+
+	if t.cfg.Limit > 0 && a.failures >= t.cfg.Limit {
+		lock := Lock{Failures: a.failures, Until: now.Add(t.cfg.Lockout)}
+		a.failures = 0
+		a.blockedUntil = lock.Until
+		return lock, true
+	}
+	if t.cfg.BaseDelay > 0 && a.failures > FreeAttempts {
+		delay := t.cfg.BaseDelay << min(a.failures-FreeAttempts-1, 30)
+		if t.cfg.Lockout > 0 && delay > t.cfg.Lockout {
+			delay = t.cfg.Lockout
+		}
+		a.blockedUntil = now.Add(delay)
+	}
+	return Lock{}, false
+}
+
+// Reset забывает неудачи по key, в том числе снимает задержку и блокировку.
+func (t *Throttle) Reset(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+}
+
+func (t *Throttle) expired(a *attempts, now time.Time) bool {
+	return !a.blockedUntil.After(now) && now.Sub(a.last) > t.cfg.Lockout
+}
+
+func (t *Throttle) prune(now time.Time) {
+	if len(t.entries) < maxEntries {
+		return
+	}
+	for key, a := range t.entries {
+		if t.expired(a, now) {
+			delete(t.entries, key)
+		}
+	}
+}
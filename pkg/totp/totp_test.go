@@ -0,0 +1,62 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Секрет "12345678901234567890" из приложения B RFC 6238; ожидаемые значения —
+// последние 6 цифр восьмизначных кодов SHA1 оттуда же.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestRFC6238Vectors(t *testing.T) {
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		code, err := Code(rfcSecret, time.Unix(tc.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tc.code {
+			t.Errorf("T=%d: code %s, want %s", tc.unix, code, tc.code)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	prev, _ := Code(rfcSecret, now.Add(-Period))
+	if step, ok := Validate(rfcSecret, prev, now, 1); !ok || step != Step(now)-1 {
+		t.Fatalf("previous interval rejected: step=%d ok=%t", step, ok)
+	}
+	old, _ := Code(rfcSecret, now.Add(-2*Period))
+	if _, ok := Validate(rfcSecret, old, now, 1); ok {
+		t.Fatal("code outside skew accepted")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now, 1); ok {
+		t.Fatal("short code accepted")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Fatalf("generated secret is not valid base32: %v", err)
+	}
+	uri := URI("me-ai", "alice@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/me-ai:alice@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected URI: %s", uri)
+	}
+}